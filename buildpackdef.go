@@ -0,0 +1,220 @@
+// Copyright 2020 YourBase Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package yb
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"text/template"
+
+	"gopkg.in/yaml.v2"
+)
+
+// BuildpackDefinition declares a buildpack in configuration rather than in
+// yb's source. Such a buildpack downloads a file and unpacks it into a
+// directory of its own. Templates are expanded with BuildpackTemplateData.
+type BuildpackDefinition struct {
+	// URL is a template for the download URL.
+	URL string `yaml:"url"`
+	// Archive is the format of the downloaded file. If empty, it is
+	// determined from the extension of URL.
+	Archive string `yaml:"archive"`
+	// StripTopDir indicates that the archive contains a single top-level
+	// directory whose contents should be installed.
+	StripTopDir bool `yaml:"strip_top_dir"`
+	// Checksum is an optional template for either the hex-encoded SHA-256 or
+	// SHA-512 digest of the downloaded file or the URL of a checksum file in
+	// the format written by sha256sum.
+	Checksum string `yaml:"checksum"`
+	// Env is the set of environment variables the buildpack sets. Values are
+	// templates that may refer to the installation directory.
+	Env map[string]string `yaml:"env"`
+	// Path is the list of directories to prepend to PATH, relative to the
+	// installation directory. If empty, a binary buildpack adds the
+	// installation directory.
+	Path []string `yaml:"path"`
+	// OSNames maps yb's operating system names ("linux", "darwin",
+	// "windows") to the names used in templates.
+	OSNames map[string]string `yaml:"os_names"`
+	// ArchNames maps yb's architecture names ("amd64", "arm64", "386") to
+	// the names used in templates.
+	ArchNames map[string]string `yaml:"arch_names"`
+	// Requires lists buildpacks that must be installed before this one.
+	// An entry may be a bare name (like "node"), in which case the target must
+	// list that buildpack, or a buildpack specifier (like "node:14.17.0"),
+	// which is installed if the target does not list the buildpack.
+	Requires []string `yaml:"requires"`
+}
+
+// BuildpackTemplateData is the data given to BuildpackDefinition templates.
+type BuildpackTemplateData struct {
+	Version string
+	OS      string
+	Arch    string
+	// InstallDir is the absolute path of the installation directory.
+	// It is only set for Env templates.
+	InstallDir string
+}
+
+// Archive formats for BuildpackDefinition.
+const (
+	ArchiveZip    = "zip"
+	ArchiveTarGZ  = "tar.gz"
+	ArchiveTarXZ  = "tar.xz"
+	ArchiveTarBZ2 = "tar.bz2"
+	ArchiveTarZst = "tar.zst"
+	// ArchiveBinary is a single executable file.
+	ArchiveBinary = "binary"
+)
+
+var archiveFormats = []string{ArchiveZip, ArchiveTarGZ, ArchiveTarXZ, ArchiveTarBZ2, ArchiveTarZst, ArchiveBinary}
+
+// ArchiveFormat returns the format of the downloaded file.
+func (def *BuildpackDefinition) ArchiveFormat() string {
+	if def.Archive != "" {
+		return def.Archive
+	}
+	for _, format := range archiveFormats {
+		if strings.HasSuffix(def.URL, "."+format) {
+			return format
+		}
+	}
+	if strings.HasSuffix(def.URL, ".tgz") {
+		return ArchiveTarGZ
+	}
+	return ""
+}
+
+// Validate returns an error if the definition is incomplete or malformed.
+func (def *BuildpackDefinition) Validate() error {
+	if def.URL == "" {
+		return errors.New("url is required")
+	}
+	if _, err := template.New("url").Parse(def.URL); err != nil {
+		return fmt.Errorf("url: %w", err)
+	}
+	if _, err := template.New("checksum").Parse(def.Checksum); err != nil {
+		return fmt.Errorf("checksum: %w", err)
+	}
+	switch format := def.ArchiveFormat(); {
+	case format == "":
+		return fmt.Errorf("cannot determine archive format from url; set archive to one of %s", strings.Join(archiveFormats, ", "))
+	case !stringInSlice(archiveFormats, format):
+		return fmt.Errorf("unknown archive %q (must be one of %s)", format, strings.Join(archiveFormats, ", "))
+	case format == ArchiveBinary && def.StripTopDir:
+		return errors.New("strip_top_dir cannot be used with a binary")
+	}
+	for k, v := range def.Env {
+		if k == "" || strings.Contains(k, "=") {
+			return fmt.Errorf("env: invalid variable name %q", k)
+		}
+		if _, err := template.New(k).Parse(v); err != nil {
+			return fmt.Errorf("env: %s: %w", k, err)
+		}
+	}
+	for _, req := range def.Requires {
+		name, version, hasVersion := splitRequirement(req)
+		if err := validateBuildpackName(name); err != nil {
+			return fmt.Errorf("requires: %w", err)
+		}
+		if hasVersion && version == "" {
+			return fmt.Errorf("requires: %q is missing a version", req)
+		}
+	}
+	for _, p := range def.Path {
+		if path.IsAbs(p) || p == ".." || strings.HasPrefix(p, "../") {
+			return fmt.Errorf("path: %q must be relative to the installation directory", p)
+		}
+	}
+	return nil
+}
+
+// BuildpackRequirement is a parsed entry of BuildpackDefinition.Requires.
+type BuildpackRequirement struct {
+	Name string
+	// Default is installed if the target does not list the buildpack.
+	// If empty, the target must list the buildpack.
+	Default BuildpackSpec
+}
+
+// Requirements parses the definition's Requires list.
+func (def *BuildpackDefinition) Requirements() []BuildpackRequirement {
+	reqs := make([]BuildpackRequirement, 0, len(def.Requires))
+	for _, req := range def.Requires {
+		name, _, hasVersion := splitRequirement(req)
+		r := BuildpackRequirement{Name: name}
+		if hasVersion {
+			r.Default = BuildpackSpec(req)
+		}
+		reqs = append(reqs, r)
+	}
+	return reqs
+}
+
+func splitRequirement(req string) (name, version string, hasVersion bool) {
+	i := strings.IndexByte(req, ':')
+	if i == -1 {
+		return req, "", false
+	}
+	return req[:i], req[i+1:], true
+}
+
+// ParseBuildpackDefinitions parses a YAML mapping of buildpack names to
+// definitions.
+func ParseBuildpackDefinitions(data []byte) (map[string]*BuildpackDefinition, error) {
+	var defs map[string]*BuildpackDefinition
+	if err := yaml.UnmarshalStrict(data, &defs); err != nil {
+		return nil, fmt.Errorf("parse buildpack definitions: %w", err)
+	}
+	if err := validateBuildpackDefinitions(defs); err != nil {
+		return nil, fmt.Errorf("parse buildpack definitions: %w", err)
+	}
+	return defs, nil
+}
+
+func validateBuildpackDefinitions(defs map[string]*BuildpackDefinition) error {
+	for name, def := range defs {
+		if err := validateBuildpackName(name); err != nil {
+			return err
+		}
+		if def == nil {
+			return fmt.Errorf("buildpack %s: url is required", name)
+		}
+		if err := def.Validate(); err != nil {
+			return fmt.Errorf("buildpack %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func validateBuildpackName(name string) error {
+	if name == "" || strings.ContainsAny(name, ": \t") {
+		return fmt.Errorf("invalid buildpack name %q", name)
+	}
+	return nil
+}
+
+func stringInSlice(slice []string, s string) bool {
+	for _, elem := range slice {
+		if elem == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,120 @@
+// Copyright 2020 YourBase Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package yb
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestBuildpackDefinitionArchiveFormat(t *testing.T) {
+	tests := []struct {
+		def  BuildpackDefinition
+		want string
+	}{
+		{BuildpackDefinition{URL: "https://example.com/tool-{{.Version}}.zip"}, ArchiveZip},
+		{BuildpackDefinition{URL: "https://example.com/tool-{{.Version}}.tar.gz"}, ArchiveTarGZ},
+		{BuildpackDefinition{URL: "https://example.com/tool-{{.Version}}.tgz"}, ArchiveTarGZ},
+		{BuildpackDefinition{URL: "https://example.com/tool-{{.Version}}.tar.xz"}, ArchiveTarXZ},
+		{BuildpackDefinition{URL: "https://example.com/tool-{{.Version}}.tar.bz2"}, ArchiveTarBZ2},
+		{BuildpackDefinition{URL: "https://example.com/tool-{{.Version}}.tar.zst"}, ArchiveTarZst},
+		{BuildpackDefinition{URL: "https://example.com/tool-{{.Version}}"}, ""},
+		{BuildpackDefinition{URL: "https://example.com/tool-{{.Version}}", Archive: ArchiveBinary}, ArchiveBinary},
+		{BuildpackDefinition{URL: "https://example.com/download?v={{.Version}}", Archive: ArchiveZip}, ArchiveZip},
+	}
+	for _, test := range tests {
+		if got := test.def.ArchiveFormat(); got != test.want {
+			t.Errorf("%+v.ArchiveFormat() = %q; want %q", test.def, got, test.want)
+		}
+	}
+}
+
+func TestParseBuildpackDefinitions(t *testing.T) {
+	tests := []struct {
+		name      string
+		yaml      string
+		wantError bool
+	}{
+		{
+			name: "Valid",
+			yaml: "kubectl:\n" +
+				"  url: https://dl.k8s.io/release/v{{.Version}}/bin/{{.OS}}/{{.Arch}}/kubectl\n" +
+				"  archive: binary\n",
+		},
+		{
+			name:      "UnknownField",
+			yaml:      "kubectl:\n  uri: https://example.com/kubectl.zip\n",
+			wantError: true,
+		},
+		{
+			name:      "UnknownArchive",
+			yaml:      "kubectl:\n  url: https://example.com/kubectl\n  archive: rar\n",
+			wantError: true,
+		},
+		{
+			name:      "AbsolutePath",
+			yaml:      "kubectl:\n  url: https://example.com/kubectl.zip\n  path: [/usr/bin]\n",
+			wantError: true,
+		},
+		{
+			name:      "EscapingPath",
+			yaml:      "kubectl:\n  url: https://example.com/kubectl.zip\n  path: [../bin]\n",
+			wantError: true,
+		},
+		{
+			name:      "BadName",
+			yaml:      "\"kube:ctl\":\n  url: https://example.com/kubectl.zip\n",
+			wantError: true,
+		},
+		{
+			name: "Requires",
+			yaml: "mytool:\n  url: https://example.com/mytool.zip\n  requires: [java, \"node:12.19.0\"]\n",
+		},
+		{
+			name:      "RequiresEmptyVersion",
+			yaml:      "mytool:\n  url: https://example.com/mytool.zip\n  requires: [\"node:\"]\n",
+			wantError: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := ParseBuildpackDefinitions([]byte(test.yaml))
+			if err != nil {
+				t.Log(err)
+				if !test.wantError {
+					t.Fail()
+				}
+				return
+			}
+			if test.wantError {
+				t.Error("ParseBuildpackDefinitions did not return an error")
+			}
+		})
+	}
+}
+
+func TestBuildpackDefinitionRequirements(t *testing.T) {
+	def := &BuildpackDefinition{Requires: []string{"java", "node:12.19.0"}}
+	want := []BuildpackRequirement{
+		{Name: "java"},
+		{Name: "node", Default: "node:12.19.0"},
+	}
+	if diff := cmp.Diff(want, def.Requirements()); diff != "" {
+		t.Errorf("Requirements() (-want +got):\n%s", diff)
+	}
+}
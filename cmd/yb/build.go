@@ -14,6 +14,7 @@ import (
 	"github.com/yourbase/yb"
 	"github.com/yourbase/yb/internal/biome"
 	"github.com/yourbase/yb/internal/build"
+	"github.com/yourbase/yb/internal/config"
 	"github.com/yourbase/yb/internal/ybdata"
 	"github.com/yourbase/yb/internal/ybtrace"
 	"go.opentelemetry.io/otel/api/global"
@@ -24,16 +25,18 @@ import (
 )
 
 type buildCmd struct {
+	cfg              config.Getter
 	targetNames      []string
 	env              []commandLineEnv
 	netrcFiles       []string
 	execPrefix       string
 	mode             executionMode
 	dependenciesOnly bool
+	updateLock       bool
 }
 
-func newBuildCmd() *cobra.Command {
-	b := new(buildCmd)
+func newBuildCmd(cfg config.Getter) *cobra.Command {
+	b := &buildCmd{cfg: cfg}
 	c := &cobra.Command{
 		Use:   "build [options] [TARGET [...]]",
 		Short: "Build target(s)",
@@ -42,7 +45,11 @@ func newBuildCmd() *cobra.Command {
 			"\n\n" +
 			`yb build will search for the .yourbase.yml file in the current directory ` +
 			`and its parent directories. The target's commands will be run in the ` +
-			`directory the .yourbase.yml file appears in.`,
+			`directory the .yourbase.yml file appears in.` +
+			"\n\n" +
+			`If the package has a ` + yb.LockFilename + ` file, yb build uses the locked ` +
+			`buildpack versions, downloads, and images, and fails if a target's ` +
+			`configuration has drifted from the lock.`,
 		Args:                  cobra.ArbitraryArgs,
 		DisableFlagsInUseLine: true,
 		SilenceErrors:         true,
@@ -63,6 +70,7 @@ func newBuildCmd() *cobra.Command {
 	netrcFlagVar(c.Flags(), &b.netrcFiles)
 	executionModeVar(c.Flags(), &b.mode)
 	c.Flags().BoolVar(&b.dependenciesOnly, "deps-only", false, "Install only dependencies, don't do anything else")
+	c.Flags().BoolVar(&b.updateLock, "update-lock", false, "Lock the targets again and rewrite "+yb.LockFilename+" before building")
 	c.Flags().StringVar(&b.execPrefix, "exec-prefix", "", "Add a prefix to all executed commands (useful for timing or wrapping things)")
 	return c
 }
@@ -81,7 +89,13 @@ func (b *buildCmd) run(ctx context.Context) error {
 	if err != nil {
 		return err
 	}
-	downloader := ybdata.NewDownloader(dataDirs.Downloads())
+	// Hold a shared lock on the cache so that other yb processes don't trim
+	// it while this one uses it.
+	cacheLock, err := dataDirs.LockCache()
+	if err != nil {
+		return err
+	}
+	defer cacheLock.Unlock()
 	baseEnv, err := envFromCommandLine(b.env)
 	if err != nil {
 		return err
@@ -116,6 +130,33 @@ func (b *buildCmd) run(ctx context.Context) error {
 	}
 	buildTargets := yb.BuildOrder(desired...)
 	showDockerWarningsIfNeeded(ctx, b.mode, buildTargets)
+	buildpacks, err := buildpackDefinitions(targetPackage)
+	if err != nil {
+		return err
+	}
+	mirrors, err := downloadMirrors(b.cfg, targetPackage)
+	if err != nil {
+		return err
+	}
+	remoteCache, err := config.RemoteCache(b.cfg)
+	if err != nil {
+		return err
+	}
+	downloader := newDownloader(dataDirs, mirrors, remoteCache)
+	lock, err := loadBuildLock(ctx, targetPackage, buildTargets, b.updateLock, &lockOptions{
+		dataDirs:      dataDirs,
+		buildpacks:    buildpacks,
+		mirrors:       mirrors,
+		netrcFiles:    b.netrcFiles,
+		executionMode: b.mode,
+		dockerClient:  dockerClient,
+	})
+	if err != nil {
+		return err
+	}
+	if lock != nil {
+		downloader.Checksums = lock.Checksums()
+	}
 
 	// Do the build!
 	log.Debugf(ctx, "Building package %s in %s...", targetPackage.Name, targetPackage.Path)
@@ -126,11 +167,15 @@ func (b *buildCmd) run(ctx context.Context) error {
 		dockerClient:  dockerClient,
 		dataDirs:      dataDirs,
 		downloader:    downloader,
+		buildpacks:    buildpacks,
+		lock:          lock,
 		execPrefix:    execPrefix,
 		setupOnly:     b.dependenciesOnly,
 		baseEnv:       baseEnv,
 		netrcFiles:    b.netrcFiles,
 	})
+	cacheLock.Unlock()
+	trimCacheAfterBuild(ctx, b.cfg, dataDirs, targetPackage.Path)
 	if buildError != nil {
 		span.SetStatus(codes.Unknown, buildError.Error())
 		log.Errorf(ctx, "%v", buildError)
@@ -156,6 +201,8 @@ type doOptions struct {
 	output          io.Writer
 	dataDirs        *ybdata.Dirs
 	downloader      *ybdata.Downloader
+	buildpacks      map[string]*yb.BuildpackDefinition
+	lock            *yb.Lock
 	executionMode   executionMode
 	dockerClient    *docker.Client
 	dockerNetworkID string
@@ -203,6 +250,19 @@ func doTarget(ctx context.Context, pkg *yb.Package, target *yb.Target, opts *doO
 
 	ctx = withLogPrefix(ctx, target.Name)
 
+	if opts.lock != nil {
+		var err error
+		target, err = pinTarget(opts.lock, target)
+		if err != nil {
+			return err
+		}
+		if opts.dockerClient != nil {
+			useContainer := willUseDockerForCommands(opts.executionMode, []*yb.Target{target})
+			if err := pullPinnedImages(ctx, opts.dockerClient, target, useContainer); err != nil {
+				return fmt.Errorf("target %s: %w", target.Name, err)
+			}
+		}
+	}
 	bio, err := newBiome(ctx, target, newBiomeOptions{
 		packageDir:      pkg.Path,
 		dataDirs:        opts.dataDirs,
@@ -227,9 +287,13 @@ func doTarget(ctx context.Context, pkg *yb.Package, target *yb.Target, opts *doO
 		Downloader:      opts.downloader,
 		DockerClient:    opts.dockerClient,
 		DockerNetworkID: opts.dockerNetworkID,
+		Definitions:     opts.buildpacks,
 
-		Stdout: output,
-		Stderr: output,
+		Stdout:      output,
+		Stderr:      output,
+		Parallelism: setupParallelism,
+		LogPrefix:   withBuildpackLogPrefix,
+		Inventory:   toolInventory(opts.dataDirs, pkg.Path, target, bio),
 	}
 	execBiome, err := build.Setup(withLogPrefix(ctx, setupLogPrefix), sys, target)
 	if err != nil {
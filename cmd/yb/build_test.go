@@ -23,6 +23,7 @@ import (
 	"path/filepath"
 	"testing"
 
+	"github.com/yourbase/commons/ini"
 	"zombiezen.com/go/log/testlog"
 )
 
@@ -39,7 +40,7 @@ func TestBuildCmd(t *testing.T) {
 		}
 
 		ctx := testlog.WithTB(context.Background(), t)
-		c := newBuildCmd()
+		c := newBuildCmd(new(ini.File))
 		c.SetArgs([]string{"--no-container"})
 		if err := c.ExecuteContext(ctx); err != nil {
 			t.Error("yb build:", err)
@@ -58,7 +59,7 @@ func TestBuildCmd(t *testing.T) {
 		}
 
 		ctx := testlog.WithTB(context.Background(), t)
-		c := newBuildCmd()
+		c := newBuildCmd(new(ini.File))
 		c.SetArgs([]string{"--no-container"})
 		err = c.ExecuteContext(ctx)
 		if err == nil {
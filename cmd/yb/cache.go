@@ -0,0 +1,310 @@
+// Copyright 2020 YourBase Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/yourbase/yb/internal/config"
+	"github.com/yourbase/yb/internal/ybdata"
+	"zombiezen.com/go/log"
+)
+
+func newCacheCmd(cfg config.Getter) *cobra.Command {
+	group := &cobra.Command{
+		Use:   "cache",
+		Short: "Manage downloads and build homes",
+		Long: "cache lists and deletes the files yb keeps between builds: downloaded\n" +
+			"files and the build homes available as $HOME in the build environment.\n" +
+			"If the cache-max-size setting is set, yb build deletes the least\n" +
+			"recently used entries after each build to stay under that size.",
+		SilenceErrors: true,
+		SilenceUsage:  true,
+	}
+	group.AddCommand(
+		newCacheListCmd(),
+		newCacheCleanCmd(cfg),
+		newCacheServeCmd(),
+	)
+	return group
+}
+
+func newCacheListCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:                   "ls",
+		Aliases:               []string{"list"},
+		Short:                 "List cache entries",
+		Args:                  cobra.NoArgs,
+		DisableFlagsInUseLine: true,
+		SilenceErrors:         true,
+		SilenceUsage:          true,
+		RunE: func(cc *cobra.Command, args []string) error {
+			dataDirs, err := ybdata.DirsFromEnv()
+			if err != nil {
+				return err
+			}
+			entries, err := dataDirs.CacheEntries()
+			if err != nil {
+				return err
+			}
+			return listCache(os.Stdout, entries)
+		},
+	}
+}
+
+type cacheCleanCmd struct {
+	cfg     config.Getter
+	maxSize string
+	all     bool
+	dryRun  bool
+}
+
+func newCacheCleanCmd(cfg config.Getter) *cobra.Command {
+	cmd := &cacheCleanCmd{cfg: cfg}
+	c := &cobra.Command{
+		Use:   "clean [options]",
+		Short: "Delete least recently used cache entries",
+		Long: "clean deletes the least recently used cache entries until the cache\n" +
+			"is no larger than --max-size or the cache-max-size setting. With\n" +
+			"--all, every entry is deleted.",
+		Args:                  cobra.NoArgs,
+		DisableFlagsInUseLine: true,
+		SilenceErrors:         true,
+		SilenceUsage:          true,
+		RunE: func(cc *cobra.Command, args []string) error {
+			return cmd.run(cc.Context())
+		},
+	}
+	c.Flags().StringVar(&cmd.maxSize, "max-size", "", "Trim the cache to this `size` (like 500M or 20G)")
+	c.Flags().BoolVar(&cmd.all, "all", false, "Delete every cache entry")
+	c.Flags().BoolVarP(&cmd.dryRun, "dry-run", "n", false, "Print the entries that would be deleted without deleting them")
+	return c
+}
+
+func (cmd *cacheCleanCmd) run(ctx context.Context) error {
+	var maxSize int64
+	switch {
+	case cmd.all && cmd.maxSize != "":
+		return errors.New("can't use --all with --max-size")
+	case cmd.all:
+		maxSize = 0
+	case cmd.maxSize != "":
+		var err error
+		maxSize, err = config.ParseSize(cmd.maxSize)
+		if err != nil {
+			return fmt.Errorf("--max-size: %w", err)
+		}
+	default:
+		var err error
+		maxSize, err = config.CacheMaxSize(cmd.cfg)
+		if err != nil {
+			return err
+		}
+		if maxSize == 0 {
+			return errors.New("no maximum size: pass --max-size or --all, or set cache-max-size with yb config set")
+		}
+	}
+	dataDirs, err := ybdata.DirsFromEnv()
+	if err != nil {
+		return err
+	}
+	if !cmd.dryRun {
+		cacheLock, err := dataDirs.TryLockCacheForTrim()
+		if errors.Is(err, ybdata.ErrCacheInUse) {
+			return fmt.Errorf("%w; try again when it finishes", err)
+		}
+		if err != nil {
+			return err
+		}
+		defer cacheLock.Unlock()
+	}
+	entries, err := dataDirs.CacheEntries()
+	if err != nil {
+		return err
+	}
+	if cmd.dryRun {
+		var total, freed int64
+		for _, ent := range entries {
+			total += ent.Size
+		}
+		for _, ent := range entries {
+			if total-freed <= maxSize {
+				break
+			}
+			fmt.Printf("Would remove %s %s (%s)\n", ent.Kind, ent.Name, formatSize(ent.Size))
+			freed += ent.Size
+		}
+		fmt.Printf("Would free %s\n", formatSize(freed))
+		return nil
+	}
+	removed, err := ybdata.TrimCache(entries, maxSize, nil)
+	var freed int64
+	for _, ent := range removed {
+		fmt.Printf("Removed %s %s (%s)\n", ent.Kind, ent.Name, formatSize(ent.Size))
+		freed += ent.Size
+	}
+	fmt.Printf("Freed %s\n", formatSize(freed))
+	return err
+}
+
+type cacheServeCmd struct {
+	addr string
+	dir  string
+}
+
+func newCacheServeCmd() *cobra.Command {
+	cmd := new(cacheServeCmd)
+	c := &cobra.Command{
+		Use:   "serve [options]",
+		Short: "Serve downloads to other machines",
+		Long: "serve runs an HTTP server that shares downloaded files with other\n" +
+			"machines. Point yb at it by setting remote-cache to the server's URL\n" +
+			"(like http://cache.example.com:8080) with yb config set. Files are\n" +
+			"stored under their SHA-256 digest. yb fetches a file from the server\n" +
+			"before its origin when it knows the file's digest, from\n" +
+			".yourbase.lock or the file's publisher, and uploads files it\n" +
+			"fetches from their origin. By default, the server shares this\n" +
+			"machine's download cache.",
+		Args:                  cobra.NoArgs,
+		DisableFlagsInUseLine: true,
+		SilenceErrors:         true,
+		SilenceUsage:          true,
+		RunE: func(cc *cobra.Command, args []string) error {
+			return cmd.run(cc.Context())
+		},
+	}
+	c.Flags().StringVar(&cmd.addr, "addr", ":8080", "Listen on `address`")
+	c.Flags().StringVar(&cmd.dir, "dir", "", "Store files in `dir` instead of the download cache")
+	return c
+}
+
+func (cmd *cacheServeCmd) run(ctx context.Context) error {
+	dir := cmd.dir
+	if dir == "" {
+		dataDirs, err := ybdata.DirsFromEnv()
+		if err != nil {
+			return err
+		}
+		dir = dataDirs.Downloads()
+	}
+	l, err := net.Listen("tcp", cmd.addr)
+	if err != nil {
+		return err
+	}
+	srv := &http.Server{
+		Handler: ybdata.NewCacheServer(dir),
+		BaseContext: func(net.Listener) context.Context {
+			return ctx
+		},
+	}
+	log.Infof(ctx, "Serving %s on %v", dir, l.Addr())
+	errc := make(chan error, 1)
+	go func() {
+		errc <- srv.Serve(l)
+	}()
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+		log.Infof(ctx, "Shutting down")
+		if err := srv.Shutdown(context.Background()); err != nil {
+			return err
+		}
+		<-errc
+		return nil
+	}
+}
+
+func listCache(out io.Writer, entries []*ybdata.CacheEntry) error {
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "KIND\tSIZE\tLAST USED\tNAME")
+	var total int64
+	for _, ent := range entries {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n",
+			ent.Kind,
+			formatSize(ent.Size),
+			ent.LastUsed.Local().Format("2006-01-02 15:04"),
+			ent.Name,
+		)
+		total += ent.Size
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(out, "Total: %s\n", formatSize(total))
+	return err
+}
+
+// trimCacheRecentUse is how long ago a cache entry must have been used for
+// trimCacheAfterBuild to remove it.
+const trimCacheRecentUse = 5 * time.Minute
+
+// trimCacheAfterBuild removes the least recently used cache entries if the
+// cache exceeds the configured maximum size. It never removes the build homes
+// of packageDir or entries used in the last few minutes, and it doesn't trim
+// at all while another yb process holds a lock on the cache. The caller must
+// have released its own lock. Failures are logged, since they shouldn't fail
+// an otherwise successful build.
+func trimCacheAfterBuild(ctx context.Context, cfg config.Getter, dataDirs *ybdata.Dirs, packageDir string) {
+	maxSize, err := config.CacheMaxSize(cfg)
+	if err != nil {
+		log.Warnf(ctx, "Trim cache: %v", err)
+		return
+	}
+	if maxSize == 0 {
+		return
+	}
+	cacheLock, err := dataDirs.TryLockCacheForTrim()
+	if errors.Is(err, ybdata.ErrCacheInUse) {
+		log.Debugf(ctx, "Not trimming cache: %v", err)
+		return
+	}
+	if err != nil {
+		log.Warnf(ctx, "Trim cache: %v", err)
+		return
+	}
+	defer cacheLock.Unlock()
+	entries, err := dataDirs.CacheEntries()
+	if err != nil {
+		log.Warnf(ctx, "Trim cache: %v", err)
+		return
+	}
+	keep, err := dataDirs.BuildHomes(packageDir)
+	if err != nil {
+		log.Warnf(ctx, "Trim cache: %v", err)
+		return
+	}
+	removed, err := ybdata.TrimCache(entries, maxSize, &ybdata.TrimOptions{
+		MinAge: trimCacheRecentUse,
+		Keep:   keep,
+	})
+	for _, ent := range removed {
+		log.Debugf(ctx, "Removed %s %s from cache", ent.Kind, ent.Name)
+	}
+	if err != nil {
+		log.Warnf(ctx, "%v", err)
+	}
+}
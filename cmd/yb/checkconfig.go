@@ -2,6 +2,9 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"os"
 
 	"github.com/spf13/cobra"
 	"github.com/yourbase/yb"
@@ -9,15 +12,23 @@ import (
 )
 
 type checkConfigCmd struct {
-	file string
+	file   string
+	format string
 }
 
 func newCheckConfigCmd() *cobra.Command {
 	b := new(checkConfigCmd)
 	c := &cobra.Command{
-		Use:                   "checkconfig [-file FILE]",
-		Short:                 "Check the config file syntax",
-		Long:                  `Validate the local YourBase config file, .yourbase.yml by default.`,
+		Use:   "checkconfig [-file FILE] [--format=text|json]",
+		Short: "Check the config file syntax",
+		Long: `Validate the local YourBase config file, .yourbase.yml by default.` +
+			"\n\n" +
+			`Besides syntax, checkconfig reports unknown buildpacks, malformed ` +
+			`versions, invalid environment templates, mounts, and ports, along with ` +
+			`deprecated constructs. Each problem is reported with its position in ` +
+			`the file. checkconfig also reports which file each version read from ` +
+			`the package directory (with tool_versions or a version of "auto") ` +
+			`came from.`,
 		Args:                  cobra.NoArgs,
 		DisableFlagsInUseLine: true,
 		SilenceErrors:         true,
@@ -27,15 +38,59 @@ func newCheckConfigCmd() *cobra.Command {
 		},
 	}
 	c.Flags().StringVar(&b.file, "file", yb.PackageConfigFilename, "YAML file to check")
+	c.Flags().StringVar(&b.format, "format", "text", "output format: text or json")
 	return c
 }
 
 func (b *checkConfigCmd) run(ctx context.Context) error {
-	targetPackage, err := yb.LoadPackage(b.file)
+	if b.format != "text" && b.format != "json" {
+		return fmt.Errorf("unknown format %q (must be text or json)", b.format)
+	}
+	names, err := buildpackNames()
+	if err != nil {
+		return err
+	}
+	diags, err := yb.ValidatePackage(b.file, &yb.ValidateOptions{
+		Buildpacks: names,
+	})
 	if err != nil {
 		return err
 	}
+	errorCount := 0
+	for _, d := range diags {
+		if d.Severity == yb.SeverityError {
+			errorCount++
+		}
+	}
 
-	log.Infof(ctx, "Syntax for package '%s' is OK: your package is YourBase'd!", targetPackage.Name)
+	if b.format == "json" {
+		if diags == nil {
+			diags = []*yb.Diagnostic{}
+		}
+		out, err := json.MarshalIndent(diags, "", "  ")
+		if err != nil {
+			return err
+		}
+		out = append(out, '\n')
+		if _, err := os.Stdout.Write(out); err != nil {
+			return err
+		}
+	} else {
+		for _, d := range diags {
+			fmt.Println(d)
+		}
+	}
+	if errorCount > 0 {
+		return alreadyLoggedError{fmt.Errorf("%s: found %d error(s)", b.file, errorCount)}
+	}
+	if b.format == "text" {
+		targetPackage, err := yb.LoadPackage(b.file, &yb.LoadOptions{
+			Buildpacks: names,
+		})
+		if err != nil {
+			return err
+		}
+		log.Infof(ctx, "Syntax for package '%s' is OK: your package is YourBase'd!", targetPackage.Name)
+	}
 	return nil
 }
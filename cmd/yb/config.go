@@ -12,7 +12,7 @@ import (
 )
 
 var (
-	VARS = []string{"environment", "log-level", "log-section", "no-pretty-output"}
+	VARS = []string{"cache-max-size", "environment", "log-level", "log-section", "mirror-fallback", "mirrors", "no-pretty-output", "remote-cache"}
 )
 
 func newConfigCmd(cfg ini.FileSet) *cobra.Command {
@@ -8,19 +8,21 @@ import (
 	"github.com/spf13/cobra"
 	"github.com/yourbase/yb"
 	"github.com/yourbase/yb/internal/build"
+	"github.com/yourbase/yb/internal/config"
 	"github.com/yourbase/yb/internal/ybdata"
 	"zombiezen.com/go/log"
 )
 
 type execCmd struct {
+	cfg         config.Getter
 	execEnvName string
 	env         []commandLineEnv
 	netrcFiles  []string
 	mode        executionMode
 }
 
-func newExecCmd() *cobra.Command {
-	b := new(execCmd)
+func newExecCmd(cfg config.Getter) *cobra.Command {
+	b := &execCmd{cfg: cfg}
 	c := &cobra.Command{
 		Use:   "exec",
 		Short: "Run the package",
@@ -49,7 +51,13 @@ func (b *execCmd) run(ctx context.Context) error {
 	if err != nil {
 		return err
 	}
-	downloader := ybdata.NewDownloader(dataDirs.Downloads())
+	// Hold a shared lock on the cache so that other yb processes don't trim
+	// it while this one uses it.
+	cacheLock, err := dataDirs.LockCache()
+	if err != nil {
+		return err
+	}
+	defer cacheLock.Unlock()
 	baseEnv, err := envFromCommandLine(b.env)
 	if err != nil {
 		return err
@@ -70,6 +78,19 @@ func (b *execCmd) run(ctx context.Context) error {
 		return fmt.Errorf("exec %s: no such environment", b.execEnvName)
 	}
 	showDockerWarningsIfNeeded(ctx, b.mode, []*yb.Target{execTarget})
+	buildpacks, err := buildpackDefinitions(pkg)
+	if err != nil {
+		return err
+	}
+	mirrors, err := downloadMirrors(b.cfg, pkg)
+	if err != nil {
+		return err
+	}
+	remoteCache, err := config.RemoteCache(b.cfg)
+	if err != nil {
+		return err
+	}
+	downloader := newDownloader(dataDirs, mirrors, remoteCache)
 	dockerNetworkID, removeNetwork, err := newDockerNetwork(ctx, dockerClient, b.mode, []*yb.Target{execTarget})
 	if err != nil {
 		return err
@@ -98,8 +119,12 @@ func (b *execCmd) run(ctx context.Context) error {
 		Downloader:      downloader,
 		DockerClient:    dockerClient,
 		DockerNetworkID: dockerNetworkID,
+		Definitions:     buildpacks,
 		Stdout:          os.Stdout,
 		Stderr:          os.Stderr,
+		Parallelism:     setupParallelism,
+		LogPrefix:       withBuildpackLogPrefix,
+		Inventory:       toolInventory(dataDirs, pkg.Path, execTarget, bio),
 	}
 	execBiome, err := build.Setup(withLogPrefix(ctx, execTarget.Name+setupLogPrefix), sys, execTarget)
 	if err != nil {
@@ -7,6 +7,7 @@ import (
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"net"
 	"os"
 	"os/user"
@@ -22,6 +23,7 @@ import (
 	"github.com/yourbase/yb"
 	"github.com/yourbase/yb/internal/biome"
 	"github.com/yourbase/yb/internal/build"
+	"github.com/yourbase/yb/internal/buildpack"
 	"github.com/yourbase/yb/internal/config"
 	"github.com/yourbase/yb/internal/ybdata"
 	"zombiezen.com/go/log"
@@ -96,6 +98,8 @@ type newBiomeOptions struct {
 	dataDirs   *ybdata.Dirs
 	baseEnv    biome.Environment
 	netrcFiles []string
+	// homeDir overrides the target's build home directory if not empty.
+	homeDir string
 
 	executionMode   executionMode
 	dockerClient    *docker.Client
@@ -117,10 +121,13 @@ func newBiome(ctx context.Context, target *yb.Target, opts newBiomeOptions) (bio
 		l := biome.Local{
 			PackageDir: opts.packageDir,
 		}
-		var err error
-		l.HomeDir, err = opts.dataDirs.BuildHome(opts.packageDir, target.Name, l.Describe())
-		if err != nil {
-			return nil, fmt.Errorf("set up environment for target %s: %w", target.Name, err)
+		l.HomeDir = opts.homeDir
+		if l.HomeDir == "" {
+			var err error
+			l.HomeDir, err = opts.dataDirs.BuildHome(opts.packageDir, target.Name, l.Describe())
+			if err != nil {
+				return nil, fmt.Errorf("set up environment for target %s: %w", target.Name, err)
+			}
 		}
 		log.Debugf(ctx, "Home located at %s", l.HomeDir)
 		if err := ensureKeychain(ctx, l); err != nil {
@@ -140,12 +147,15 @@ func newBiome(ctx context.Context, target *yb.Target, opts newBiomeOptions) (bio
 	if err != nil {
 		return nil, fmt.Errorf("set up environment for target %s: %w", target.Name, err)
 	}
-	home, err := opts.dataDirs.BuildHome(opts.packageDir, target.Name, dockerDesc)
-	if err != nil {
-		return nil, fmt.Errorf("set up environment for target %s: %w", target.Name, err)
+	home := opts.homeDir
+	if home == "" {
+		home, err = opts.dataDirs.BuildHome(opts.packageDir, target.Name, dockerDesc)
+		if err != nil {
+			return nil, fmt.Errorf("set up environment for target %s: %w", target.Name, err)
+		}
 	}
 	log.Debugf(ctx, "Home located at %s", home)
-	tiniFile, err := opts.downloader.Download(ctx, biome.TiniURL)
+	tiniFile, err := downloadTini(ctx, opts.downloader, dockerDesc)
 	if err != nil {
 		return nil, fmt.Errorf("set up environment for target %s: %w", target.Name, err)
 	}
@@ -171,6 +181,61 @@ func newBiome(ctx context.Context, target *yb.Target, opts newBiomeOptions) (bio
 	}, nil
 }
 
+// downloadTini downloads the tini executable for containers on the given
+// platform, verified against the digest that tini publishes.
+func downloadTini(ctx context.Context, downloader *ybdata.Downloader, desc *biome.Descriptor) (*os.File, error) {
+	url, err := biome.TiniURL(desc)
+	if err != nil {
+		return nil, err
+	}
+	// The digest file changes if the executable does, so it is never locked.
+	sumFile, err := downloader.Download(ybdata.Unpinned(ctx), url+".sha256sum")
+	if err != nil {
+		return nil, fmt.Errorf("download tini: %w", err)
+	}
+	sumData, err := ioutil.ReadAll(sumFile)
+	sumFile.Close()
+	if err != nil {
+		return nil, fmt.Errorf("download tini: %w", err)
+	}
+	fields := strings.Fields(string(sumData))
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("download tini: %s.sha256sum is empty", url)
+	}
+	f, err := downloader.Download(ybdata.WithChecksum(ctx, url, fields[0]), url)
+	if err != nil {
+		return nil, fmt.Errorf("download tini: %w", err)
+	}
+	return f, nil
+}
+
+// toolInventory returns the inventory of the buildpacks installed in the
+// target's build home, as used by a biome created by newBiome without a
+// homeDir override.
+func toolInventory(dataDirs *ybdata.Dirs, packageDir string, target *yb.Target, bio biome.Biome) *buildpack.Inventory {
+	home := dataDirs.FindBuildHome(packageDir, target.Name, bio.Describe())
+	return &buildpack.Inventory{Dir: ybdata.BuildHomeTools(home)}
+}
+
+// newDownloader returns a Downloader for the user's download cache that uses
+// the given mirrors and remote cache and shows a progress line if stderr is a
+// terminal.
+func newDownloader(dataDirs *ybdata.Dirs, mirrors []*yb.Mirror, remoteCache string) *ybdata.Downloader {
+	downloader := ybdata.NewDownloader(dataDirs.Downloads())
+	downloader.Mirrors = mirrors
+	downloader.RemoteCache = remoteCache
+	if isTerminal(os.Stderr) {
+		downloader.Progress = os.Stderr
+	}
+	return downloader
+}
+
+// isTerminal reports whether f is a character device, like a terminal.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
 // netrcFlagVar registers the --netrc flag.
 func netrcFlagVar(flags *pflag.FlagSet, netrc *[]string) {
 	// StringArray makes every --netrc flag add to the list.
@@ -316,8 +381,13 @@ func findPackage() (pkg *yb.Package, subdir string, err error) {
 	if err != nil {
 		return nil, "", fmt.Errorf("find package configuration: %w", err)
 	}
+	names, err := buildpackNames()
+	if err != nil {
+		return nil, "", fmt.Errorf("find package configuration: %w", err)
+	}
+	opts := &yb.LoadOptions{Buildpacks: names}
 	for {
-		pkg, err = yb.LoadPackage(filepath.Join(dir, yb.PackageConfigFilename))
+		pkg, err = yb.LoadPackage(filepath.Join(dir, yb.PackageConfigFilename), opts)
 		if err == nil {
 			return pkg, subdir, nil
 		}
@@ -336,6 +406,48 @@ func findPackage() (pkg *yb.Package, subdir string, err error) {
 	}
 }
 
+// downloadMirrors returns the download mirror rules from the user's settings
+// followed by the rules declared in the package.
+func downloadMirrors(cfg config.Getter, pkg *yb.Package) ([]*yb.Mirror, error) {
+	mirrors, err := config.Mirrors(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return append(mirrors, pkg.Mirrors...), nil
+}
+
+// buildpackDefinitions returns the buildpacks declared in the user's settings
+// and in the package. The package's declarations take precedence.
+func buildpackDefinitions(pkg *yb.Package) (map[string]*yb.BuildpackDefinition, error) {
+	defs, err := config.LoadBuildpacks()
+	if err != nil {
+		return nil, err
+	}
+	for name, def := range pkg.Buildpacks {
+		defs[name] = def
+	}
+	return defs, nil
+}
+
+// buildpackNames returns the names of the built-in buildpacks and the
+// buildpacks declared in the user's settings in sorted order. Buildpacks
+// declared in a package are not included.
+func buildpackNames() ([]string, error) {
+	defs, err := config.LoadBuildpacks()
+	if err != nil {
+		return nil, err
+	}
+	builtins := buildpack.Names()
+	names := builtins
+	for name := range defs {
+		if i := sort.SearchStrings(builtins, name); i == len(builtins) || builtins[i] != name {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
 func listTargetNames(targets map[string]*yb.Target) []string {
 	names := make([]string, 0, len(targets))
 	for name := range targets {
@@ -129,7 +129,7 @@ func TestPackageConfigTemplates(t *testing.T) {
 			if err := ioutil.WriteFile(dst, []byte(data), 0o666); err != nil {
 				t.Fatal(err)
 			}
-			if _, err := yb.LoadPackage(dst); err != nil {
+			if _, err := yb.LoadPackage(dst, nil); err != nil {
 				t.Errorf("Load file: %v", err)
 			}
 		})
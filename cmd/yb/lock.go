@@ -0,0 +1,425 @@
+// Copyright 2020 YourBase Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	docker "github.com/fsouza/go-dockerclient"
+	"github.com/spf13/cobra"
+	"github.com/yourbase/yb"
+	"github.com/yourbase/yb/internal/buildpack"
+	"github.com/yourbase/yb/internal/config"
+	"github.com/yourbase/yb/internal/ybdata"
+	"zombiezen.com/go/log"
+)
+
+type lockCmd struct {
+	cfg         config.Getter
+	targetNames []string
+	netrcFiles  []string
+	mode        executionMode
+}
+
+func newLockCmd(cfg config.Getter) *cobra.Command {
+	cmd := &lockCmd{cfg: cfg}
+	c := &cobra.Command{
+		Use:   "lock [options] [TARGET [...]]",
+		Short: "Record resolved dependencies in " + yb.LockFilename,
+		Long: "lock resolves the buildpack versions, downloads, and container images\n" +
+			"used by the package's targets and records them in " + yb.LockFilename + ".\n" +
+			"If no targets are given, every target in the package is locked.\n" +
+			"\n" +
+			"Once " + yb.LockFilename + " exists, yb build uses the locked versions and\n" +
+			"refuses to build a target whose configuration has drifted from the lock\n" +
+			"unless --update-lock is passed. Downloads are recorded for the platform\n" +
+			"the target builds on.",
+		Args:                  cobra.ArbitraryArgs,
+		DisableFlagsInUseLine: true,
+		SilenceErrors:         true,
+		SilenceUsage:          true,
+		RunE: func(cc *cobra.Command, args []string) error {
+			cmd.targetNames = args
+			return cmd.run(cc.Context())
+		},
+		ValidArgsFunction: func(cc *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			return autocompleteTargetName(toComplete)
+		},
+	}
+	netrcFlagVar(c.Flags(), &cmd.netrcFiles)
+	executionModeVar(c.Flags(), &cmd.mode)
+	return c
+}
+
+func (cmd *lockCmd) run(ctx context.Context) error {
+	dataDirs, err := ybdata.DirsFromEnv()
+	if err != nil {
+		return err
+	}
+	// Hold a shared lock on the cache so that other yb processes don't trim
+	// it while this one uses it.
+	cacheLock, err := dataDirs.LockCache()
+	if err != nil {
+		return err
+	}
+	defer cacheLock.Unlock()
+	dockerClient, err := connectDockerClient(cmd.mode)
+	if err != nil {
+		return err
+	}
+	pkg, _, err := findPackage()
+	if err != nil {
+		return err
+	}
+	names := cmd.targetNames
+	if len(names) == 0 {
+		names = listTargetNames(pkg.Targets)
+	}
+	desired := make([]*yb.Target, 0, len(names))
+	for _, name := range names {
+		target := pkg.Targets[name]
+		if target == nil {
+			return fmt.Errorf("%s: no such target (found: %s)", name, strings.Join(listTargetNames(pkg.Targets), ", "))
+		}
+		desired = append(desired, target)
+	}
+	targets := yb.BuildOrder(desired...)
+	showDockerWarningsIfNeeded(ctx, cmd.mode, targets)
+	buildpacks, err := buildpackDefinitions(pkg)
+	if err != nil {
+		return err
+	}
+	mirrors, err := downloadMirrors(cmd.cfg, pkg)
+	if err != nil {
+		return err
+	}
+
+	lock, err := lockTargets(ctx, pkg, targets, &lockOptions{
+		dataDirs:      dataDirs,
+		buildpacks:    buildpacks,
+		mirrors:       mirrors,
+		netrcFiles:    cmd.netrcFiles,
+		executionMode: cmd.mode,
+		dockerClient:  dockerClient,
+	})
+	if err != nil {
+		return err
+	}
+	if len(cmd.targetNames) == 0 {
+		// Forget targets that no longer exist.
+		for name := range lock.Targets {
+			if pkg.Targets[name] == nil {
+				log.Infof(ctx, "Removing target %s from %s", name, yb.LockFilename)
+				delete(lock.Targets, name)
+			}
+		}
+	}
+	return writeLock(ctx, pkg, lock)
+}
+
+type lockOptions struct {
+	dataDirs   *ybdata.Dirs
+	buildpacks map[string]*yb.BuildpackDefinition
+	mirrors    []*yb.Mirror
+	netrcFiles []string
+
+	executionMode executionMode
+	dockerClient  *docker.Client
+}
+
+// lockTargets locks the given targets and merges them into the package's
+// existing lock, if any. It does not write the lock to disk.
+func lockTargets(ctx context.Context, pkg *yb.Package, targets []*yb.Target, opts *lockOptions) (*yb.Lock, error) {
+	lock, err := yb.LoadLock(filepath.Join(pkg.Path, yb.LockFilename))
+	if errors.Is(err, os.ErrNotExist) {
+		lock = &yb.Lock{Targets: make(map[string]*yb.LockedTarget)}
+	} else if err != nil {
+		return nil, err
+	}
+	dockerNetworkID, removeNetwork, err := newDockerNetwork(ctx, opts.dockerClient, opts.executionMode, targets)
+	if err != nil {
+		return nil, err
+	}
+	defer removeNetwork()
+	for _, target := range targets {
+		lt, err := lockTarget(withLogPrefix(ctx, target.Name), pkg, target, opts, dockerNetworkID)
+		if err != nil {
+			return nil, fmt.Errorf("lock target %s: %w", target.Name, err)
+		}
+		lock.Targets[target.Name] = lt
+	}
+	return lock, nil
+}
+
+// lockTarget resolves the target's buildpack versions and image digests and
+// records the files downloaded while installing its buildpacks into an empty
+// home directory.
+func lockTarget(ctx context.Context, pkg *yb.Package, target *yb.Target, opts *lockOptions, dockerNetworkID string) (*yb.LockedTarget, error) {
+	lt := &yb.LockedTarget{
+		Buildpacks: make(map[string]*yb.LockedBuildpack),
+		Downloads:  make(map[string]string),
+	}
+	// Locked digests should come from the origin, so skip the remote cache.
+	downloader := newDownloader(opts.dataDirs, opts.mirrors, "")
+	downloader.OnDownload = func(url string, sha256 string) {
+		lt.Downloads[url] = sha256
+	}
+
+	names := make([]string, 0, len(target.Buildpacks))
+	for name := range target.Buildpacks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		spec := target.Buildpacks[name]
+		resolved, err := buildpack.Resolve(ctx, buildpack.Sys{Downloader: downloader, Definitions: opts.buildpacks}, spec)
+		if err != nil {
+			return nil, err
+		}
+		if resolved != spec {
+			log.Infof(ctx, "Resolved %s to %s", spec, resolved)
+		}
+		lt.Buildpacks[name] = &yb.LockedBuildpack{Spec: spec, Resolved: resolved}
+	}
+
+	home, err := ioutil.TempDir("", "yb-lock-")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := os.RemoveAll(home); err != nil {
+			log.Warnf(ctx, "Clean up temporary home: %v", err)
+		}
+	}()
+	bio, err := newBiome(ctx, target, newBiomeOptions{
+		packageDir:      pkg.Path,
+		dataDirs:        opts.dataDirs,
+		downloader:      downloader,
+		netrcFiles:      opts.netrcFiles,
+		homeDir:         home,
+		executionMode:   opts.executionMode,
+		dockerClient:    opts.dockerClient,
+		dockerNetworkID: dockerNetworkID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := bio.Close(); err != nil {
+			log.Warnf(ctx, "Clean up environment: %v", err)
+		}
+	}()
+	output := newLinePrefixWriter(os.Stdout, target.Name)
+	sys := buildpack.Sys{
+		Biome:             bio,
+		Downloader:        downloader,
+		DockerClient:      opts.dockerClient,
+		DockerNetworkID:   dockerNetworkID,
+		Definitions:       opts.buildpacks,
+		AndroidComponents: target.AndroidComponents,
+		Stdout:            output,
+		Stderr:            output,
+	}
+	resolved := make([]yb.BuildpackSpec, 0, len(names))
+	for _, name := range names {
+		resolved = append(resolved, lt.Buildpacks[name].Resolved)
+	}
+	if _, err := buildpack.InstallAll(ctx, sys, resolved); err != nil {
+		return nil, err
+	}
+
+	if willUseDockerForCommands(opts.executionMode, []*yb.Target{target}) {
+		digest, err := imageDigest(ctx, opts.dockerClient, target.Container.Image)
+		if err != nil {
+			return nil, err
+		}
+		lt.Container = &yb.LockedImage{Image: target.Container.Image, Digest: digest}
+	}
+	if len(target.Resources) > 0 {
+		if opts.dockerClient == nil {
+			return nil, fmt.Errorf("docker required to lock resource images but unavailable")
+		}
+		lt.Resources = make(map[string]*yb.LockedImage, len(target.Resources))
+		for name, rd := range target.Resources {
+			digest, err := imageDigest(ctx, opts.dockerClient, rd.Image)
+			if err != nil {
+				return nil, err
+			}
+			lt.Resources[name] = &yb.LockedImage{Image: rd.Image, Digest: digest}
+		}
+	}
+	return lt, nil
+}
+
+// imageDigest returns a reference to the image by its registry digest, pulling
+// the image if it is not present. It returns the empty string if the image
+// has no registry digest.
+func imageDigest(ctx context.Context, client *docker.Client, image string) (string, error) {
+	info, err := client.InspectImage(image)
+	if errors.Is(err, docker.ErrNoSuchImage) {
+		log.Infof(ctx, "Pulling %s...", image)
+		repo, tag := splitImageTag(image)
+		err = client.PullImage(docker.PullImageOptions{
+			Context:    ctx,
+			Repository: repo,
+			Tag:        tag,
+		}, docker.AuthConfiguration{})
+		if err != nil {
+			return "", fmt.Errorf("resolve digest of %s: %w", image, err)
+		}
+		info, err = client.InspectImage(image)
+	}
+	if err != nil {
+		return "", fmt.Errorf("resolve digest of %s: %w", image, err)
+	}
+	repo, _ := splitImageTag(image)
+	for _, digest := range info.RepoDigests {
+		if strings.HasPrefix(digest, repo+"@") {
+			return digest, nil
+		}
+	}
+	if len(info.RepoDigests) > 0 {
+		return info.RepoDigests[0], nil
+	}
+	log.Warnf(ctx, "Image %s has no registry digest; it will not be pinned", image)
+	return "", nil
+}
+
+// pullPinnedImages pulls the images that the target refers to by digest if
+// they are not present. narwhal drops the digest from an image reference when it
+// looks for and pulls the image, so a locked target must have its images pulled
+// before narwhal creates containers for it. The container image is only pulled
+// if useContainer is true.
+func pullPinnedImages(ctx context.Context, client *docker.Client, target *yb.Target, useContainer bool) error {
+	var images []string
+	if useContainer && target.Container != nil {
+		images = append(images, target.Container.Image)
+	}
+	for _, rd := range target.Resources {
+		images = append(images, rd.Image)
+	}
+	for _, image := range images {
+		if err := pullPinnedImage(ctx, client, image); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pullPinnedImage pulls an image reference like "postgres:12@sha256:..." by its
+// digest if it is not present. If the tag is not present either, the pulled
+// image is given the tag so that narwhal does not pull the tag on its own.
+// References without a digest are left to narwhal.
+func pullPinnedImage(ctx context.Context, client *docker.Client, image string) error {
+	i := strings.LastIndexByte(image, '@')
+	if i == -1 {
+		return nil
+	}
+	repo, tag := splitImageTag(image[:i])
+	digest := image[i+1:]
+	ref := repo + "@" + digest
+	_, err := client.InspectImage(ref)
+	if errors.Is(err, docker.ErrNoSuchImage) {
+		log.Infof(ctx, "Pulling %s...", ref)
+		err = client.PullImage(docker.PullImageOptions{
+			Context:    ctx,
+			Repository: repo,
+			Tag:        digest,
+		}, docker.AuthConfiguration{})
+	}
+	if err != nil {
+		return fmt.Errorf("pull %s: %w", ref, err)
+	}
+	_, err = client.InspectImage(repo + ":" + tag)
+	if errors.Is(err, docker.ErrNoSuchImage) {
+		err = client.TagImage(ref, docker.TagImageOptions{
+			Context: ctx,
+			Repo:    repo,
+			Tag:     tag,
+		})
+	}
+	if err != nil {
+		return fmt.Errorf("pull %s: tag %s: %w", ref, tag, err)
+	}
+	return nil
+}
+
+// splitImageTag splits an image reference into its repository and tag.
+func splitImageTag(image string) (repo, tag string) {
+	i := strings.LastIndexByte(image, ':')
+	if i == -1 || i < strings.LastIndexByte(image, '/') {
+		return image, "latest"
+	}
+	return image[:i], image[i+1:]
+}
+
+func writeLock(ctx context.Context, pkg *yb.Package, lock *yb.Lock) error {
+	data, err := lock.Encode()
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(pkg.Path, yb.LockFilename)
+	if err := ioutil.WriteFile(path, data, 0o666); err != nil {
+		return fmt.Errorf("write lock: %w", err)
+	}
+	log.Infof(ctx, "Wrote %s", path)
+	return nil
+}
+
+// loadBuildLock returns the package's lock for a build. If update is true,
+// the targets are locked again and the lock is written before it is returned.
+// loadBuildLock returns nil if the package has no lock file and update is false.
+func loadBuildLock(ctx context.Context, pkg *yb.Package, targets []*yb.Target, update bool, opts *lockOptions) (*yb.Lock, error) {
+	if update {
+		lock, err := lockTargets(ctx, pkg, targets, opts)
+		if err != nil {
+			return nil, err
+		}
+		if err := writeLock(ctx, pkg, lock); err != nil {
+			return nil, err
+		}
+		return lock, nil
+	}
+	lock, err := yb.LoadLock(filepath.Join(pkg.Path, yb.LockFilename))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return lock, nil
+}
+
+// pinTarget returns the target with the locked versions applied. It returns an
+// error if the target's configuration has drifted from the lock.
+func pinTarget(lock *yb.Lock, target *yb.Target) (*yb.Target, error) {
+	lt := lock.Targets[target.Name]
+	if lt == nil {
+		return nil, fmt.Errorf("target %s is not in %s (run yb lock or pass --update-lock)", target.Name, yb.LockFilename)
+	}
+	if err := lt.Check(target); err != nil {
+		return nil, fmt.Errorf("%w (run yb lock or pass --update-lock)", err)
+	}
+	return lt.Pin(target), nil
+}
@@ -0,0 +1,126 @@
+// Copyright 2020 YourBase Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	docker "github.com/fsouza/go-dockerclient"
+	"github.com/yourbase/narwhal"
+	"github.com/yourbase/yb"
+	"zombiezen.com/go/log/testlog"
+)
+
+func TestSplitImageTag(t *testing.T) {
+	tests := []struct {
+		image    string
+		wantRepo string
+		wantTag  string
+	}{
+		{"postgres", "postgres", "latest"},
+		{"postgres:12", "postgres", "12"},
+		{"yourbase/yb_ubuntu:18.04", "yourbase/yb_ubuntu", "18.04"},
+		{"localhost:5000/myimage", "localhost:5000/myimage", "latest"},
+		{"localhost:5000/myimage:1.0", "localhost:5000/myimage", "1.0"},
+	}
+	for _, test := range tests {
+		repo, tag := splitImageTag(test.image)
+		if repo != test.wantRepo || tag != test.wantTag {
+			t.Errorf("splitImageTag(%q) = %q, %q; want %q, %q", test.image, repo, tag, test.wantRepo, test.wantTag)
+		}
+	}
+}
+
+func TestPullPinnedImages(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping Docker test due to -short")
+	}
+	ctx := testlog.WithTB(context.Background(), t)
+	client, err := docker.NewVersionedClientFromEnv("1.39")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Ping(); err != nil {
+		t.Skip("Docker not available:", err)
+	}
+
+	// Lock the image, then remove it so that the pinned target runs against an
+	// image store that has neither the tag nor the digest.
+	const image = "busybox:1.33.1"
+	digest, err := imageDigest(ctx, client, image)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if digest == "" {
+		t.Fatalf("%s has no registry digest", image)
+	}
+	info, err := client.InspectImage(image)
+	if err != nil {
+		t.Fatal(err)
+	}
+	removeImage := func() {
+		err := client.RemoveImageExtended(info.ID, docker.RemoveImageOptions{Force: true, Context: ctx})
+		if err != nil && err != docker.ErrNoSuchImage {
+			t.Error(err)
+		}
+	}
+	removeImage()
+	defer removeImage()
+
+	target := &yb.Target{
+		Name: "default",
+		Resources: map[string]*yb.ResourceDefinition{
+			"svc": {ContainerDefinition: narwhal.ContainerDefinition{
+				Image:   image,
+				Label:   "yb-pin-test",
+				Command: "sleep 60",
+			}},
+		},
+	}
+	lt := &yb.LockedTarget{
+		Resources: map[string]*yb.LockedImage{
+			"svc": {Image: image, Digest: digest},
+		},
+	}
+	pinned := lt.Pin(target)
+	if err := pullPinnedImages(ctx, client, pinned, false); err != nil {
+		t.Fatal(err)
+	}
+	pullOutput := new(strings.Builder)
+	containerID, err := narwhal.CreateContainer(ctx, client, pullOutput, &pinned.Resources["svc"].ContainerDefinition)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := client.RemoveContainer(docker.RemoveContainerOptions{ID: containerID, Force: true, Context: ctx})
+		if err != nil {
+			t.Error(err)
+		}
+	}()
+	if pullOutput.Len() > 0 {
+		t.Errorf("narwhal pulled an image after pullPinnedImages:\n%s", pullOutput)
+	}
+	c, err := client.InspectContainer(containerID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Image != info.ID {
+		t.Errorf("container image = %s; want locked %s (%s)", c.Image, info.ID, digest)
+	}
+}
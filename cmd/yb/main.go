@@ -61,17 +61,22 @@ func main() {
 	}
 
 	rootCmd.AddCommand(
-		newBuildCmd(),
+		newBuildCmd(cfg),
+		newCacheCmd(cfg),
 		newCheckConfigCmd(),
 		newCleanCmd(),
 		newConfigCmd(cfg),
-		newExecCmd(),
+		newExecCmd(cfg),
 		newGenCompleteCmd(),
 		newInitCmd(),
+		newLockCmd(cfg),
 		newLoginCmd(cfg),
+		newMigrateCmd(),
 		newRemoteCmd(cfg),
-		newRunCmd(),
+		newRunCmd(cfg),
+		newSchemaCmd(),
 		newTokenCmd(cfg),
+		newToolsCmd(cfg),
 	)
 	rootCmd.AddCommand(&cobra.Command{
 		Use:           "version",
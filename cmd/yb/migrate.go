@@ -0,0 +1,215 @@
+// Copyright 2020 YourBase Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/yourbase/yb"
+	"zombiezen.com/go/log"
+)
+
+type migrateCmd struct {
+	file   string
+	dryRun bool
+	yes    bool
+}
+
+func newMigrateCmd() *cobra.Command {
+	cmd := new(migrateCmd)
+	c := &cobra.Command{
+		Use:   "migrate [options]",
+		Short: "Rewrite legacy configuration forms",
+		Long: "migrate rewrites .yourbase.yml into its canonical form: a top-level\n" +
+			"build section becomes a build_targets entry named \"default\" and\n" +
+			"environment variable lists become mappings. Comments and key order\n" +
+			"are preserved. migrate shows a diff of its changes and asks for\n" +
+			"confirmation before writing the file.",
+		Args:                  cobra.NoArgs,
+		DisableFlagsInUseLine: true,
+		SilenceErrors:         true,
+		SilenceUsage:          true,
+		RunE: func(cc *cobra.Command, args []string) error {
+			return cmd.run(cc.Context())
+		},
+	}
+	c.Flags().StringVar(&cmd.file, "file", yb.PackageConfigFilename, "YAML file to migrate")
+	c.Flags().BoolVarP(&cmd.dryRun, "dry-run", "n", false, "show the changes without writing them")
+	c.Flags().BoolVarP(&cmd.yes, "yes", "y", false, "write the changes without asking for confirmation")
+	return c
+}
+
+func (cmd *migrateCmd) run(ctx context.Context) error {
+	info, err := os.Stat(cmd.file)
+	if err != nil {
+		return err
+	}
+	original, err := ioutil.ReadFile(cmd.file)
+	if err != nil {
+		return err
+	}
+	migrated, changes, err := yb.MigrateConfig(original)
+	if err != nil {
+		return fmt.Errorf("%s: %w", cmd.file, err)
+	}
+	if len(changes) == 0 {
+		log.Infof(ctx, "%s is already in canonical form", cmd.file)
+		return nil
+	}
+	for _, c := range changes {
+		log.Infof(ctx, "%s:%s", cmd.file, c)
+	}
+	fmt.Print(unifiedDiff(cmd.file, string(original), string(migrated)))
+	if cmd.dryRun {
+		return nil
+	}
+	if !cmd.yes {
+		fmt.Printf("Write changes to %s? [y/N] ", cmd.file)
+		answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		answer = strings.ToLower(strings.TrimSpace(answer))
+		if answer != "y" && answer != "yes" {
+			log.Infof(ctx, "No changes written")
+			return nil
+		}
+	}
+	if err := ioutil.WriteFile(cmd.file, migrated, info.Mode().Perm()); err != nil {
+		return err
+	}
+	log.Infof(ctx, "Wrote %s", cmd.file)
+	return nil
+}
+
+// unifiedDiff returns a unified diff between two texts with three lines of
+// context. It returns the empty string if the texts are equal.
+func unifiedDiff(name, a, b string) string {
+	const contextLines = 3
+	lines1 := splitLines(a)
+	lines2 := splitLines(b)
+
+	// Find the longest common subsequence.
+	lcs := make([][]int, len(lines1)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(lines2)+1)
+	}
+	for i := len(lines1) - 1; i >= 0; i-- {
+		for j := len(lines2) - 1; j >= 0; j-- {
+			switch {
+			case lines1[i] == lines2[j]:
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			case lcs[i+1][j] >= lcs[i][j+1]:
+				lcs[i][j] = lcs[i+1][j]
+			default:
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	type edit struct {
+		op   byte // ' ', '-', or '+'
+		line string
+		i, j int // line indices in a and b before this edit
+	}
+	var edits []edit
+	i, j := 0, 0
+	for i < len(lines1) || j < len(lines2) {
+		switch {
+		case i < len(lines1) && j < len(lines2) && lines1[i] == lines2[j]:
+			edits = append(edits, edit{' ', lines1[i], i, j})
+			i++
+			j++
+		case i < len(lines1) && (j == len(lines2) || lcs[i+1][j] >= lcs[i][j+1]):
+			edits = append(edits, edit{'-', lines1[i], i, j})
+			i++
+		default:
+			edits = append(edits, edit{'+', lines2[j], i, j})
+			j++
+		}
+	}
+
+	sb := new(strings.Builder)
+	for start := 0; start < len(edits); {
+		// Find the next change.
+		for start < len(edits) && edits[start].op == ' ' {
+			start++
+		}
+		if start == len(edits) {
+			break
+		}
+		if sb.Len() == 0 {
+			fmt.Fprintf(sb, "--- a/%s\n+++ b/%s\n", name, name)
+		}
+		// Extend the hunk until there are more than 2*contextLines unchanged lines.
+		hunkStart := start - contextLines
+		if hunkStart < 0 {
+			hunkStart = 0
+		}
+		end := start
+		for unchanged := 0; end < len(edits) && unchanged <= 2*contextLines; end++ {
+			if edits[end].op == ' ' {
+				unchanged++
+			} else {
+				unchanged = 0
+			}
+		}
+		// Trim trailing context.
+		for end > start && edits[end-1].op == ' ' {
+			end--
+		}
+		hunkEnd := end + contextLines
+		if hunkEnd > len(edits) {
+			hunkEnd = len(edits)
+		}
+		n1, n2 := 0, 0
+		for _, e := range edits[hunkStart:hunkEnd] {
+			if e.op != '+' {
+				n1++
+			}
+			if e.op != '-' {
+				n2++
+			}
+		}
+		fmt.Fprintf(sb, "@@ -%s +%s @@\n", hunkRange(edits[hunkStart].i, n1), hunkRange(edits[hunkStart].j, n2))
+		for _, e := range edits[hunkStart:hunkEnd] {
+			sb.WriteByte(e.op)
+			sb.WriteString(e.line)
+			sb.WriteByte('\n')
+		}
+		start = hunkEnd
+	}
+	return sb.String()
+}
+
+// hunkRange formats a line range for a unified diff hunk header.
+func hunkRange(start, n int) string {
+	if n == 0 {
+		// An empty range refers to the line before the change.
+		return fmt.Sprintf("%d,0", start)
+	}
+	return fmt.Sprintf("%d,%d", start+1, n)
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
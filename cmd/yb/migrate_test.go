@@ -0,0 +1,87 @@
+// Copyright 2020 YourBase Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	tests := []struct {
+		name string
+		a    string
+		b    string
+		want string
+	}{
+		{
+			name: "Equal",
+			a:    "a\nb\n",
+			b:    "a\nb\n",
+			want: "",
+		},
+		{
+			name: "FromEmpty",
+			a:    "",
+			b:    "a\n",
+			want: "--- a/x.yml\n+++ b/x.yml\n" +
+				"@@ -0,0 +1,1 @@\n" +
+				"+a\n",
+		},
+		{
+			name: "Change",
+			a:    "a\nb\nc\nd\ne\nf\n",
+			b:    "a\nb\nc\nD\ne\nf\n",
+			want: "--- a/x.yml\n+++ b/x.yml\n" +
+				"@@ -1,6 +1,6 @@\n" +
+				" a\n" +
+				" b\n" +
+				" c\n" +
+				"-d\n" +
+				"+D\n" +
+				" e\n" +
+				" f\n",
+		},
+		{
+			name: "SeparateHunks",
+			a:    "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl\nm\n",
+			b:    "a\nB\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl\nm\nn\n",
+			want: "--- a/x.yml\n+++ b/x.yml\n" +
+				"@@ -1,5 +1,5 @@\n" +
+				" a\n" +
+				"-b\n" +
+				"+B\n" +
+				" c\n" +
+				" d\n" +
+				" e\n" +
+				"@@ -11,3 +11,4 @@\n" +
+				" k\n" +
+				" l\n" +
+				" m\n" +
+				"+n\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := unifiedDiff("x.yml", test.a, test.b)
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("unifiedDiff(...) (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -41,6 +41,10 @@ const (
 // target's setup.
 const setupLogPrefix = ".deps"
 
+// setupParallelism is the number of buildpacks installed at once when running
+// a target's setup.
+const setupParallelism = 4
+
 type logger struct {
 	color termStyles
 
@@ -134,6 +138,12 @@ func withLogPrefix(parent context.Context, prefix string) context.Context {
 	return context.WithValue(parent, logPrefixKey{}, parentPrefix+prefix)
 }
 
+// withBuildpackLogPrefix labels log messages with a buildpack's name while it
+// is installed during setup.
+func withBuildpackLogPrefix(parent context.Context, packName string) context.Context {
+	return withLogPrefix(parent, "."+packName)
+}
+
 // linePrefixWriter prepends a timestamp and a prefix string to every line
 // written to it and writes to an underlying writer.
 type linePrefixWriter struct {
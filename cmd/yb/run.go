@@ -11,19 +11,21 @@ import (
 	"github.com/yourbase/yb"
 	"github.com/yourbase/yb/internal/biome"
 	"github.com/yourbase/yb/internal/build"
+	"github.com/yourbase/yb/internal/config"
 	"github.com/yourbase/yb/internal/ybdata"
 	"zombiezen.com/go/log"
 )
 
 type runCmd struct {
+	cfg        config.Getter
 	env        []commandLineEnv
 	netrcFiles []string
 	target     string
 	mode       executionMode
 }
 
-func newRunCmd() *cobra.Command {
-	b := new(runCmd)
+func newRunCmd(cfg config.Getter) *cobra.Command {
+	b := &runCmd{cfg: cfg}
 	c := &cobra.Command{
 		Use:   "run [options] COMMAND [ARG [...]]",
 		Short: "Run an arbitrary command",
@@ -55,7 +57,13 @@ func (b *runCmd) run(ctx context.Context, args []string) error {
 	if err != nil {
 		return err
 	}
-	downloader := ybdata.NewDownloader(dataDirs.Downloads())
+	// Hold a shared lock on the cache so that other yb processes don't trim
+	// it while this one uses it.
+	cacheLock, err := dataDirs.LockCache()
+	if err != nil {
+		return err
+	}
+	defer cacheLock.Unlock()
 	baseEnv, err := envFromCommandLine(b.env)
 	if err != nil {
 		return err
@@ -74,6 +82,19 @@ func (b *runCmd) run(ctx context.Context, args []string) error {
 	}
 	targets := yb.BuildOrder(execTarget)
 	showDockerWarningsIfNeeded(ctx, b.mode, targets)
+	buildpacks, err := buildpackDefinitions(pkg)
+	if err != nil {
+		return err
+	}
+	mirrors, err := downloadMirrors(b.cfg, pkg)
+	if err != nil {
+		return err
+	}
+	remoteCache, err := config.RemoteCache(b.cfg)
+	if err != nil {
+		return err
+	}
+	downloader := newDownloader(dataDirs, mirrors, remoteCache)
 	dockerNetworkID, removeNetwork, err := newDockerNetwork(ctx, dockerClient, b.mode, targets)
 	if err != nil {
 		return err
@@ -88,6 +109,7 @@ func (b *runCmd) run(ctx context.Context, args []string) error {
 		dockerNetworkID: dockerNetworkID,
 		dataDirs:        dataDirs,
 		downloader:      downloader,
+		buildpacks:      buildpacks,
 		baseEnv:         baseEnv,
 		netrcFiles:      b.netrcFiles,
 	})
@@ -120,8 +142,12 @@ func (b *runCmd) run(ctx context.Context, args []string) error {
 		Downloader:      downloader,
 		DockerClient:    dockerClient,
 		DockerNetworkID: dockerNetworkID,
+		Definitions:     buildpacks,
 		Stdout:          os.Stderr,
 		Stderr:          os.Stderr,
+		Parallelism:     setupParallelism,
+		LogPrefix:       withBuildpackLogPrefix,
+		Inventory:       toolInventory(dataDirs, pkg.Path, execTarget, bio),
 	}
 	execBiome, err := build.Setup(withLogPrefix(ctx, execTarget.Name+setupLogPrefix), sys, execTarget)
 	if err != nil {
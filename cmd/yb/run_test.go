@@ -25,6 +25,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/yourbase/commons/ini"
 	"zombiezen.com/go/log/testlog"
 )
 
@@ -48,7 +49,7 @@ func TestRunCommand(t *testing.T) {
 	t.Cleanup(func() { os.Stdout = oldStdout })
 
 	ctx := testlog.WithTB(context.Background(), t)
-	c := newRunCmd()
+	c := newRunCmd(new(ini.File))
 	const want = "foo\n"
 	c.SetArgs([]string{"echo", strings.TrimSuffix(want, "\n")})
 	if err := c.ExecuteContext(ctx); err != nil {
@@ -0,0 +1,68 @@
+// Copyright 2020 YourBase Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/yourbase/yb"
+)
+
+type schemaCmd struct {
+	output string
+}
+
+func newSchemaCmd() *cobra.Command {
+	cmd := new(schemaCmd)
+	c := &cobra.Command{
+		Use:   "schema [--output FILE]",
+		Short: "Print the JSON Schema for .yourbase.yml",
+		Long: "schema prints a JSON Schema describing the .yourbase.yml format.\n" +
+			"Editors that support JSON Schema can use it to validate and\n" +
+			"complete configuration files.",
+		Args:                  cobra.NoArgs,
+		DisableFlagsInUseLine: true,
+		SilenceErrors:         true,
+		SilenceUsage:          true,
+		RunE: func(cc *cobra.Command, args []string) error {
+			return cmd.run(cc.Context())
+		},
+	}
+	c.Flags().StringVarP(&cmd.output, "output", "o", "", "write the schema to `FILE` instead of stdout")
+	return c
+}
+
+func (cmd *schemaCmd) run(ctx context.Context) error {
+	names, err := buildpackNames()
+	if err != nil {
+		return err
+	}
+	schema, err := yb.JSONSchema(&yb.SchemaOptions{
+		Buildpacks: names,
+	})
+	if err != nil {
+		return err
+	}
+	if cmd.output != "" {
+		return ioutil.WriteFile(cmd.output, schema, 0o666)
+	}
+	_, err = os.Stdout.Write(schema)
+	return err
+}
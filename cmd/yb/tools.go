@@ -0,0 +1,490 @@
+// Copyright 2020 YourBase Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	docker "github.com/fsouza/go-dockerclient"
+	"github.com/spf13/cobra"
+	"github.com/yourbase/yb"
+	"github.com/yourbase/yb/internal/buildpack"
+	"github.com/yourbase/yb/internal/config"
+	"github.com/yourbase/yb/internal/ybdata"
+	"zombiezen.com/go/log"
+)
+
+func newToolsCmd(cfg config.Getter) *cobra.Command {
+	group := &cobra.Command{
+		Use:   "tools",
+		Short: "Manage installed buildpacks",
+		Long: "tools lists and deletes the buildpacks installed in build homes, the\n" +
+			"per-target directories available as $HOME in the build environment.\n" +
+			"By default, only the current package's build homes are considered.",
+		SilenceErrors: true,
+		SilenceUsage:  true,
+	}
+	group.AddCommand(
+		newToolsListCmd(),
+		newToolsPruneCmd(),
+		newToolsRemoveCmd(),
+		newToolsPrefetchCmd(cfg),
+	)
+	return group
+}
+
+func newToolsListCmd() *cobra.Command {
+	var all bool
+	c := &cobra.Command{
+		Use:                   "list [options]",
+		Short:                 "List installed buildpacks",
+		Args:                  cobra.NoArgs,
+		DisableFlagsInUseLine: true,
+		SilenceErrors:         true,
+		SilenceUsage:          true,
+		RunE: func(cc *cobra.Command, args []string) error {
+			homes, err := findToolHomes(all)
+			if err != nil {
+				return err
+			}
+			return listTools(os.Stdout, homes)
+		},
+	}
+	allPackagesFlagVar(c, &all)
+	return c
+}
+
+func newToolsPruneCmd() *cobra.Command {
+	var all, dryRun bool
+	var unusedFor string
+	c := &cobra.Command{
+		Use:                   "prune [options]",
+		Short:                 "Delete buildpacks that have not been used recently",
+		Args:                  cobra.NoArgs,
+		DisableFlagsInUseLine: true,
+		SilenceErrors:         true,
+		SilenceUsage:          true,
+		RunE: func(cc *cobra.Command, args []string) error {
+			age, err := parseAge(unusedFor)
+			if err != nil {
+				return fmt.Errorf("--unused-for: %w", err)
+			}
+			homes, err := findToolHomes(all)
+			if err != nil {
+				return err
+			}
+			cutoff := time.Now().Add(-age)
+			return removeTools(cc.Context(), os.Stdout, homes, dryRun, func(rec *buildpack.InstallRecord) (bool, error) {
+				return rec.LastUsed.Before(cutoff), nil
+			})
+		},
+	}
+	allPackagesFlagVar(c, &all)
+	c.Flags().StringVar(&unusedFor, "unused-for", "30d", "Delete buildpacks not used in this `duration` (like 12h, 30d, or 2w)")
+	c.Flags().BoolVarP(&dryRun, "dry-run", "n", false, "Print the buildpacks that would be deleted without deleting them")
+	return c
+}
+
+func newToolsRemoveCmd() *cobra.Command {
+	var all, dryRun bool
+	c := &cobra.Command{
+		Use:   "remove [options] BUILDPACK [...]",
+		Short: "Delete installed buildpacks",
+		Long: "remove deletes the installed buildpacks that match its arguments. An\n" +
+			"argument is a buildpack name optionally followed by a version or\n" +
+			"version range, like node, node:12.19.0, or node:12.x.",
+		Args:                  cobra.MinimumNArgs(1),
+		DisableFlagsInUseLine: true,
+		SilenceErrors:         true,
+		SilenceUsage:          true,
+		RunE: func(cc *cobra.Command, args []string) error {
+			homes, err := findToolHomes(all)
+			if err != nil {
+				return err
+			}
+			matched := false
+			err = removeTools(cc.Context(), os.Stdout, homes, dryRun, func(rec *buildpack.InstallRecord) (bool, error) {
+				for _, pattern := range args {
+					ok, err := rec.Match(pattern)
+					if err != nil {
+						return false, err
+					}
+					if ok {
+						matched = true
+						return true, nil
+					}
+				}
+				return false, nil
+			})
+			if err != nil {
+				return err
+			}
+			if !matched {
+				return fmt.Errorf("no installed buildpacks match %s", strings.Join(args, ", "))
+			}
+			return nil
+		},
+	}
+	allPackagesFlagVar(c, &all)
+	c.Flags().BoolVarP(&dryRun, "dry-run", "n", false, "Print the buildpacks that would be deleted without deleting them")
+	return c
+}
+
+func allPackagesFlagVar(c *cobra.Command, all *bool) {
+	c.Flags().BoolVar(all, "all", false, "Include the build homes of all packages, not just the current package")
+}
+
+// A toolHome is a build home with buildpacks installed in it.
+type toolHome struct {
+	// label identifies the build home in output.
+	label     string
+	inventory *buildpack.Inventory
+}
+
+// findToolHomes returns the build homes for the current package, or for all
+// packages if all is true.
+func findToolHomes(all bool) ([]toolHome, error) {
+	dataDirs, err := ybdata.DirsFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	var homes []string
+	if all {
+		homes, err = dataDirs.AllBuildHomes()
+	} else {
+		var pkg *yb.Package
+		pkg, _, err = findPackage()
+		if err != nil {
+			return nil, err
+		}
+		homes, err = dataDirs.BuildHomes(pkg.Path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	toolHomes := make([]toolHome, 0, len(homes))
+	for _, home := range homes {
+		// Build homes are TARGET/OS/ARCH inside a per-package directory.
+		label := filepath.ToSlash(home)
+		n := 3
+		if all {
+			n = 4
+		}
+		parts := strings.Split(label, "/")
+		if len(parts) > n {
+			label = strings.Join(parts[len(parts)-n:], "/")
+		}
+		toolHomes = append(toolHomes, toolHome{
+			label:     label,
+			inventory: &buildpack.Inventory{Dir: ybdata.BuildHomeTools(home)},
+		})
+	}
+	return toolHomes, nil
+}
+
+func listTools(out io.Writer, homes []toolHome) error {
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TOOL\tVERSION\tSIZE\tLAST USED\tHOME")
+	for _, home := range homes {
+		records, err := home.inventory.List()
+		if err != nil {
+			return fmt.Errorf("%s: %w", home.label, err)
+		}
+		for _, rec := range records {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n",
+				rec.Spec.Name(),
+				rec.Spec.Version(),
+				formatSize(rec.Size),
+				rec.LastUsed.Local().Format("2006-01-02 15:04"),
+				home.label,
+			)
+		}
+	}
+	return tw.Flush()
+}
+
+// removeTools deletes the installed buildpacks for which match returns true.
+func removeTools(ctx context.Context, out io.Writer, homes []toolHome, dryRun bool, match func(*buildpack.InstallRecord) (bool, error)) error {
+	var freed int64
+	ok := true
+	for _, home := range homes {
+		records, err := home.inventory.List()
+		if err != nil {
+			return fmt.Errorf("%s: %w", home.label, err)
+		}
+		for _, rec := range records {
+			remove, err := match(rec)
+			if err != nil {
+				return err
+			}
+			if !remove {
+				continue
+			}
+			if dryRun {
+				fmt.Fprintf(out, "Would remove %s (%s) from %s\n", rec.Spec, formatSize(rec.Size), home.label)
+				freed += rec.Size
+				continue
+			}
+			if err := home.inventory.Remove(rec); err != nil {
+				log.Errorf(ctx, "%s: %v", home.label, err)
+				ok = false
+				continue
+			}
+			fmt.Fprintf(out, "Removed %s (%s) from %s\n", rec.Spec, formatSize(rec.Size), home.label)
+			freed += rec.Size
+		}
+	}
+	if dryRun {
+		fmt.Fprintf(out, "Would free %s\n", formatSize(freed))
+	} else {
+		fmt.Fprintf(out, "Freed %s\n", formatSize(freed))
+	}
+	if !ok {
+		return errors.New("failed to remove some buildpacks")
+	}
+	return nil
+}
+
+type toolsPrefetchCmd struct {
+	cfg         config.Getter
+	targetNames []string
+	netrcFiles  []string
+	mode        executionMode
+}
+
+func newToolsPrefetchCmd(cfg config.Getter) *cobra.Command {
+	cmd := &toolsPrefetchCmd{cfg: cfg}
+	c := &cobra.Command{
+		Use:   "prefetch [options] [TARGET [...]]",
+		Short: "Download and install buildpacks ahead of time",
+		Long: "prefetch installs the buildpacks for the given targets and the targets\n" +
+			"they depend on into their build homes without building anything. If no\n" +
+			"targets are given, every target in the package is prefetched. This is\n" +
+			"useful for preparing CI images. If " + yb.LockFilename + " exists, the locked\n" +
+			"versions are installed.",
+		Args:                  cobra.ArbitraryArgs,
+		DisableFlagsInUseLine: true,
+		SilenceErrors:         true,
+		SilenceUsage:          true,
+		RunE: func(cc *cobra.Command, args []string) error {
+			cmd.targetNames = args
+			return cmd.run(cc.Context())
+		},
+		ValidArgsFunction: func(cc *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			return autocompleteTargetName(toComplete)
+		},
+	}
+	netrcFlagVar(c.Flags(), &cmd.netrcFiles)
+	executionModeVar(c.Flags(), &cmd.mode)
+	return c
+}
+
+func (cmd *toolsPrefetchCmd) run(ctx context.Context) error {
+	dataDirs, err := ybdata.DirsFromEnv()
+	if err != nil {
+		return err
+	}
+	// Hold a shared lock on the cache so that other yb processes don't trim
+	// it while this one uses it.
+	cacheLock, err := dataDirs.LockCache()
+	if err != nil {
+		return err
+	}
+	defer cacheLock.Unlock()
+	dockerClient, err := connectDockerClient(cmd.mode)
+	if err != nil {
+		return err
+	}
+	ctx = withLogOutput(ctx, os.Stdout)
+	pkg, _, err := findPackage()
+	if err != nil {
+		return err
+	}
+	names := cmd.targetNames
+	if len(names) == 0 {
+		names = listTargetNames(pkg.Targets)
+	}
+	desired := make([]*yb.Target, 0, len(names))
+	for _, name := range names {
+		target := pkg.Targets[name]
+		if target == nil {
+			return fmt.Errorf("%s: no such target (found: %s)", name, strings.Join(listTargetNames(pkg.Targets), ", "))
+		}
+		desired = append(desired, target)
+	}
+	targets := yb.BuildOrder(desired...)
+	showDockerWarningsIfNeeded(ctx, cmd.mode, targets)
+	buildpacks, err := buildpackDefinitions(pkg)
+	if err != nil {
+		return err
+	}
+	mirrors, err := downloadMirrors(cmd.cfg, pkg)
+	if err != nil {
+		return err
+	}
+	remoteCache, err := config.RemoteCache(cmd.cfg)
+	if err != nil {
+		return err
+	}
+	downloader := newDownloader(dataDirs, mirrors, remoteCache)
+	lock, err := loadBuildLock(ctx, pkg, targets, false, nil)
+	if err != nil {
+		return err
+	}
+	if lock != nil {
+		downloader.Checksums = lock.Checksums()
+	}
+
+	dockerNetworkID, removeNetwork, err := newDockerNetwork(ctx, dockerClient, cmd.mode, targets)
+	if err != nil {
+		return err
+	}
+	defer removeNetwork()
+	for _, target := range targets {
+		if lock != nil {
+			target, err = pinTarget(lock, target)
+			if err != nil {
+				return err
+			}
+			if dockerClient != nil {
+				useContainer := willUseDockerForCommands(cmd.mode, []*yb.Target{target})
+				if err := pullPinnedImages(ctx, dockerClient, target, useContainer); err != nil {
+					return fmt.Errorf("prefetch target %s: %w", target.Name, err)
+				}
+			}
+		}
+		err := prefetchTarget(withLogPrefix(ctx, target.Name), pkg, target, &toolsPrefetchOptions{
+			dataDirs:        dataDirs,
+			downloader:      downloader,
+			buildpacks:      buildpacks,
+			netrcFiles:      cmd.netrcFiles,
+			executionMode:   cmd.mode,
+			dockerClient:    dockerClient,
+			dockerNetworkID: dockerNetworkID,
+		})
+		if err != nil {
+			return fmt.Errorf("prefetch target %s: %w", target.Name, err)
+		}
+	}
+	return nil
+}
+
+type toolsPrefetchOptions struct {
+	dataDirs        *ybdata.Dirs
+	downloader      *ybdata.Downloader
+	buildpacks      map[string]*yb.BuildpackDefinition
+	netrcFiles      []string
+	executionMode   executionMode
+	dockerClient    *docker.Client
+	dockerNetworkID string
+}
+
+// prefetchTarget installs the target's buildpacks into its build home.
+func prefetchTarget(ctx context.Context, pkg *yb.Package, target *yb.Target, opts *toolsPrefetchOptions) error {
+	if len(target.Buildpacks) == 0 {
+		log.Infof(ctx, "No buildpacks to install")
+		return nil
+	}
+	bio, err := newBiome(ctx, target, newBiomeOptions{
+		packageDir:      pkg.Path,
+		dataDirs:        opts.dataDirs,
+		downloader:      opts.downloader,
+		netrcFiles:      opts.netrcFiles,
+		executionMode:   opts.executionMode,
+		dockerClient:    opts.dockerClient,
+		dockerNetworkID: opts.dockerNetworkID,
+	})
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := bio.Close(); err != nil {
+			log.Warnf(ctx, "Clean up environment: %v", err)
+		}
+	}()
+	names := make([]string, 0, len(target.Buildpacks))
+	for name := range target.Buildpacks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	specs := make([]yb.BuildpackSpec, 0, len(names))
+	for _, name := range names {
+		specs = append(specs, target.Buildpacks[name])
+	}
+	output := newLinePrefixWriter(os.Stdout, target.Name)
+	sys := buildpack.Sys{
+		Biome:             bio,
+		Downloader:        opts.downloader,
+		DockerClient:      opts.dockerClient,
+		DockerNetworkID:   opts.dockerNetworkID,
+		Definitions:       opts.buildpacks,
+		AndroidComponents: target.AndroidComponents,
+		Stdout:            output,
+		Stderr:            output,
+		Parallelism:       setupParallelism,
+		LogPrefix:         withBuildpackLogPrefix,
+		Inventory:         toolInventory(opts.dataDirs, pkg.Path, target, bio),
+	}
+	_, err = buildpack.InstallAll(ctx, sys, specs)
+	return err
+}
+
+// parseAge parses a duration that may also use days ("30d") or weeks ("2w").
+func parseAge(s string) (time.Duration, error) {
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if !strings.HasSuffix(s, suffix) {
+			continue
+		}
+		n, err := strconv.ParseFloat(strings.TrimSuffix(s, suffix), 64)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		return time.Duration(n * float64(unit)), nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, err
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+	return d, nil
+}
+
+// formatSize formats a number of bytes for display.
+func formatSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
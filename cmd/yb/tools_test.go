@@ -0,0 +1,75 @@
+// Copyright 2020 YourBase Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseAge(t *testing.T) {
+	tests := []struct {
+		s       string
+		want    time.Duration
+		wantErr bool
+	}{
+		{s: "30d", want: 30 * 24 * time.Hour},
+		{s: "2w", want: 14 * 24 * time.Hour},
+		{s: "1.5d", want: 36 * time.Hour},
+		{s: "12h", want: 12 * time.Hour},
+		{s: "90m", want: 90 * time.Minute},
+		{s: "-1d", wantErr: true},
+		{s: "-1h", wantErr: true},
+		{s: "d", wantErr: true},
+		{s: "30", wantErr: true},
+	}
+	for _, test := range tests {
+		got, err := parseAge(test.s)
+		if err != nil {
+			if !test.wantErr {
+				t.Errorf("parseAge(%q): %v", test.s, err)
+			}
+			continue
+		}
+		if test.wantErr {
+			t.Errorf("parseAge(%q) = %v, <nil>; want error", test.s, got)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("parseAge(%q) = %v; want %v", test.s, got, test.want)
+		}
+	}
+}
+
+func TestFormatSize(t *testing.T) {
+	tests := []struct {
+		n    int64
+		want string
+	}{
+		{0, "0 B"},
+		{1023, "1023 B"},
+		{1024, "1.0 KiB"},
+		{1536, "1.5 KiB"},
+		{5 << 20, "5.0 MiB"},
+		{3 << 30, "3.0 GiB"},
+	}
+	for _, test := range tests {
+		if got := formatSize(test.n); got != test.want {
+			t.Errorf("formatSize(%d) = %q; want %q", test.n, got, test.want)
+		}
+	}
+}
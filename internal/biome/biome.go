@@ -18,12 +18,14 @@
 package biome
 
 import (
+	"archive/tar"
 	"context"
 	"errors"
 	"fmt"
 	"io"
 	"os"
 	"os/exec"
+	slashpath "path"
 	"path/filepath"
 	"runtime"
 	"strings"
@@ -278,6 +280,144 @@ func (l Local) MkdirAll(ctx context.Context, path string) error {
 	return os.MkdirAll(AbsPath(l, path), 0777)
 }
 
+// WriteTree writes the files in the tar stream src into the directory dir.
+// See the WriteTree function for details.
+func (l Local) WriteTree(ctx context.Context, dir string, src io.Reader) error {
+	root := AbsPath(l, dir)
+	if err := os.MkdirAll(root, 0o777); err != nil {
+		return fmt.Errorf("write tree %s: %w", dir, err)
+	}
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return fmt.Errorf("write tree %s: %w", dir, err)
+	}
+	// Directory permissions are applied after all entries are written so that
+	// read-only directories can still be populated.
+	type dirMode struct {
+		path string
+		mode os.FileMode
+	}
+	var dirModes []dirMode
+	var checker treeChecker
+	tr := tar.NewReader(src)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("write tree %s: %w", dir, err)
+		}
+		if hdr.Typeflag == tar.TypeXGlobalHeader {
+			continue
+		}
+		name, err := checker.check(hdr)
+		if err != nil {
+			return fmt.Errorf("write tree %s: %w", dir, err)
+		}
+		dst := filepath.Join(root, filepath.FromSlash(name))
+		perm := hdr.FileInfo().Mode().Perm()
+		// Directories are created and chmod-ed through an existing entry,
+		// while anything else replaces it.
+		checkPath := dst
+		if hdr.Typeflag != tar.TypeDir {
+			checkPath = filepath.Dir(dst)
+		}
+		if err := checkLocalTreePath(root, realRoot, checkPath); err != nil {
+			return fmt.Errorf("write tree %s: %w", dir, err)
+		}
+		if hdr.Typeflag != tar.TypeDir {
+			if err := os.MkdirAll(filepath.Dir(dst), 0o777); err != nil {
+				return fmt.Errorf("write tree %s: %w", dir, err)
+			}
+			// Never write through an existing file, since it may be a symlink.
+			if err := os.Remove(dst); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("write tree %s: %w", dir, err)
+			}
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(dst, 0o777); err != nil {
+				return fmt.Errorf("write tree %s: %w", dir, err)
+			}
+			dirModes = append(dirModes, dirMode{dst, perm})
+		case tar.TypeReg, tar.TypeRegA:
+			if err := writeLocalFile(dst, tr, perm); err != nil {
+				return fmt.Errorf("write tree %s: %w", dir, err)
+			}
+		case tar.TypeSymlink:
+			if err := os.Symlink(filepath.FromSlash(hdr.Linkname), dst); err != nil {
+				return fmt.Errorf("write tree %s: %w", dir, err)
+			}
+		case tar.TypeLink:
+			target := filepath.Join(root, filepath.FromSlash(slashpath.Clean(hdr.Linkname)))
+			if err := checkLocalTreePath(root, realRoot, filepath.Dir(target)); err != nil {
+				return fmt.Errorf("write tree %s: %w", dir, err)
+			}
+			if err := os.Link(target, dst); err != nil {
+				return fmt.Errorf("write tree %s: %w", dir, err)
+			}
+		}
+	}
+	for i := len(dirModes) - 1; i >= 0; i-- {
+		if err := os.Chmod(dirModes[i].path, dirModes[i].mode); err != nil {
+			return fmt.Errorf("write tree %s: %w", dir, err)
+		}
+	}
+	return nil
+}
+
+// checkLocalTreePath returns an error if path, which is inside root, passes
+// through a symbolic link on disk that resolves outside of root. realRoot is
+// root with its symbolic links evaluated.
+func checkLocalTreePath(root, realRoot, path string) error {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return err
+	}
+	if rel == "." {
+		return nil
+	}
+	p := root
+	for _, elem := range strings.Split(rel, string(filepath.Separator)) {
+		p = filepath.Join(p, elem)
+		info, err := os.Lstat(p)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if info.Mode()&os.ModeSymlink == 0 {
+			continue
+		}
+		resolved, err := filepath.EvalSymlinks(p)
+		if err != nil {
+			return err
+		}
+		if r, err := filepath.Rel(realRoot, resolved); err != nil || r == ".." || strings.HasPrefix(r, ".."+string(filepath.Separator)) {
+			return fmt.Errorf("%s: symbolic link points outside directory", p)
+		}
+	}
+	return nil
+}
+
+func writeLocalFile(path string, src io.Reader, perm os.FileMode) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return err
+	}
+	_, writeErr := io.Copy(f, src)
+	closeErr := f.Close()
+	if writeErr != nil {
+		return fmt.Errorf("write file %s: %w", path, writeErr)
+	}
+	if closeErr != nil {
+		return fmt.Errorf("write file %s: %w", path, closeErr)
+	}
+	return nil
+}
+
 // EvalSymlinks calls filepath.EvalSymlinks.
 func (l Local) EvalSymlinks(ctx context.Context, path string) (string, error) {
 	return filepath.EvalSymlinks(AbsPath(l, path))
@@ -319,6 +459,11 @@ func (ep ExecPrefix) MkdirAll(ctx context.Context, path string) error {
 	return forwardMkdirAll(ctx, ep.Biome, path)
 }
 
+// WriteTree calls ep.Context.WriteTree or returns ErrUnsupported if not present.
+func (ep ExecPrefix) WriteTree(ctx context.Context, dir string, src io.Reader) error {
+	return forwardWriteTree(ctx, ep.Biome, dir, src)
+}
+
 // EvalSymlinks calls ep.Context.EvalSymlinks or returns ErrUnsupported if not present.
 func (ep ExecPrefix) EvalSymlinks(ctx context.Context, path string) (string, error) {
 	return forwardEvalSymlinks(ctx, ep.Biome, path)
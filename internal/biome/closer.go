@@ -55,6 +55,10 @@ func (n nopCloser) MkdirAll(ctx context.Context, path string) error {
 	return forwardMkdirAll(ctx, n.Biome, path)
 }
 
+func (n nopCloser) WriteTree(ctx context.Context, dir string, src io.Reader) error {
+	return forwardWriteTree(ctx, n.Biome, dir, src)
+}
+
 func (n nopCloser) EvalSymlinks(ctx context.Context, path string) (string, error) {
 	return forwardEvalSymlinks(ctx, n.Biome, path)
 }
@@ -98,6 +102,10 @@ func (c closer) MkdirAll(ctx context.Context, path string) error {
 	return forwardMkdirAll(ctx, c.BiomeCloser, path)
 }
 
+func (c closer) WriteTree(ctx context.Context, dir string, src io.Reader) error {
+	return forwardWriteTree(ctx, c.BiomeCloser, dir, src)
+}
+
 func (c closer) EvalSymlinks(ctx context.Context, path string) (string, error) {
 	return forwardEvalSymlinks(ctx, c.BiomeCloser, path)
 }
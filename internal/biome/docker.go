@@ -18,10 +18,11 @@ package biome
 
 import (
 	"archive/tar"
+	"bytes"
 	"context"
 	"crypto/rand"
-	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -51,12 +52,21 @@ type Container struct {
 	dirs   Dirs
 }
 
-const (
-	TiniURL    = "https://github.com/krallin/tini/releases/download/v0.19.0/tini-amd64"
-	tiniSize   = 24064
-	tiniSHA256 = "93dcc18adc78c65a028a84799ecf8ad40c936fdfc5f2a57b1acda5a8117fa82c"
-	tiniPath   = "/tini"
-)
+const tiniPath = "/tini"
+
+// TiniURL returns the URL of the tini executable for containers on the given
+// platform. tini publishes the SHA-256 digest of each executable at the URL
+// with ".sha256sum" appended.
+func TiniURL(desc *Descriptor) (string, error) {
+	asset := map[Descriptor]string{
+		{OS: Linux, Arch: Intel64}: "tini-amd64",
+		{OS: Linux, Arch: ARM64}:   "tini-arm64",
+	}[*desc]
+	if asset == "" {
+		return "", fmt.Errorf("tini: unsupported platform %s/%s", desc.OS, desc.Arch)
+	}
+	return "https://github.com/krallin/tini/releases/download/v0.19.0/" + asset, nil
+}
 
 // ContainerOptions holds parameters for CreateContainer.
 type ContainerOptions struct {
@@ -66,7 +76,8 @@ type ContainerOptions struct {
 	// HomeDir is the path on the host to use as the biome's home directory.
 	// Must not be empty and the directory must exist.
 	HomeDir string
-	// TiniExe is a stream of TiniURL's contents. Must be non-nil.
+	// TiniExe is a stream of the contents of TiniURL for the Docker daemon's
+	// platform. The caller is responsible for verifying it. Must be non-nil.
 	TiniExe io.Reader
 
 	// PullOutput is where any `docker pull` progress output is sent to.
@@ -212,19 +223,21 @@ func makeMount(hostPath string) error {
 }
 
 func uploadTini(ctx context.Context, client *docker.Client, containerID string, tiniExe io.Reader) error {
-	sha256Hash := sha256.New()
+	// The size must be known ahead of time for the archive header, and tini is
+	// small enough to hold in memory.
+	tini, err := ioutil.ReadAll(tiniExe)
+	if err != nil {
+		return fmt.Errorf("upload tini: %w", err)
+	}
 	tiniHeader := &tar.Header{
-		Size:     tiniSize,
+		Size:     int64(len(tini)),
 		Typeflag: tar.TypeReg,
 		Mode:     0777,
 	}
-	err := narwhal.Upload(ctx, client, containerID, tiniPath, io.TeeReader(tiniExe, sha256Hash), tiniHeader)
+	err = narwhal.Upload(ctx, client, containerID, tiniPath, bytes.NewReader(tini), tiniHeader)
 	if err != nil {
 		return fmt.Errorf("upload tini: %w", err)
 	}
-	if hex.EncodeToString(sha256Hash.Sum(nil)) != tiniSHA256 {
-		return fmt.Errorf("upload tini: checksum mismatch")
-	}
 	return nil
 }
 
@@ -420,6 +433,83 @@ func (c *Container) WriteFile(ctx context.Context, path string, src io.Reader) e
 	})
 }
 
+// WriteTree writes the files in the tar stream src into the directory dir in
+// the container with a single upload. See the WriteTree function for details.
+func (c *Container) WriteTree(ctx context.Context, dir string, src io.Reader) error {
+	dir = AbsPath(c, dir)
+	if err := c.MkdirAll(ctx, dir); err != nil {
+		return fmt.Errorf("write tree %s: %w", dir, err)
+	}
+	// Validate entries as they are streamed to Docker. Ownership is reset so
+	// files are owned by the container's user rather than the archive's.
+	pr, pw := io.Pipe()
+	copyDone := make(chan struct{})
+	go func() {
+		defer close(copyDone)
+		pw.CloseWithError(copyTree(tar.NewWriter(pw), tar.NewReader(src)))
+	}()
+	err := c.client.UploadToContainer(c.id, docker.UploadToContainerOptions{
+		Context:     ctx,
+		InputStream: pr,
+		Path:        dir,
+	})
+	pr.CloseWithError(errors.New("upload finished"))
+	<-copyDone
+	if err != nil {
+		return fmt.Errorf("write tree %s: %w", dir, err)
+	}
+	return nil
+}
+
+// copyTree copies the entries of a tar stream given to WriteTree, returning an
+// error for any entry that would escape the destination directory.
+func copyTree(tw *tar.Writer, tr *tar.Reader) error {
+	var checker treeChecker
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return tw.Close()
+		}
+		if err != nil {
+			return err
+		}
+		if hdr.Typeflag == tar.TypeXGlobalHeader {
+			continue
+		}
+		name, err := checker.check(hdr)
+		if err != nil {
+			return err
+		}
+		if name == "." {
+			continue
+		}
+		hdr2 := &tar.Header{
+			Typeflag: hdr.Typeflag,
+			Name:     name,
+			Linkname: hdr.Linkname,
+			Size:     hdr.Size,
+			Mode:     hdr.Mode & 0o777,
+			ModTime:  hdr.ModTime,
+		}
+		switch hdr.Typeflag {
+		case tar.TypeRegA:
+			hdr2.Typeflag = tar.TypeReg
+		case tar.TypeLink:
+			hdr2.Linkname = slashpath.Clean(hdr.Linkname)
+		case tar.TypeDir:
+			hdr2.Name += "/"
+		}
+		if err := tw.WriteHeader(hdr2); err != nil {
+			return err
+		}
+		if hdr2.Typeflag == tar.TypeReg {
+			if _, err := io.Copy(tw, tr); err != nil {
+				return err
+			}
+		}
+	}
+}
+
 // MkdirAll ensures the given directory and any parent directories exist in
 // the container.
 func (c *Container) MkdirAll(ctx context.Context, path string) error {
@@ -35,6 +35,24 @@ var _ interface {
 	dirMaker
 } = new(Container)
 
+func TestTiniURL(t *testing.T) {
+	tests := []struct {
+		desc    Descriptor
+		want    string
+		wantErr bool
+	}{
+		{desc: Descriptor{OS: Linux, Arch: Intel64}, want: "https://github.com/krallin/tini/releases/download/v0.19.0/tini-amd64"},
+		{desc: Descriptor{OS: Linux, Arch: ARM64}, want: "https://github.com/krallin/tini/releases/download/v0.19.0/tini-arm64"},
+		{desc: Descriptor{OS: Windows, Arch: Intel64}, wantErr: true},
+	}
+	for _, test := range tests {
+		got, err := TiniURL(&test.desc)
+		if got != test.want || (err != nil) != test.wantErr {
+			t.Errorf("TiniURL(%+v) = %q, %v; want %q, error = %t", test.desc, got, err, test.want, test.wantErr)
+		}
+	}
+}
+
 func TestContainer(t *testing.T) {
 	if testing.Short() {
 		t.Skip("Skipping Docker test due to -short")
@@ -60,7 +78,15 @@ func TestContainer(t *testing.T) {
 	if err := ioutil.WriteFile(filepath.Join(mountDir, fname2), []byte(want2), 0666); err != nil {
 		t.Fatal(err)
 	}
-	tiniResp, err := http.Get(TiniURL)
+	desc, err := DockerDescriptor(ctx, dockerClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tiniURL, err := TiniURL(desc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tiniResp, err := http.Get(tiniURL)
 	if err != nil {
 		t.Fatal(err)
 	}
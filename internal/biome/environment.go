@@ -156,6 +156,11 @@ func (eb EnvBiome) MkdirAll(ctx context.Context, path string) error {
 	return forwardMkdirAll(ctx, eb.Biome, path)
 }
 
+// WriteTree calls eb.Context.WriteTree or returns ErrUnsupported if not present.
+func (eb EnvBiome) WriteTree(ctx context.Context, dir string, src io.Reader) error {
+	return forwardWriteTree(ctx, eb.Biome, dir, src)
+}
+
 // EvalSymlinks calls eb.Context.EvalSymlinks or returns ErrUnsupported if not present.
 func (eb EnvBiome) EvalSymlinks(ctx context.Context, path string) (string, error) {
 	return forwardEvalSymlinks(ctx, eb.Biome, path)
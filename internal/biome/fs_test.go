@@ -256,8 +256,13 @@ func (unsupported) EvalSymlinks(ctx context.Context, path string) (string, error
 	return "", fmt.Errorf("eval symlinks %s: %w", path, ErrUnsupported)
 }
 
+func (unsupported) WriteTree(ctx context.Context, dir string, src io.Reader) error {
+	return fmt.Errorf("write tree %s: %w", dir, ErrUnsupported)
+}
+
 var _ interface {
 	fileWriter
 	dirMaker
 	symlinkEvaler
+	treeWriter
 } = unsupported{}
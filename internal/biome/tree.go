@@ -0,0 +1,175 @@
+// Copyright 2020 YourBase Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package biome
+
+import (
+	"archive/tar"
+	"context"
+	"fmt"
+	"io"
+	slashpath "path"
+	"strings"
+)
+
+type treeWriter interface {
+	WriteTree(ctx context.Context, dir string, src io.Reader) error
+}
+
+// WriteTree writes the files in the tar stream src into the directory dir in
+// the biome, creating dir if necessary. Paths are resolved relative to the
+// package directory. Directories, regular files, symbolic links, and hard links
+// are supported. Permission bits are preserved, but ownership is not.
+//
+// Entries must stay inside dir: WriteTree returns an error for any entry with
+// an absolute path, a path containing "..", a symbolic link that points
+// outside of dir or that follows another symbolic link in the stream with
+// "..", or a path that passes through a symbolic link created earlier in the
+// stream. The Local biome also refuses to write through symbolic links already
+// in dir that point outside of it. Entries before the offending one may already
+// have been written.
+//
+// If the biome has a method
+// `WriteTree(ctx context.Context, dir string, src io.Reader) error`,
+// that will be used. Unlike the other functions in this package, WriteTree has
+// no fallback: if the biome does not have such a method, WriteTree returns an
+// error wrapping ErrUnsupported without reading from src.
+func WriteTree(ctx context.Context, bio Biome, dir string, src io.Reader) error {
+	return forwardWriteTree(ctx, bio, dir, src)
+}
+
+func forwardWriteTree(ctx context.Context, bio Biome, dir string, src io.Reader) error {
+	writer, ok := bio.(treeWriter)
+	if !ok {
+		return fmt.Errorf("write tree %s: %w", dir, ErrUnsupported)
+	}
+	return writer.WriteTree(ctx, dir, src)
+}
+
+// treeChecker validates the entries of a tar stream given to WriteTree.
+// The zero value is an empty tree.
+type treeChecker struct {
+	symlinks map[string]struct{}
+	// exited is the set of paths that symbolic link targets leave with "..".
+	// The operating system resolves ".." against the directory a symbolic link
+	// points to, so none of these may become symbolic links.
+	exited map[string]struct{}
+}
+
+// check returns the cleaned, slash-separated path of the entry relative to
+// the tree root or an error if writing the entry could escape the tree.
+// A path of "." refers to the root itself.
+func (tc *treeChecker) check(hdr *tar.Header) (string, error) {
+	name, err := tc.checkPath(hdr.Name)
+	if err != nil {
+		return "", err
+	}
+	switch hdr.Typeflag {
+	case tar.TypeDir:
+		if tc.isSymlink(name) {
+			return "", fmt.Errorf("%s: directory replaces symbolic link", hdr.Name)
+		}
+	case tar.TypeReg, tar.TypeRegA:
+		tc.forget(name)
+	case tar.TypeSymlink:
+		if name == "." {
+			return "", fmt.Errorf("%s: root is a symbolic link", hdr.Name)
+		}
+		target := hdr.Linkname
+		if target == "" || slashpath.IsAbs(target) {
+			return "", fmt.Errorf("%s: symbolic link to %q is not relative", hdr.Name, target)
+		}
+		if _, exited := tc.exited[name]; exited {
+			return "", fmt.Errorf("%s: symbolic link replaces a directory that an earlier symbolic link leaves with \"..\"", hdr.Name)
+		}
+		if err := tc.checkLinkTarget(name, target); err != nil {
+			return "", fmt.Errorf("%s: symbolic link to %q %w", hdr.Name, target, err)
+		}
+		if tc.symlinks == nil {
+			tc.symlinks = make(map[string]struct{})
+		}
+		tc.symlinks[name] = struct{}{}
+	case tar.TypeLink:
+		if _, err := tc.checkPath(hdr.Linkname); err != nil {
+			return "", fmt.Errorf("%s: hard link: %w", hdr.Name, err)
+		}
+		tc.forget(name)
+	default:
+		return "", fmt.Errorf("%s: unsupported file type %q", hdr.Name, hdr.Typeflag)
+	}
+	return name, nil
+}
+
+// checkPath cleans an entry path and verifies that it is inside the tree and
+// does not pass through a symbolic link.
+func (tc *treeChecker) checkPath(p string) (string, error) {
+	if slashpath.IsAbs(p) || strings.Contains(p, `\`) {
+		return "", fmt.Errorf("%s: path not relative", p)
+	}
+	name := slashpath.Clean(p)
+	if escapesTree(name) {
+		return "", fmt.Errorf("%s: path outside directory", p)
+	}
+	for dir := slashpath.Dir(name); dir != "."; dir = slashpath.Dir(dir) {
+		if tc.isSymlink(dir) {
+			return "", fmt.Errorf("%s: path passes through symbolic link %s", p, dir)
+		}
+	}
+	return name, nil
+}
+
+// checkLinkTarget walks the target of the symbolic link at name one element at
+// a time and returns an error if it points outside the tree or leaves a
+// symbolic link with "..", which could resolve outside the tree even though
+// the path does not lexically.
+func (tc *treeChecker) checkLinkTarget(name, target string) error {
+	dir := slashpath.Dir(name)
+	for _, elem := range strings.Split(target, "/") {
+		switch elem {
+		case "", ".":
+		case "..":
+			if dir == "." {
+				return fmt.Errorf("points outside directory")
+			}
+			if tc.isSymlink(dir) {
+				return fmt.Errorf("leaves symbolic link %s with \"..\"", dir)
+			}
+			if tc.exited == nil {
+				tc.exited = make(map[string]struct{})
+			}
+			tc.exited[dir] = struct{}{}
+			dir = slashpath.Dir(dir)
+		default:
+			dir = slashpath.Join(dir, elem)
+		}
+	}
+	return nil
+}
+
+func (tc *treeChecker) isSymlink(name string) bool {
+	_, ok := tc.symlinks[name]
+	return ok
+}
+
+func (tc *treeChecker) forget(name string) {
+	delete(tc.symlinks, name)
+}
+
+// escapesTree reports whether a cleaned relative path refers to a location
+// above the tree root.
+func escapesTree(name string) bool {
+	return name == ".." || strings.HasPrefix(name, "../")
+}
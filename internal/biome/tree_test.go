@@ -0,0 +1,223 @@
+// Copyright 2020 YourBase Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package biome
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"zombiezen.com/go/log/testlog"
+)
+
+func TestWriteTree(t *testing.T) {
+	if runtime.GOOS == Windows {
+		t.Skip("Symbolic links and permissions not supported on Windows")
+	}
+	ctx := testlog.WithTB(context.Background(), t)
+	dir := t.TempDir()
+	bio := Local{
+		PackageDir: dir,
+		HomeDir:    t.TempDir(),
+	}
+	const content = "#!/bin/sh\necho Hello\n"
+	archive := makeTar(t, []*tar.Header{
+		{Typeflag: tar.TypeDir, Name: "bin/", Mode: 0o755},
+		{Typeflag: tar.TypeReg, Name: "bin/hello", Mode: 0o755},
+		{Typeflag: tar.TypeSymlink, Name: "hi", Linkname: "bin/hello"},
+		{Typeflag: tar.TypeLink, Name: "bin/hello2", Linkname: "bin/hello"},
+		{Typeflag: tar.TypeReg, Name: "lib/data.txt", Mode: 0o644},
+	}, content)
+	if err := WriteTree(ctx, bio, "out", bytes.NewReader(archive)); err != nil {
+		t.Fatal("WriteTree:", err)
+	}
+
+	for _, name := range []string{"bin/hello", "bin/hello2", "hi", "lib/data.txt"} {
+		got, err := ioutil.ReadFile(filepath.Join(dir, "out", filepath.FromSlash(name)))
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if string(got) != content {
+			t.Errorf("%s content = %q; want %q", name, got, content)
+		}
+	}
+	info, err := os.Stat(filepath.Join(dir, "out", "bin", "hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm()&0o100 == 0 {
+		t.Errorf("bin/hello mode = %v; want executable", info.Mode())
+	}
+	target, err := os.Readlink(filepath.Join(dir, "out", "hi"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if target != filepath.Join("bin", "hello") {
+		t.Errorf("hi -> %q; want %q", target, filepath.Join("bin", "hello"))
+	}
+}
+
+func TestWriteTreeRejectsEscape(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers []*tar.Header
+	}{
+		{
+			name:    "DotDot",
+			headers: []*tar.Header{{Typeflag: tar.TypeReg, Name: "../evil.txt"}},
+		},
+		{
+			name:    "NestedDotDot",
+			headers: []*tar.Header{{Typeflag: tar.TypeReg, Name: "foo/../../evil.txt"}},
+		},
+		{
+			name:    "Absolute",
+			headers: []*tar.Header{{Typeflag: tar.TypeReg, Name: "/tmp/evil.txt"}},
+		},
+		{
+			name:    "AbsoluteSymlink",
+			headers: []*tar.Header{{Typeflag: tar.TypeSymlink, Name: "link", Linkname: "/etc"}},
+		},
+		{
+			name:    "EscapingSymlink",
+			headers: []*tar.Header{{Typeflag: tar.TypeSymlink, Name: "foo/link", Linkname: "../.."}},
+		},
+		{
+			name: "ThroughSymlink",
+			headers: []*tar.Header{
+				{Typeflag: tar.TypeSymlink, Name: "link", Linkname: "."},
+				{Typeflag: tar.TypeSymlink, Name: "link/up", Linkname: ".."},
+			},
+		},
+		{
+			name: "ChainedSymlink",
+			headers: []*tar.Header{
+				{Typeflag: tar.TypeSymlink, Name: "b", Linkname: "."},
+				{Typeflag: tar.TypeSymlink, Name: "a", Linkname: "b/../.."},
+				{Typeflag: tar.TypeReg, Name: "a/evil.txt"},
+			},
+		},
+		{
+			name: "SymlinkReplacesExitedDirectory",
+			headers: []*tar.Header{
+				{Typeflag: tar.TypeDir, Name: "d/x"},
+				{Typeflag: tar.TypeSymlink, Name: "a", Linkname: "d/x/../.."},
+				{Typeflag: tar.TypeSymlink, Name: "d/x", Linkname: ".."},
+			},
+		},
+		{
+			name:    "HardLinkOutside",
+			headers: []*tar.Header{{Typeflag: tar.TypeLink, Name: "link", Linkname: "../secret"}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := testlog.WithTB(context.Background(), t)
+			parent := t.TempDir()
+			dir := filepath.Join(parent, "pkg")
+			if err := os.Mkdir(dir, 0o777); err != nil {
+				t.Fatal(err)
+			}
+			bio := Local{
+				PackageDir: dir,
+				HomeDir:    t.TempDir(),
+			}
+			err := WriteTree(ctx, bio, "out", bytes.NewReader(makeTar(t, test.headers, "")))
+			if err == nil {
+				t.Fatal("WriteTree did not return an error")
+			}
+			t.Log(err)
+			if _, err := os.Lstat(filepath.Join(parent, "evil.txt")); err == nil {
+				t.Error("evil.txt written outside of directory")
+			}
+		})
+	}
+}
+
+func TestWriteTreeExistingSymlink(t *testing.T) {
+	if runtime.GOOS == Windows {
+		t.Skip("Symbolic links not supported on Windows")
+	}
+	ctx := testlog.WithTB(context.Background(), t)
+	parent := t.TempDir()
+	dir := filepath.Join(parent, "pkg")
+	if err := os.MkdirAll(filepath.Join(dir, "out"), 0o777); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join("..", ".."), filepath.Join(dir, "out", "up")); err != nil {
+		t.Fatal(err)
+	}
+	bio := Local{
+		PackageDir: dir,
+		HomeDir:    t.TempDir(),
+	}
+	archive := makeTar(t, []*tar.Header{
+		{Typeflag: tar.TypeReg, Name: "up/evil.txt"},
+	}, "")
+	err := WriteTree(ctx, bio, "out", bytes.NewReader(archive))
+	if err == nil {
+		t.Fatal("WriteTree did not return an error")
+	}
+	t.Log(err)
+	if _, err := os.Lstat(filepath.Join(parent, "evil.txt")); err == nil {
+		t.Error("evil.txt written outside of directory")
+	}
+}
+
+func TestWriteTreeUnsupported(t *testing.T) {
+	ctx := context.Background()
+	bio := unsupported{Local{
+		PackageDir: t.TempDir(),
+		HomeDir:    t.TempDir(),
+	}}
+	err := WriteTree(ctx, bio, "out", new(bytes.Buffer))
+	if !errors.Is(err, ErrUnsupported) {
+		t.Errorf("WriteTree(...) = %v; want %v", err, ErrUnsupported)
+	}
+}
+
+// makeTar returns a tar archive with the given entries. Regular files have
+// the given content.
+func makeTar(tb testing.TB, headers []*tar.Header, content string) []byte {
+	tb.Helper()
+	buf := new(bytes.Buffer)
+	tw := tar.NewWriter(buf)
+	for _, hdr := range headers {
+		if hdr.Typeflag == tar.TypeReg {
+			hdr.Size = int64(len(content))
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			tb.Fatal(err)
+		}
+		if hdr.Typeflag == tar.TypeReg {
+			if _, err := tw.Write([]byte(content)); err != nil {
+				tb.Fatal(err)
+			}
+		}
+	}
+	if err := tw.Close(); err != nil {
+		tb.Fatal(err)
+	}
+	return buf.Bytes()
+}
@@ -54,6 +54,7 @@ func Setup(ctx context.Context, sys Sys, target *yb.Target) (_ biome.BiomeCloser
 	}()
 
 	// Randomize pack setup order to surface unexpected data dependencies.
+	// Buildpacks are still installed after the buildpacks they require.
 	var packs []yb.BuildpackSpec
 	if len(target.Buildpacks) > 0 {
 		rng := rand.New(rand.NewSource(time.Now().UnixNano()))
@@ -67,16 +68,14 @@ func Setup(ctx context.Context, sys Sys, target *yb.Target) (_ biome.BiomeCloser
 	}
 
 	// Install all buildpacks.
+	sys.AndroidComponents = target.AndroidComponents
+	packEnv, err := buildpack.InstallAll(ctx, sys, packs)
+	if err != nil {
+		return nil, fmt.Errorf("setup %s: %w", target.Name, err)
+	}
 	newEnv := biome.Environment{
 		Vars: make(map[string]string),
-	}
-	for _, pack := range packs {
-		packEnv, err := buildpack.Install(ctx, sys, pack)
-		if err != nil {
-			return nil, fmt.Errorf("setup %s: %w", target.Name, err)
-		}
-		newEnv = newEnv.Merge(packEnv)
-	}
+	}.Merge(packEnv)
 
 	expContainers, closeFunc, err := startContainers(ctx, sys, target.Resources)
 	if err != nil {
@@ -10,18 +10,19 @@ import (
 	"zombiezen.com/go/log"
 )
 
-const anacondaDistMirrorTemplate = "https://repo.continuum.io/miniconda/Miniconda{{.PyMajor}}-{{.Version}}-{{.OS}}-{{.Arch}}.sh"
-const anacondaNewerDistMirrorTemplate = "https://repo.continuum.io/miniconda/Miniconda{{.PyMajor}}-py{{.PyMajor}}{{.PyMinor}}_{{.Version}}-{{.OS}}-{{.Arch}}.sh"
+const anacondaDistMirrorTemplate = "https://repo.continuum.io/miniconda/Miniconda{{.PyMajor}}-{{.Version}}-{{.Platform}}.sh"
+const anacondaNewerDistMirrorTemplate = "https://repo.continuum.io/miniconda/Miniconda{{.PyMajor}}-py{{.PyMajor}}{{.PyMinor}}_{{.Version}}-{{.Platform}}.sh"
 
 func installAnaconda2(ctx context.Context, sys Sys, spec yb.BuildpackSpec) (biome.Environment, error) {
-	return installAnaconda(ctx, sys, 2, spec.Version())
+	return installAnaconda(ctx, sys, spec, 2)
 }
 
 func installAnaconda3(ctx context.Context, sys Sys, spec yb.BuildpackSpec) (biome.Environment, error) {
-	return installAnaconda(ctx, sys, 3, spec.Version())
+	return installAnaconda(ctx, sys, spec, 3)
 }
 
-func installAnaconda(ctx context.Context, sys Sys, pyMajor int, version string) (biome.Environment, error) {
+func installAnaconda(ctx context.Context, sys Sys, spec yb.BuildpackSpec, pyMajor int) (biome.Environment, error) {
+	version := spec.Version()
 	anacondaRoot := sys.Biome.JoinPath(sys.Biome.Dirs().Tools, "miniconda")
 	anacondaDir := sys.Biome.JoinPath(anacondaRoot, fmt.Sprintf("miniconda-py%d-%s", pyMajor, version))
 
@@ -94,45 +95,35 @@ func installAnaconda(ctx context.Context, sys Sys, pyMajor int, version string)
 	if err != nil {
 		return biome.Environment{}, fmt.Errorf("configure miniconda: %w", err)
 	}
+	recordInstall(ctx, sys, spec, anacondaDir)
 	return env, nil
 }
 
 func anacondaDownloadURL(version string, pyMajor, pyMinor int, desc *biome.Descriptor) (string, error) {
-	var (
-		anacondaOSMap = map[string]string{
-			biome.MacOS: "MacOSX",
-			biome.Linux: "Linux",
-			// TODO(light): Omitting Windows, since the extension also needs to change to .exe.
-		}
-		anacondaArchMap = map[string]string{
-			biome.Intel64: "x86_64",
-			biome.Intel32: "x86",
-		}
-	)
 	v, err := semver.Parse(version)
 	if err != nil {
 		return "", fmt.Errorf("compute anaconda %s download url: %w", version, err)
 	}
-	opsys := anacondaOSMap[desc.OS]
-	if opsys == "" {
-		return "", fmt.Errorf("compute anaconda %s download url: not found for OS %s", version, desc.OS)
-	}
-	arch := anacondaArchMap[desc.Arch]
-	if arch == "" {
-		return "", fmt.Errorf("compute anaconda %s download url: not found for architecture %s", version, desc.Arch)
+	// TODO(light): Omitting Windows, since the extension also needs to change to .exe.
+	platform, err := platformName("Miniconda", map[biome.Descriptor]string{
+		{OS: biome.Linux, Arch: biome.Intel64}: "Linux-x86_64",
+		{OS: biome.Linux, Arch: biome.Intel32}: "Linux-x86",
+		{OS: biome.Linux, Arch: biome.ARM64}:   "Linux-aarch64",
+		{OS: biome.MacOS, Arch: biome.Intel64}: "MacOSX-x86_64",
+	}, desc)
+	if err != nil {
+		return "", fmt.Errorf("compute anaconda %s download url: %w", version, err)
 	}
 
 	data := struct {
-		PyMajor int
-		PyMinor int
-		OS      string
-		Arch    string
-		Version string
+		PyMajor  int
+		PyMinor  int
+		Platform string
+		Version  string
 	}{
 		pyMajor,
 		pyMinor,
-		opsys,
-		arch,
+		platform,
 		version,
 	}
 
@@ -64,6 +64,10 @@ func TestAnacondaDownloadURL(t *testing.T) {
 		OS:   biome.Linux,
 		Arch: biome.Intel64,
 	}
+	linuxARM := &biome.Descriptor{
+		OS:   biome.Linux,
+		Arch: biome.ARM64,
+	}
 	tests := []struct {
 		version   string
 		pyMajor   int
@@ -114,6 +118,33 @@ func TestAnacondaDownloadURL(t *testing.T) {
 			desc:    linux,
 			want:    "https://repo.continuum.io/miniconda/Miniconda2-4.7.10-Linux-x86_64.sh",
 		},
+		{
+			version: "4.9.2",
+			pyMajor: 3,
+			pyMinor: 8,
+			desc:    linuxARM,
+			want:    "https://repo.continuum.io/miniconda/Miniconda3-py38_4.9.2-Linux-aarch64.sh",
+		},
+		{
+			version: "4.9.2",
+			pyMajor: 3,
+			pyMinor: 8,
+			desc: &biome.Descriptor{
+				OS:   biome.MacOS,
+				Arch: biome.Intel64,
+			},
+			want: "https://repo.continuum.io/miniconda/Miniconda3-py38_4.9.2-MacOSX-x86_64.sh",
+		},
+		{
+			version: "4.9.2",
+			pyMajor: 3,
+			pyMinor: 8,
+			desc: &biome.Descriptor{
+				OS:   biome.MacOS,
+				Arch: biome.ARM64,
+			},
+			wantError: true,
+		},
 	}
 	for _, test := range tests {
 		got, err := anacondaDownloadURL(test.version, test.pyMajor, test.pyMinor, test.desc)
@@ -2,14 +2,13 @@ package buildpack
 
 import (
 	"context"
-	"fmt"
 
 	"github.com/yourbase/yb"
 	"github.com/yourbase/yb/internal/biome"
 	"zombiezen.com/go/log"
 )
 
-const androidNDKDistMirror = "https://dl.google.com/android/repository/android-ndk-{{.Version}}-{{.OS}}-{{.Arch}}.zip"
+const androidNDKDistMirror = "https://dl.google.com/android/repository/android-ndk-{{.Version}}-{{.Platform}}.zip"
 
 func installAndroidNDK(ctx context.Context, sys Sys, spec yb.BuildpackSpec) (biome.Environment, error) {
 	ndkDir := sys.Biome.JoinPath(sys.Biome.Dirs().Tools, "android-ndk", "android-ndk-"+spec.Version())
@@ -21,28 +20,11 @@ func installAndroidNDK(ctx context.Context, sys Sys, spec yb.BuildpackSpec) (bio
 
 	if _, err := biome.EvalSymlinks(ctx, sys.Biome, ndkDir); err == nil {
 		log.Infof(ctx, "Found Android NDK at %s", ndkDir)
+		recordInstall(ctx, sys, spec, ndkDir)
 		return env, nil
 	}
 
-	desc := sys.Biome.Describe()
-	data := struct {
-		OS      string
-		Arch    string
-		Version string
-	}{
-		map[string]string{
-			biome.Linux: "linux",
-			biome.MacOS: "darwin",
-		}[desc.OS],
-		map[string]string{
-			biome.Intel64: "x86_64",
-		}[desc.Arch],
-		spec.Version(),
-	}
-	if data.OS == "" || data.Arch == "" {
-		return biome.Environment{}, fmt.Errorf("unsupported os/arch %s/%s", desc.OS, desc.Arch)
-	}
-	downloadURL, err := templateToString(androidNDKDistMirror, data)
+	downloadURL, err := androidNDKDownloadURL(spec.Version(), sys.Biome.Describe())
 	if err != nil {
 		return biome.Environment{}, err
 	}
@@ -51,5 +33,20 @@ func installAndroidNDK(ctx context.Context, sys Sys, spec yb.BuildpackSpec) (bio
 	if err := extract(ctx, sys, ndkDir, downloadURL, stripTopDirectory); err != nil {
 		return biome.Environment{}, err
 	}
+	recordInstall(ctx, sys, spec, ndkDir)
 	return env, nil
 }
+
+func androidNDKDownloadURL(version string, desc *biome.Descriptor) (string, error) {
+	platform, err := platformName("The Android NDK", map[biome.Descriptor]string{
+		{OS: biome.Linux, Arch: biome.Intel64}: "linux-x86_64",
+		{OS: biome.MacOS, Arch: biome.Intel64}: "darwin-x86_64",
+	}, desc)
+	if err != nil {
+		return "", err
+	}
+	return templateToString(androidNDKDistMirror, struct {
+		Version  string
+		Platform string
+	}{version, platform})
+}
@@ -18,6 +18,7 @@ package buildpack
 
 import (
 	"context"
+	"net/http"
 	"testing"
 
 	"github.com/yourbase/yb/internal/biome"
@@ -36,3 +37,14 @@ func TestAndroidNDK(t *testing.T) {
 		t.Error("Find ndk-build:", err)
 	}
 }
+
+func TestAndroidNDKDownloadURL(t *testing.T) {
+	const version = "r21d"
+	testDownloadURLs(t, http.MethodHead, func(desc *biome.Descriptor) (string, error) {
+		return androidNDKDownloadURL(version, desc)
+	}, []downloadURLTest{
+		{os: biome.Linux, arch: biome.Intel64, want: "https://dl.google.com/android/repository/android-ndk-r21d-linux-x86_64.zip"},
+		{os: biome.Linux, arch: biome.ARM64},
+		{os: biome.MacOS, arch: biome.Intel64, want: "https://dl.google.com/android/repository/android-ndk-r21d-darwin-x86_64.zip"},
+	})
+}
@@ -3,6 +3,8 @@ package buildpack
 import (
 	"context"
 	"fmt"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/yourbase/yb"
@@ -10,59 +12,158 @@ import (
 	"zombiezen.com/go/log"
 )
 
-const latestAndroidVersion = "4333796"
+const latestAndroidVersion = "11076708"
+
+// lastAndroidSDKToolsVersion is the build number of the final sdk-tools
+// release. Later builds are published as cmdline-tools, which use a different
+// archive name and directory layout.
+const lastAndroidSDKToolsVersion = 4333796
+
+// androidLicenses maps the name of each Android SDK license file to the
+// hashes of the license texts that are considered accepted. sdkmanager refuses
+// to install a package whose license hash is not listed, so new hashes should
+// be appended here when Google revises a license.
+var androidLicenses = map[string][]string{
+	"android-googletv-license": {
+		"601085b94cd77f0b54ff86406957099ebe79c4d6",
+	},
+	"android-sdk-arm-dbt-license": {
+		"859f317696f67ef3d7f30a50a5560e7834b43903",
+	},
+	"android-sdk-license": {
+		"24333f8a63b6825ea9c5514f83c2829b004d1fee",
+		"8933bad161af4178b1185d1a37fbf41ea5269c55",
+		"d56f5187479451eabf01fb78af6dfcb131a6481e",
+	},
+	"android-sdk-preview-license": {
+		"84831b9409646a918e30573bab4c9c91346d8abd",
+		"504667f4c0de7af1a06de9f4b1727b84351f2910",
+	},
+	"google-gdk-license": {
+		"33b6a2b64607f11b759f320ef9dff4ae5c47d97a",
+	},
+	"intel-android-extra-license": {
+		"d975f751698a77b662f1254ddbeed3901e976f5a",
+	},
+	"mips-android-sysimage-license": {
+		"e9acab5b5fbb560a72cfaecce8946896ff6aab9d",
+	},
+}
 
 func installAndroidSDK(ctx context.Context, sys Sys, spec yb.BuildpackSpec) (biome.Environment, error) {
-	version := spec.Version()
-	if version == "latest" {
-		version = latestAndroidVersion
-	}
+	version := aliasedVersion(spec)
 	sdkRoot := sys.Biome.JoinPath(sys.Biome.Dirs().Tools, "android", "android-"+version)
-	sdkToolsDir := sys.Biome.JoinPath(sdkRoot, "tools")
+	var toolsDir, binDir string
+	var toolsPath []string
+	if isAndroidCmdlineTools(version) {
+		// sdkmanager locates the SDK root relative to its own directory,
+		// so cmdline-tools must live in cmdline-tools/latest.
+		toolsDir = sys.Biome.JoinPath(sdkRoot, "cmdline-tools", "latest")
+		binDir = sys.Biome.JoinPath(sdkRoot, "cmdline-tools", "latest", "bin")
+		toolsPath = []string{binDir}
+	} else {
+		toolsDir = sys.Biome.JoinPath(sdkRoot, "tools")
+		binDir = sys.Biome.JoinPath(sdkRoot, "tools", "bin")
+		toolsPath = []string{binDir, sys.Biome.JoinPath(sdkRoot, "tools")}
+	}
 	env := biome.Environment{
 		Vars: map[string]string{
 			"ANDROID_SDK_ROOT": sdkRoot,
 			"ANDROID_HOME":     sdkRoot,
 		},
-		PrependPath: []string{
-			sys.Biome.JoinPath(sdkRoot, "tools", "bin"),
-			sys.Biome.JoinPath(sdkRoot, "tools"),
-		},
+		PrependPath: append(toolsPath,
+			sys.Biome.JoinPath(sdkRoot, "platform-tools"),
+			sys.Biome.JoinPath(sdkRoot, "emulator"),
+		),
 	}
 
 	// If directory already exists, then use it.
-	if _, err := biome.EvalSymlinks(ctx, sys.Biome, sdkToolsDir); err == nil {
+	if _, err := biome.EvalSymlinks(ctx, sys.Biome, toolsDir); err == nil {
 		log.Infof(ctx, "Android SDK v%s located in %s", version, sdkRoot)
-		return env, nil
+	} else {
+		log.Infof(ctx, "Installing Android SDK v%s in %s", version, sdkRoot)
+		downloadURL, err := androidSDKDownloadURL(version, sys.Biome.Describe())
+		if err != nil {
+			return biome.Environment{}, err
+		}
+		if err := extract(ctx, sys, toolsDir, downloadURL, stripTopDirectory); err != nil {
+			return biome.Environment{}, err
+		}
+		if err := writeAndroidAgreements(ctx, sys.Biome, sdkRoot); err != nil {
+			return biome.Environment{}, err
+		}
+	}
+	if err := installAndroidComponents(ctx, sys, sdkRoot, binDir, sys.AndroidComponents); err != nil {
+		return biome.Environment{}, err
 	}
+	recordInstall(ctx, sys, spec, sdkRoot)
+	return env, nil
+}
+
+// isAndroidCmdlineTools reports whether the given Android SDK version is a
+// cmdline-tools build rather than a legacy sdk-tools build.
+func isAndroidCmdlineTools(version string) bool {
+	build, err := strconv.Atoi(version)
+	return err == nil && build > lastAndroidSDKToolsVersion
+}
 
-	log.Infof(ctx, "Installing Android SDK v%s in %s", version, sdkRoot)
-	desc := sys.Biome.Describe()
-	const template = "https://dl.google.com/android/repository/sdk-tools-{{.OS}}-{{.Version}}.zip"
+func androidSDKDownloadURL(version string, desc *biome.Descriptor) (string, error) {
+	const sdkToolsTemplate = "https://dl.google.com/android/repository/sdk-tools-{{.OS}}-{{.Version}}.zip"
+	const cmdlineToolsTemplate = "https://dl.google.com/android/repository/commandlinetools-{{.OS}}-{{.Version}}_latest.zip"
+	template := sdkToolsTemplate
+	osNames := map[string]string{
+		biome.Linux: "linux",
+		biome.MacOS: "darwin",
+	}
+	if isAndroidCmdlineTools(version) {
+		template = cmdlineToolsTemplate
+		osNames[biome.MacOS] = "mac"
+	}
 	data := struct {
 		OS      string
 		Version string
-	}{
-		map[string]string{
-			biome.Linux: "linux",
-			biome.MacOS: "darwin",
-		}[desc.OS],
-		version,
-	}
+	}{osNames[desc.OS], version}
 	if data.OS == "" {
-		return biome.Environment{}, fmt.Errorf("unsupported os %s", desc.OS)
+		return "", fmt.Errorf("unsupported os %s", desc.OS)
 	}
-	downloadURL, err := templateToString(template, data)
-	if err != nil {
-		return biome.Environment{}, err
+	return templateToString(template, data)
+}
+
+// installAndroidComponents runs sdkmanager to install any of the given SDK
+// packages that are not already present in sdkRoot. binDir is the directory
+// containing sdkmanager.
+func installAndroidComponents(ctx context.Context, sys Sys, sdkRoot, binDir string, components []string) error {
+	var missing []string
+	for _, c := range components {
+		elems := append([]string{sdkRoot}, strings.Split(c, ";")...)
+		pkgXML := sys.Biome.JoinPath(append(elems, "package.xml")...)
+		if _, err := biome.EvalSymlinks(ctx, sys.Biome, pkgXML); err == nil {
+			log.Debugf(ctx, "Android SDK component %s already installed", c)
+			continue
+		}
+		missing = append(missing, c)
 	}
-	if err := extract(ctx, sys, sdkToolsDir, downloadURL, stripTopDirectory); err != nil {
-		return biome.Environment{}, err
+	if len(missing) == 0 {
+		return nil
 	}
+	// Rewrite the license files in case the table has been updated since the
+	// SDK was first installed.
 	if err := writeAndroidAgreements(ctx, sys.Biome, sdkRoot); err != nil {
-		return biome.Environment{}, err
+		return err
 	}
-	return env, nil
+	log.Infof(ctx, "Installing Android SDK components: %s", strings.Join(missing, ", "))
+	sdkmanager := sys.Biome.JoinPath(binDir, "sdkmanager")
+	argv := append([]string{sdkmanager, "--sdk_root=" + sdkRoot}, missing...)
+	err := sys.Biome.Run(ctx, &biome.Invocation{
+		Argv:   argv,
+		Env:    sys.Env,
+		Stdout: sys.Stdout,
+		Stderr: sys.Stderr,
+	})
+	if err != nil {
+		return fmt.Errorf("install android components: %w", err)
+	}
+	return nil
 }
 
 func writeAndroidAgreements(ctx context.Context, bio biome.Biome, androidDir string) error {
@@ -71,22 +172,17 @@ func writeAndroidAgreements(ctx context.Context, bio biome.Biome, androidDir str
 	if err != nil {
 		return fmt.Errorf("write agreement files: %w", err)
 	}
-	agreements := []struct {
-		filename string
-		hash     string
-	}{
-		{"android-googletv-license", "601085b94cd77f0b54ff86406957099ebe79c4d6"},
-		{"android-sdk-license", "24333f8a63b6825ea9c5514f83c2829b004d1fee"},
-		{"android-sdk-preview-license", "84831b9409646a918e30573bab4c9c91346d8abd"},
-		{"google-gdk-license", "33b6a2b64607f11b759f320ef9dff4ae5c47d97a"},
-		{"intel-android-extra-license", "d975f751698a77b662f1254ddbeed3901e976f5a"},
-		{"mips-android-sysimage-license", "e9acab5b5fbb560a72cfaecce8946896ff6aab9d"},
+	filenames := make([]string, 0, len(androidLicenses))
+	for filename := range androidLicenses {
+		filenames = append(filenames, filename)
 	}
-	for _, a := range agreements {
-		dst := bio.JoinPath(licensesDir, a.filename)
-		err := biome.WriteFile(ctx, bio, dst, strings.NewReader(a.hash))
+	sort.Strings(filenames)
+	for _, filename := range filenames {
+		dst := bio.JoinPath(licensesDir, filename)
+		content := strings.Join(androidLicenses[filename], "\n")
+		err := biome.WriteFile(ctx, bio, dst, strings.NewReader(content))
 		if err != nil {
-			return fmt.Errorf("write agreement files: %s: %w", a.filename, err)
+			return fmt.Errorf("write agreement files: %s: %w", filename, err)
 		}
 	}
 	return nil
@@ -18,6 +18,10 @@ package buildpack
 
 import (
 	"context"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
@@ -29,7 +33,7 @@ func TestAndroidSDK(t *testing.T) {
 	ctx := testlog.WithTB(context.Background(), t)
 	// TODO(light): The Android SDK depends on very specific versions of Java
 	// and won't install them by itself.
-	androidBiome, _ := testInstall(ctx, t, "java:8.265+01", "android:"+latestAndroidVersion)
+	androidBiome, _ := testInstall(ctx, t, "java:8.265+01", "android:4333796")
 	installOutput := new(strings.Builder)
 	// TODO(light): There isn't a great "get current version" command AFAICT.
 	// I've found that this is typically the first command that gets run by users.
@@ -43,3 +47,88 @@ func TestAndroidSDK(t *testing.T) {
 		t.Errorf("sdkmanager: %v", err)
 	}
 }
+
+func TestAndroidCmdlineTools(t *testing.T) {
+	ctx := testlog.WithTB(context.Background(), t)
+	// Relies on the default Java that cmdline-tools needs.
+	androidBiome, _ := testInstall(ctx, t, "android:"+latestAndroidVersion)
+	versionOutput := new(strings.Builder)
+	err := androidBiome.Run(ctx, &biome.Invocation{
+		Argv:   []string{"sdkmanager", "--version"},
+		Stdout: versionOutput,
+		Stderr: versionOutput,
+	})
+	t.Logf("sdkmanager --version output:\n%s", versionOutput)
+	if err != nil {
+		t.Errorf("sdkmanager --version: %v", err)
+	}
+}
+
+func TestAndroidSDKDownloadURL(t *testing.T) {
+	t.Run("SDKTools", func(t *testing.T) {
+		testDownloadURLs(t, http.MethodHead, func(desc *biome.Descriptor) (string, error) {
+			return androidSDKDownloadURL("4333796", desc)
+		}, []downloadURLTest{
+			{os: biome.Linux, arch: biome.Intel64, want: "https://dl.google.com/android/repository/sdk-tools-linux-4333796.zip"},
+			{os: biome.MacOS, arch: biome.Intel64, want: "https://dl.google.com/android/repository/sdk-tools-darwin-4333796.zip"},
+		})
+	})
+	t.Run("CmdlineTools", func(t *testing.T) {
+		testDownloadURLs(t, http.MethodHead, func(desc *biome.Descriptor) (string, error) {
+			return androidSDKDownloadURL(latestAndroidVersion, desc)
+		}, []downloadURLTest{
+			{os: biome.Linux, arch: biome.Intel64, want: "https://dl.google.com/android/repository/commandlinetools-linux-11076708_latest.zip"},
+			{os: biome.MacOS, arch: biome.Intel64, want: "https://dl.google.com/android/repository/commandlinetools-mac-11076708_latest.zip"},
+		})
+	})
+}
+
+func TestInstallAndroidComponents(t *testing.T) {
+	ctx := testlog.WithTB(context.Background(), t)
+	bio := newLocalTestBiome(t)
+	sdkRoot := filepath.Join(bio.Dirs().Tools, "android", "android-test")
+	binDir := filepath.Join(sdkRoot, "cmdline-tools", "latest", "bin")
+	if err := os.MkdirAll(binDir, 0o777); err != nil {
+		t.Fatal(err)
+	}
+	// Fake sdkmanager that records its arguments.
+	argsFile := filepath.Join(t.TempDir(), "args")
+	script := "#!/bin/sh\necho \"$@\" > " + argsFile + "\n"
+	if err := ioutil.WriteFile(filepath.Join(binDir, "sdkmanager"), []byte(script), 0o777); err != nil {
+		t.Fatal(err)
+	}
+	installedDir := filepath.Join(sdkRoot, "platforms", "android-30")
+	if err := os.MkdirAll(installedDir, 0o777); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(installedDir, "package.xml"), nil, 0o666); err != nil {
+		t.Fatal(err)
+	}
+
+	sys := Sys{
+		Biome:  bio,
+		Stdout: ioutil.Discard,
+		Stderr: ioutil.Discard,
+	}
+	components := []string{"platforms;android-30", "build-tools;30.0.3", "emulator"}
+	if err := installAndroidComponents(ctx, sys, sdkRoot, binDir, components); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ioutil.ReadFile(argsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "--sdk_root=" + sdkRoot + " build-tools;30.0.3 emulator\n"
+	if string(got) != want {
+		t.Errorf("sdkmanager arguments = %q; want %q", got, want)
+	}
+	license, err := ioutil.ReadFile(filepath.Join(sdkRoot, "licenses", "android-sdk-license"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, hash := range androidLicenses["android-sdk-license"] {
+		if !strings.Contains(string(license), hash) {
+			t.Errorf("android-sdk-license does not contain %s", hash)
+		}
+	}
+}
@@ -20,6 +20,7 @@ func installAnt(ctx context.Context, sys Sys, spec yb.BuildpackSpec) (biome.Envi
 	// If directory already exists, then use it.
 	if _, err := biome.EvalSymlinks(ctx, sys.Biome, antDir); err == nil {
 		log.Infof(ctx, "Ant v%s located in %s", spec.Version(), antDir)
+		recordInstall(ctx, sys, spec, antDir)
 		return env, nil
 	}
 
@@ -28,5 +29,6 @@ func installAnt(ctx context.Context, sys Sys, spec yb.BuildpackSpec) (biome.Envi
 	if err := extract(ctx, sys, antDir, downloadURL, stripTopDirectory); err != nil {
 		return biome.Environment{}, err
 	}
+	recordInstall(ctx, sys, spec, antDir)
 	return env, nil
 }
@@ -0,0 +1,253 @@
+// Copyright 2020 YourBase Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package buildpack
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"compress/bzip2"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+	"github.com/yourbase/yb/internal/biome"
+)
+
+// maxZipSymlinkSize is the largest zip entry that will be read as the target
+// of a symbolic link.
+const maxZipSymlinkSize = 4096
+
+// errTreeWritten is returned to convertArchive when the biome stops reading
+// the converted archive.
+var errTreeWritten = errors.New("biome finished writing tree")
+
+// extractNative extracts the archive in f to dstDir by decompressing it in
+// this process and sending the files to biome.WriteTree. If the biome does not
+// support WriteTree, extractNative returns an error wrapping
+// biome.ErrUnsupported without having modified the biome.
+func extractNative(ctx context.Context, bio biome.Biome, dstDir string, f *os.File, ext string, extractMode bool) error {
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	pr, pw := io.Pipe()
+	convertDone := make(chan error, 1)
+	go func() {
+		err := convertArchive(tar.NewWriter(pw), f, ext, extractMode)
+		pw.CloseWithError(err)
+		convertDone <- err
+	}()
+	writeErr := biome.WriteTree(ctx, bio, dstDir, pr)
+	pr.CloseWithError(errTreeWritten)
+	convertErr := <-convertDone
+	if writeErr == nil || errors.Is(writeErr, biome.ErrUnsupported) {
+		return writeErr
+	}
+	if convertErr != nil && !errors.Is(convertErr, errTreeWritten) {
+		// Report the underlying decoding error rather than the error the biome
+		// received from reading the pipe.
+		return convertErr
+	}
+	return writeErr
+}
+
+// convertArchive writes the contents of the archive in f as an uncompressed
+// tar stream, stripping the top-level directory if requested.
+func convertArchive(tw *tar.Writer, f *os.File, ext string, extractMode bool) error {
+	if ext == zipExt {
+		info, err := f.Stat()
+		if err != nil {
+			return err
+		}
+		zr, err := zip.NewReader(f, info.Size())
+		if err != nil {
+			return err
+		}
+		return convertZip(tw, zr, extractMode)
+	}
+	var r io.Reader
+	switch ext {
+	case tarGZExt:
+		zr, err := gzip.NewReader(bufio.NewReader(f))
+		if err != nil {
+			return err
+		}
+		r = zr
+	case tarXZExt:
+		xr, err := xz.NewReader(bufio.NewReader(f))
+		if err != nil {
+			return err
+		}
+		r = xr
+	case tarBZ2Ext:
+		r = bzip2.NewReader(bufio.NewReader(f))
+	case tarZstExt:
+		zr, err := zstd.NewReader(bufio.NewReader(f))
+		if err != nil {
+			return err
+		}
+		defer zr.Close()
+		r = zr
+	default:
+		return fmt.Errorf("unknown archive extension %q", ext)
+	}
+	return convertTar(tw, tar.NewReader(r), extractMode)
+}
+
+func convertTar(tw *tar.Writer, tr *tar.Reader, extractMode bool) error {
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return tw.Close()
+		}
+		if err != nil {
+			return err
+		}
+		if hdr.Typeflag == tar.TypeXGlobalHeader {
+			continue
+		}
+		name, ok := stripArchivePath(hdr.Name, extractMode)
+		if !ok {
+			continue
+		}
+		hdr2 := &tar.Header{
+			Typeflag: hdr.Typeflag,
+			Name:     name,
+			Linkname: hdr.Linkname,
+			Size:     hdr.Size,
+			Mode:     hdr.Mode,
+			ModTime:  hdr.ModTime,
+		}
+		switch hdr.Typeflag {
+		case tar.TypeRegA:
+			hdr2.Typeflag = tar.TypeReg
+		case tar.TypeLink:
+			hdr2.Linkname, ok = stripArchivePath(hdr.Linkname, extractMode)
+			if !ok {
+				return fmt.Errorf("%s: hard link to %s outside of top-level directory", hdr.Name, hdr.Linkname)
+			}
+		}
+		if hdr2.Typeflag != tar.TypeReg {
+			hdr2.Size = 0
+		}
+		if err := tw.WriteHeader(hdr2); err != nil {
+			return err
+		}
+		if hdr2.Typeflag == tar.TypeReg {
+			if _, err := io.Copy(tw, tr); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func convertZip(tw *tar.Writer, zr *zip.Reader, extractMode bool) error {
+	for _, zf := range zr.File {
+		name, ok := stripArchivePath(zf.Name, extractMode)
+		if !ok {
+			continue
+		}
+		mode := zf.Mode()
+		hdr := &tar.Header{
+			Name:    name,
+			Mode:    int64(mode.Perm()),
+			ModTime: zf.Modified,
+		}
+		switch {
+		case mode&os.ModeSymlink != 0:
+			target, err := readZipSymlink(zf)
+			if err != nil {
+				return err
+			}
+			hdr.Typeflag = tar.TypeSymlink
+			hdr.Linkname = target
+		case mode.IsDir() || strings.HasSuffix(zf.Name, "/"):
+			hdr.Typeflag = tar.TypeDir
+		default:
+			hdr.Typeflag = tar.TypeReg
+			hdr.Size = int64(zf.UncompressedSize64)
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if hdr.Typeflag == tar.TypeReg {
+			if err := copyZipFile(tw, zf); err != nil {
+				return err
+			}
+		}
+	}
+	return tw.Close()
+}
+
+func copyZipFile(dst io.Writer, zf *zip.File) error {
+	rc, err := zf.Open()
+	if err != nil {
+		return fmt.Errorf("%s: %w", zf.Name, err)
+	}
+	defer rc.Close()
+	if _, err := io.Copy(dst, rc); err != nil {
+		return fmt.Errorf("%s: %w", zf.Name, err)
+	}
+	return nil
+}
+
+// readZipSymlink returns the target of a symbolic link stored in a zip file,
+// which is the content of the entry.
+func readZipSymlink(zf *zip.File) (string, error) {
+	if zf.UncompressedSize64 > maxZipSymlinkSize {
+		return "", fmt.Errorf("%s: symbolic link target too long", zf.Name)
+	}
+	rc, err := zf.Open()
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", zf.Name, err)
+	}
+	defer rc.Close()
+	target, err := ioutil.ReadAll(io.LimitReader(rc, maxZipSymlinkSize))
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", zf.Name, err)
+	}
+	return string(target), nil
+}
+
+// stripArchivePath returns the slash-separated path an archive entry should be
+// extracted to. If extractMode is stripTopDirectory, then the first path
+// component is removed and entries outside of a top-level directory (including
+// the directory itself) are skipped, like `tar --strip-components 1`.
+func stripArchivePath(name string, extractMode bool) (_ string, ok bool) {
+	if extractMode != stripTopDirectory {
+		return name, true
+	}
+	for strings.HasPrefix(name, "./") {
+		name = name[len("./"):]
+	}
+	i := strings.IndexByte(name, '/')
+	if i == -1 {
+		return "", false
+	}
+	name = strings.TrimLeft(name[i+1:], "/")
+	if name == "" {
+		return "", false
+	}
+	return name, true
+}
@@ -0,0 +1,263 @@
+// Copyright 2020 YourBase Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package buildpack
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+	"github.com/yourbase/commons/http/headers"
+	"github.com/yourbase/yb/internal/biome"
+	"github.com/yourbase/yb/internal/ybdata"
+	"zombiezen.com/go/log/testlog"
+)
+
+func TestStripArchivePath(t *testing.T) {
+	tests := []struct {
+		name   string
+		want   string
+		wantOK bool
+	}{
+		{name: "go/bin/go", want: "bin/go", wantOK: true},
+		{name: "./go/bin/go", want: "bin/go", wantOK: true},
+		{name: "go/bin/", want: "bin/", wantOK: true},
+		{name: "go/", wantOK: false},
+		{name: "go", wantOK: false},
+		{name: "./", wantOK: false},
+		{name: "README", wantOK: false},
+	}
+	for _, test := range tests {
+		got, ok := stripArchivePath(test.name, stripTopDirectory)
+		if got != test.want || ok != test.wantOK {
+			t.Errorf("stripArchivePath(%q, stripTopDirectory) = %q, %t; want %q, %t", test.name, got, ok, test.want, test.wantOK)
+		}
+	}
+	if got, ok := stripArchivePath("go/bin/go", tarbomb); got != "go/bin/go" || !ok {
+		t.Errorf("stripArchivePath(%q, tarbomb) = %q, %t; want %q, true", "go/bin/go", got, ok, "go/bin/go")
+	}
+}
+
+func TestExtractNative(t *testing.T) {
+	if runtime.GOOS == biome.Windows {
+		t.Skip("Symbolic links and permissions not supported on Windows")
+	}
+	tests := []struct {
+		ext     string
+		archive []byte
+	}{
+		{ext: zipExt, archive: makeToolZip(t)},
+		{ext: tarGZExt, archive: makeToolTar(t, tarGZExt)},
+		{ext: tarXZExt, archive: makeToolTar(t, tarXZExt)},
+		{ext: tarZstExt, archive: makeToolTar(t, tarZstExt)},
+	}
+	for _, test := range tests {
+		t.Run(strings.TrimPrefix(test.ext, "."), func(t *testing.T) {
+			ctx := testlog.WithTB(context.Background(), t)
+			bio := biome.Local{
+				PackageDir: t.TempDir(),
+				HomeDir:    t.TempDir(),
+			}
+			sys := Sys{
+				Biome:      bio,
+				Downloader: ybdata.NewDownloader(t.TempDir()),
+			}
+			srv := serveArchive(t, "/tool"+test.ext, test.archive)
+			sys.Downloader.Client = srv.Client()
+
+			dstDir := bio.JoinPath(bio.HomeDir, "tool")
+			if err := extract(ctx, sys, dstDir, srv.URL+"/tool"+test.ext, stripTopDirectory); err != nil {
+				t.Fatal("extract:", err)
+			}
+			toolPath := filepath.Join(dstDir, "bin", "tool")
+			got, err := ioutil.ReadFile(toolPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != extractContent {
+				t.Errorf("%s content = %q; want %q", toolPath, got, extractContent)
+			}
+			info, err := os.Stat(toolPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.Mode().Perm()&0o100 == 0 {
+				t.Errorf("%s mode = %v; want executable", toolPath, info.Mode())
+			}
+			linkPath := filepath.Join(dstDir, "tool")
+			target, err := os.Readlink(linkPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := filepath.Join("bin", "tool"); target != want {
+				t.Errorf("%s -> %q; want %q", linkPath, target, want)
+			}
+		})
+	}
+}
+
+func TestExtractRejectsTraversal(t *testing.T) {
+	buf := new(bytes.Buffer)
+	zw := gzip.NewWriter(buf)
+	tw := tar.NewWriter(zw)
+	err := tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     "root/../../evil.txt",
+		Mode:     0o644,
+		Size:     int64(len(extractContent)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.WriteString(tw, extractContent); err != nil {
+		t.Fatal(err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := testlog.WithTB(context.Background(), t)
+	bio := biome.Local{
+		PackageDir: t.TempDir(),
+		HomeDir:    t.TempDir(),
+	}
+	output := new(strings.Builder)
+	sys := Sys{
+		Biome:      bio,
+		Stdout:     output,
+		Stderr:     output,
+		Downloader: ybdata.NewDownloader(t.TempDir()),
+	}
+	srv := serveArchive(t, "/evil.tar.gz", buf.Bytes())
+	sys.Downloader.Client = srv.Client()
+
+	dstDir := bio.JoinPath(bio.HomeDir, "a", "b")
+	err = extract(ctx, sys, dstDir, srv.URL+"/evil.tar.gz", stripTopDirectory)
+	if err == nil {
+		t.Fatal("extract did not return an error")
+	}
+	t.Log(err)
+	if _, err := os.Lstat(bio.JoinPath(bio.HomeDir, "evil.txt")); err == nil {
+		t.Error("evil.txt written outside of extraction directory")
+	}
+}
+
+// serveArchive starts an HTTP server that serves the given content at path.
+func serveArchive(tb testing.TB, path string, content []byte) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != path {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set(headers.ContentLength, strconv.Itoa(len(content)))
+		w.Write(content)
+	}))
+	tb.Cleanup(srv.Close)
+	return srv
+}
+
+// makeToolTar returns a compressed tar archive containing an executable at
+// root/bin/tool and a symbolic link to it at root/tool.
+func makeToolTar(tb testing.TB, ext string) []byte {
+	tb.Helper()
+	buf := new(bytes.Buffer)
+	var zw io.WriteCloser
+	var err error
+	switch ext {
+	case tarGZExt:
+		zw = gzip.NewWriter(buf)
+	case tarXZExt:
+		zw, err = xz.NewWriter(buf)
+	case tarZstExt:
+		zw, err = zstd.NewWriter(buf)
+	default:
+		tb.Fatalf("unknown extension %q", ext)
+	}
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tw := tar.NewWriter(zw)
+	hdrs := []*tar.Header{
+		{Typeflag: tar.TypeDir, Name: "root/", Mode: 0o755},
+		{Typeflag: tar.TypeDir, Name: "root/bin/", Mode: 0o755},
+		{Typeflag: tar.TypeReg, Name: "root/bin/tool", Mode: 0o755, Size: int64(len(extractContent))},
+		{Typeflag: tar.TypeSymlink, Name: "root/tool", Linkname: "bin/tool", Mode: 0o777},
+	}
+	for _, hdr := range hdrs {
+		if err := tw.WriteHeader(hdr); err != nil {
+			tb.Fatal(err)
+		}
+		if hdr.Typeflag == tar.TypeReg {
+			if _, err := io.WriteString(tw, extractContent); err != nil {
+				tb.Fatal(err)
+			}
+		}
+	}
+	if err := tw.Close(); err != nil {
+		tb.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		tb.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// makeToolZip returns a zip archive with the same content as makeToolTar.
+func makeToolZip(tb testing.TB) []byte {
+	tb.Helper()
+	buf := new(bytes.Buffer)
+	zw := zip.NewWriter(buf)
+	toolHeader := &zip.FileHeader{Name: "root/bin/tool", Method: zip.Deflate}
+	toolHeader.SetMode(0o755)
+	f, err := zw.CreateHeader(toolHeader)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	if _, err := io.WriteString(f, extractContent); err != nil {
+		tb.Fatal(err)
+	}
+	linkHeader := &zip.FileHeader{Name: "root/tool"}
+	linkHeader.SetMode(os.ModeSymlink | 0o777)
+	f, err = zw.CreateHeader(linkHeader)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	if _, err := io.WriteString(f, "bin/tool"); err != nil {
+		tb.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		tb.Fatal(err)
+	}
+	return buf.Bytes()
+}
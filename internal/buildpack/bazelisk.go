@@ -0,0 +1,134 @@
+// Copyright 2020 YourBase Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package buildpack
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/yourbase/commons/xcontext"
+	"github.com/yourbase/yb"
+	"github.com/yourbase/yb/internal/biome"
+	"zombiezen.com/go/log"
+)
+
+// installBazelisk installs Bazelisk as both bazelisk and bazel. Bazelisk
+// downloads the Bazel release named in the package's .bazelversion file.
+func installBazelisk(ctx context.Context, sys Sys, spec yb.BuildpackSpec) (_ biome.Environment, err error) {
+	home := sys.Biome.Dirs().Home
+	bazeliskDir := sys.Biome.JoinPath(sys.Biome.Dirs().Tools, "bazelisk", "bazelisk-"+spec.Version())
+	env := biome.Environment{
+		Vars: map[string]string{
+			// Keep downloaded Bazel releases in the build's home.
+			"BAZELISK_HOME": sys.Biome.JoinPath(home, ".cache", "bazelisk"),
+		},
+		PrependPath: []string{bazeliskDir},
+	}
+	// Bazelisk only looks for .bazelversion in the workspace root, which may be
+	// above the package directory.
+	if v, err := readBiomeFile(ctx, sys, sys.Biome.JoinPath(sys.Biome.Dirs().Package, ".bazelversion")); err == nil {
+		if v = strings.TrimSpace(v); v != "" {
+			log.Debugf(ctx, "Using Bazel %s from .bazelversion", v)
+			env.Vars["USE_BAZEL_VERSION"] = v
+		}
+	}
+	if err := writeBazelrc(ctx, sys); err != nil {
+		return biome.Environment{}, err
+	}
+
+	// If directory already exists, then use it.
+	if _, err := biome.EvalSymlinks(ctx, sys.Biome, bazeliskDir); err == nil {
+		log.Infof(ctx, "Bazelisk v%s located in %s", spec.Version(), bazeliskDir)
+		recordInstall(ctx, sys, spec, bazeliskDir)
+		return env, nil
+	}
+
+	log.Infof(ctx, "Installing Bazelisk v%s in %s", spec.Version(), bazeliskDir)
+	downloadURL, err := bazeliskDownloadURL(spec.Version(), sys.Biome.Describe())
+	if err != nil {
+		return biome.Environment{}, err
+	}
+	if err := installBinary(ctx, sys, bazeliskDir, downloadURL); err != nil {
+		return biome.Environment{}, err
+	}
+	defer func() {
+		// Remove the directory on failure so that later builds retry.
+		if err != nil {
+			rmCtx, cancel := xcontext.KeepAlive(ctx, 10*time.Second)
+			defer cancel()
+			rmErr := sys.Biome.Run(rmCtx, &biome.Invocation{
+				Argv:   []string{"rm", "-rf", bazeliskDir},
+				Stdout: sys.Stdout,
+				Stderr: sys.Stderr,
+			})
+			if rmErr != nil {
+				log.Warnf(ctx, "Cleaning up failed Bazelisk install: %v", rmErr)
+			}
+		}
+	}()
+	downloadName := downloadURL[strings.LastIndex(downloadURL, "/")+1:]
+	for _, name := range []string{"bazelisk", "bazel"} {
+		err = sys.Biome.Run(ctx, &biome.Invocation{
+			Argv:   []string{"ln", "-s", downloadName, sys.Biome.JoinPath(bazeliskDir, name)},
+			Stdout: sys.Stdout,
+			Stderr: sys.Stderr,
+		})
+		if err != nil {
+			return biome.Environment{}, fmt.Errorf("install bazelisk: %w", err)
+		}
+	}
+	recordInstall(ctx, sys, spec, bazeliskDir)
+	return env, nil
+}
+
+// writeBazelrc writes a .bazelrc in the build's home directory that puts
+// Bazel's output base and repository cache in the home directory, so that
+// `yb clean` removes them.
+func writeBazelrc(ctx context.Context, sys Sys) error {
+	home := sys.Biome.Dirs().Home
+	outputRoot := sys.Biome.JoinPath(home, ".cache", "bazel")
+	repoCache := sys.Biome.JoinPath(home, ".cache", "bazel-repo")
+	rc := new(strings.Builder)
+	fmt.Fprintf(rc, "startup --output_user_root=%s\n", outputRoot)
+	for _, cmd := range []string{"build", "fetch", "query", "sync"} {
+		fmt.Fprintf(rc, "%s --repository_cache=%s\n", cmd, repoCache)
+	}
+	rcPath := sys.Biome.JoinPath(home, ".bazelrc")
+	if err := biome.WriteFile(ctx, sys.Biome, rcPath, strings.NewReader(rc.String())); err != nil {
+		return fmt.Errorf("write %s: %w", rcPath, err)
+	}
+	return nil
+}
+
+func bazeliskDownloadURL(version string, desc *biome.Descriptor) (string, error) {
+	const template = "https://github.com/bazelbuild/bazelisk/releases/download/v{{.Version}}/bazelisk-{{.Platform}}"
+	platform, err := platformName("Bazelisk", map[biome.Descriptor]string{
+		{OS: biome.Linux, Arch: biome.Intel64}: "linux-amd64",
+		{OS: biome.Linux, Arch: biome.ARM64}:   "linux-arm64",
+		{OS: biome.MacOS, Arch: biome.Intel64}: "darwin-amd64",
+		{OS: biome.MacOS, Arch: biome.ARM64}:   "darwin-arm64",
+	}, desc)
+	if err != nil {
+		return "", err
+	}
+	return templateToString(template, struct {
+		Version  string
+		Platform string
+	}{version, platform})
+}
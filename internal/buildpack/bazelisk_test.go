@@ -0,0 +1,88 @@
+// Copyright 2020 YourBase Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package buildpack
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/yourbase/yb/internal/biome"
+	"zombiezen.com/go/log/testlog"
+)
+
+func TestBazelisk(t *testing.T) {
+	const version = "1.10.1"
+	ctx := testlog.WithTB(context.Background(), t)
+	bazeliskBiome, _ := testInstall(ctx, t, "bazelisk:"+version)
+	// Run through the bazel link to check that it points at Bazelisk.
+	// Bazelisk prints its own version before running Bazel.
+	versionOutput := new(strings.Builder)
+	err := bazeliskBiome.Run(ctx, &biome.Invocation{
+		Argv:   []string{"bazel", "version"},
+		Stdout: versionOutput,
+		Stderr: versionOutput,
+	})
+	t.Logf("bazel version output:\n%s", versionOutput)
+	if err != nil {
+		t.Errorf("bazel version: %v", err)
+	}
+	if got := versionOutput.String(); !strings.Contains(got, "Bazelisk version: v"+version) {
+		t.Errorf("bazel version output does not include Bazelisk version %q", version)
+	}
+}
+
+func TestBazeliskDownloadURL(t *testing.T) {
+	const version = "1.10.1"
+	testDownloadURLs(t, http.MethodGet, func(desc *biome.Descriptor) (string, error) {
+		return bazeliskDownloadURL(version, desc)
+	}, []downloadURLTest{
+		{os: biome.Linux, arch: biome.Intel64, want: "https://github.com/bazelbuild/bazelisk/releases/download/v1.10.1/bazelisk-linux-amd64"},
+		{os: biome.Linux, arch: biome.Intel32, want: ""},
+		{os: biome.Linux, arch: biome.ARM64, want: "https://github.com/bazelbuild/bazelisk/releases/download/v1.10.1/bazelisk-linux-arm64"},
+		{os: biome.MacOS, arch: biome.Intel64, want: "https://github.com/bazelbuild/bazelisk/releases/download/v1.10.1/bazelisk-darwin-amd64"},
+		{os: biome.MacOS, arch: biome.ARM64, want: "https://github.com/bazelbuild/bazelisk/releases/download/v1.10.1/bazelisk-darwin-arm64"},
+	})
+}
+
+func TestWriteBazelrc(t *testing.T) {
+	ctx := testlog.WithTB(context.Background(), t)
+	bio := newLocalTestBiome(t)
+	sys := Sys{
+		Biome:  bio,
+		Stdout: ioutil.Discard,
+		Stderr: ioutil.Discard,
+	}
+	if err := writeBazelrc(ctx, sys); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ioutil.ReadFile(filepath.Join(bio.Dirs().Home, ".bazelrc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"startup --output_user_root=" + filepath.Join(bio.Dirs().Home, ".cache", "bazel") + "\n",
+		"build --repository_cache=" + filepath.Join(bio.Dirs().Home, ".cache", "bazel-repo") + "\n",
+	} {
+		if !strings.Contains(string(got), want) {
+			t.Errorf(".bazelrc does not contain %q. Content:\n%s", want, got)
+		}
+	}
+}
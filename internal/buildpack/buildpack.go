@@ -3,8 +3,11 @@ package buildpack
 import (
 	"archive/zip"
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"os"
+	"sort"
 	"strings"
 	"text/template"
 	"time"
@@ -30,6 +33,34 @@ type Sys struct {
 
 	DockerClient    *docker.Client
 	DockerNetworkID string
+
+	// Definitions is the set of buildpacks declared in configuration, keyed by
+	// name. A declared buildpack takes precedence over a built-in buildpack
+	// with the same name.
+	Definitions map[string]*yb.BuildpackDefinition
+
+	// Env is the merged environment of the buildpacks that the buildpack
+	// being installed requires. Installers use it to run programs provided
+	// by those buildpacks. InstallAll sets it for each buildpack.
+	Env biome.Environment
+
+	// AndroidComponents is the list of Android SDK packages (in sdkmanager
+	// syntax, like "build-tools;30.0.3") that the android buildpack installs
+	// after the SDK tools.
+	AndroidComponents []string
+
+	// Parallelism is the maximum number of buildpacks that InstallAll
+	// installs at once. Zero or one installs buildpacks one at a time.
+	Parallelism int
+
+	// LogPrefix, if not nil, returns a context whose log messages are
+	// labeled with the given buildpack name. InstallAll uses it when
+	// installing buildpacks concurrently.
+	LogPrefix func(ctx context.Context, packName string) context.Context
+
+	// Inventory, if not nil, records the buildpacks installed in the biome's
+	// tools directory and when they were last used.
+	Inventory *Inventory
 }
 
 var packs = map[string]func(context.Context, Sys, yb.BuildpackSpec) (biome.Environment, error){
@@ -38,23 +69,53 @@ var packs = map[string]func(context.Context, Sys, yb.BuildpackSpec) (biome.Envir
 	"android":    installAndroidSDK,
 	"androidndk": installAndroidNDK,
 	"ant":        installAnt,
+	"bazelisk":   installBazelisk,
+	"chrome":     installChrome,
+	"cmake":      installCMake,
+	"composer":   installComposer,
+	"cpython":    installCPython,
 	"dart":       installDart,
+	"dotnet":     installDotnet,
+	"elixir":     installElixir,
+	"erlang":     installErlang,
+	"firefox":    installFirefox,
 	"flutter":    installFlutter,
 	"glide":      installGlide,
 	"go":         installGo,
 	"gradle":     installGradle,
+	"helm":       installHelm,
 	"heroku":     installHeroku,
 	"java":       installJava,
+	"kotlin":     installKotlin,
+	"kubectl":    installKubectl,
+	"kustomize":  installKustomize,
 	"maven":      installMaven,
+	"ninja":      installNinja,
 	"node":       installNode,
+	"packer":     installPacker,
+	"php":        installPHP,
+	"pnpm":       installPnpm,
 	"protoc":     installProtoc,
+	"pwsh":       installPowerShell,
 	"python":     installPython,
 	"r":          installR,
 	"ruby":       installRuby,
 	"rust":       installRust,
+	"sbt":        installSbt,
+	"terraform":  installTerraform,
 	"yarn":       installYarn,
 }
 
+// Names returns the names of the available buildpacks in sorted order.
+func Names() []string {
+	names := make([]string, 0, len(packs))
+	for name := range packs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
 type buildToolSpec struct {
 	tool       yb.BuildpackSpec
 	dataDirs   *ybdata.Dirs
@@ -62,7 +123,8 @@ type buildToolSpec struct {
 	packageDir string
 }
 
-// Install installs the buildpack given by spec into the biome.
+// Install installs the buildpack given by spec into the biome. It does not
+// install the buildpacks that spec requires: use InstallAll for that.
 func Install(ctx context.Context, sys Sys, spec yb.BuildpackSpec) (_ biome.Environment, err error) {
 	ctx, span := ybtrace.Start(ctx, "Buildpack "+string(spec), trace.WithAttributes(
 		label.String("buildpack", spec.Name()),
@@ -76,10 +138,23 @@ func Install(ctx context.Context, sys Sys, spec yb.BuildpackSpec) (_ biome.Envir
 	}()
 	log.Infof(ctx, "Configuring build tool %s...", spec)
 	f := packs[spec.Name()]
+	if def := sys.Definitions[spec.Name()]; def != nil {
+		f = func(ctx context.Context, sys Sys, spec yb.BuildpackSpec) (biome.Environment, error) {
+			return installDefined(ctx, sys, spec, def)
+		}
+	}
 	if f == nil {
 		return biome.Environment{}, fmt.Errorf("install buildpack %s: no such buildpack", spec)
 	}
-	env, err := f(ctx, sys, spec)
+	resolved, err := Resolve(ctx, sys, spec)
+	if err != nil {
+		return biome.Environment{}, fmt.Errorf("install buildpack %s: %w", spec, err)
+	}
+	if resolved != spec {
+		log.Infof(ctx, "Resolved %s to %s", spec, resolved)
+	}
+	span.SetAttribute("resolved_version", resolved.Version())
+	env, err := f(ctx, sys, resolved)
 	if err != nil {
 		return biome.Environment{}, fmt.Errorf("install buildpack %s: %w", spec, err)
 	}
@@ -95,20 +170,23 @@ const (
 	stripTopDirectory = true
 )
 
+// Archive extensions understood by extract.
+const (
+	zipExt    = ".zip"
+	tarXZExt  = ".tar.xz"
+	tarGZExt  = ".tar.gz"
+	tarBZ2Ext = ".tar.bz2"
+	tarZstExt = ".tar.zst"
+)
+
 // extract downloads the given URL and extracts it to the given directory in the biome.
-func extract(ctx context.Context, sys Sys, dstDir, url string, extractMode bool) (err error) {
-	const (
-		zipExt    = ".zip"
-		tarXZExt  = ".tar.xz"
-		tarGZExt  = ".tar.gz"
-		tarBZ2Ext = ".tar.bz2"
-	)
-	const cleanupTimeout = 10 * time.Second
+func extract(ctx context.Context, sys Sys, dstDir, url string, extractMode bool) error {
 	exts := []string{
 		zipExt,
 		tarXZExt,
 		tarGZExt,
 		tarBZ2Ext,
+		tarZstExt,
 	}
 	var ext string
 	for _, testExt := range exts {
@@ -120,7 +198,17 @@ func extract(ctx context.Context, sys Sys, dstDir, url string, extractMode bool)
 	if ext == "" {
 		return fmt.Errorf("extract %s in %s: unknown extension", url, dstDir)
 	}
+	return extractArchive(ctx, sys, dstDir, url, ext, extractMode)
+}
 
+// extractArchive downloads the given URL and extracts it to the given directory
+// in the biome, treating the download as an archive with the given extension.
+//
+// If the biome supports biome.WriteTree, the archive is decompressed by yb
+// and the files are written directly. Otherwise, the archive is copied into
+// the biome and extracted with tar or unzip.
+func extractArchive(ctx context.Context, sys Sys, dstDir, url, ext string, extractMode bool) (err error) {
+	const cleanupTimeout = 10 * time.Second
 	f, err := sys.Downloader.Download(ctx, url)
 	if err != nil {
 		return fmt.Errorf("extract %s in %s: %w", url, dstDir, err)
@@ -142,10 +230,31 @@ func extract(ctx context.Context, sys Sys, dstDir, url string, extractMode bool)
 			}
 		}
 	}()
-	err = biome.MkdirAll(ctx, sys.Biome, dstDir)
-	if err != nil {
+	err = extractNative(ctx, sys.Biome, dstDir, f, ext, extractMode)
+	if !errors.Is(err, biome.ErrUnsupported) {
+		if err != nil {
+			return fmt.Errorf("extract %s in %s: %w", url, dstDir, err)
+		}
+		return nil
+	}
+	log.Debugf(ctx, "Extracting %s with archive tools: %v", url, err)
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
 		return fmt.Errorf("extract %s in %s: %w", url, dstDir, err)
 	}
+	if err := extractWithTools(ctx, sys, dstDir, f, ext, extractMode); err != nil {
+		return fmt.Errorf("extract %s in %s: %w", url, dstDir, err)
+	}
+	return nil
+}
+
+// extractWithTools copies the archive in f into the biome and runs tar or
+// unzip to extract it to dstDir.
+func extractWithTools(ctx context.Context, sys Sys, dstDir string, f *os.File, ext string, extractMode bool) error {
+	const cleanupTimeout = 10 * time.Second
+	err := biome.MkdirAll(ctx, sys.Biome, dstDir)
+	if err != nil {
+		return err
+	}
 	dstFile := dstDir + ext
 	defer func() {
 		ctx, cancel := xcontext.KeepAlive(ctx, cleanupTimeout)
@@ -161,7 +270,7 @@ func extract(ctx context.Context, sys Sys, dstDir, url string, extractMode bool)
 	}()
 	err = biome.WriteFile(ctx, sys.Biome, dstFile, f)
 	if err != nil {
-		return fmt.Errorf("extract %s in %s: %w", url, dstDir, err)
+		return err
 	}
 
 	invoke := &biome.Invocation{
@@ -203,26 +312,36 @@ func extract(ctx context.Context, sys Sys, dstDir, url string, extractMode bool)
 		if extractMode == stripTopDirectory {
 			invoke.Argv = append(invoke.Argv, "--strip-components", "1")
 		}
+	case tarZstExt:
+		invoke.Argv = []string{
+			"tar",
+			"-x", // extract
+			"--zstd",
+			"-f", absDstFile,
+		}
+		if extractMode == stripTopDirectory {
+			invoke.Argv = append(invoke.Argv, "--strip-components", "1")
+		}
 	default:
 		panic("unreachable")
 	}
 	if err := sys.Biome.Run(ctx, invoke); err != nil {
-		return fmt.Errorf("extract %s in %s: %w", url, dstDir, err)
+		return err
 	}
 	if ext == zipExt && extractMode == stripTopDirectory {
 		// There's no convenient way of stripping the top-level directory from an
 		// unzip invocation, but we can move the files ourselves.
 		size, err := f.Seek(0, io.SeekCurrent)
 		if err != nil {
-			return fmt.Errorf("extract %s in %s: determine archive size: %w", url, dstDir, err)
+			return fmt.Errorf("determine archive size: %w", err)
 		}
 		zr, err := zip.NewReader(f, size)
 		if err != nil {
-			return fmt.Errorf("extract %s in %s: %w", url, dstDir, err)
+			return err
 		}
 		root, names, err := topLevelZipFilenames(zr.File)
 		if err != nil {
-			return fmt.Errorf("extract %s in %s: %w", url, dstDir, err)
+			return err
 		}
 
 		mvArgv := []string{"mv"}
@@ -237,7 +356,7 @@ func extract(ctx context.Context, sys Sys, dstDir, url string, extractMode bool)
 			Stderr: sys.Stderr,
 		})
 		if err != nil {
-			return fmt.Errorf("extract %s in %s: %w", url, dstDir, err)
+			return err
 		}
 		err = sys.Biome.Run(ctx, &biome.Invocation{
 			Argv:   []string{"rmdir", root},
@@ -246,7 +365,7 @@ func extract(ctx context.Context, sys Sys, dstDir, url string, extractMode bool)
 			Stderr: sys.Stderr,
 		})
 		if err != nil {
-			return fmt.Errorf("extract %s in %s: %w", url, dstDir, err)
+			return err
 		}
 	}
 	return nil
@@ -294,6 +413,16 @@ func templateToString(templateText string, data interface{}) (string, error) {
 	return expanded.String(), nil
 }
 
+// platformName returns the name that a tool's upstream uses in download URLs
+// for the given platform. names should list every platform upstream publishes
+// binaries for.
+func platformName(tool string, names map[biome.Descriptor]string, desc *biome.Descriptor) (string, error) {
+	if name := names[*desc]; name != "" {
+		return name, nil
+	}
+	return "", fmt.Errorf("%s does not publish binaries for %s/%s", tool, desc.OS, desc.Arch)
+}
+
 func stringInSlice(slice []string, s string) bool {
 	for _, elem := range slice {
 		if elem == s {
@@ -83,16 +83,12 @@ func runTestInstall(ctx context.Context, tb testing.TB, bio biome.Biome, specs .
 		// Using the user's download cache so that downloads persist between runs.
 		Downloader: ybdata.NewDownloader(dirs.Downloads()),
 	}
-	var mergedEnv biome.Environment
-	for _, spec := range specs {
-		newEnv, err := Install(ctx, sys, spec)
-		tb.Logf("Install(ctx, sys, %q) output:\n%s", spec, installOutput)
-		if err != nil {
-			return biome.Environment{}, err
-		}
-		tb.Logf("%s environment:\n%v", spec, newEnv)
-		mergedEnv = mergedEnv.Merge(newEnv)
+	mergedEnv, err := InstallAll(ctx, sys, specs)
+	tb.Logf("InstallAll(ctx, sys, %q) output:\n%s", specs, installOutput)
+	if err != nil {
+		return biome.Environment{}, err
 	}
+	tb.Logf("Environment:\n%v", mergedEnv)
 	return mergedEnv, nil
 }
 
@@ -159,6 +155,43 @@ func verifyURLExists(tb testing.TB, method, url string) {
 	)
 }
 
+// A downloadURLTest is a test case for a function that computes a download
+// URL for a platform.
+type downloadURLTest struct {
+	os   string
+	arch string
+	// want is the expected URL or empty if the function should return an error.
+	want string
+}
+
+// testDownloadURLs checks the URLs that f returns for each platform. Unless
+// the test is run with -short, it also verifies that the URLs exist using the
+// given HTTP method.
+func testDownloadURLs(t *testing.T, method string, f func(*biome.Descriptor) (string, error), tests []downloadURLTest) {
+	t.Helper()
+	for _, test := range tests {
+		desc := &biome.Descriptor{OS: test.os, Arch: test.arch}
+		got, err := f(desc)
+		switch {
+		case test.want == "" && err == nil:
+			t.Errorf("%s/%s: got %q; want error", test.os, test.arch, got)
+		case test.want != "" && (got != test.want || err != nil):
+			t.Errorf("%s/%s: got %q, %v; want %q, <nil>", test.os, test.arch, got, err, test.want)
+		}
+	}
+
+	t.Run("Existence", func(t *testing.T) {
+		if testing.Short() {
+			t.Skip("Skipping due to -short")
+		}
+		for _, test := range tests {
+			if test.want != "" {
+				verifyURLExists(t, method, test.want)
+			}
+		}
+	})
+}
+
 const extractContent = "Hello, World!\n"
 
 func TestExtract(t *testing.T) {
@@ -0,0 +1,138 @@
+// Copyright 2020 YourBase Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package buildpack
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/yourbase/commons/xcontext"
+	"github.com/yourbase/yb"
+	"github.com/yourbase/yb/internal/biome"
+	"zombiezen.com/go/log"
+)
+
+// chromeVersionsURL lists the Chrome for Testing releases, which are builds of
+// Chrome published alongside a chromedriver of the same version.
+const chromeVersionsURL = "https://googlechromelabs.github.io/chrome-for-testing/known-good-versions-with-downloads.json"
+
+// installChrome installs a Chrome for Testing release and its chromedriver.
+// The version must be a full Chrome version like "118.0.5993.70".
+func installChrome(ctx context.Context, sys Sys, spec yb.BuildpackSpec) (_ biome.Environment, err error) {
+	desc := sys.Biome.Describe()
+	platform, err := platformName("Chrome for Testing", map[biome.Descriptor]string{
+		{OS: biome.Linux, Arch: biome.Intel64}: "linux64",
+		{OS: biome.MacOS, Arch: biome.Intel64}: "mac-x64",
+		{OS: biome.MacOS, Arch: biome.ARM64}:   "mac-arm64",
+	}, desc)
+	if err != nil {
+		return biome.Environment{}, err
+	}
+	chromeRoot := sys.Biome.JoinPath(sys.Biome.Dirs().Tools, "chrome", "chrome-"+spec.Version())
+	chromeDir := sys.Biome.JoinPath(chromeRoot, "chrome")
+	chromedriverDir := sys.Biome.JoinPath(chromeRoot, "chromedriver")
+	chromeBin := sys.Biome.JoinPath(chromeDir, "chrome")
+	if desc.OS == biome.MacOS {
+		chromeBin = sys.Biome.JoinPath(chromeDir, "Google Chrome for Testing.app", "Contents", "MacOS", "Google Chrome for Testing")
+	}
+	env := biome.Environment{
+		Vars: map[string]string{
+			"CHROME_BIN":   chromeBin,
+			"CHROMEDRIVER": sys.Biome.JoinPath(chromedriverDir, "chromedriver"),
+		},
+		PrependPath: []string{chromeDir, chromedriverDir},
+	}
+
+	// If directory already exists, then use it.
+	if _, err := biome.EvalSymlinks(ctx, sys.Biome, chromeRoot); err == nil {
+		log.Infof(ctx, "Chrome v%s located in %s", spec.Version(), chromeRoot)
+		recordInstall(ctx, sys, spec, chromeRoot)
+		warnMissingLibraries(ctx, sys, "Chrome", chromeBin)
+		return env, nil
+	}
+
+	log.Infof(ctx, "Installing Chrome and chromedriver v%s in %s", spec.Version(), chromeRoot)
+	var index chromeIndex
+	if err := fetchJSON(ctx, sys, chromeVersionsURL, &index); err != nil {
+		return biome.Environment{}, fmt.Errorf("find chrome %s: %w", spec.Version(), err)
+	}
+	chromeURL, chromedriverURL := pickChromeDownloads(&index, spec.Version(), platform)
+	if chromeURL == "" || chromedriverURL == "" {
+		return biome.Environment{}, fmt.Errorf("find chrome %s: no Chrome for Testing release with chromedriver for %s", spec.Version(), platform)
+	}
+	defer func() {
+		// Remove the directory on failure so that a missing driver doesn't
+		// leave an installation that later builds would use.
+		if err != nil {
+			rmCtx, cancel := xcontext.KeepAlive(ctx, 10*time.Second)
+			defer cancel()
+			rmErr := sys.Biome.Run(rmCtx, &biome.Invocation{
+				Argv:   []string{"rm", "-rf", chromeRoot},
+				Stdout: sys.Stdout,
+				Stderr: sys.Stderr,
+			})
+			if rmErr != nil {
+				log.Warnf(ctx, "Cleaning up failed Chrome install: %v", rmErr)
+			}
+		}
+	}()
+	if err := extract(ctx, sys, chromeDir, chromeURL, stripTopDirectory); err != nil {
+		return biome.Environment{}, err
+	}
+	if err := extract(ctx, sys, chromedriverDir, chromedriverURL, stripTopDirectory); err != nil {
+		return biome.Environment{}, err
+	}
+	recordInstall(ctx, sys, spec, chromeRoot)
+	warnMissingLibraries(ctx, sys, "Chrome", chromeBin)
+	return env, nil
+}
+
+// chromeIndex is the subset of the Chrome for Testing version list that the
+// chrome buildpack uses.
+type chromeIndex struct {
+	Versions []struct {
+		Version   string `json:"version"`
+		Downloads map[string][]struct {
+			Platform string `json:"platform"`
+			URL      string `json:"url"`
+		} `json:"downloads"`
+	} `json:"versions"`
+}
+
+// pickChromeDownloads returns the URLs of the Chrome and chromedriver archives
+// for the given version and platform. Early Chrome for Testing releases don't
+// have a chromedriver, so either URL may be empty.
+func pickChromeDownloads(index *chromeIndex, version, platform string) (chromeURL, chromedriverURL string) {
+	for _, v := range index.Versions {
+		if v.Version != version {
+			continue
+		}
+		for _, d := range v.Downloads["chrome"] {
+			if d.Platform == platform {
+				chromeURL = d.URL
+			}
+		}
+		for _, d := range v.Downloads["chromedriver"] {
+			if d.Platform == platform {
+				chromedriverURL = d.URL
+			}
+		}
+		break
+	}
+	return chromeURL, chromedriverURL
+}
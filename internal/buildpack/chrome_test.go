@@ -0,0 +1,135 @@
+// Copyright 2020 YourBase Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package buildpack
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/yourbase/yb/internal/biome"
+	"zombiezen.com/go/log/testlog"
+)
+
+func TestChrome(t *testing.T) {
+	const version = "118.0.5993.70"
+	ctx := testlog.WithTB(context.Background(), t)
+	chromeBiome, chromeEnv := testInstall(ctx, t, "chrome:"+version)
+	// The Chrome binary is only on PATH on Linux.
+	versionOutput := new(strings.Builder)
+	err := chromeBiome.Run(ctx, &biome.Invocation{
+		Argv:   []string{chromeEnv.Vars["CHROME_BIN"], "--version"},
+		Stdout: versionOutput,
+		Stderr: versionOutput,
+	})
+	t.Logf("chrome --version output:\n%s", versionOutput)
+	if err != nil {
+		t.Errorf("chrome --version: %v", err)
+	}
+	if got := versionOutput.String(); !strings.Contains(got, version) {
+		t.Errorf("chrome --version output does not include %q", version)
+	}
+
+	driverOutput := new(strings.Builder)
+	err = chromeBiome.Run(ctx, &biome.Invocation{
+		Argv:   []string{"chromedriver", "--version"},
+		Stdout: driverOutput,
+		Stderr: driverOutput,
+	})
+	t.Logf("chromedriver --version output:\n%s", driverOutput)
+	if err != nil {
+		t.Errorf("chromedriver --version: %v", err)
+	}
+	if got := driverOutput.String(); !strings.Contains(got, version) {
+		t.Errorf("chromedriver --version output does not include %q", version)
+	}
+}
+
+func TestPickChromeDownloads(t *testing.T) {
+	const indexJSON = `{
+		"timestamp": "2023-10-18T08:09:15.181Z",
+		"versions": [
+			{
+				"version": "113.0.5672.0",
+				"revision": "1121455",
+				"downloads": {
+					"chrome": [
+						{"platform": "linux64", "url": "https://example.com/113.0.5672.0/linux64/chrome-linux64.zip"}
+					]
+				}
+			},
+			{
+				"version": "118.0.5993.70",
+				"revision": "1192594",
+				"downloads": {
+					"chrome": [
+						{"platform": "linux64", "url": "https://example.com/118.0.5993.70/linux64/chrome-linux64.zip"},
+						{"platform": "mac-arm64", "url": "https://example.com/118.0.5993.70/mac-arm64/chrome-mac-arm64.zip"}
+					],
+					"chromedriver": [
+						{"platform": "linux64", "url": "https://example.com/118.0.5993.70/linux64/chromedriver-linux64.zip"},
+						{"platform": "mac-arm64", "url": "https://example.com/118.0.5993.70/mac-arm64/chromedriver-mac-arm64.zip"}
+					]
+				}
+			}
+		]
+	}`
+	index := new(chromeIndex)
+	if err := json.Unmarshal([]byte(indexJSON), index); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		version          string
+		platform         string
+		wantChrome       string
+		wantChromedriver string
+	}{
+		{
+			version:          "118.0.5993.70",
+			platform:         "linux64",
+			wantChrome:       "https://example.com/118.0.5993.70/linux64/chrome-linux64.zip",
+			wantChromedriver: "https://example.com/118.0.5993.70/linux64/chromedriver-linux64.zip",
+		},
+		{
+			version:          "118.0.5993.70",
+			platform:         "mac-arm64",
+			wantChrome:       "https://example.com/118.0.5993.70/mac-arm64/chrome-mac-arm64.zip",
+			wantChromedriver: "https://example.com/118.0.5993.70/mac-arm64/chromedriver-mac-arm64.zip",
+		},
+		{
+			version:    "113.0.5672.0",
+			platform:   "linux64",
+			wantChrome: "https://example.com/113.0.5672.0/linux64/chrome-linux64.zip",
+		},
+		{
+			version:  "118.0.5993.70",
+			platform: "mac-x64",
+		},
+		{
+			version:  "119.0.6045.105",
+			platform: "linux64",
+		},
+	}
+	for _, test := range tests {
+		gotChrome, gotChromedriver := pickChromeDownloads(index, test.version, test.platform)
+		if gotChrome != test.wantChrome || gotChromedriver != test.wantChromedriver {
+			t.Errorf("pickChromeDownloads(index, %q, %q) = %q, %q; want %q, %q",
+				test.version, test.platform, gotChrome, gotChromedriver, test.wantChrome, test.wantChromedriver)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+// Copyright 2020 YourBase Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package buildpack
+
+import (
+	"context"
+
+	"github.com/blang/semver"
+	"github.com/yourbase/yb"
+	"github.com/yourbase/yb/internal/biome"
+	"zombiezen.com/go/log"
+)
+
+// cmakeReleaseURL is the URL of a CMake release's assets.
+const cmakeReleaseURL = "https://github.com/Kitware/CMake/releases/download/v{{.Version}}/"
+
+func installCMake(ctx context.Context, sys Sys, spec yb.BuildpackSpec) (biome.Environment, error) {
+	cmakeDir := sys.Biome.JoinPath(sys.Biome.Dirs().Tools, "cmake", "cmake-"+spec.Version())
+	desc := sys.Biome.Describe()
+	binDir := sys.Biome.JoinPath(cmakeDir, "bin")
+	if desc.OS == biome.MacOS {
+		binDir = sys.Biome.JoinPath(cmakeDir, "CMake.app", "Contents", "bin")
+	}
+	env := biome.Environment{
+		PrependPath: []string{binDir},
+	}
+
+	// If directory already exists, then use it.
+	if _, err := biome.EvalSymlinks(ctx, sys.Biome, cmakeDir); err == nil {
+		log.Infof(ctx, "CMake v%s located in %s", spec.Version(), cmakeDir)
+		recordInstall(ctx, sys, spec, cmakeDir)
+		return env, nil
+	}
+
+	log.Infof(ctx, "Installing CMake v%s in %s", spec.Version(), cmakeDir)
+	downloadURL, err := cmakeDownloadURL(spec.Version(), desc)
+	if err != nil {
+		return biome.Environment{}, err
+	}
+	checksumURL, err := templateToString(cmakeReleaseURL+"cmake-{{.Version}}-SHA-256.txt", struct {
+		Version string
+	}{spec.Version()})
+	if err != nil {
+		return biome.Environment{}, err
+	}
+	ctx, err = verifyDownload(ctx, sys, downloadURL, checksumURL)
+	if err != nil {
+		return biome.Environment{}, err
+	}
+	if err := extract(ctx, sys, cmakeDir, downloadURL, stripTopDirectory); err != nil {
+		return biome.Environment{}, err
+	}
+	recordInstall(ctx, sys, spec, cmakeDir)
+	return env, nil
+}
+
+func cmakeDownloadURL(version string, desc *biome.Descriptor) (string, error) {
+	const template = cmakeReleaseURL + "cmake-{{.Version}}-{{.Platform}}.tar.gz"
+	names := map[biome.Descriptor]string{
+		{OS: biome.Linux, Arch: biome.Intel64}: "linux-x86_64",
+		{OS: biome.Linux, Arch: biome.ARM64}:   "linux-aarch64",
+		{OS: biome.MacOS, Arch: biome.Intel64}: "macos-universal",
+		{OS: biome.MacOS, Arch: biome.ARM64}:   "macos-universal",
+	}
+	// CMake changed its archive names in 3.20.
+	if v, err := semver.ParseTolerant(version); err == nil && v.LT(semver.Version{Major: 3, Minor: 20}) {
+		names = map[biome.Descriptor]string{
+			{OS: biome.Linux, Arch: biome.Intel64}: "Linux-x86_64",
+			{OS: biome.Linux, Arch: biome.ARM64}:   "Linux-aarch64",
+			{OS: biome.MacOS, Arch: biome.Intel64}: "Darwin-x86_64",
+		}
+	}
+	platform, err := platformName("CMake", names, desc)
+	if err != nil {
+		return "", err
+	}
+	return templateToString(template, struct {
+		Version  string
+		Platform string
+	}{version, platform})
+}
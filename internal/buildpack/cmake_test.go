@@ -0,0 +1,70 @@
+// Copyright 2020 YourBase Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package buildpack
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/yourbase/yb/internal/biome"
+	"zombiezen.com/go/log/testlog"
+)
+
+func TestCMake(t *testing.T) {
+	const version = "3.20.2"
+	ctx := testlog.WithTB(context.Background(), t)
+	cmakeBiome, _ := testInstall(ctx, t, "cmake:"+version)
+	versionOutput := new(strings.Builder)
+	err := cmakeBiome.Run(ctx, &biome.Invocation{
+		Argv:   []string{"cmake", "--version"},
+		Stdout: versionOutput,
+		Stderr: versionOutput,
+	})
+	t.Logf("cmake --version output:\n%s", versionOutput)
+	if err != nil {
+		t.Errorf("cmake --version: %v", err)
+	}
+	if got := versionOutput.String(); !strings.Contains(got, version) {
+		t.Errorf("cmake --version output does not include %q", version)
+	}
+}
+
+func TestCMakeDownloadURL(t *testing.T) {
+	t.Run("3.20.2", func(t *testing.T) {
+		testDownloadURLs(t, http.MethodGet, func(desc *biome.Descriptor) (string, error) {
+			return cmakeDownloadURL("3.20.2", desc)
+		}, []downloadURLTest{
+			{os: biome.Linux, arch: biome.Intel64, want: "https://github.com/Kitware/CMake/releases/download/v3.20.2/cmake-3.20.2-linux-x86_64.tar.gz"},
+			{os: biome.Linux, arch: biome.Intel32, want: ""},
+			{os: biome.Linux, arch: biome.ARM64, want: "https://github.com/Kitware/CMake/releases/download/v3.20.2/cmake-3.20.2-linux-aarch64.tar.gz"},
+			{os: biome.MacOS, arch: biome.Intel64, want: "https://github.com/Kitware/CMake/releases/download/v3.20.2/cmake-3.20.2-macos-universal.tar.gz"},
+			{os: biome.MacOS, arch: biome.ARM64, want: "https://github.com/Kitware/CMake/releases/download/v3.20.2/cmake-3.20.2-macos-universal.tar.gz"},
+		})
+	})
+	t.Run("3.19.8", func(t *testing.T) {
+		testDownloadURLs(t, http.MethodGet, func(desc *biome.Descriptor) (string, error) {
+			return cmakeDownloadURL("3.19.8", desc)
+		}, []downloadURLTest{
+			{os: biome.Linux, arch: biome.Intel64, want: "https://github.com/Kitware/CMake/releases/download/v3.19.8/cmake-3.19.8-Linux-x86_64.tar.gz"},
+			{os: biome.Linux, arch: biome.ARM64, want: "https://github.com/Kitware/CMake/releases/download/v3.19.8/cmake-3.19.8-Linux-aarch64.tar.gz"},
+			{os: biome.MacOS, arch: biome.Intel64, want: "https://github.com/Kitware/CMake/releases/download/v3.19.8/cmake-3.19.8-Darwin-x86_64.tar.gz"},
+			{os: biome.MacOS, arch: biome.ARM64, want: ""},
+		})
+	})
+}
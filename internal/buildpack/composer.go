@@ -0,0 +1,100 @@
+// Copyright 2020 YourBase Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package buildpack
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/yourbase/commons/xcontext"
+	"github.com/yourbase/yb"
+	"github.com/yourbase/yb/internal/biome"
+	"zombiezen.com/go/log"
+)
+
+func installComposer(ctx context.Context, sys Sys, spec yb.BuildpackSpec) (_ biome.Environment, err error) {
+	composerDir := sys.Biome.JoinPath(sys.Biome.Dirs().Tools, "composer", "composer-"+spec.Version())
+	composerHome := sys.Biome.JoinPath(sys.Biome.Dirs().Home, ".composer")
+	env := biome.Environment{
+		Vars: map[string]string{
+			"COMPOSER_HOME": composerHome,
+		},
+		PrependPath: []string{
+			composerDir,
+			// Where `composer global require` puts executables.
+			sys.Biome.JoinPath(composerHome, "vendor", "bin"),
+		},
+	}
+
+	// If directory already exists, then use it.
+	if _, err := biome.EvalSymlinks(ctx, sys.Biome, composerDir); err == nil {
+		log.Infof(ctx, "Composer v%s located in %s", spec.Version(), composerDir)
+		recordInstall(ctx, sys, spec, composerDir)
+		return env, nil
+	}
+
+	log.Infof(ctx, "Installing Composer v%s in %s", spec.Version(), composerDir)
+	downloadURL, err := composerDownloadURL(spec.Version())
+	if err != nil {
+		return biome.Environment{}, err
+	}
+	ctx, err = verifyDownload(ctx, sys, downloadURL, downloadURL+".sha256sum")
+	if err != nil {
+		return biome.Environment{}, err
+	}
+	if err := installBinary(ctx, sys, composerDir, downloadURL); err != nil {
+		return biome.Environment{}, err
+	}
+	defer func() {
+		// Remove the directory on failure so that later builds retry.
+		if err != nil {
+			rmCtx, cancel := xcontext.KeepAlive(ctx, 10*time.Second)
+			defer cancel()
+			rmErr := sys.Biome.Run(rmCtx, &biome.Invocation{
+				Argv:   []string{"rm", "-rf", composerDir},
+				Stdout: sys.Stdout,
+				Stderr: sys.Stderr,
+			})
+			if rmErr != nil {
+				log.Warnf(ctx, "Cleaning up failed Composer install: %v", rmErr)
+			}
+		}
+	}()
+	// composer.phar has a shebang line, so it can be run directly.
+	err = sys.Biome.Run(ctx, &biome.Invocation{
+		Argv: []string{
+			"mv",
+			sys.Biome.JoinPath(composerDir, "composer.phar"),
+			sys.Biome.JoinPath(composerDir, "composer"),
+		},
+		Stdout: sys.Stdout,
+		Stderr: sys.Stderr,
+	})
+	if err != nil {
+		return biome.Environment{}, fmt.Errorf("install composer: %w", err)
+	}
+	recordInstall(ctx, sys, spec, composerDir)
+	return env, nil
+}
+
+func composerDownloadURL(version string) (string, error) {
+	const template = "https://getcomposer.org/download/{{.Version}}/composer.phar"
+	return templateToString(template, struct {
+		Version string
+	}{version})
+}
@@ -0,0 +1,353 @@
+// Copyright 2020 YourBase Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package buildpack
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/yourbase/yb"
+	"github.com/yourbase/yb/internal/biome"
+	"zombiezen.com/go/log"
+)
+
+// cpythonReleasesURL lists the releases of python-build-standalone, which
+// publishes relocatable CPython builds.
+const cpythonReleasesURL = "https://api.github.com/repos/indygreg/python-build-standalone/releases"
+
+// cpythonMaxPages is the number of pages of releases that cpythonDownloadURL
+// searches before giving up. Each page has 100 releases.
+const cpythonMaxPages = 10
+
+// cpythonLockStamp is the name of the file in the virtualenv that records the
+// dependency file last installed into it.
+const cpythonLockStamp = ".yb-lockfile"
+
+// pythonLockfiles lists the dependency files that the cpython buildpack
+// installs into its virtualenv in order of precedence, along with the commands
+// that install them. Commands run in the package directory with the virtualenv
+// activated. If tool is set, it names a package that is installed into a
+// separate virtualenv, so that it doesn't mix with the package's
+// dependencies, and commands named tool run from there.
+var pythonLockfiles = []struct {
+	name string
+	tool string
+	argv [][]string
+}{
+	{
+		name: "poetry.lock",
+		tool: "poetry",
+		argv: [][]string{
+			{"poetry", "install", "--no-root", "--no-interaction"},
+		},
+	},
+	{
+		name: "Pipfile.lock",
+		tool: "pipenv",
+		argv: [][]string{
+			{"pipenv", "sync"},
+		},
+	},
+	{
+		name: "requirements.txt",
+		argv: [][]string{
+			{"pip", "install", "-r", "requirements.txt"},
+		},
+	},
+}
+
+// installCPython installs a standalone CPython build and creates a virtualenv
+// for it in the build's home directory. If the package has a dependency file,
+// it is installed into the virtualenv whenever the file changes.
+func installCPython(ctx context.Context, sys Sys, spec yb.BuildpackSpec) (biome.Environment, error) {
+	dirs := sys.Biome.Dirs()
+	pythonDir := sys.Biome.JoinPath(dirs.Tools, "cpython", "cpython-"+spec.Version())
+	// Build homes are specific to a target, so each target gets its own
+	// virtualenv.
+	venvDir := sys.Biome.JoinPath(dirs.Home, ".venvs", "cpython-"+spec.Version())
+	env := biome.Environment{
+		Vars: map[string]string{
+			"VIRTUAL_ENV": venvDir,
+			// Install into the virtualenv instead of one Poetry manages.
+			"POETRY_VIRTUALENVS_CREATE": "false",
+		},
+		PrependPath: []string{
+			sys.Biome.JoinPath(venvDir, "bin"),
+			sys.Biome.JoinPath(pythonDir, "bin"),
+		},
+	}
+
+	if _, err := biome.EvalSymlinks(ctx, sys.Biome, pythonDir); err == nil {
+		log.Infof(ctx, "CPython v%s located in %s", spec.Version(), pythonDir)
+	} else {
+		log.Infof(ctx, "Installing CPython v%s in %s", spec.Version(), pythonDir)
+		downloadURL, checksumURL, err := cpythonDownloadURL(ctx, sys, spec.Version())
+		if err != nil {
+			return biome.Environment{}, err
+		}
+		ctx, err = verifyDownload(ctx, sys, downloadURL, checksumURL)
+		if err != nil {
+			return biome.Environment{}, err
+		}
+		if err := extract(ctx, sys, pythonDir, downloadURL, stripTopDirectory); err != nil {
+			return biome.Environment{}, err
+		}
+	}
+	recordInstall(ctx, sys, spec, pythonDir)
+
+	if _, err := biome.EvalSymlinks(ctx, sys.Biome, venvDir); err != nil {
+		log.Infof(ctx, "Creating virtualenv in %s", venvDir)
+		err := sys.Biome.Run(ctx, &biome.Invocation{
+			Argv:   []string{sys.Biome.JoinPath(pythonDir, "bin", "python3"), "-m", "venv", venvDir},
+			Stdout: sys.Stdout,
+			Stderr: sys.Stderr,
+		})
+		if err != nil {
+			return biome.Environment{}, fmt.Errorf("create virtualenv: %w", err)
+		}
+	}
+	if err := syncPythonLockfile(ctx, sys, env, venvDir); err != nil {
+		return biome.Environment{}, err
+	}
+	return env, nil
+}
+
+// cpythonDownloadURL finds the newest python-build-standalone release that
+// has the given CPython version for the biome's platform. It returns the URL
+// of the archive and the URL of its checksum file. If the build is locked, the
+// archive recorded in the lock file is used instead, which avoids searching
+// the GitHub API.
+func cpythonDownloadURL(ctx context.Context, sys Sys, version string) (url, checksumURL string, _ error) {
+	triple, err := platformName("python-build-standalone", map[biome.Descriptor]string{
+		{OS: biome.Linux, Arch: biome.Intel64}: "x86_64-unknown-linux-gnu",
+		{OS: biome.Linux, Arch: biome.ARM64}:   "aarch64-unknown-linux-gnu",
+		{OS: biome.MacOS, Arch: biome.Intel64}: "x86_64-apple-darwin",
+		{OS: biome.MacOS, Arch: biome.ARM64}:   "aarch64-apple-darwin",
+	}, sys.Biome.Describe())
+	if err != nil {
+		return "", "", err
+	}
+	if url := lockedCPythonAsset(sys, version, triple); url != "" {
+		log.Debugf(ctx, "Using CPython %s archive from lock file: %s", version, url)
+		return url, url + ".sha256", nil
+	}
+	return findCPythonAsset(ctx, sys, cpythonReleasesURL, version, triple)
+}
+
+// lockedCPythonAsset returns the URL of an archive of the given CPython
+// version and target triple that the downloader has a locked checksum for, or
+// the empty string if there is none.
+func lockedCPythonAsset(sys Sys, version, triple string) string {
+	if sys.Downloader == nil {
+		return ""
+	}
+	var found string
+	for url := range sys.Downloader.Checksums {
+		if !isCPythonAsset(path.Base(url), version, triple) {
+			continue
+		}
+		// Pick one deterministically if several match.
+		if found == "" || url > found {
+			found = url
+		}
+	}
+	return found
+}
+
+// findCPythonAsset searches the GitHub releases listed at releasesURL for an
+// archive of the given CPython version and target triple. GitHub lists
+// releases from newest to oldest, one page at a time.
+func findCPythonAsset(ctx context.Context, sys Sys, releasesURL, version, triple string) (url, checksumURL string, _ error) {
+	for page := 1; page <= cpythonMaxPages; page++ {
+		var releases []cpythonRelease
+		pageURL := fmt.Sprintf("%s?per_page=100&page=%d", releasesURL, page)
+		if err := fetchJSON(ctx, sys, pageURL, &releases); err != nil {
+			return "", "", fmt.Errorf("find cpython %s: %w", version, err)
+		}
+		if len(releases) == 0 {
+			break
+		}
+		if url, checksumURL := pickCPythonAsset(releases, version, triple); url != "" {
+			return url, checksumURL, nil
+		}
+	}
+	return "", "", fmt.Errorf("find cpython %s: no python-build-standalone release has CPython %s for %s", version, version, triple)
+}
+
+// cpythonRelease is the subset of a GitHub release that cpythonDownloadURL
+// uses.
+type cpythonRelease struct {
+	Assets []struct {
+		Name string `json:"name"`
+		URL  string `json:"browser_download_url"`
+	} `json:"assets"`
+}
+
+// pickCPythonAsset returns the download URLs of the first "install_only"
+// archive of the given version and target triple in releases and of its
+// ".sha256" checksum file. Archives without a checksum file are skipped.
+// Asset names have the form
+// "cpython-3.9.10+20220227-x86_64-unknown-linux-gnu-install_only.tar.gz" or,
+// in older releases,
+// "cpython-3.9.6-x86_64-unknown-linux-gnu-install_only-20210724T1424.tar.gz".
+func pickCPythonAsset(releases []cpythonRelease, version, triple string) (url, checksumURL string) {
+	for _, r := range releases {
+		urls := make(map[string]string, len(r.Assets))
+		for _, asset := range r.Assets {
+			urls[asset.Name] = asset.URL
+		}
+		for _, asset := range r.Assets {
+			if !isCPythonAsset(asset.Name, version, triple) {
+				continue
+			}
+			if sum := urls[asset.Name+".sha256"]; sum != "" {
+				return asset.URL, sum
+			}
+		}
+	}
+	return "", ""
+}
+
+// isCPythonAsset reports whether name is the name of an "install_only"
+// archive of the given version and target triple.
+func isCPythonAsset(name, version, triple string) bool {
+	rest := strings.TrimPrefix(name, "cpython-"+version)
+	if rest == name || rest == "" || (rest[0] != '+' && rest[0] != '-') {
+		return false
+	}
+	return strings.Contains(rest, "-"+triple+"-install_only") && strings.HasSuffix(rest, ".tar.gz")
+}
+
+// syncPythonLockfile installs the package's dependency file into the
+// virtualenv unless the file is unchanged since it was last installed.
+func syncPythonLockfile(ctx context.Context, sys Sys, env biome.Environment, venvDir string) error {
+	name, digest, err := findPythonLockfile(ctx, sys)
+	if err != nil {
+		return err
+	}
+	if name == "" {
+		return nil
+	}
+	stampPath := sys.Biome.JoinPath(venvDir, cpythonLockStamp)
+	stamp := name + " " + digest + "\n"
+	if got, err := readBiomeFile(ctx, sys, stampPath); err == nil && got == stamp {
+		log.Debugf(ctx, "%s unchanged since last install", name)
+		return nil
+	}
+	log.Infof(ctx, "Installing dependencies from %s", name)
+	for _, lf := range pythonLockfiles {
+		if lf.name != name {
+			continue
+		}
+		var toolExe string
+		if lf.tool != "" {
+			toolExe, err = installPythonTool(ctx, sys, env, venvDir+"-tools", lf.tool)
+			if err != nil {
+				return fmt.Errorf("install %s: %w", name, err)
+			}
+		}
+		for _, argv := range lf.argv {
+			if toolExe != "" && argv[0] == lf.tool {
+				argv = append([]string{toolExe}, argv[1:]...)
+			}
+			err := sys.Biome.Run(ctx, &biome.Invocation{
+				Argv:   argv,
+				Env:    env,
+				Stdout: sys.Stdout,
+				Stderr: sys.Stderr,
+			})
+			if err != nil {
+				return fmt.Errorf("install %s: %w", name, err)
+			}
+		}
+	}
+	if err := biome.WriteFile(ctx, sys.Biome, stampPath, strings.NewReader(stamp)); err != nil {
+		return fmt.Errorf("install %s: %w", name, err)
+	}
+	return nil
+}
+
+// installPythonTool installs the named Python package into the virtualenv at
+// toolsDir, creating it if needed, and returns the path of the package's
+// program. The package is only installed once.
+func installPythonTool(ctx context.Context, sys Sys, env biome.Environment, toolsDir, tool string) (string, error) {
+	exe := sys.Biome.JoinPath(toolsDir, "bin", tool)
+	if _, err := biome.EvalSymlinks(ctx, sys.Biome, exe); err == nil {
+		return exe, nil
+	}
+	log.Infof(ctx, "Installing %s in %s", tool, toolsDir)
+	commands := [][]string{
+		{"python3", "-m", "venv", toolsDir},
+		{sys.Biome.JoinPath(toolsDir, "bin", "pip"), "install", "--quiet", tool},
+	}
+	for _, argv := range commands {
+		err := sys.Biome.Run(ctx, &biome.Invocation{
+			Argv:   argv,
+			Env:    env,
+			Stdout: sys.Stdout,
+			Stderr: sys.Stderr,
+		})
+		if err != nil {
+			return "", fmt.Errorf("install %s: %w", tool, err)
+		}
+	}
+	return exe, nil
+}
+
+// findPythonLockfile returns the name and hex-encoded SHA-256 digest of the
+// package's preferred dependency file. It returns an empty name if the package
+// has none.
+func findPythonLockfile(ctx context.Context, sys Sys) (name, digest string, _ error) {
+	for _, lf := range pythonLockfiles {
+		path := sys.Biome.JoinPath(sys.Biome.Dirs().Package, lf.name)
+		if _, err := biome.EvalSymlinks(ctx, sys.Biome, path); err != nil {
+			continue
+		}
+		h := sha256.New()
+		err := sys.Biome.Run(ctx, &biome.Invocation{
+			Argv:   []string{"cat", path},
+			Stdout: h,
+			Stderr: sys.Stderr,
+		})
+		if err != nil {
+			return "", "", fmt.Errorf("read %s: %w", lf.name, err)
+		}
+		return lf.name, hex.EncodeToString(h.Sum(nil)), nil
+	}
+	return "", "", nil
+}
+
+// readBiomeFile returns the content of a file in the biome.
+func readBiomeFile(ctx context.Context, sys Sys, path string) (string, error) {
+	if _, err := biome.EvalSymlinks(ctx, sys.Biome, path); err != nil {
+		return "", err
+	}
+	sb := new(strings.Builder)
+	err := sys.Biome.Run(ctx, &biome.Invocation{
+		Argv:   []string{"cat", path},
+		Stdout: sb,
+		Stderr: sys.Stderr,
+	})
+	if err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
@@ -0,0 +1,320 @@
+// Copyright 2020 YourBase Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package buildpack
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/yourbase/commons/http/headers"
+	"github.com/yourbase/yb/internal/biome"
+	"github.com/yourbase/yb/internal/ybdata"
+	"zombiezen.com/go/log/testlog"
+)
+
+func TestPickCPythonAsset(t *testing.T) {
+	const releasesJSON = `[
+		{"assets": [
+			{"name": "cpython-3.9.10+20220227-x86_64-unknown-linux-gnu-install_only.tar.gz", "browser_download_url": "https://example.com/20220227/3.9.10-linux"},
+			{"name": "cpython-3.9.10+20220227-x86_64-unknown-linux-gnu-install_only.tar.gz.sha256", "browser_download_url": "https://example.com/20220227/3.9.10-linux.sha256"},
+			{"name": "cpython-3.9.10+20220227-x86_64-unknown-linux-gnu-pgo+lto-full.tar.zst", "browser_download_url": "https://example.com/20220227/3.9.10-linux-full"},
+			{"name": "cpython-3.10.2+20220227-aarch64-apple-darwin-install_only.tar.gz", "browser_download_url": "https://example.com/20220227/3.10.2-mac"},
+			{"name": "cpython-3.10.2+20220227-aarch64-apple-darwin-install_only.tar.gz.sha256", "browser_download_url": "https://example.com/20220227/3.10.2-mac.sha256"},
+			{"name": "cpython-3.10.2+20220227-x86_64-apple-darwin-install_only.tar.gz", "browser_download_url": "https://example.com/20220227/3.10.2-mac-intel"}
+		]},
+		{"assets": [
+			{"name": "cpython-3.9.1-x86_64-unknown-linux-gnu-install_only-20210103T1125.tar.gz", "browser_download_url": "https://example.com/20210103/3.9.1-linux"},
+			{"name": "cpython-3.9.1-x86_64-unknown-linux-gnu-install_only-20210103T1125.tar.gz.sha256", "browser_download_url": "https://example.com/20210103/3.9.1-linux.sha256"},
+			{"name": "cpython-3.9.10-x86_64-unknown-linux-gnu-install_only-20210103T1125.tar.gz", "browser_download_url": "https://example.com/20210103/3.9.10-linux"},
+			{"name": "cpython-3.9.10-x86_64-unknown-linux-gnu-install_only-20210103T1125.tar.gz.sha256", "browser_download_url": "https://example.com/20210103/3.9.10-linux.sha256"},
+			{"name": "cpython-3.10.2-x86_64-apple-darwin-install_only-20210103T1125.tar.gz", "browser_download_url": "https://example.com/20210103/3.10.2-mac-intel"},
+			{"name": "cpython-3.10.2-x86_64-apple-darwin-install_only-20210103T1125.tar.gz.sha256", "browser_download_url": "https://example.com/20210103/3.10.2-mac-intel.sha256"}
+		]}
+	]`
+	var releases []cpythonRelease
+	if err := json.Unmarshal([]byte(releasesJSON), &releases); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		version      string
+		triple       string
+		want         string
+		wantChecksum string
+	}{
+		{
+			version:      "3.9.10",
+			triple:       "x86_64-unknown-linux-gnu",
+			want:         "https://example.com/20220227/3.9.10-linux",
+			wantChecksum: "https://example.com/20220227/3.9.10-linux.sha256",
+		},
+		{
+			version:      "3.9.1",
+			triple:       "x86_64-unknown-linux-gnu",
+			want:         "https://example.com/20210103/3.9.1-linux",
+			wantChecksum: "https://example.com/20210103/3.9.1-linux.sha256",
+		},
+		{
+			version:      "3.10.2",
+			triple:       "aarch64-apple-darwin",
+			want:         "https://example.com/20220227/3.10.2-mac",
+			wantChecksum: "https://example.com/20220227/3.10.2-mac.sha256",
+		},
+		{
+			// The newest build has no checksum file.
+			version:      "3.10.2",
+			triple:       "x86_64-apple-darwin",
+			want:         "https://example.com/20210103/3.10.2-mac-intel",
+			wantChecksum: "https://example.com/20210103/3.10.2-mac-intel.sha256",
+		},
+		{
+			version: "3.10.2",
+			triple:  "x86_64-unknown-linux-gnu",
+			want:    "",
+		},
+		{
+			version: "3.9",
+			triple:  "x86_64-unknown-linux-gnu",
+			want:    "",
+		},
+	}
+	for _, test := range tests {
+		got, gotChecksum := pickCPythonAsset(releases, test.version, test.triple)
+		if got != test.want || gotChecksum != test.wantChecksum {
+			t.Errorf("pickCPythonAsset(releases, %q, %q) = %q, %q; want %q, %q", test.version, test.triple, got, gotChecksum, test.want, test.wantChecksum)
+		}
+	}
+}
+
+func TestFindCPythonAsset(t *testing.T) {
+	pages := map[string]string{
+		"1": `[{"assets": [
+			{"name": "cpython-3.10.2+20220227-x86_64-unknown-linux-gnu-install_only.tar.gz", "browser_download_url": "https://example.com/3.10.2"},
+			{"name": "cpython-3.10.2+20220227-x86_64-unknown-linux-gnu-install_only.tar.gz.sha256", "browser_download_url": "https://example.com/3.10.2.sha256"}
+		]}]`,
+		"2": `[{"assets": [
+			{"name": "cpython-3.9.1-x86_64-unknown-linux-gnu-install_only-20210103T1125.tar.gz", "browser_download_url": "https://example.com/3.9.1"},
+			{"name": "cpython-3.9.1-x86_64-unknown-linux-gnu-install_only-20210103T1125.tar.gz.sha256", "browser_download_url": "https://example.com/3.9.1.sha256"}
+		]}]`,
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		content, ok := pages[r.URL.Query().Get("page")]
+		if !ok {
+			content = "[]"
+		}
+		w.Header().Set(headers.ContentLength, strconv.Itoa(len(content)))
+		if r.Method == http.MethodHead {
+			return
+		}
+		io.WriteString(w, content)
+	}))
+	t.Cleanup(srv.Close)
+	ctx := testlog.WithTB(context.Background(), t)
+	sys := Sys{
+		Biome:      newLocalTestBiome(t),
+		Stdout:     ioutil.Discard,
+		Stderr:     ioutil.Discard,
+		Downloader: ybdata.NewDownloader(t.TempDir()),
+	}
+	sys.Downloader.Client = srv.Client()
+
+	url, checksumURL, err := findCPythonAsset(ctx, sys, srv.URL+"/releases", "3.9.1", "x86_64-unknown-linux-gnu")
+	if err != nil || url != "https://example.com/3.9.1" || checksumURL != "https://example.com/3.9.1.sha256" {
+		t.Errorf("findCPythonAsset(..., \"3.9.1\", ...) = %q, %q, %v; want %q, %q, <nil>", url, checksumURL, err, "https://example.com/3.9.1", "https://example.com/3.9.1.sha256")
+	}
+	if url, _, err := findCPythonAsset(ctx, sys, srv.URL+"/releases", "3.8.6", "x86_64-unknown-linux-gnu"); err == nil {
+		t.Errorf("findCPythonAsset(..., \"3.8.6\", ...) = %q, _, <nil>; want error", url)
+	}
+}
+
+func TestLockedCPythonAsset(t *testing.T) {
+	const (
+		url1 = "https://github.com/indygreg/python-build-standalone/releases/download/20210103/cpython-3.9.1-x86_64-unknown-linux-gnu-install_only-20210103T1125.tar.gz"
+		url2 = "https://github.com/indygreg/python-build-standalone/releases/download/20220227/cpython-3.10.2+20220227-x86_64-unknown-linux-gnu-install_only.tar.gz"
+	)
+	sys := Sys{Downloader: ybdata.NewDownloader(t.TempDir())}
+	sys.Downloader.Checksums = map[string]string{
+		url1:             "0123",
+		url1 + ".sha256": "4567",
+		url2:             "89ab",
+	}
+	tests := []struct {
+		version string
+		triple  string
+		want    string
+	}{
+		{version: "3.9.1", triple: "x86_64-unknown-linux-gnu", want: url1},
+		{version: "3.10.2", triple: "x86_64-unknown-linux-gnu", want: url2},
+		{version: "3.10.2", triple: "aarch64-unknown-linux-gnu", want: ""},
+		{version: "3.8.6", triple: "x86_64-unknown-linux-gnu", want: ""},
+	}
+	for _, test := range tests {
+		if got := lockedCPythonAsset(sys, test.version, test.triple); got != test.want {
+			t.Errorf("lockedCPythonAsset(sys, %q, %q) = %q; want %q", test.version, test.triple, got, test.want)
+		}
+	}
+}
+
+func TestFindPythonLockfile(t *testing.T) {
+	ctx := testlog.WithTB(context.Background(), t)
+	bio := newLocalTestBiome(t)
+	sys := Sys{
+		Biome:  bio,
+		Stdout: ioutil.Discard,
+		Stderr: ioutil.Discard,
+	}
+	pkgDir := bio.Dirs().Package
+
+	name, _, err := findPythonLockfile(ctx, sys)
+	if err != nil || name != "" {
+		t.Errorf("findPythonLockfile(...) with no files = %q, _, %v; want \"\", _, <nil>", name, err)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(pkgDir, "requirements.txt"), []byte("requests==2.25.1\n"), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	name, digest1, err := findPythonLockfile(ctx, sys)
+	if err != nil || name != "requirements.txt" {
+		t.Errorf("findPythonLockfile(...) = %q, _, %v; want \"requirements.txt\", _, <nil>", name, err)
+	}
+
+	// poetry.lock takes precedence.
+	if err := ioutil.WriteFile(filepath.Join(pkgDir, "poetry.lock"), []byte("[metadata]\n"), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	name, digest2, err := findPythonLockfile(ctx, sys)
+	if err != nil || name != "poetry.lock" {
+		t.Errorf("findPythonLockfile(...) = %q, _, %v; want \"poetry.lock\", _, <nil>", name, err)
+	}
+	if digest1 == digest2 {
+		t.Errorf("requirements.txt and poetry.lock have the same digest %s", digest1)
+	}
+}
+
+func TestSyncPythonLockfile(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Test uses a shell script")
+	}
+	ctx := testlog.WithTB(context.Background(), t)
+	bio := newLocalTestBiome(t)
+	output := new(strings.Builder)
+	sys := Sys{
+		Biome:  bio,
+		Stdout: output,
+		Stderr: output,
+	}
+	requirementsPath := filepath.Join(bio.Dirs().Package, "requirements.txt")
+	if err := ioutil.WriteFile(requirementsPath, []byte("requests==2.25.1\n"), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	// Replace pip with a script that counts its runs.
+	venvDir := filepath.Join(bio.Dirs().Home, ".venvs", "cpython-3.9.1")
+	countPath := filepath.Join(bio.Dirs().Home, "pip-runs")
+	const pipScript = "#!/bin/sh\necho run >> \"$HOME/pip-runs\"\n"
+	if err := os.MkdirAll(filepath.Join(venvDir, "bin"), 0o777); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(venvDir, "bin", "pip"), []byte(pipScript), 0o777); err != nil {
+		t.Fatal(err)
+	}
+	env := biome.Environment{PrependPath: []string{filepath.Join(venvDir, "bin")}}
+	pipRuns := func() int {
+		t.Helper()
+		data, err := ioutil.ReadFile(countPath)
+		if os.IsNotExist(err) {
+			return 0
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		return strings.Count(string(data), "run")
+	}
+
+	if err := syncPythonLockfile(ctx, sys, env, venvDir); err != nil {
+		t.Fatalf("first syncPythonLockfile: %v\noutput:\n%s", err, output)
+	}
+	if got := pipRuns(); got != 1 {
+		t.Errorf("after first sync, pip ran %d times; want 1", got)
+	}
+	if err := syncPythonLockfile(ctx, sys, env, venvDir); err != nil {
+		t.Fatalf("second syncPythonLockfile: %v\noutput:\n%s", err, output)
+	}
+	if got := pipRuns(); got != 1 {
+		t.Errorf("after sync with unchanged requirements.txt, pip ran %d times; want 1", got)
+	}
+	if err := ioutil.WriteFile(requirementsPath, []byte("requests==2.26.0\n"), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	if err := syncPythonLockfile(ctx, sys, env, venvDir); err != nil {
+		t.Fatalf("third syncPythonLockfile: %v\noutput:\n%s", err, output)
+	}
+	if got := pipRuns(); got != 2 {
+		t.Errorf("after sync with changed requirements.txt, pip ran %d times; want 2", got)
+	}
+}
+
+func TestSyncPythonLockfileTool(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Test uses a shell script")
+	}
+	ctx := testlog.WithTB(context.Background(), t)
+	bio := newLocalTestBiome(t)
+	output := new(strings.Builder)
+	sys := Sys{
+		Biome:  bio,
+		Stdout: output,
+		Stderr: output,
+	}
+	if err := ioutil.WriteFile(filepath.Join(bio.Dirs().Package, "poetry.lock"), []byte("[metadata]\n"), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	// Poetry runs from its own virtualenv, but installs into the target's.
+	venvDir := filepath.Join(bio.Dirs().Home, ".venvs", "cpython-3.9.1")
+	toolsDir := venvDir + "-tools"
+	const poetryScript = "#!/bin/sh\necho \"$VIRTUAL_ENV $*\" >> \"$HOME/poetry-runs\"\n"
+	for _, dir := range []string{venvDir, filepath.Join(toolsDir, "bin")} {
+		if err := os.MkdirAll(dir, 0o777); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ioutil.WriteFile(filepath.Join(toolsDir, "bin", "poetry"), []byte(poetryScript), 0o777); err != nil {
+		t.Fatal(err)
+	}
+	env := biome.Environment{
+		Vars:        map[string]string{"VIRTUAL_ENV": venvDir},
+		PrependPath: []string{filepath.Join(venvDir, "bin")},
+	}
+	if err := syncPythonLockfile(ctx, sys, env, venvDir); err != nil {
+		t.Fatalf("syncPythonLockfile: %v\noutput:\n%s", err, output)
+	}
+	got, err := ioutil.ReadFile(filepath.Join(bio.Dirs().Home, "poetry-runs"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := venvDir + " install --no-root --no-interaction\n"; string(got) != want {
+		t.Errorf("poetry runs = %q; want %q", got, want)
+	}
+}
@@ -2,7 +2,6 @@ package buildpack
 
 import (
 	"context"
-	"fmt"
 
 	"github.com/yourbase/yb"
 	"github.com/yourbase/yb/internal/biome"
@@ -20,38 +19,34 @@ func installDart(ctx context.Context, sys Sys, spec yb.BuildpackSpec) (biome.Env
 	// If directory already exists, then use it.
 	if _, err := biome.EvalSymlinks(ctx, sys.Biome, dartDir); err == nil {
 		log.Infof(ctx, "Dart v%s located in %s", spec.Version(), dartDir)
+		recordInstall(ctx, sys, spec, dartDir)
 		return env, nil
 	}
 
 	log.Infof(ctx, "Installing Dart v%s in %s", spec.Version(), dartDir)
-	desc := sys.Biome.Describe()
-	const template = "https://storage.googleapis.com/dart-archive/channels/stable/release/{{.Version}}/sdk/dartsdk-{{.OS}}-{{.Arch}}-release.zip"
-	data := struct {
-		Version string
-		OS      string
-		Arch    string
-	}{
-		spec.Version(),
-		map[string]string{
-			biome.Linux: "linux",
-			biome.MacOS: "macos",
-		}[desc.OS],
-		map[string]string{
-			biome.Intel64: "x64",
-		}[desc.Arch],
-	}
-	if data.OS == "" {
-		return biome.Environment{}, fmt.Errorf("unsupported os %s", desc.OS)
-	}
-	if data.OS == "" {
-		return biome.Environment{}, fmt.Errorf("unsupported architecture %s", desc.Arch)
-	}
-	downloadURL, err := templateToString(template, data)
+	downloadURL, err := dartDownloadURL(spec.Version(), sys.Biome.Describe())
 	if err != nil {
 		return biome.Environment{}, err
 	}
 	if err := extract(ctx, sys, dartDir, downloadURL, stripTopDirectory); err != nil {
 		return biome.Environment{}, err
 	}
+	recordInstall(ctx, sys, spec, dartDir)
 	return env, nil
 }
+
+func dartDownloadURL(version string, desc *biome.Descriptor) (string, error) {
+	const template = "https://storage.googleapis.com/dart-archive/channels/stable/release/{{.Version}}/sdk/dartsdk-{{.Platform}}-release.zip"
+	platform, err := platformName("Dart", map[biome.Descriptor]string{
+		{OS: biome.Linux, Arch: biome.Intel64}: "linux-x64",
+		{OS: biome.Linux, Arch: biome.ARM64}:   "linux-arm64",
+		{OS: biome.MacOS, Arch: biome.Intel64}: "macos-x64",
+	}, desc)
+	if err != nil {
+		return "", err
+	}
+	return templateToString(template, struct {
+		Version  string
+		Platform string
+	}{version, platform})
+}
@@ -18,6 +18,7 @@ package buildpack
 
 import (
 	"context"
+	"net/http"
 	"strings"
 	"testing"
 
@@ -43,3 +44,15 @@ func TestDart(t *testing.T) {
 		t.Errorf("dart --version output does not include %q", version)
 	}
 }
+
+func TestDartDownloadURL(t *testing.T) {
+	const version = "2.10.4"
+	testDownloadURLs(t, http.MethodHead, func(desc *biome.Descriptor) (string, error) {
+		return dartDownloadURL(version, desc)
+	}, []downloadURLTest{
+		{os: biome.Linux, arch: biome.Intel64, want: "https://storage.googleapis.com/dart-archive/channels/stable/release/2.10.4/sdk/dartsdk-linux-x64-release.zip"},
+		{os: biome.Linux, arch: biome.ARM64, want: "https://storage.googleapis.com/dart-archive/channels/stable/release/2.10.4/sdk/dartsdk-linux-arm64-release.zip"},
+		{os: biome.MacOS, arch: biome.Intel64, want: "https://storage.googleapis.com/dart-archive/channels/stable/release/2.10.4/sdk/dartsdk-macos-x64-release.zip"},
+		{os: biome.Windows, arch: biome.Intel64},
+	})
+}
@@ -0,0 +1,264 @@
+// Copyright 2020 YourBase Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package buildpack
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/yourbase/commons/xcontext"
+	"github.com/yourbase/yb"
+	"github.com/yourbase/yb/internal/biome"
+	"github.com/yourbase/yb/internal/ybdata"
+	"zombiezen.com/go/log"
+)
+
+// installDefined installs a buildpack declared in configuration.
+func installDefined(ctx context.Context, sys Sys, spec yb.BuildpackSpec, def *yb.BuildpackDefinition) (biome.Environment, error) {
+	data := definedTemplateData(def, spec, sys.Biome.Describe())
+	downloadURL, err := templateToString(def.URL, data)
+	if err != nil {
+		return biome.Environment{}, fmt.Errorf("url: %w", err)
+	}
+	// Include a hash of the definition in the directory name so that changing
+	// the definition causes a reinstall.
+	defJSON, err := json.Marshal(def)
+	if err != nil {
+		return biome.Environment{}, err
+	}
+	defHash := sha256.Sum256(defJSON)
+	installDir := sys.Biome.JoinPath(
+		sys.Biome.Dirs().Tools,
+		"defined",
+		spec.Name()+"-"+spec.Version()+"-"+hex.EncodeToString(defHash[:6]),
+	)
+	data.InstallDir = biome.AbsPath(sys.Biome, installDir)
+	env, err := definedEnv(sys.Biome, def, data)
+	if err != nil {
+		return biome.Environment{}, err
+	}
+
+	// If directory already exists, then use it.
+	if _, err := biome.EvalSymlinks(ctx, sys.Biome, installDir); err == nil {
+		log.Infof(ctx, "%s v%s located in %s", spec.Name(), spec.Version(), installDir)
+		recordInstall(ctx, sys, spec, installDir)
+		return env, nil
+	}
+
+	log.Infof(ctx, "Installing %s v%s in %s", spec.Name(), spec.Version(), installDir)
+	if def.Checksum != "" {
+		checksum, err := templateToString(def.Checksum, data)
+		if err != nil {
+			return biome.Environment{}, fmt.Errorf("checksum: %w", err)
+		}
+		ctx, err = verifyDownload(ctx, sys, downloadURL, checksum)
+		if err != nil {
+			return biome.Environment{}, err
+		}
+	}
+	format := def.ArchiveFormat()
+	if format == yb.ArchiveBinary {
+		if err := installBinary(ctx, sys, installDir, downloadURL); err != nil {
+			return biome.Environment{}, err
+		}
+		recordInstall(ctx, sys, spec, installDir)
+		return env, nil
+	}
+	mode := tarbomb
+	if def.StripTopDir {
+		mode = stripTopDirectory
+	}
+	if err := extractArchive(ctx, sys, installDir, downloadURL, "."+format, mode); err != nil {
+		return biome.Environment{}, err
+	}
+	recordInstall(ctx, sys, spec, installDir)
+	return env, nil
+}
+
+// definedTemplateData returns the data for expanding a buildpack definition's
+// templates in a biome with the given descriptor.
+func definedTemplateData(def *yb.BuildpackDefinition, spec yb.BuildpackSpec, desc *biome.Descriptor) yb.BuildpackTemplateData {
+	data := yb.BuildpackTemplateData{
+		Version: spec.Version(),
+		OS:      desc.OS,
+		Arch:    desc.Arch,
+	}
+	if name := def.OSNames[desc.OS]; name != "" {
+		data.OS = name
+	}
+	if name := def.ArchNames[desc.Arch]; name != "" {
+		data.Arch = name
+	}
+	return data
+}
+
+// definedEnv returns the environment for a buildpack definition. data.InstallDir
+// must be set.
+func definedEnv(bio biome.Biome, def *yb.BuildpackDefinition, data yb.BuildpackTemplateData) (biome.Environment, error) {
+	env := biome.Environment{
+		Vars: make(map[string]string, len(def.Env)),
+	}
+	for k, v := range def.Env {
+		expanded, err := templateToString(v, data)
+		if err != nil {
+			return biome.Environment{}, fmt.Errorf("env: %s: %w", k, err)
+		}
+		env.Vars[k] = expanded
+	}
+	pathDirs := def.Path
+	if len(pathDirs) == 0 && def.ArchiveFormat() == yb.ArchiveBinary {
+		pathDirs = []string{"."}
+	}
+	for _, dir := range pathDirs {
+		if dir == "." || dir == "" {
+			env.PrependPath = append(env.PrependPath, data.InstallDir)
+			continue
+		}
+		env.PrependPath = append(env.PrependPath, bio.JoinPath(append([]string{data.InstallDir}, strings.Split(dir, "/")...)...))
+	}
+	return env, nil
+}
+
+// installBinary downloads a single executable into dstDir.
+func installBinary(ctx context.Context, sys Sys, dstDir, url string) (err error) {
+	const cleanupTimeout = 10 * time.Second
+	f, err := sys.Downloader.Download(ctx, url)
+	if err != nil {
+		return fmt.Errorf("install %s in %s: %w", url, dstDir, err)
+	}
+	defer f.Close()
+
+	defer func() {
+		// Attempt to clean up if the install fails.
+		if err != nil {
+			ctx, cancel := xcontext.KeepAlive(ctx, cleanupTimeout)
+			defer cancel()
+			rmErr := sys.Biome.Run(ctx, &biome.Invocation{
+				Argv:   []string{"rm", "-rf", dstDir},
+				Stdout: sys.Stdout,
+				Stderr: sys.Stderr,
+			})
+			if rmErr != nil {
+				log.Warnf(ctx, "Failed to clean up %s: %v", dstDir, rmErr)
+			}
+		}
+	}()
+	if err := biome.MkdirAll(ctx, sys.Biome, dstDir); err != nil {
+		return fmt.Errorf("install %s in %s: %w", url, dstDir, err)
+	}
+	name := path.Base(strings.SplitN(url, "?", 2)[0])
+	dst := sys.Biome.JoinPath(dstDir, name)
+	if err := biome.WriteFile(ctx, sys.Biome, dst, f); err != nil {
+		return fmt.Errorf("install %s in %s: %w", url, dstDir, err)
+	}
+	err = sys.Biome.Run(ctx, &biome.Invocation{
+		Argv:   []string{"chmod", "+x", dst},
+		Stdout: sys.Stdout,
+		Stderr: sys.Stderr,
+	})
+	if err != nil {
+		return fmt.Errorf("install %s in %s: %w", url, dstDir, err)
+	}
+	return nil
+}
+
+var (
+	sha256Pattern = regexp.MustCompile(`^[0-9a-fA-F]{64}$`)
+	sha512Pattern = regexp.MustCompile(`^[0-9a-fA-F]{128}$`)
+)
+
+// verifyDownload downloads the given URL and checks its SHA-256 digest.
+// checksum is either a hex-encoded digest or the URL of a checksum file as
+// written by sha256sum. A hex-encoded SHA-512 digest is also accepted for
+// projects that only publish those.
+//
+// verifyDownload returns a context that holds the SHA-256 digest of the
+// verified content. Callers must download url with the returned context to
+// install it so that the Downloader rejects any content that was not verified,
+// such as a file that changed on the server in the meantime.
+func verifyDownload(ctx context.Context, sys Sys, url string, checksum string) (context.Context, error) {
+	want := checksum
+	algo, h := "sha256", sha256.New()
+	switch {
+	case sha512Pattern.MatchString(checksum):
+		algo, h = "sha512", sha512.New()
+	case !sha256Pattern.MatchString(checksum):
+		f, err := sys.Downloader.Download(ctx, checksum)
+		if err != nil {
+			return nil, fmt.Errorf("verify %s: %w", url, err)
+		}
+		want, err = parseChecksumFile(f, path.Base(strings.SplitN(url, "?", 2)[0]))
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("verify %s: %s: %w", url, checksum, err)
+		}
+	}
+	downloadCtx := ctx
+	if algo == "sha256" {
+		// Knowing the digest lets the download come from a remote cache.
+		downloadCtx = ybdata.WithChecksum(ctx, url, want)
+	}
+	f, err := sys.Downloader.Download(downloadCtx, url)
+	if err != nil {
+		return nil, fmt.Errorf("verify %s: %w", url, err)
+	}
+	defer f.Close()
+	h256 := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(h, h256), f); err != nil {
+		return nil, fmt.Errorf("verify %s: %w", url, err)
+	}
+	if got := hex.EncodeToString(h.Sum(nil)); !strings.EqualFold(got, want) {
+		return nil, fmt.Errorf("verify %s: %s %s does not match expected %s", url, algo, got, want)
+	}
+	log.Debugf(ctx, "Verified %s of %s", algo, url)
+	return ybdata.WithChecksum(ctx, url, hex.EncodeToString(h256.Sum(nil))), nil
+}
+
+// parseChecksumFile finds the digest for the given filename in the output of
+// sha256sum. A file containing a single digest applies to any filename. If
+// filename contains a slash, it must match the listed path exactly; otherwise,
+// it may match the listed path's last element.
+func parseChecksumFile(r io.Reader, filename string) (string, error) {
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		switch {
+		case len(fields) == 1 && sha256Pattern.MatchString(fields[0]):
+			return fields[0], nil
+		case len(fields) == 2 && sha256Pattern.MatchString(fields[0]):
+			// sha256sum marks files read in binary mode with an asterisk.
+			name := strings.TrimPrefix(fields[1], "*")
+			if name == filename || (!strings.Contains(filename, "/") && path.Base(name) == filename) {
+				return fields[0], nil
+			}
+		}
+	}
+	if err := s.Err(); err != nil {
+		return "", err
+	}
+	return "", fmt.Errorf("no checksum for %s", filename)
+}
@@ -0,0 +1,241 @@
+// Copyright 2020 YourBase Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package buildpack
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/yourbase/commons/http/headers"
+	"github.com/yourbase/yb"
+	"github.com/yourbase/yb/internal/biome"
+	"github.com/yourbase/yb/internal/ybdata"
+	"zombiezen.com/go/log/testlog"
+)
+
+func TestDefinedTemplateData(t *testing.T) {
+	def := &yb.BuildpackDefinition{
+		URL:       "https://example.com/tool-{{.Version}}-{{.OS}}-{{.Arch}}.tar.gz",
+		OSNames:   map[string]string{biome.MacOS: "macos"},
+		ArchNames: map[string]string{biome.Intel64: "x64"},
+	}
+	tests := []struct {
+		desc biome.Descriptor
+		want string
+	}{
+		{
+			desc: biome.Descriptor{OS: biome.Linux, Arch: biome.Intel64},
+			want: "https://example.com/tool-1.2.3-linux-x64.tar.gz",
+		},
+		{
+			desc: biome.Descriptor{OS: biome.MacOS, Arch: biome.Intel64},
+			want: "https://example.com/tool-1.2.3-macos-x64.tar.gz",
+		},
+		{
+			desc: biome.Descriptor{OS: biome.Linux, Arch: biome.ARM64},
+			want: "https://example.com/tool-1.2.3-linux-arm64.tar.gz",
+		},
+	}
+	for _, test := range tests {
+		data := definedTemplateData(def, "tool:1.2.3", &test.desc)
+		got, err := templateToString(def.URL, data)
+		if err != nil {
+			t.Errorf("%s/%s: %v", test.desc.OS, test.desc.Arch, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("%s/%s: url = %q; want %q", test.desc.OS, test.desc.Arch, got, test.want)
+		}
+	}
+}
+
+func TestParseChecksumFile(t *testing.T) {
+	const sum1 = "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	const sum2 = "fedcba9876543210fedcba9876543210fedcba9876543210fedcba9876543210"
+	tests := []struct {
+		name      string
+		content   string
+		filename  string
+		want      string
+		wantError bool
+	}{
+		{
+			name:     "Single",
+			content:  sum1 + "\n",
+			filename: "tool.zip",
+			want:     sum1,
+		},
+		{
+			name:     "List",
+			content:  sum1 + "  tool_linux_amd64.zip\n" + sum2 + "  tool_darwin_amd64.zip\n",
+			filename: "tool_darwin_amd64.zip",
+			want:     sum2,
+		},
+		{
+			name:     "BinaryMode",
+			content:  sum1 + " *tool.zip\n",
+			filename: "tool.zip",
+			want:     sum1,
+		},
+		{
+			name:     "Path",
+			content:  sum1 + "  linux-i686/en-US/tool.zip\n" + sum2 + "  linux-x86_64/en-US/tool.zip\n",
+			filename: "linux-x86_64/en-US/tool.zip",
+			want:     sum2,
+		},
+		{
+			name:      "Missing",
+			content:   sum1 + "  other.zip\n",
+			filename:  "tool.zip",
+			wantError: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := parseChecksumFile(strings.NewReader(test.content), test.filename)
+			if err != nil {
+				t.Log(err)
+				if !test.wantError {
+					t.Fail()
+				}
+				return
+			}
+			if test.wantError {
+				t.Fatal("parseChecksumFile did not return an error")
+			}
+			if got != test.want {
+				t.Errorf("parseChecksumFile(...) = %q; want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestInstallDefined(t *testing.T) {
+	archive := makeGzipTar("tool-1.0/bin/hello.txt")
+	archiveSum := sha256.Sum256(archive)
+	archiveSum512 := sha512.Sum512(archive)
+	// changed is served after the first download of the archive to simulate a
+	// file that is replaced on the server between verification and extraction.
+	changed := makeGzipTar("tool-1.0/bin/a-much-longer-name-than-hello.txt")
+	tests := []struct {
+		name      string
+		checksum  string
+		changes   bool
+		wantError bool
+	}{
+		{name: "NoChecksum"},
+		{name: "Digest", checksum: hex.EncodeToString(archiveSum[:])},
+		{name: "ChecksumFile", checksum: "{{.URL}}/SHA256SUMS"},
+		{name: "Mismatch", checksum: strings.Repeat("0", 64), wantError: true},
+		{name: "SHA512Digest", checksum: hex.EncodeToString(archiveSum512[:])},
+		{name: "SHA512Mismatch", checksum: strings.Repeat("0", 128), wantError: true},
+		{name: "ChangedAfterVerify", checksum: hex.EncodeToString(archiveSum[:]), changes: true, wantError: true},
+		{name: "SHA512ChangedAfterVerify", checksum: hex.EncodeToString(archiveSum512[:]), changes: true, wantError: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var mu sync.Mutex
+			archiveGets := 0
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var content []byte
+				switch r.URL.Path {
+				case "/tool-1.0.tar.gz":
+					mu.Lock()
+					content = archive
+					if test.changes && archiveGets > 0 {
+						content = changed
+					}
+					if r.Method == http.MethodGet {
+						archiveGets++
+					}
+					mu.Unlock()
+				case "/SHA256SUMS":
+					content = []byte(fmt.Sprintf("%x  tool-1.0.tar.gz\n", archiveSum))
+				default:
+					http.NotFound(w, r)
+					return
+				}
+				w.Header().Set(headers.ContentLength, strconv.Itoa(len(content)))
+				w.Write(content)
+			}))
+			t.Cleanup(srv.Close)
+
+			ctx := testlog.WithTB(context.Background(), t)
+			bio := biome.Local{
+				PackageDir: t.TempDir(),
+				HomeDir:    t.TempDir(),
+			}
+			output := new(strings.Builder)
+			sys := Sys{
+				Biome:      bio,
+				Stdout:     output,
+				Stderr:     output,
+				Downloader: ybdata.NewDownloader(t.TempDir()),
+				Definitions: map[string]*yb.BuildpackDefinition{
+					"tool": {
+						URL:         srv.URL + "/tool-{{.Version}}.tar.gz",
+						StripTopDir: true,
+						Checksum:    strings.ReplaceAll(test.checksum, "{{.URL}}", srv.URL),
+						Env: map[string]string{
+							"TOOL_HOME": "{{.InstallDir}}",
+						},
+						Path: []string{"bin"},
+					},
+				},
+			}
+			sys.Downloader.Client = srv.Client()
+
+			env, err := Install(ctx, sys, "tool:1.0")
+			if err != nil {
+				t.Log(err)
+				if !test.wantError {
+					t.Fail()
+				}
+				return
+			}
+			if test.wantError {
+				t.Fatal("Install did not return an error")
+			}
+			toolHome := env.Vars["TOOL_HOME"]
+			if toolHome == "" {
+				t.Fatalf("TOOL_HOME not set in %v", env)
+			}
+			wantPath := []string{bio.JoinPath(toolHome, "bin")}
+			if diff := cmp.Diff(wantPath, env.PrependPath); diff != "" {
+				t.Errorf("PrependPath (-want +got):\n%s", diff)
+			}
+			got, err := ioutil.ReadFile(bio.JoinPath(toolHome, "bin", "hello.txt"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != extractContent {
+				t.Errorf("hello.txt content = %q; want %q", got, extractContent)
+			}
+		})
+	}
+}
@@ -0,0 +1,167 @@
+// Copyright 2020 YourBase Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package buildpack
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/yourbase/yb"
+	"github.com/yourbase/yb/internal/biome"
+	"zombiezen.com/go/log"
+)
+
+// dotnetMetadataURL is the base URL of the .NET release metadata.
+const dotnetMetadataURL = "https://dotnetcli.blob.core.windows.net/dotnet/release-metadata/"
+
+// installDotnet installs the .NET SDK. The version is either an exact SDK
+// version like "5.0.202" or a channel like "5.0", which Resolve turns into the
+// channel's latest SDK.
+func installDotnet(ctx context.Context, sys Sys, spec yb.BuildpackSpec) (biome.Environment, error) {
+	home := sys.Biome.Dirs().Home
+	dotnetDir := sys.Biome.JoinPath(sys.Biome.Dirs().Tools, "dotnet", "dotnet-sdk-"+spec.Version())
+	env := biome.Environment{
+		Vars: map[string]string{
+			"DOTNET_ROOT": dotnetDir,
+			// The .NET CLI and NuGet look up the user's home directory from
+			// /etc/passwd like Java does, so point them at the build's home.
+			"DOTNET_CLI_HOME": home,
+			"NUGET_PACKAGES":  sys.Biome.JoinPath(home, ".nuget", "packages"),
+
+			"DOTNET_CLI_TELEMETRY_OPTOUT":       "1",
+			"DOTNET_NOLOGO":                     "1",
+			"DOTNET_SKIP_FIRST_TIME_EXPERIENCE": "1",
+		},
+		PrependPath: []string{
+			dotnetDir,
+			// Where `dotnet tool install --global` puts tools.
+			sys.Biome.JoinPath(home, ".dotnet", "tools"),
+		},
+	}
+
+	// If directory already exists, then use it.
+	if _, err := biome.EvalSymlinks(ctx, sys.Biome, dotnetDir); err == nil {
+		log.Infof(ctx, ".NET SDK v%s located in %s", spec.Version(), dotnetDir)
+		recordInstall(ctx, sys, spec, dotnetDir)
+		return env, nil
+	}
+
+	log.Infof(ctx, "Installing .NET SDK v%s in %s", spec.Version(), dotnetDir)
+	downloadURL, checksum, err := dotnetDownloadURL(ctx, sys, spec.Version())
+	if err != nil {
+		return biome.Environment{}, err
+	}
+	ctx, err = verifyDownload(ctx, sys, downloadURL, checksum)
+	if err != nil {
+		return biome.Environment{}, err
+	}
+	if err := extract(ctx, sys, dotnetDir, downloadURL, tarbomb); err != nil {
+		return biome.Environment{}, err
+	}
+	recordInstall(ctx, sys, spec, dotnetDir)
+	return env, nil
+}
+
+// dotnetDownloadURL looks up the URL and SHA-512 digest of an SDK archive in
+// its channel's release metadata.
+func dotnetDownloadURL(ctx context.Context, sys Sys, version string) (url, checksum string, _ error) {
+	rid, err := platformName(".NET", map[biome.Descriptor]string{
+		{OS: biome.Linux, Arch: biome.Intel64}: "linux-x64",
+		{OS: biome.Linux, Arch: biome.ARM64}:   "linux-arm64",
+		{OS: biome.MacOS, Arch: biome.Intel64}: "osx-x64",
+		{OS: biome.MacOS, Arch: biome.ARM64}:   "osx-arm64",
+	}, sys.Biome.Describe())
+	if err != nil {
+		return "", "", err
+	}
+	parts := strings.SplitN(version, ".", 3)
+	if len(parts) < 3 {
+		return "", "", fmt.Errorf("find .NET SDK %s: not an SDK version", version)
+	}
+	var channel dotnetChannel
+	releasesURL := dotnetMetadataURL + parts[0] + "." + parts[1] + "/releases.json"
+	if err := fetchJSON(ctx, sys, releasesURL, &channel); err != nil {
+		return "", "", fmt.Errorf("find .NET SDK %s: %w", version, err)
+	}
+	url, checksum = pickDotnetFile(&channel, version, rid)
+	if url == "" {
+		return "", "", fmt.Errorf("find .NET SDK %s: no %s archive published", version, rid)
+	}
+	return url, checksum, nil
+}
+
+// dotnetChannel is the subset of a channel's releases.json that the dotnet
+// buildpack uses.
+type dotnetChannel struct {
+	Releases []struct {
+		SDKs []dotnetSDK `json:"sdks"`
+		// SDK is the release's main SDK. Older releases have no "sdks" list.
+		SDK dotnetSDK `json:"sdk"`
+	} `json:"releases"`
+}
+
+type dotnetSDK struct {
+	Version string `json:"version"`
+	Files   []struct {
+		Name string `json:"name"`
+		RID  string `json:"rid"`
+		URL  string `json:"url"`
+		// Hash is a hex-encoded SHA-512 digest.
+		Hash string `json:"hash"`
+	} `json:"files"`
+}
+
+// pickDotnetFile returns the URL and digest of the .tar.gz archive for the
+// given SDK version and runtime identifier in channel.
+func pickDotnetFile(channel *dotnetChannel, version, rid string) (url, checksum string) {
+	for _, r := range channel.Releases {
+		for _, sdk := range append([]dotnetSDK{r.SDK}, r.SDKs...) {
+			if sdk.Version != version {
+				continue
+			}
+			for _, f := range sdk.Files {
+				if f.RID == rid && strings.HasSuffix(f.Name, ".tar.gz") {
+					return f.URL, f.Hash
+				}
+			}
+		}
+	}
+	return "", ""
+}
+
+// dotnetVersions lists the latest SDK of each .NET channel.
+func dotnetVersions(ctx context.Context, sys Sys) ([]availableVersion, error) {
+	var index struct {
+		Channels []struct {
+			LatestSDK   string `json:"latest-sdk"`
+			ReleaseType string `json:"release-type"`
+		} `json:"releases-index"`
+	}
+	if err := fetchJSON(ctx, sys, dotnetMetadataURL+"releases-index.json", &index); err != nil {
+		return nil, fmt.Errorf("list .NET SDK versions: %w", err)
+	}
+	versions := make([]availableVersion, 0, len(index.Channels))
+	for _, c := range index.Channels {
+		versions = append(versions, availableVersion{
+			name: c.LatestSDK,
+			lts:  c.ReleaseType == "lts",
+		})
+	}
+	log.Debugf(ctx, "Found %d .NET channels", len(versions))
+	return versions, nil
+}
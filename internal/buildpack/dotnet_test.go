@@ -0,0 +1,137 @@
+// Copyright 2020 YourBase Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package buildpack
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/yourbase/yb/internal/biome"
+	"zombiezen.com/go/log/testlog"
+)
+
+func TestDotnet(t *testing.T) {
+	const version = "5.0.202"
+	ctx := testlog.WithTB(context.Background(), t)
+	dotnetBiome, _ := testInstall(ctx, t, "dotnet:"+version)
+	versionOutput := new(strings.Builder)
+	err := dotnetBiome.Run(ctx, &biome.Invocation{
+		Argv:   []string{"dotnet", "--version"},
+		Stdout: versionOutput,
+		Stderr: versionOutput,
+	})
+	t.Logf("dotnet --version output:\n%s", versionOutput)
+	if err != nil {
+		t.Errorf("dotnet --version: %v", err)
+	}
+	if got := versionOutput.String(); !strings.Contains(got, version) {
+		t.Errorf("dotnet --version output does not include %q", version)
+	}
+}
+
+func TestPickDotnetFile(t *testing.T) {
+	const releasesJSON = `{
+		"channel-version": "5.0",
+		"releases": [
+			{
+				"release-version": "5.0.5",
+				"sdk": {
+					"version": "5.0.202",
+					"files": [
+						{"name": "dotnet-sdk-linux-x64.tar.gz", "rid": "linux-x64", "url": "https://example.com/5.0.202/linux-x64.tar.gz", "hash": "aaaa"},
+						{"name": "dotnet-sdk-linux-x64.zip", "rid": "linux-x64", "url": "https://example.com/5.0.202/linux-x64.zip", "hash": "bbbb"},
+						{"name": "dotnet-sdk-osx-x64.tar.gz", "rid": "osx-x64", "url": "https://example.com/5.0.202/osx-x64.tar.gz", "hash": "cccc"}
+					]
+				},
+				"sdks": [
+					{
+						"version": "5.0.202",
+						"files": [
+							{"name": "dotnet-sdk-linux-x64.tar.gz", "rid": "linux-x64", "url": "https://example.com/5.0.202/linux-x64.tar.gz", "hash": "aaaa"}
+						]
+					},
+					{
+						"version": "5.0.105",
+						"files": [
+							{"name": "dotnet-sdk-linux-x64.tar.gz", "rid": "linux-x64", "url": "https://example.com/5.0.105/linux-x64.tar.gz", "hash": "dddd"}
+						]
+					}
+				]
+			},
+			{
+				"release-version": "5.0.0",
+				"sdk": {
+					"version": "5.0.100",
+					"files": [
+						{"name": "dotnet-sdk-linux-x64.tar.gz", "rid": "linux-x64", "url": "https://example.com/5.0.100/linux-x64.tar.gz", "hash": "eeee"}
+					]
+				}
+			}
+		]
+	}`
+	channel := new(dotnetChannel)
+	if err := json.Unmarshal([]byte(releasesJSON), channel); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		version      string
+		rid          string
+		wantURL      string
+		wantChecksum string
+	}{
+		{
+			version:      "5.0.202",
+			rid:          "linux-x64",
+			wantURL:      "https://example.com/5.0.202/linux-x64.tar.gz",
+			wantChecksum: "aaaa",
+		},
+		{
+			version:      "5.0.202",
+			rid:          "osx-x64",
+			wantURL:      "https://example.com/5.0.202/osx-x64.tar.gz",
+			wantChecksum: "cccc",
+		},
+		{
+			version:      "5.0.105",
+			rid:          "linux-x64",
+			wantURL:      "https://example.com/5.0.105/linux-x64.tar.gz",
+			wantChecksum: "dddd",
+		},
+		{
+			version:      "5.0.100",
+			rid:          "linux-x64",
+			wantURL:      "https://example.com/5.0.100/linux-x64.tar.gz",
+			wantChecksum: "eeee",
+		},
+		{
+			version: "5.0.202",
+			rid:     "linux-arm64",
+		},
+		{
+			version: "5.0.300",
+			rid:     "linux-x64",
+		},
+	}
+	for _, test := range tests {
+		gotURL, gotChecksum := pickDotnetFile(channel, test.version, test.rid)
+		if gotURL != test.wantURL || gotChecksum != test.wantChecksum {
+			t.Errorf("pickDotnetFile(channel, %q, %q) = %q, %q; want %q, %q", test.version, test.rid, gotURL, gotChecksum, test.wantURL, test.wantChecksum)
+		}
+	}
+}
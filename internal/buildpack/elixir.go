@@ -0,0 +1,71 @@
+// Copyright 2020 YourBase Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package buildpack
+
+import (
+	"context"
+
+	"github.com/yourbase/yb"
+	"github.com/yourbase/yb/internal/biome"
+	"zombiezen.com/go/log"
+)
+
+func installElixir(ctx context.Context, sys Sys, spec yb.BuildpackSpec) (biome.Environment, error) {
+	elixirDir := sys.Biome.JoinPath(sys.Biome.Dirs().Tools, "elixir", "elixir-"+spec.Version())
+	mixHome := sys.Biome.JoinPath(sys.Biome.Dirs().Home, ".mix")
+	env := biome.Environment{
+		Vars: map[string]string{
+			"MIX_HOME": mixHome,
+			"HEX_HOME": sys.Biome.JoinPath(sys.Biome.Dirs().Home, ".hex"),
+		},
+		PrependPath: []string{
+			sys.Biome.JoinPath(elixirDir, "bin"),
+			// Where `mix escript.install` puts executables.
+			sys.Biome.JoinPath(mixHome, "escripts"),
+		},
+	}
+
+	// If directory already exists, then use it.
+	if _, err := biome.EvalSymlinks(ctx, sys.Biome, elixirDir); err == nil {
+		log.Infof(ctx, "Elixir v%s located in %s", spec.Version(), elixirDir)
+		recordInstall(ctx, sys, spec, elixirDir)
+		return env, nil
+	}
+
+	log.Infof(ctx, "Installing Elixir v%s in %s", spec.Version(), elixirDir)
+	downloadURL, err := elixirDownloadURL(spec.Version())
+	if err != nil {
+		return biome.Environment{}, err
+	}
+	ctx, err = verifyDownload(ctx, sys, downloadURL, downloadURL+".sha256sum")
+	if err != nil {
+		return biome.Environment{}, err
+	}
+	// The precompiled release is platform-independent BEAM bytecode.
+	if err := extract(ctx, sys, elixirDir, downloadURL, tarbomb); err != nil {
+		return biome.Environment{}, err
+	}
+	recordInstall(ctx, sys, spec, elixirDir)
+	return env, nil
+}
+
+func elixirDownloadURL(version string) (string, error) {
+	const template = "https://github.com/elixir-lang/elixir/releases/download/v{{.Version}}/Precompiled.zip"
+	return templateToString(template, struct {
+		Version string
+	}{version})
+}
@@ -0,0 +1,45 @@
+// Copyright 2020 YourBase Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package buildpack
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/yourbase/yb/internal/biome"
+	"zombiezen.com/go/log/testlog"
+)
+
+func TestElixir(t *testing.T) {
+	const version = "1.11.4"
+	ctx := testlog.WithTB(context.Background(), t)
+	elixirBiome, _ := testInstall(ctx, t, "erlang:23.3.1", "elixir:"+version)
+	versionOutput := new(strings.Builder)
+	err := elixirBiome.Run(ctx, &biome.Invocation{
+		Argv:   []string{"elixir", "--version"},
+		Stdout: versionOutput,
+		Stderr: versionOutput,
+	})
+	t.Logf("elixir --version output:\n%s", versionOutput)
+	if err != nil {
+		t.Errorf("elixir --version: %v", err)
+	}
+	if got := versionOutput.String(); !strings.Contains(got, version) {
+		t.Errorf("elixir --version output does not include %q", version)
+	}
+}
@@ -0,0 +1,152 @@
+// Copyright 2020 YourBase Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package buildpack
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/yourbase/commons/xcontext"
+	"github.com/yourbase/yb"
+	"github.com/yourbase/yb/internal/biome"
+	"github.com/yourbase/yb/internal/ybdata"
+	"zombiezen.com/go/log"
+)
+
+// installErlang installs Erlang/OTP. The Erlang project only publishes source
+// releases, so this uses Hex's pre-built binaries on Ubuntu and otherwise
+// compiles from source.
+func installErlang(ctx context.Context, sys Sys, spec yb.BuildpackSpec) (biome.Environment, error) {
+	erlangDir := sys.Biome.JoinPath(sys.Biome.Dirs().Tools, "erlang", "otp-"+spec.Version())
+	env := biome.Environment{
+		PrependPath: []string{sys.Biome.JoinPath(erlangDir, "bin")},
+	}
+
+	// If directory already exists, then use it.
+	if _, err := biome.EvalSymlinks(ctx, sys.Biome, erlangDir); err == nil {
+		log.Infof(ctx, "Erlang/OTP %s located in %s", spec.Version(), erlangDir)
+		recordInstall(ctx, sys, spec, erlangDir)
+		return env, nil
+	}
+
+	log.Infof(ctx, "Installing Erlang/OTP %s in %s", spec.Version(), erlangDir)
+	installed, err := installPrebuiltErlang(ctx, sys, spec.Version(), erlangDir)
+	if err != nil {
+		return biome.Environment{}, err
+	}
+	if !installed {
+		downloadURL, err := erlangSourceURL(spec.Version())
+		if err != nil {
+			return biome.Environment{}, err
+		}
+		// wxWidgets and Java are only needed for GUI tools and jinterface.
+		err = buildFromSource(ctx, sys, "Erlang/OTP", erlangDir, downloadURL, "--without-wx", "--without-javac")
+		if err != nil {
+			return biome.Environment{}, err
+		}
+	}
+	recordInstall(ctx, sys, spec, erlangDir)
+	return env, nil
+}
+
+// installPrebuiltErlang tries to install one of Hex's pre-built Erlang/OTP
+// releases. It returns false if there isn't one for the biome.
+func installPrebuiltErlang(ctx context.Context, sys Sys, version, erlangDir string) (_ bool, err error) {
+	desc := sys.Biome.Describe()
+	if _, err := erlangDownloadURL(version, "", desc); err != nil {
+		log.Debugf(ctx, "Pre-built binaries unsupported: %v", err)
+		return false, nil
+	}
+	distro, err := readOSRelease(ctx, sys, "ID")
+	if err != nil {
+		log.Warnf(ctx, "Skipping search for pre-built binary: %v", err)
+		return false, nil
+	}
+	if distro != "ubuntu" {
+		log.Debugf(ctx, "Pre-built binaries unsupported on %s", distro)
+		return false, nil
+	}
+	distroVersion, err := readOSRelease(ctx, sys, "VERSION_ID")
+	if err != nil {
+		log.Warnf(ctx, "Skipping search for pre-built binary: %v", err)
+		return false, nil
+	}
+	downloadURL, err := erlangDownloadURL(version, distroVersion, desc)
+	if err != nil {
+		return false, fmt.Errorf("download pre-built binary: %w", err)
+	}
+	log.Infof(ctx, "Searching for Hex-built Erlang/OTP binary...")
+	if err := extract(ctx, sys, erlangDir, downloadURL, stripTopDirectory); err != nil {
+		if ybdata.IsNotFound(err) {
+			log.Debugf(ctx, "No pre-built binary: %v", err)
+			return false, nil
+		}
+		return false, fmt.Errorf("download pre-built binary: %w", err)
+	}
+	defer func() {
+		// Remove the directory on failure so that later builds retry.
+		if err != nil {
+			rmCtx, cancel := xcontext.KeepAlive(ctx, 10*time.Second)
+			defer cancel()
+			rmErr := sys.Biome.Run(rmCtx, &biome.Invocation{
+				Argv:   []string{"rm", "-rf", erlangDir},
+				Stdout: sys.Stdout,
+				Stderr: sys.Stderr,
+			})
+			if rmErr != nil {
+				log.Warnf(ctx, "Cleaning up failed Erlang/OTP install: %v", rmErr)
+			}
+		}
+	}()
+	// The builds must be told where they are installed.
+	err = sys.Biome.Run(ctx, &biome.Invocation{
+		Argv:   []string{sys.Biome.JoinPath(erlangDir, "Install"), "-minimal", erlangDir},
+		Dir:    erlangDir,
+		Stdout: sys.Stdout,
+		Stderr: sys.Stderr,
+	})
+	if err != nil {
+		return false, fmt.Errorf("install pre-built binary: %w", err)
+	}
+	return true, nil
+}
+
+// erlangDownloadURL returns the URL of a Hex-built Erlang/OTP release for the
+// given Ubuntu version. The build is not guaranteed to exist.
+func erlangDownloadURL(version string, ubuntuVersion string, desc *biome.Descriptor) (string, error) {
+	const template = "https://builds.hex.pm/builds/otp/{{.Platform}}/ubuntu-{{.UbuntuVersion}}/OTP-{{.Version}}.tar.gz"
+	platform, err := platformName("Hex", map[biome.Descriptor]string{
+		{OS: biome.Linux, Arch: biome.Intel64}: "amd64",
+		{OS: biome.Linux, Arch: biome.ARM64}:   "arm64",
+	}, desc)
+	if err != nil {
+		return "", err
+	}
+	return templateToString(template, struct {
+		Version       string
+		Platform      string
+		UbuntuVersion string
+	}{version, platform, ubuntuVersion})
+}
+
+func erlangSourceURL(version string) (string, error) {
+	const template = "https://github.com/erlang/otp/releases/download/OTP-{{.Version}}/otp_src_{{.Version}}.tar.gz"
+	return templateToString(template, struct {
+		Version string
+	}{version})
+}
@@ -0,0 +1,69 @@
+// Copyright 2020 YourBase Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package buildpack
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/yourbase/yb/internal/biome"
+	"zombiezen.com/go/log/testlog"
+)
+
+func TestErlang(t *testing.T) {
+	const version = "23.3.1"
+	ctx := testlog.WithTB(context.Background(), t)
+	erlangBiome, _ := testInstall(ctx, t, "erlang:"+version)
+	// erl has no version flag, so print the release from inside the VM.
+	versionOutput := new(strings.Builder)
+	err := erlangBiome.Run(ctx, &biome.Invocation{
+		Argv:   []string{"erl", "-noshell", "-eval", "io:format(\"~s~n\", [erlang:system_info(otp_release)]), halt()."},
+		Stdout: versionOutput,
+		Stderr: versionOutput,
+	})
+	t.Logf("erl output:\n%s", versionOutput)
+	if err != nil {
+		t.Errorf("erl: %v", err)
+	}
+	const release = "23"
+	if got := strings.TrimSpace(versionOutput.String()); got != release {
+		t.Errorf("erl printed OTP release %q; want %q", got, release)
+	}
+}
+
+func TestErlangDownloadURL(t *testing.T) {
+	const version = "23.3.1"
+	testDownloadURLs(t, http.MethodHead, func(desc *biome.Descriptor) (string, error) {
+		return erlangDownloadURL(version, "20.04", desc)
+	}, []downloadURLTest{
+		{os: biome.Linux, arch: biome.Intel64, want: "https://builds.hex.pm/builds/otp/amd64/ubuntu-20.04/OTP-23.3.1.tar.gz"},
+		{os: biome.Linux, arch: biome.Intel32, want: ""},
+		{os: biome.Linux, arch: biome.ARM64, want: "https://builds.hex.pm/builds/otp/arm64/ubuntu-20.04/OTP-23.3.1.tar.gz"},
+		{os: biome.MacOS, arch: biome.Intel64, want: ""},
+	})
+}
+
+func TestErlangSourceURL(t *testing.T) {
+	const version = "23.3.1"
+	testDownloadURLs(t, http.MethodGet, func(*biome.Descriptor) (string, error) {
+		return erlangSourceURL(version)
+	}, []downloadURLTest{
+		{os: biome.Linux, arch: biome.Intel64, want: "https://github.com/erlang/otp/releases/download/OTP-23.3.1/otp_src_23.3.1.tar.gz"},
+	})
+}
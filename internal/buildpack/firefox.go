@@ -0,0 +1,201 @@
+// Copyright 2020 YourBase Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package buildpack
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yourbase/commons/xcontext"
+	"github.com/yourbase/yb"
+	"github.com/yourbase/yb/internal/biome"
+	"zombiezen.com/go/log"
+)
+
+// firefoxReleaseURL is the URL of a Firefox release's files.
+const firefoxReleaseURL = "https://ftp.mozilla.org/pub/firefox/releases/{{.Version}}/"
+
+// geckodriverVersions maps the oldest Firefox major version that a
+// geckodriver release supports to that release, newest first. See
+// https://firefox-source-docs.mozilla.org/testing/geckodriver/Support.html
+var geckodriverVersions = []struct {
+	minFirefox int
+	version    string
+}{
+	{115, "0.34.0"},
+	{102, "0.33.0"},
+	{91, "0.31.0"},
+	{78, "0.30.0"},
+	{60, "0.29.1"},
+}
+
+// installFirefox installs Firefox and the newest geckodriver that supports it.
+// Mozilla only publishes macOS builds as disk images, so only Linux is
+// supported.
+func installFirefox(ctx context.Context, sys Sys, spec yb.BuildpackSpec) (_ biome.Environment, err error) {
+	firefoxRoot := sys.Biome.JoinPath(sys.Biome.Dirs().Tools, "firefox", "firefox-"+spec.Version())
+	firefoxDir := sys.Biome.JoinPath(firefoxRoot, "firefox")
+	geckodriverDir := sys.Biome.JoinPath(firefoxRoot, "geckodriver")
+	firefoxBin := sys.Biome.JoinPath(firefoxDir, "firefox")
+	env := biome.Environment{
+		Vars: map[string]string{
+			"FIREFOX_BIN": firefoxBin,
+			"GECKODRIVER": sys.Biome.JoinPath(geckodriverDir, "geckodriver"),
+		},
+		PrependPath: []string{firefoxDir, geckodriverDir},
+	}
+
+	// If directory already exists, then use it.
+	if _, err := biome.EvalSymlinks(ctx, sys.Biome, firefoxRoot); err == nil {
+		log.Infof(ctx, "Firefox v%s located in %s", spec.Version(), firefoxRoot)
+		recordInstall(ctx, sys, spec, firefoxRoot)
+		warnMissingLibraries(ctx, sys, "Firefox", firefoxBin)
+		return env, nil
+	}
+
+	desc := sys.Biome.Describe()
+	downloadURL, err := firefoxDownloadURL(spec.Version(), desc)
+	if err != nil {
+		return biome.Environment{}, err
+	}
+	geckodriverVersion, err := geckodriverVersionFor(spec.Version())
+	if err != nil {
+		return biome.Environment{}, err
+	}
+	geckodriverURL, err := geckodriverDownloadURL(geckodriverVersion, desc)
+	if err != nil {
+		return biome.Environment{}, err
+	}
+	log.Infof(ctx, "Installing Firefox v%s and geckodriver v%s in %s", spec.Version(), geckodriverVersion, firefoxRoot)
+
+	// Mozilla's SHA256SUMS lists every platform and locale, so the file has to
+	// be matched by its path relative to the release directory.
+	releaseURL, err := templateToString(firefoxReleaseURL, struct {
+		Version string
+	}{spec.Version()})
+	if err != nil {
+		return biome.Environment{}, err
+	}
+	f, err := sys.Downloader.Download(ctx, releaseURL+"SHA256SUMS")
+	if err != nil {
+		return biome.Environment{}, fmt.Errorf("verify %s: %w", downloadURL, err)
+	}
+	checksum, err := parseChecksumFile(f, strings.TrimPrefix(downloadURL, releaseURL))
+	f.Close()
+	if err != nil {
+		return biome.Environment{}, fmt.Errorf("verify %s: %w", downloadURL, err)
+	}
+	ctx, err = verifyDownload(ctx, sys, downloadURL, checksum)
+	if err != nil {
+		return biome.Environment{}, err
+	}
+
+	defer func() {
+		// Remove the directory on failure so that a missing driver doesn't
+		// leave an installation that later builds would use.
+		if err != nil {
+			rmCtx, cancel := xcontext.KeepAlive(ctx, 10*time.Second)
+			defer cancel()
+			rmErr := sys.Biome.Run(rmCtx, &biome.Invocation{
+				Argv:   []string{"rm", "-rf", firefoxRoot},
+				Stdout: sys.Stdout,
+				Stderr: sys.Stderr,
+			})
+			if rmErr != nil {
+				log.Warnf(ctx, "Cleaning up failed Firefox install: %v", rmErr)
+			}
+		}
+	}()
+	if err := extract(ctx, sys, firefoxDir, downloadURL, stripTopDirectory); err != nil {
+		return biome.Environment{}, err
+	}
+	if err := extract(ctx, sys, geckodriverDir, geckodriverURL, tarbomb); err != nil {
+		return biome.Environment{}, err
+	}
+	recordInstall(ctx, sys, spec, firefoxRoot)
+	warnMissingLibraries(ctx, sys, "Firefox", firefoxBin)
+	return env, nil
+}
+
+func firefoxDownloadURL(version string, desc *biome.Descriptor) (string, error) {
+	const template = firefoxReleaseURL + "{{.Platform}}/en-US/firefox-{{.Version}}{{.Extension}}"
+	platform, err := platformName("Firefox", map[biome.Descriptor]string{
+		{OS: biome.Linux, Arch: biome.Intel64}: "linux-x86_64",
+		{OS: biome.Linux, Arch: biome.Intel32}: "linux-i686",
+		{OS: biome.Linux, Arch: biome.ARM64}:   "linux-aarch64",
+	}, desc)
+	if err != nil {
+		return "", err
+	}
+	major, err := firefoxMajorVersion(version)
+	if err != nil {
+		return "", err
+	}
+	ext := tarBZ2Ext
+	if major >= 135 {
+		ext = tarXZExt
+	}
+	return templateToString(template, struct {
+		Version   string
+		Platform  string
+		Extension string
+	}{version, platform, ext})
+}
+
+// geckodriverVersionFor returns the newest geckodriver release that supports
+// the given Firefox version.
+func geckodriverVersionFor(firefoxVersion string) (string, error) {
+	major, err := firefoxMajorVersion(firefoxVersion)
+	if err != nil {
+		return "", err
+	}
+	for _, v := range geckodriverVersions {
+		if major >= v.minFirefox {
+			return v.version, nil
+		}
+	}
+	return "", fmt.Errorf("no geckodriver release supports Firefox %s", firefoxVersion)
+}
+
+// firefoxMajorVersion parses the major version of a Firefox version like
+// "89.0.2" or "78.10.0esr".
+func firefoxMajorVersion(version string) (int, error) {
+	major, err := strconv.Atoi(strings.SplitN(version, ".", 2)[0])
+	if err != nil {
+		return 0, fmt.Errorf("invalid Firefox version %q", version)
+	}
+	return major, nil
+}
+
+func geckodriverDownloadURL(version string, desc *biome.Descriptor) (string, error) {
+	const template = "https://github.com/mozilla/geckodriver/releases/download/v{{.Version}}/geckodriver-v{{.Version}}-{{.Platform}}.tar.gz"
+	platform, err := platformName("geckodriver", map[biome.Descriptor]string{
+		{OS: biome.Linux, Arch: biome.Intel64}: "linux64",
+		{OS: biome.Linux, Arch: biome.Intel32}: "linux32",
+		{OS: biome.Linux, Arch: biome.ARM64}:   "linux-aarch64",
+	}, desc)
+	if err != nil {
+		return "", err
+	}
+	return templateToString(template, struct {
+		Version  string
+		Platform string
+	}{version, platform})
+}
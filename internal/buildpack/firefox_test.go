@@ -0,0 +1,108 @@
+// Copyright 2020 YourBase Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package buildpack
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/yourbase/yb/internal/biome"
+	"zombiezen.com/go/log/testlog"
+)
+
+func TestFirefox(t *testing.T) {
+	const version = "89.0"
+	const geckodriverVersion = "0.30.0"
+	ctx := testlog.WithTB(context.Background(), t)
+	firefoxBiome, _ := testInstall(ctx, t, "firefox:"+version)
+	versionOutput := new(strings.Builder)
+	err := firefoxBiome.Run(ctx, &biome.Invocation{
+		Argv:   []string{"firefox", "--version"},
+		Stdout: versionOutput,
+		Stderr: versionOutput,
+	})
+	t.Logf("firefox --version output:\n%s", versionOutput)
+	if err != nil {
+		t.Errorf("firefox --version: %v", err)
+	}
+	if got := versionOutput.String(); !strings.Contains(got, version) {
+		t.Errorf("firefox --version output does not include %q", version)
+	}
+
+	driverOutput := new(strings.Builder)
+	err = firefoxBiome.Run(ctx, &biome.Invocation{
+		Argv:   []string{"geckodriver", "--version"},
+		Stdout: driverOutput,
+		Stderr: driverOutput,
+	})
+	t.Logf("geckodriver --version output:\n%s", driverOutput)
+	if err != nil {
+		t.Errorf("geckodriver --version: %v", err)
+	}
+	if got := driverOutput.String(); !strings.Contains(got, geckodriverVersion) {
+		t.Errorf("geckodriver --version output does not include %q", geckodriverVersion)
+	}
+}
+
+func TestFirefoxDownloadURL(t *testing.T) {
+	const version = "89.0"
+	testDownloadURLs(t, http.MethodHead, func(desc *biome.Descriptor) (string, error) {
+		return firefoxDownloadURL(version, desc)
+	}, []downloadURLTest{
+		{os: biome.Linux, arch: biome.Intel64, want: "https://ftp.mozilla.org/pub/firefox/releases/89.0/linux-x86_64/en-US/firefox-89.0.tar.bz2"},
+		{os: biome.Linux, arch: biome.Intel32, want: "https://ftp.mozilla.org/pub/firefox/releases/89.0/linux-i686/en-US/firefox-89.0.tar.bz2"},
+		{os: biome.MacOS, arch: biome.Intel64, want: ""},
+	})
+}
+
+func TestGeckodriverVersionFor(t *testing.T) {
+	tests := []struct {
+		firefox string
+		want    string
+	}{
+		{firefox: "128.0", want: "0.34.0"},
+		{firefox: "115.12.0esr", want: "0.34.0"},
+		{firefox: "102.0", want: "0.33.0"},
+		{firefox: "89.0", want: "0.30.0"},
+		{firefox: "60.0", want: "0.29.1"},
+		{firefox: "52.0", want: ""},
+		{firefox: "latest", want: ""},
+	}
+	for _, test := range tests {
+		got, err := geckodriverVersionFor(test.firefox)
+		switch {
+		case test.want == "" && err == nil:
+			t.Errorf("geckodriverVersionFor(%q) = %q, <nil>; want error", test.firefox, got)
+		case test.want != "" && (got != test.want || err != nil):
+			t.Errorf("geckodriverVersionFor(%q) = %q, %v; want %q, <nil>", test.firefox, got, err, test.want)
+		}
+	}
+}
+
+func TestGeckodriverDownloadURL(t *testing.T) {
+	const version = "0.34.0"
+	testDownloadURLs(t, http.MethodGet, func(desc *biome.Descriptor) (string, error) {
+		return geckodriverDownloadURL(version, desc)
+	}, []downloadURLTest{
+		{os: biome.Linux, arch: biome.Intel64, want: "https://github.com/mozilla/geckodriver/releases/download/v0.34.0/geckodriver-v0.34.0-linux64.tar.gz"},
+		{os: biome.Linux, arch: biome.Intel32, want: "https://github.com/mozilla/geckodriver/releases/download/v0.34.0/geckodriver-v0.34.0-linux32.tar.gz"},
+		{os: biome.Linux, arch: biome.ARM64, want: "https://github.com/mozilla/geckodriver/releases/download/v0.34.0/geckodriver-v0.34.0-linux-aarch64.tar.gz"},
+		{os: biome.MacOS, arch: biome.Intel64, want: ""},
+	})
+}
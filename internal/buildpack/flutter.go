@@ -2,7 +2,6 @@ package buildpack
 
 import (
 	"context"
-	"fmt"
 	"strings"
 
 	"golang.org/x/mod/semver"
@@ -21,6 +20,7 @@ func installFlutter(ctx context.Context, sys Sys, spec yb.BuildpackSpec) (_ biom
 	// If directory already exists, then use it.
 	if _, err := biome.EvalSymlinks(ctx, sys.Biome, dir); err == nil {
 		log.Infof(ctx, "Flutter v%s located in %s", spec.Version(), dir)
+		recordInstall(ctx, sys, spec, dir)
 		return env, nil
 	}
 
@@ -32,6 +32,7 @@ func installFlutter(ctx context.Context, sys Sys, spec yb.BuildpackSpec) (_ biom
 	if err := extract(ctx, sys, dir, downloadURL, stripTopDirectory); err != nil {
 		return biome.Environment{}, err
 	}
+	recordInstall(ctx, sys, spec, dir)
 	return env, nil
 }
 
@@ -43,15 +44,18 @@ func flutterDownloadURL(version string, desc *biome.Descriptor) (string, error)
 		Version   string
 		Extension string
 	}
-	switch desc.OS {
-	case biome.Linux:
-		data.OS = "linux"
-		data.Extension = "tar.xz"
-	case biome.MacOS:
-		data.OS = "macos"
+	// Flutter's releases bundle an x86-64 Dart SDK.
+	var err error
+	data.OS, err = platformName("Flutter", map[biome.Descriptor]string{
+		{OS: biome.Linux, Arch: biome.Intel64}: "linux",
+		{OS: biome.MacOS, Arch: biome.Intel64}: "macos",
+	}, desc)
+	if err != nil {
+		return "", err
+	}
+	data.Extension = "tar.xz"
+	if desc.OS == biome.MacOS {
 		data.Extension = "zip"
-	default:
-		return "", fmt.Errorf("unsupported os %s", desc.OS)
 	}
 
 	// Starting with flutter 1.17 the version format changed.
@@ -88,3 +88,15 @@ func TestFlutterDownloadURL(t *testing.T) {
 		}
 	})
 }
+
+func TestFlutterDownloadURLPlatforms(t *testing.T) {
+	const version = "1.20.2"
+	testDownloadURLs(t, http.MethodHead, func(desc *biome.Descriptor) (string, error) {
+		return flutterDownloadURL(version, desc)
+	}, []downloadURLTest{
+		{os: biome.Linux, arch: biome.Intel64, want: "https://storage.googleapis.com/flutter_infra/releases/stable/linux/flutter_linux_1.20.2-stable.tar.xz"},
+		{os: biome.Linux, arch: biome.ARM64},
+		{os: biome.MacOS, arch: biome.Intel64, want: "https://storage.googleapis.com/flutter_infra/releases/stable/macos/flutter_macos_1.20.2-stable.zip"},
+		{os: biome.Windows, arch: biome.Intel64},
+	})
+}
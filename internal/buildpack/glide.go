@@ -19,27 +19,35 @@ func installGlide(ctx context.Context, sys Sys, spec yb.BuildpackSpec) (biome.En
 	// If directory already exists, then use it.
 	if _, err := biome.EvalSymlinks(ctx, sys.Biome, glideDir); err == nil {
 		log.Infof(ctx, "Ant v%s located in %s", spec.Version(), glideDir)
+		recordInstall(ctx, sys, spec, glideDir)
 		return env, nil
 	}
 
 	log.Infof(ctx, "Installing Glide v%s in %s", spec.Version(), glideDir)
-	const template = "https://github.com/Masterminds/glide/releases/download/v{{.Version}}/glide-v{{.Version}}-{{.OS}}-{{.Arch}}.tar.gz"
-	desc := sys.Biome.Describe()
-	data := struct {
-		Version string
-		OS      string
-		Arch    string
-	}{
-		Version: spec.Version(),
-		OS:      desc.OS,
-		Arch:    desc.Arch,
-	}
-	downloadURL, err := templateToString(template, data)
+	downloadURL, err := glideDownloadURL(spec.Version(), sys.Biome.Describe())
 	if err != nil {
 		return biome.Environment{}, err
 	}
 	if err := extract(ctx, sys, glideDir, downloadURL, stripTopDirectory); err != nil {
 		return biome.Environment{}, err
 	}
+	recordInstall(ctx, sys, spec, glideDir)
 	return env, nil
 }
+
+func glideDownloadURL(version string, desc *biome.Descriptor) (string, error) {
+	const template = "https://github.com/Masterminds/glide/releases/download/v{{.Version}}/glide-v{{.Version}}-{{.Platform}}.tar.gz"
+	platform, err := platformName("Glide", map[biome.Descriptor]string{
+		{OS: biome.Linux, Arch: biome.Intel64}: "linux-amd64",
+		{OS: biome.Linux, Arch: biome.Intel32}: "linux-386",
+		{OS: biome.Linux, Arch: biome.ARM64}:   "linux-arm64",
+		{OS: biome.MacOS, Arch: biome.Intel64}: "darwin-amd64",
+	}, desc)
+	if err != nil {
+		return "", err
+	}
+	return templateToString(template, struct {
+		Version  string
+		Platform string
+	}{version, platform})
+}
@@ -18,6 +18,7 @@ package buildpack
 
 import (
 	"context"
+	"net/http"
 	"strings"
 	"testing"
 
@@ -43,3 +44,16 @@ func TestGlide(t *testing.T) {
 		t.Errorf("glide --version output does not include %q", version)
 	}
 }
+
+func TestGlideDownloadURL(t *testing.T) {
+	const version = "0.13.3"
+	testDownloadURLs(t, http.MethodGet, func(desc *biome.Descriptor) (string, error) {
+		return glideDownloadURL(version, desc)
+	}, []downloadURLTest{
+		{os: biome.Linux, arch: biome.Intel64, want: "https://github.com/Masterminds/glide/releases/download/v0.13.3/glide-v0.13.3-linux-amd64.tar.gz"},
+		{os: biome.Linux, arch: biome.Intel32, want: "https://github.com/Masterminds/glide/releases/download/v0.13.3/glide-v0.13.3-linux-386.tar.gz"},
+		{os: biome.Linux, arch: biome.ARM64, want: "https://github.com/Masterminds/glide/releases/download/v0.13.3/glide-v0.13.3-linux-arm64.tar.gz"},
+		{os: biome.MacOS, arch: biome.Intel64, want: "https://github.com/Masterminds/glide/releases/download/v0.13.3/glide-v0.13.3-darwin-amd64.tar.gz"},
+		{os: biome.Windows, arch: biome.Intel64},
+	})
+}
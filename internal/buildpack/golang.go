@@ -27,14 +27,32 @@ func installGo(ctx context.Context, sys Sys, spec yb.BuildpackSpec) (biome.Envir
 	// If directory already exists, then use it.
 	if _, err := biome.EvalSymlinks(ctx, sys.Biome, golangDir); err == nil {
 		log.Infof(ctx, "Go v%s located in %s", spec.Version(), golangDir)
+		recordInstall(ctx, sys, spec, golangDir)
 		return env, nil
 	}
 
 	log.Infof(ctx, "Installing Go v%s in %s", spec.Version(), golangDir)
-	desc := sys.Biome.Describe()
-	downloadURL := fmt.Sprintf("https://dl.google.com/go/go%s.%s-%s.tar.gz", spec.Version(), desc.OS, desc.Arch)
+	downloadURL, err := golangDownloadURL(spec.Version(), sys.Biome.Describe())
+	if err != nil {
+		return biome.Environment{}, err
+	}
 	if err := extract(ctx, sys, golangDir, downloadURL, stripTopDirectory); err != nil {
 		return biome.Environment{}, err
 	}
+	recordInstall(ctx, sys, spec, golangDir)
 	return env, nil
 }
+
+func golangDownloadURL(version string, desc *biome.Descriptor) (string, error) {
+	platform, err := platformName("Go", map[biome.Descriptor]string{
+		{OS: biome.Linux, Arch: biome.Intel64}: "linux-amd64",
+		{OS: biome.Linux, Arch: biome.Intel32}: "linux-386",
+		{OS: biome.Linux, Arch: biome.ARM64}:   "linux-arm64",
+		{OS: biome.MacOS, Arch: biome.Intel64}: "darwin-amd64",
+		{OS: biome.MacOS, Arch: biome.ARM64}:   "darwin-arm64",
+	}, desc)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("https://dl.google.com/go/go%s.%s.tar.gz", version, platform), nil
+}
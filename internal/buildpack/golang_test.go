@@ -18,6 +18,7 @@ package buildpack
 
 import (
 	"context"
+	"net/http"
 	"strings"
 	"testing"
 
@@ -68,3 +69,17 @@ func TestGo(t *testing.T) {
 		t.Fatalf("stringer: %v", err)
 	}
 }
+
+func TestGolangDownloadURL(t *testing.T) {
+	const version = "1.16.3"
+	testDownloadURLs(t, http.MethodHead, func(desc *biome.Descriptor) (string, error) {
+		return golangDownloadURL(version, desc)
+	}, []downloadURLTest{
+		{os: biome.Linux, arch: biome.Intel64, want: "https://dl.google.com/go/go1.16.3.linux-amd64.tar.gz"},
+		{os: biome.Linux, arch: biome.Intel32, want: "https://dl.google.com/go/go1.16.3.linux-386.tar.gz"},
+		{os: biome.Linux, arch: biome.ARM64, want: "https://dl.google.com/go/go1.16.3.linux-arm64.tar.gz"},
+		{os: biome.MacOS, arch: biome.Intel64, want: "https://dl.google.com/go/go1.16.3.darwin-amd64.tar.gz"},
+		{os: biome.MacOS, arch: biome.ARM64, want: "https://dl.google.com/go/go1.16.3.darwin-arm64.tar.gz"},
+		{os: biome.Windows, arch: biome.Intel64},
+	})
+}
@@ -2,14 +2,30 @@ package buildpack
 
 import (
 	"context"
+	"crypto/md5"
 	"fmt"
+	"math/big"
+	"net/url"
+	"path"
+	"strings"
 
 	"github.com/yourbase/yb"
 	"github.com/yourbase/yb/internal/biome"
 	"zombiezen.com/go/log"
 )
 
+// gradleWrapperVersion is the gradle buildpack version that installs the
+// distribution named by the package's Gradle wrapper.
+const gradleWrapperVersion = "wrapper"
+
+// gradleWrapperProperties is the path of the Gradle wrapper's configuration
+// relative to the package directory.
+const gradleWrapperProperties = "gradle/wrapper/gradle-wrapper.properties"
+
 func installGradle(ctx context.Context, sys Sys, spec yb.BuildpackSpec) (biome.Environment, error) {
+	if spec.Version() == gradleWrapperVersion {
+		return installGradleWrapper(ctx, sys)
+	}
 	gradleDir := sys.Biome.JoinPath(sys.Biome.Dirs().Tools, "gradle", "gradle-"+spec.Version())
 	env := biome.Environment{
 		Vars: map[string]string{
@@ -23,6 +39,7 @@ func installGradle(ctx context.Context, sys Sys, spec yb.BuildpackSpec) (biome.E
 	// If directory already exists, then use it.
 	if _, err := biome.EvalSymlinks(ctx, sys.Biome, gradleDir); err == nil {
 		log.Infof(ctx, "Gradle v%s located in %s", spec.Version(), gradleDir)
+		recordInstall(ctx, sys, spec, gradleDir)
 		return env, nil
 	}
 
@@ -31,5 +48,123 @@ func installGradle(ctx context.Context, sys Sys, spec yb.BuildpackSpec) (biome.E
 	if err := extract(ctx, sys, gradleDir, downloadURL, stripTopDirectory); err != nil {
 		return biome.Environment{}, err
 	}
+	recordInstall(ctx, sys, spec, gradleDir)
 	return env, nil
 }
+
+// installGradleWrapper unpacks the distribution named in the package's
+// gradle-wrapper.properties where gradlew looks for it, so that the download
+// goes through the download cache and mirrors instead of gradlew.
+func installGradleWrapper(ctx context.Context, sys Sys) (biome.Environment, error) {
+	gradleHome := sys.Biome.JoinPath(sys.Biome.Dirs().Home, ".gradle")
+	env := biome.Environment{
+		Vars: map[string]string{
+			"GRADLE_USER_HOME": gradleHome,
+		},
+	}
+	propsPath := sys.Biome.JoinPath(sys.Biome.Dirs().Package, gradleWrapperProperties)
+	propsData, err := readBiomeFile(ctx, sys, propsPath)
+	if err != nil {
+		return biome.Environment{}, fmt.Errorf("read %s: %w", gradleWrapperProperties, err)
+	}
+	props := parseJavaProperties(propsData)
+	distURL := props["distributionUrl"]
+	if distURL == "" {
+		return biome.Environment{}, fmt.Errorf("read %s: no distributionUrl", gradleWrapperProperties)
+	}
+	if props["distributionBase"] != "" && props["distributionBase"] != "GRADLE_USER_HOME" {
+		return biome.Environment{}, fmt.Errorf("read %s: distributionBase %s not supported", gradleWrapperProperties, props["distributionBase"])
+	}
+	distPath := props["distributionPath"]
+	if distPath == "" {
+		distPath = "wrapper/dists"
+	}
+	distName, distHash, err := gradleWrapperDistDir(distURL)
+	if err != nil {
+		return biome.Environment{}, fmt.Errorf("read %s: %w", gradleWrapperProperties, err)
+	}
+	distElems := append([]string{gradleHome}, strings.Split(distPath, "/")...)
+	distDir := sys.Biome.JoinPath(append(distElems, distName, distHash)...)
+	// gradlew considers a distribution installed once this marker file exists.
+	markerPath := sys.Biome.JoinPath(distDir, path.Base(distURL)+".ok")
+
+	if _, err := biome.EvalSymlinks(ctx, sys.Biome, markerPath); err == nil {
+		log.Infof(ctx, "Gradle wrapper distribution located in %s", distDir)
+		return env, nil
+	}
+
+	log.Infof(ctx, "Installing Gradle wrapper distribution %s in %s", distURL, distDir)
+	if sum := props["distributionSha256Sum"]; sum != "" {
+		ctx, err = verifyDownload(ctx, sys, distURL, sum)
+		if err != nil {
+			return biome.Environment{}, err
+		}
+	}
+	if err := extract(ctx, sys, distDir, distURL, tarbomb); err != nil {
+		return biome.Environment{}, err
+	}
+	if err := biome.WriteFile(ctx, sys.Biome, markerPath, strings.NewReader("")); err != nil {
+		return biome.Environment{}, fmt.Errorf("install gradle wrapper distribution: %w", err)
+	}
+	return env, nil
+}
+
+// gradleWrapperDistDir returns the names of the directories under the
+// wrapper's distributionPath that gradlew unpacks the given distribution URL
+// into: the archive name without its extension, then the base-36 MD5 digest
+// of the URL.
+func gradleWrapperDistDir(distURL string) (name, hash string, _ error) {
+	u, err := url.Parse(distURL)
+	if err != nil {
+		return "", "", fmt.Errorf("distributionUrl: %w", err)
+	}
+	name = path.Base(u.Path)
+	name = strings.TrimSuffix(name, path.Ext(name))
+	sum := md5.Sum([]byte(distURL))
+	return name, new(big.Int).SetBytes(sum[:]).Text(36), nil
+}
+
+// parseJavaProperties parses the subset of the Java properties file format
+// that gradle-wrapper.properties uses: one key=value pair per line, with
+// comments and backslash escapes.
+func parseJavaProperties(data string) map[string]string {
+	props := make(map[string]string)
+	for _, line := range strings.Split(data, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || line[0] == '#' || line[0] == '!' {
+			continue
+		}
+		sep := len(line)
+		for i := 0; i < len(line); i++ {
+			if line[i] == '\\' {
+				i++
+				continue
+			}
+			if line[i] == '=' || line[i] == ':' {
+				sep = i
+				break
+			}
+		}
+		key := unescapeJavaProperty(strings.TrimSpace(line[:sep]))
+		value := ""
+		if sep < len(line) {
+			value = unescapeJavaProperty(strings.TrimSpace(line[sep+1:]))
+		}
+		props[key] = value
+	}
+	return props
+}
+
+func unescapeJavaProperty(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	sb := new(strings.Builder)
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+		}
+		sb.WriteByte(s[i])
+	}
+	return sb.String()
+}
@@ -18,10 +18,21 @@ package buildpack
 
 import (
 	"context"
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"testing"
 
+	"github.com/google/go-cmp/cmp"
+	"github.com/yourbase/commons/http/headers"
 	"github.com/yourbase/yb/internal/biome"
+	"github.com/yourbase/yb/internal/ybdata"
 	"zombiezen.com/go/log/testlog"
 )
 
@@ -44,3 +55,120 @@ func TestGradle(t *testing.T) {
 		t.Errorf("gradle -version output does not include %q", version)
 	}
 }
+
+func TestGradleWrapperDistDir(t *testing.T) {
+	tests := []struct {
+		url      string
+		wantName string
+		wantHash string
+	}{
+		{
+			url:      "https://services.gradle.org/distributions/gradle-6.7-bin.zip",
+			wantName: "gradle-6.7-bin",
+			wantHash: "efvqh8uyq79v2n7rcncuhu9sv",
+		},
+		{
+			url:      "https://services.gradle.org/distributions/gradle-6.8.3-bin.zip",
+			wantName: "gradle-6.8.3-bin",
+			wantHash: "7ykxq50lst7lb7wx1nijpicxn",
+		},
+	}
+	for _, test := range tests {
+		name, hash, err := gradleWrapperDistDir(test.url)
+		if name != test.wantName || hash != test.wantHash || err != nil {
+			t.Errorf("gradleWrapperDistDir(%q) = %q, %q, %v; want %q, %q, <nil>", test.url, name, hash, err, test.wantName, test.wantHash)
+		}
+	}
+}
+
+func TestParseJavaProperties(t *testing.T) {
+	const data = "#Mon Oct 05 12:00:00 UTC 2020\n" +
+		"distributionBase=GRADLE_USER_HOME\n" +
+		"distributionPath = wrapper/dists\n" +
+		"distributionUrl=https\\://services.gradle.org/distributions/gradle-6.7-bin.zip\n" +
+		"! another comment\n" +
+		"zipStoreBase:GRADLE_USER_HOME\n" +
+		"key\\=with\\:separators=value\n" +
+		"\n" +
+		"empty\n"
+	got := parseJavaProperties(data)
+	want := map[string]string{
+		"distributionBase":    "GRADLE_USER_HOME",
+		"distributionPath":    "wrapper/dists",
+		"distributionUrl":     "https://services.gradle.org/distributions/gradle-6.7-bin.zip",
+		"zipStoreBase":        "GRADLE_USER_HOME",
+		"key=with:separators": "value",
+		"empty":               "",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("parseJavaProperties(...) (-want +got):\n%s", diff)
+	}
+}
+
+func TestInstallGradleWrapper(t *testing.T) {
+	dist := makeZip("gradle-6.7/bin/gradle")
+	distSum := sha256.Sum256(dist)
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/gradle-6.7-bin.zip" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set(headers.ContentLength, strconv.Itoa(len(dist)))
+		if r.Method == http.MethodHead {
+			return
+		}
+		requests++
+		w.Write(dist)
+	}))
+	t.Cleanup(srv.Close)
+
+	ctx := testlog.WithTB(context.Background(), t)
+	bio := newLocalTestBiome(t)
+	output := new(strings.Builder)
+	sys := Sys{
+		Biome:      bio,
+		Stdout:     output,
+		Stderr:     output,
+		Downloader: ybdata.NewDownloader(t.TempDir()),
+	}
+	sys.Downloader.Client = srv.Client()
+	distURL := srv.URL + "/gradle-6.7-bin.zip"
+	propsPath := filepath.Join(bio.Dirs().Package, filepath.FromSlash(gradleWrapperProperties))
+	if err := os.MkdirAll(filepath.Dir(propsPath), 0o777); err != nil {
+		t.Fatal(err)
+	}
+	props := fmt.Sprintf("distributionUrl=%s\ndistributionSha256Sum=%x\n", strings.ReplaceAll(distURL, ":", `\:`), distSum)
+	if err := ioutil.WriteFile(propsPath, []byte(props), 0o666); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		env, err := Install(ctx, sys, "gradle:wrapper")
+		if err != nil {
+			t.Fatalf("Install #%d: %v\noutput:\n%s", i+1, err, output)
+		}
+		gradleHome := filepath.Join(bio.Dirs().Home, ".gradle")
+		if got := env.Vars["GRADLE_USER_HOME"]; got != gradleHome {
+			t.Errorf("GRADLE_USER_HOME = %q; want %q", got, gradleHome)
+		}
+	}
+	if requests != 1 {
+		t.Errorf("distribution was downloaded %d times; want 1", requests)
+	}
+	_, hash, err := gradleWrapperDistDir(distURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	distDir := filepath.Join(bio.Dirs().Home, ".gradle", "wrapper", "dists", "gradle-6.7-bin", hash)
+	if _, err := os.Stat(filepath.Join(distDir, "gradle-6.7-bin.zip.ok")); err != nil {
+		t.Error(err)
+	}
+	got, err := ioutil.ReadFile(filepath.Join(distDir, "gradle-6.7", "bin", "gradle"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != extractContent {
+		t.Errorf("gradle content = %q; want %q", got, extractContent)
+	}
+}
@@ -0,0 +1,85 @@
+// Copyright 2020 YourBase Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package buildpack
+
+import (
+	"context"
+	"strings"
+
+	"github.com/yourbase/yb"
+	"github.com/yourbase/yb/internal/biome"
+	"zombiezen.com/go/log"
+)
+
+func installTerraform(ctx context.Context, sys Sys, spec yb.BuildpackSpec) (biome.Environment, error) {
+	return installHashiCorp(ctx, sys, spec, "terraform", "Terraform")
+}
+
+func installPacker(ctx context.Context, sys Sys, spec yb.BuildpackSpec) (biome.Environment, error) {
+	return installHashiCorp(ctx, sys, spec, "packer", "Packer")
+}
+
+// installHashiCorp installs a tool from releases.hashicorp.com. product is the
+// tool's name in URLs and displayName is its name in log messages.
+func installHashiCorp(ctx context.Context, sys Sys, spec yb.BuildpackSpec, product, displayName string) (biome.Environment, error) {
+	productDir := sys.Biome.JoinPath(sys.Biome.Dirs().Tools, product, product+"-"+spec.Version())
+	env := biome.Environment{
+		PrependPath: []string{productDir},
+	}
+
+	// If directory already exists, then use it.
+	if _, err := biome.EvalSymlinks(ctx, sys.Biome, productDir); err == nil {
+		log.Infof(ctx, "%s v%s located in %s", displayName, spec.Version(), productDir)
+		recordInstall(ctx, sys, spec, productDir)
+		return env, nil
+	}
+
+	log.Infof(ctx, "Installing %s v%s in %s", displayName, spec.Version(), productDir)
+	downloadURL, err := hashiCorpDownloadURL(product, displayName, spec.Version(), sys.Biome.Describe())
+	if err != nil {
+		return biome.Environment{}, err
+	}
+	// The SHA256SUMS file is next to the archives.
+	checksumURL := downloadURL[:strings.LastIndex(downloadURL, "/")+1] + product + "_" + spec.Version() + "_SHA256SUMS"
+	ctx, err = verifyDownload(ctx, sys, downloadURL, checksumURL)
+	if err != nil {
+		return biome.Environment{}, err
+	}
+	if err := extract(ctx, sys, productDir, downloadURL, tarbomb); err != nil {
+		return biome.Environment{}, err
+	}
+	recordInstall(ctx, sys, spec, productDir)
+	return env, nil
+}
+
+func hashiCorpDownloadURL(product, displayName, version string, desc *biome.Descriptor) (string, error) {
+	const template = "https://releases.hashicorp.com/{{.Product}}/{{.Version}}/{{.Product}}_{{.Version}}_{{.Platform}}.zip"
+	platform, err := platformName(displayName, map[biome.Descriptor]string{
+		{OS: biome.Linux, Arch: biome.Intel64}: "linux_amd64",
+		{OS: biome.Linux, Arch: biome.Intel32}: "linux_386",
+		{OS: biome.Linux, Arch: biome.ARM64}:   "linux_arm64",
+		{OS: biome.MacOS, Arch: biome.Intel64}: "darwin_amd64",
+	}, desc)
+	if err != nil {
+		return "", err
+	}
+	return templateToString(template, struct {
+		Product  string
+		Version  string
+		Platform string
+	}{product, version, platform})
+}
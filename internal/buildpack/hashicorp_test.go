@@ -0,0 +1,91 @@
+// Copyright 2020 YourBase Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package buildpack
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/yourbase/yb/internal/biome"
+	"zombiezen.com/go/log/testlog"
+)
+
+func TestTerraform(t *testing.T) {
+	const version = "0.15.1"
+	ctx := testlog.WithTB(context.Background(), t)
+	terraformBiome, _ := testInstall(ctx, t, "terraform:"+version)
+	versionOutput := new(strings.Builder)
+	err := terraformBiome.Run(ctx, &biome.Invocation{
+		Argv:   []string{"terraform", "version"},
+		Stdout: versionOutput,
+		Stderr: versionOutput,
+	})
+	t.Logf("terraform version output:\n%s", versionOutput)
+	if err != nil {
+		t.Errorf("terraform version: %v", err)
+	}
+	if got := versionOutput.String(); !strings.Contains(got, version) {
+		t.Errorf("terraform version output does not include %q", version)
+	}
+}
+
+func TestTerraformDownloadURL(t *testing.T) {
+	const version = "0.15.1"
+	testDownloadURLs(t, http.MethodHead, func(desc *biome.Descriptor) (string, error) {
+		return hashiCorpDownloadURL("terraform", "Terraform", version, desc)
+	}, []downloadURLTest{
+		{os: biome.Linux, arch: biome.Intel64, want: "https://releases.hashicorp.com/terraform/0.15.1/terraform_0.15.1_linux_amd64.zip"},
+		{os: biome.Linux, arch: biome.Intel32, want: "https://releases.hashicorp.com/terraform/0.15.1/terraform_0.15.1_linux_386.zip"},
+		{os: biome.Linux, arch: biome.ARM64, want: "https://releases.hashicorp.com/terraform/0.15.1/terraform_0.15.1_linux_arm64.zip"},
+		{os: biome.MacOS, arch: biome.Intel64, want: "https://releases.hashicorp.com/terraform/0.15.1/terraform_0.15.1_darwin_amd64.zip"},
+		{os: biome.Windows, arch: biome.Intel64, want: ""},
+	})
+}
+
+func TestPacker(t *testing.T) {
+	const version = "1.7.2"
+	ctx := testlog.WithTB(context.Background(), t)
+	packerBiome, _ := testInstall(ctx, t, "packer:"+version)
+	versionOutput := new(strings.Builder)
+	err := packerBiome.Run(ctx, &biome.Invocation{
+		Argv:   []string{"packer", "version"},
+		Stdout: versionOutput,
+		Stderr: versionOutput,
+	})
+	t.Logf("packer version output:\n%s", versionOutput)
+	if err != nil {
+		t.Errorf("packer version: %v", err)
+	}
+	if got := versionOutput.String(); !strings.Contains(got, version) {
+		t.Errorf("packer version output does not include %q", version)
+	}
+}
+
+func TestPackerDownloadURL(t *testing.T) {
+	const version = "1.7.2"
+	testDownloadURLs(t, http.MethodHead, func(desc *biome.Descriptor) (string, error) {
+		return hashiCorpDownloadURL("packer", "Packer", version, desc)
+	}, []downloadURLTest{
+		{os: biome.Linux, arch: biome.Intel64, want: "https://releases.hashicorp.com/packer/1.7.2/packer_1.7.2_linux_amd64.zip"},
+		{os: biome.Linux, arch: biome.Intel32, want: "https://releases.hashicorp.com/packer/1.7.2/packer_1.7.2_linux_386.zip"},
+		{os: biome.Linux, arch: biome.ARM64, want: "https://releases.hashicorp.com/packer/1.7.2/packer_1.7.2_linux_arm64.zip"},
+		{os: biome.MacOS, arch: biome.Intel64, want: "https://releases.hashicorp.com/packer/1.7.2/packer_1.7.2_darwin_amd64.zip"},
+		{os: biome.Windows, arch: biome.Intel64, want: ""},
+	})
+}
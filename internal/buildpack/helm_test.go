@@ -0,0 +1,59 @@
+// Copyright 2020 YourBase Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package buildpack
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/yourbase/yb/internal/biome"
+	"zombiezen.com/go/log/testlog"
+)
+
+func TestHelm(t *testing.T) {
+	const version = "3.5.4"
+	ctx := testlog.WithTB(context.Background(), t)
+	helmBiome, _ := testInstall(ctx, t, "helm:"+version)
+	versionOutput := new(strings.Builder)
+	err := helmBiome.Run(ctx, &biome.Invocation{
+		Argv:   []string{"helm", "version"},
+		Stdout: versionOutput,
+		Stderr: versionOutput,
+	})
+	t.Logf("helm version output:\n%s", versionOutput)
+	if err != nil {
+		t.Errorf("helm version: %v", err)
+	}
+	if got := versionOutput.String(); !strings.Contains(got, version) {
+		t.Errorf("helm version output does not include %q", version)
+	}
+}
+
+func TestHelmDownloadURL(t *testing.T) {
+	const version = "3.5.4"
+	testDownloadURLs(t, http.MethodHead, func(desc *biome.Descriptor) (string, error) {
+		return helmDownloadURL(version, desc)
+	}, []downloadURLTest{
+		{os: biome.Linux, arch: biome.Intel64, want: "https://get.helm.sh/helm-v3.5.4-linux-amd64.tar.gz"},
+		{os: biome.Linux, arch: biome.Intel32, want: "https://get.helm.sh/helm-v3.5.4-linux-386.tar.gz"},
+		{os: biome.Linux, arch: biome.ARM64, want: "https://get.helm.sh/helm-v3.5.4-linux-arm64.tar.gz"},
+		{os: biome.MacOS, arch: biome.Intel64, want: "https://get.helm.sh/helm-v3.5.4-darwin-amd64.tar.gz"},
+		{os: biome.Windows, arch: biome.Intel64, want: ""},
+	})
+}
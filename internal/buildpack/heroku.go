@@ -35,28 +35,7 @@ func installHeroku(ctx context.Context, sys Sys, spec yb.BuildpackSpec) (biome.E
 	} else {
 		// Download Heroku.
 		log.Infof(ctx, "Installing Heroku in %s", herokuDir)
-		const template = "https://cli-assets.heroku.com/heroku-{{.OS}}-{{.Arch}}.tar.gz"
-		desc := sys.Biome.Describe()
-		data := struct {
-			OS   string
-			Arch string
-		}{
-			OS: map[string]string{
-				biome.Linux: "linux",
-				biome.MacOS: "darwin",
-			}[desc.OS],
-			Arch: map[string]string{
-				biome.Intel64: "x64",
-				biome.Intel32: "x86",
-			}[desc.Arch],
-		}
-		if data.OS == "" {
-			return biome.Environment{}, fmt.Errorf("unsupported os %s", desc.OS)
-		}
-		if data.Arch == "" {
-			return biome.Environment{}, fmt.Errorf("unsupported architecture %s", desc.Arch)
-		}
-		downloadURL, err := templateToString(template, data)
+		downloadURL, err := herokuDownloadURL(sys.Biome.Describe())
 		if err != nil {
 			return biome.Environment{}, err
 		}
@@ -64,5 +43,23 @@ func installHeroku(ctx context.Context, sys Sys, spec yb.BuildpackSpec) (biome.E
 			return biome.Environment{}, err
 		}
 	}
+	recordInstall(ctx, sys, spec, herokuDir)
 	return env, nil
 }
+
+func herokuDownloadURL(desc *biome.Descriptor) (string, error) {
+	// The Heroku CLI's only ARM build is for 32-bit ARM, which arm64 machines
+	// can't necessarily run.
+	const template = "https://cli-assets.heroku.com/heroku-{{.Platform}}.tar.gz"
+	platform, err := platformName("The Heroku CLI", map[biome.Descriptor]string{
+		{OS: biome.Linux, Arch: biome.Intel64}: "linux-x64",
+		{OS: biome.Linux, Arch: biome.Intel32}: "linux-x86",
+		{OS: biome.MacOS, Arch: biome.Intel64}: "darwin-x64",
+	}, desc)
+	if err != nil {
+		return "", err
+	}
+	return templateToString(template, struct {
+		Platform string
+	}{platform})
+}
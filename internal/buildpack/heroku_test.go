@@ -18,6 +18,7 @@ package buildpack
 
 import (
 	"context"
+	"net/http"
 	"strings"
 	"testing"
 
@@ -56,3 +57,12 @@ func TestHeroku(t *testing.T) {
 		t.Errorf("heroku --version: %v", err)
 	}
 }
+
+func TestHerokuDownloadURL(t *testing.T) {
+	testDownloadURLs(t, http.MethodHead, herokuDownloadURL, []downloadURLTest{
+		{os: biome.Linux, arch: biome.Intel64, want: "https://cli-assets.heroku.com/heroku-linux-x64.tar.gz"},
+		{os: biome.Linux, arch: biome.Intel32, want: "https://cli-assets.heroku.com/heroku-linux-x86.tar.gz"},
+		{os: biome.Linux, arch: biome.ARM64},
+		{os: biome.MacOS, arch: biome.Intel64, want: "https://cli-assets.heroku.com/heroku-darwin-x64.tar.gz"},
+	})
+}
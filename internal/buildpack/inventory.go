@@ -0,0 +1,253 @@
+// Copyright 2020 YourBase Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package buildpack
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/blang/semver"
+	"github.com/yourbase/yb"
+	"github.com/yourbase/yb/internal/biome"
+	"github.com/yourbase/yb/internal/ybdata"
+	"zombiezen.com/go/log"
+)
+
+// inventoryDirName is the name of the directory inside a tools directory that
+// holds install records.
+const inventoryDirName = ".installs"
+
+// An Inventory records which buildpacks are installed in a tools directory and
+// when they were last used.
+type Inventory struct {
+	// Dir is the path on the host of the tools directory. For a biome whose
+	// tools are stored on the host, this is the host path of
+	// biome.Dirs.Tools.
+	Dir string
+}
+
+// An InstallRecord describes a buildpack installed in a tools directory.
+type InstallRecord struct {
+	Spec yb.BuildpackSpec `json:"spec"`
+	// Dirs is the list of directories that belong to the installation, as
+	// slash-separated paths relative to the tools directory.
+	Dirs []string `json:"dirs"`
+	// LastUsed is the last time the buildpack was installed or located.
+	LastUsed time.Time `json:"last_used"`
+
+	// Size is the total size in bytes of the files in Dirs. It is computed by
+	// Inventory.List and is not stored.
+	Size int64 `json:"-"`
+}
+
+// Match reports whether the record matches a buildpack name optionally
+// followed by a colon and a version or version range, like "node",
+// "node:12.19.0", or "node:12.x".
+func (rec *InstallRecord) Match(pattern string) (bool, error) {
+	name, query := pattern, ""
+	if i := strings.IndexByte(pattern, ':'); i != -1 {
+		name, query = pattern[:i], pattern[i+1:]
+	}
+	if name != rec.Spec.Name() {
+		return false, nil
+	}
+	version := rec.Spec.Version()
+	if query == "" || query == version {
+		return true, nil
+	}
+	if !isVersionQuery(query) {
+		return false, nil
+	}
+	match, err := parseVersionQuery(query)
+	if err != nil {
+		return false, err
+	}
+	sv, err := semver.ParseTolerant(version)
+	if err != nil {
+		return false, nil
+	}
+	return match(sv), nil
+}
+
+// List returns the buildpacks recorded in the tools directory, sorted by
+// buildpack specifier. Records whose directories have all been deleted are
+// skipped.
+func (inv *Inventory) List() ([]*InstallRecord, error) {
+	infos, err := ioutil.ReadDir(filepath.Join(inv.Dir, inventoryDirName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("list installed buildpacks: %w", err)
+	}
+	var records []*InstallRecord
+	for _, info := range infos {
+		if !info.Mode().IsRegular() || !strings.HasSuffix(info.Name(), ".json") {
+			continue
+		}
+		rec, err := inv.read(info.Name())
+		if err != nil {
+			return nil, fmt.Errorf("list installed buildpacks: %w", err)
+		}
+		found := false
+		for _, dir := range rec.Dirs {
+			size, err := ybdata.DiskUsage(filepath.Join(inv.Dir, filepath.FromSlash(dir)))
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			if err != nil {
+				return nil, fmt.Errorf("list installed buildpacks: %s: %w", rec.Spec, err)
+			}
+			found = true
+			rec.Size += size
+		}
+		if found {
+			records = append(records, rec)
+		}
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].Spec < records[j].Spec
+	})
+	return records, nil
+}
+
+// Remove deletes the buildpack's directories and its record.
+func (inv *Inventory) Remove(rec *InstallRecord) error {
+	for _, dir := range rec.Dirs {
+		if err := os.RemoveAll(filepath.Join(inv.Dir, filepath.FromSlash(dir))); err != nil {
+			return fmt.Errorf("remove %s: %w", rec.Spec, err)
+		}
+	}
+	err := os.Remove(filepath.Join(inv.Dir, inventoryDirName, recordFilename(rec.Spec)))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("remove %s: %w", rec.Spec, err)
+	}
+	return nil
+}
+
+// record writes an install record, replacing any previous record for the same
+// buildpack.
+func (inv *Inventory) record(rec *InstallRecord) error {
+	for _, dir := range rec.Dirs {
+		if !isLocalSlashPath(dir) {
+			return fmt.Errorf("record %s: directory %q not inside tools directory", rec.Spec, dir)
+		}
+	}
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("record %s: %w", rec.Spec, err)
+	}
+	dir := filepath.Join(inv.Dir, inventoryDirName)
+	if err := os.MkdirAll(dir, 0o777); err != nil {
+		return fmt.Errorf("record %s: %w", rec.Spec, err)
+	}
+	// Write to a temporary file and rename so that a concurrent List never
+	// observes a partial record.
+	f, err := ioutil.TempFile(dir, ".tmp*")
+	if err != nil {
+		return fmt.Errorf("record %s: %w", rec.Spec, err)
+	}
+	_, writeErr := f.Write(data)
+	closeErr := f.Close()
+	if writeErr != nil || closeErr != nil {
+		os.Remove(f.Name())
+		if writeErr != nil {
+			return fmt.Errorf("record %s: %w", rec.Spec, writeErr)
+		}
+		return fmt.Errorf("record %s: %w", rec.Spec, closeErr)
+	}
+	if err := os.Rename(f.Name(), filepath.Join(dir, recordFilename(rec.Spec))); err != nil {
+		os.Remove(f.Name())
+		return fmt.Errorf("record %s: %w", rec.Spec, err)
+	}
+	return nil
+}
+
+func (inv *Inventory) read(name string) (*InstallRecord, error) {
+	data, err := ioutil.ReadFile(filepath.Join(inv.Dir, inventoryDirName, name))
+	if err != nil {
+		return nil, err
+	}
+	rec := new(InstallRecord)
+	if err := json.Unmarshal(data, rec); err != nil {
+		return nil, fmt.Errorf("read %s: %w", name, err)
+	}
+	if !strings.Contains(string(rec.Spec), ":") {
+		return nil, fmt.Errorf("read %s: invalid buildpack %q", name, rec.Spec)
+	}
+	for _, dir := range rec.Dirs {
+		if !isLocalSlashPath(dir) {
+			return nil, fmt.Errorf("read %s: directory %q not inside tools directory", name, dir)
+		}
+	}
+	return rec, nil
+}
+
+// recordInstall notes in sys.Inventory that the buildpack given by spec is
+// installed in the given biome directories and was just used. The directories
+// must be inside the biome's tools directory. Failures are logged rather than
+// returned, since the inventory is only used to manage disk space.
+func recordInstall(ctx context.Context, sys Sys, spec yb.BuildpackSpec, dirs ...string) {
+	if sys.Inventory == nil {
+		return
+	}
+	tools := sys.Biome.Dirs().Tools
+	windows := sys.Biome.Describe().OS == biome.Windows
+	rec := &InstallRecord{
+		Spec:     spec,
+		LastUsed: time.Now().UTC(),
+	}
+	for _, dir := range dirs {
+		rel := strings.TrimPrefix(dir, tools)
+		if rel == dir {
+			log.Warnf(ctx, "Not recording %s: %s not inside %s", spec, dir, tools)
+			return
+		}
+		if windows {
+			rel = strings.ReplaceAll(rel, `\`, "/")
+		}
+		rec.Dirs = append(rec.Dirs, strings.TrimPrefix(rel, "/"))
+	}
+	if err := sys.Inventory.record(rec); err != nil {
+		log.Warnf(ctx, "%v", err)
+	}
+}
+
+// recordFilename returns the name of the file in the inventory directory that
+// holds the record for spec.
+func recordFilename(spec yb.BuildpackSpec) string {
+	return spec.Name() + "-" + spec.Version() + ".json"
+}
+
+// isLocalSlashPath reports whether p is a non-empty relative slash-separated
+// path that does not leave its parent directory.
+func isLocalSlashPath(p string) bool {
+	if p == "" || path.IsAbs(p) || strings.Contains(p, `\`) {
+		return false
+	}
+	clean := path.Clean(p)
+	return clean != "." && clean != ".." && !strings.HasPrefix(clean, "../")
+}
@@ -0,0 +1,170 @@
+// Copyright 2020 YourBase Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package buildpack
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yourbase/commons/http/headers"
+	"github.com/yourbase/yb"
+	"github.com/yourbase/yb/internal/biome"
+	"github.com/yourbase/yb/internal/ybdata"
+	"zombiezen.com/go/log/testlog"
+)
+
+func TestInventory(t *testing.T) {
+	inv := &Inventory{Dir: t.TempDir()}
+	writeTool := func(dir string, size int) {
+		t.Helper()
+		path := filepath.Join(inv.Dir, filepath.FromSlash(dir), "bin", "tool")
+		if err := os.MkdirAll(filepath.Dir(path), 0o777); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, make([]byte, size), 0o666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	used := time.Date(2020, time.December, 1, 12, 0, 0, 0, time.UTC)
+	writeTool("nodejs/node-12.19.0", 100)
+	writeTool("nodejs/node-14.17.0", 200)
+	for _, rec := range []*InstallRecord{
+		{Spec: "node:14.17.0", Dirs: []string{"nodejs/node-14.17.0"}, LastUsed: used},
+		{Spec: "node:12.19.0", Dirs: []string{"nodejs/node-12.19.0"}, LastUsed: used},
+		{Spec: "go:1.15.2", Dirs: []string{"go/go1.15.2"}, LastUsed: used},
+	} {
+		if err := inv.record(rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := inv.record(&InstallRecord{Spec: "evil:1.0", Dirs: []string{"../.."}}); err == nil {
+		t.Error("record with directory outside tools directory did not return an error")
+	}
+
+	got, err := inv.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// go:1.15.2 is skipped because its directory does not exist.
+	if len(got) != 2 || got[0].Spec != "node:12.19.0" || got[1].Spec != "node:14.17.0" {
+		t.Fatalf("List() = %v; want [node:12.19.0 node:14.17.0]", got)
+	}
+	if got[0].Size != 100 || got[1].Size != 200 {
+		t.Errorf("sizes = %d, %d; want 100, 200", got[0].Size, got[1].Size)
+	}
+	if !got[0].LastUsed.Equal(used) {
+		t.Errorf("LastUsed = %v; want %v", got[0].LastUsed, used)
+	}
+
+	if err := inv.Remove(got[0]); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(inv.Dir, "nodejs", "node-12.19.0")); !os.IsNotExist(err) {
+		t.Errorf("after Remove, stat node-12.19.0 directory: %v; want not exist", err)
+	}
+	got, err = inv.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].Spec != "node:14.17.0" {
+		t.Errorf("after Remove, List() = %v; want [node:14.17.0]", got)
+	}
+}
+
+func TestInstallRecordMatch(t *testing.T) {
+	tests := []struct {
+		spec    yb.BuildpackSpec
+		pattern string
+		want    bool
+	}{
+		{"node:12.19.0", "node", true},
+		{"node:12.19.0", "node:12.19.0", true},
+		{"node:12.19.0", "node:12.x", true},
+		{"node:12.19.0", "node:^12", true},
+		{"node:12.19.0", "node:12", true},
+		{"node:12.19.0", "node:14.x", false},
+		{"node:12.19.0", "nodejs", false},
+		{"java:11.0.8+10", "java:11", true},
+		{"heroku:latest", "heroku:latest", true},
+		{"heroku:latest", "heroku:1.x", false},
+	}
+	for _, test := range tests {
+		rec := &InstallRecord{Spec: test.spec}
+		got, err := rec.Match(test.pattern)
+		if err != nil {
+			t.Errorf("(%s).Match(%q): %v", test.spec, test.pattern, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("(%s).Match(%q) = %t; want %t", test.spec, test.pattern, got, test.want)
+		}
+	}
+}
+
+func TestInstallRecordsInventory(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		content := makeGzipTar("tool-1.0/bin/hello.txt")
+		w.Header().Set(headers.ContentLength, strconv.Itoa(len(content)))
+		w.Write(content)
+	}))
+	t.Cleanup(srv.Close)
+	ctx := testlog.WithTB(context.Background(), t)
+	bio := biome.Local{
+		PackageDir: t.TempDir(),
+		HomeDir:    t.TempDir(),
+	}
+	output := new(strings.Builder)
+	sys := Sys{
+		Biome:      bio,
+		Stdout:     output,
+		Stderr:     output,
+		Downloader: ybdata.NewDownloader(t.TempDir()),
+		Definitions: map[string]*yb.BuildpackDefinition{
+			"tool": {
+				URL:         srv.URL + "/tool-{{.Version}}.tar.gz",
+				StripTopDir: true,
+				Path:        []string{"bin"},
+			},
+		},
+		Inventory: &Inventory{Dir: bio.Dirs().Tools},
+	}
+	sys.Downloader.Client = srv.Client()
+	for i := 0; i < 2; i++ {
+		// The second install locates the existing installation.
+		if _, err := Install(ctx, sys, "tool:1.0"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got, err := sys.Inventory.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].Spec != "tool:1.0" || len(got[0].Dirs) != 1 || !strings.HasPrefix(got[0].Dirs[0], "defined/tool-1.0-") {
+		t.Fatalf("List() = %+v; want a single tool:1.0 record in defined/", got)
+	}
+	if got[0].Size != int64(len(extractContent)) {
+		t.Errorf("Size = %d; want %d", got[0].Size, len(extractContent))
+	}
+}
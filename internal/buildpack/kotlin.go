@@ -0,0 +1,62 @@
+// Copyright 2020 YourBase Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package buildpack
+
+import (
+	"context"
+
+	"github.com/yourbase/yb"
+	"github.com/yourbase/yb/internal/biome"
+	"zombiezen.com/go/log"
+)
+
+func installKotlin(ctx context.Context, sys Sys, spec yb.BuildpackSpec) (biome.Environment, error) {
+	kotlinDir := sys.Biome.JoinPath(sys.Biome.Dirs().Tools, "kotlin", "kotlinc-"+spec.Version())
+	env := biome.Environment{
+		Vars: map[string]string{
+			"KOTLIN_HOME": kotlinDir,
+		},
+		PrependPath: []string{
+			sys.Biome.JoinPath(kotlinDir, "bin"),
+		},
+	}
+
+	// If directory already exists, then use it.
+	if _, err := biome.EvalSymlinks(ctx, sys.Biome, kotlinDir); err == nil {
+		log.Infof(ctx, "Kotlin v%s located in %s", spec.Version(), kotlinDir)
+		recordInstall(ctx, sys, spec, kotlinDir)
+		return env, nil
+	}
+
+	log.Infof(ctx, "Installing Kotlin v%s in %s", spec.Version(), kotlinDir)
+	downloadURL, err := kotlinDownloadURL(spec.Version())
+	if err != nil {
+		return biome.Environment{}, err
+	}
+	if err := extract(ctx, sys, kotlinDir, downloadURL, stripTopDirectory); err != nil {
+		return biome.Environment{}, err
+	}
+	recordInstall(ctx, sys, spec, kotlinDir)
+	return env, nil
+}
+
+func kotlinDownloadURL(version string) (string, error) {
+	const template = "https://github.com/JetBrains/kotlin/releases/download/v{{.Version}}/kotlin-compiler-{{.Version}}.zip"
+	return templateToString(template, struct {
+		Version string
+	}{version})
+}
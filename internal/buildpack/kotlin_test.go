@@ -0,0 +1,55 @@
+// Copyright 2020 YourBase Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package buildpack
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/yourbase/yb/internal/biome"
+	"zombiezen.com/go/log/testlog"
+)
+
+func TestKotlin(t *testing.T) {
+	const version = "1.5.31"
+	ctx := testlog.WithTB(context.Background(), t)
+	kotlinBiome, _ := testInstall(ctx, t, "java:8.265+01", "kotlin:"+version)
+	versionOutput := new(strings.Builder)
+	err := kotlinBiome.Run(ctx, &biome.Invocation{
+		Argv:   []string{"kotlinc", "-version"},
+		Stdout: versionOutput,
+		Stderr: versionOutput,
+	})
+	t.Logf("kotlinc -version output:\n%s", versionOutput)
+	if err != nil {
+		t.Errorf("kotlinc -version: %v", err)
+	}
+	if got := versionOutput.String(); !strings.Contains(got, version) {
+		t.Errorf("kotlinc -version output does not include %q", version)
+	}
+}
+
+func TestKotlinDownloadURL(t *testing.T) {
+	const version = "1.5.31"
+	testDownloadURLs(t, http.MethodGet, func(*biome.Descriptor) (string, error) {
+		return kotlinDownloadURL(version)
+	}, []downloadURLTest{
+		{os: biome.Linux, arch: biome.Intel64, want: "https://github.com/JetBrains/kotlin/releases/download/v1.5.31/kotlin-compiler-1.5.31.zip"},
+	})
+}
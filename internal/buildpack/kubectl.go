@@ -0,0 +1,71 @@
+// Copyright 2020 YourBase Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package buildpack
+
+import (
+	"context"
+
+	"github.com/yourbase/yb"
+	"github.com/yourbase/yb/internal/biome"
+	"zombiezen.com/go/log"
+)
+
+func installKubectl(ctx context.Context, sys Sys, spec yb.BuildpackSpec) (biome.Environment, error) {
+	kubectlDir := sys.Biome.JoinPath(sys.Biome.Dirs().Tools, "kubectl", "kubectl-"+spec.Version())
+	env := biome.Environment{
+		PrependPath: []string{kubectlDir},
+	}
+
+	// If directory already exists, then use it.
+	if _, err := biome.EvalSymlinks(ctx, sys.Biome, kubectlDir); err == nil {
+		log.Infof(ctx, "kubectl v%s located in %s", spec.Version(), kubectlDir)
+		recordInstall(ctx, sys, spec, kubectlDir)
+		return env, nil
+	}
+
+	log.Infof(ctx, "Installing kubectl v%s in %s", spec.Version(), kubectlDir)
+	downloadURL, err := kubectlDownloadURL(spec.Version(), sys.Biome.Describe())
+	if err != nil {
+		return biome.Environment{}, err
+	}
+	ctx, err = verifyDownload(ctx, sys, downloadURL, downloadURL+".sha256")
+	if err != nil {
+		return biome.Environment{}, err
+	}
+	if err := installBinary(ctx, sys, kubectlDir, downloadURL); err != nil {
+		return biome.Environment{}, err
+	}
+	recordInstall(ctx, sys, spec, kubectlDir)
+	return env, nil
+}
+
+func kubectlDownloadURL(version string, desc *biome.Descriptor) (string, error) {
+	const template = "https://dl.k8s.io/release/v{{.Version}}/bin/{{.Platform}}/kubectl"
+	platform, err := platformName("Kubernetes", map[biome.Descriptor]string{
+		{OS: biome.Linux, Arch: biome.Intel64}: "linux/amd64",
+		{OS: biome.Linux, Arch: biome.Intel32}: "linux/386",
+		{OS: biome.Linux, Arch: biome.ARM64}:   "linux/arm64",
+		{OS: biome.MacOS, Arch: biome.Intel64}: "darwin/amd64",
+	}, desc)
+	if err != nil {
+		return "", err
+	}
+	return templateToString(template, struct {
+		Version  string
+		Platform string
+	}{version, platform})
+}
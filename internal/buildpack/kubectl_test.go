@@ -0,0 +1,59 @@
+// Copyright 2020 YourBase Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package buildpack
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/yourbase/yb/internal/biome"
+	"zombiezen.com/go/log/testlog"
+)
+
+func TestKubectl(t *testing.T) {
+	const version = "1.21.0"
+	ctx := testlog.WithTB(context.Background(), t)
+	kubectlBiome, _ := testInstall(ctx, t, "kubectl:"+version)
+	versionOutput := new(strings.Builder)
+	err := kubectlBiome.Run(ctx, &biome.Invocation{
+		Argv:   []string{"kubectl", "version", "--client"},
+		Stdout: versionOutput,
+		Stderr: versionOutput,
+	})
+	t.Logf("kubectl version --client output:\n%s", versionOutput)
+	if err != nil {
+		t.Errorf("kubectl version --client: %v", err)
+	}
+	if got := versionOutput.String(); !strings.Contains(got, version) {
+		t.Errorf("kubectl version --client output does not include %q", version)
+	}
+}
+
+func TestKubectlDownloadURL(t *testing.T) {
+	const version = "1.21.0"
+	testDownloadURLs(t, http.MethodHead, func(desc *biome.Descriptor) (string, error) {
+		return kubectlDownloadURL(version, desc)
+	}, []downloadURLTest{
+		{os: biome.Linux, arch: biome.Intel64, want: "https://dl.k8s.io/release/v1.21.0/bin/linux/amd64/kubectl"},
+		{os: biome.Linux, arch: biome.Intel32, want: "https://dl.k8s.io/release/v1.21.0/bin/linux/386/kubectl"},
+		{os: biome.Linux, arch: biome.ARM64, want: "https://dl.k8s.io/release/v1.21.0/bin/linux/arm64/kubectl"},
+		{os: biome.MacOS, arch: biome.Intel64, want: "https://dl.k8s.io/release/v1.21.0/bin/darwin/amd64/kubectl"},
+		{os: biome.Windows, arch: biome.Intel64, want: ""},
+	})
+}
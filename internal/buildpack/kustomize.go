@@ -0,0 +1,80 @@
+// Copyright 2020 YourBase Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package buildpack
+
+import (
+	"context"
+
+	"github.com/yourbase/yb"
+	"github.com/yourbase/yb/internal/biome"
+	"zombiezen.com/go/log"
+)
+
+// kustomizeReleaseURL is the URL of a kustomize release's assets. Release tags
+// have the form "kustomize/vX.Y.Z".
+const kustomizeReleaseURL = "https://github.com/kubernetes-sigs/kustomize/releases/download/kustomize%2Fv{{.Version}}/"
+
+func installKustomize(ctx context.Context, sys Sys, spec yb.BuildpackSpec) (biome.Environment, error) {
+	kustomizeDir := sys.Biome.JoinPath(sys.Biome.Dirs().Tools, "kustomize", "kustomize-"+spec.Version())
+	env := biome.Environment{
+		PrependPath: []string{kustomizeDir},
+	}
+
+	// If directory already exists, then use it.
+	if _, err := biome.EvalSymlinks(ctx, sys.Biome, kustomizeDir); err == nil {
+		log.Infof(ctx, "Kustomize v%s located in %s", spec.Version(), kustomizeDir)
+		recordInstall(ctx, sys, spec, kustomizeDir)
+		return env, nil
+	}
+
+	log.Infof(ctx, "Installing Kustomize v%s in %s", spec.Version(), kustomizeDir)
+	downloadURL, err := kustomizeDownloadURL(spec.Version(), sys.Biome.Describe())
+	if err != nil {
+		return biome.Environment{}, err
+	}
+	checksumURL, err := templateToString(kustomizeReleaseURL+"checksums.txt", struct {
+		Version string
+	}{spec.Version()})
+	if err != nil {
+		return biome.Environment{}, err
+	}
+	ctx, err = verifyDownload(ctx, sys, downloadURL, checksumURL)
+	if err != nil {
+		return biome.Environment{}, err
+	}
+	if err := extract(ctx, sys, kustomizeDir, downloadURL, tarbomb); err != nil {
+		return biome.Environment{}, err
+	}
+	recordInstall(ctx, sys, spec, kustomizeDir)
+	return env, nil
+}
+
+func kustomizeDownloadURL(version string, desc *biome.Descriptor) (string, error) {
+	const template = kustomizeReleaseURL + "kustomize_v{{.Version}}_{{.Platform}}.tar.gz"
+	platform, err := platformName("Kustomize", map[biome.Descriptor]string{
+		{OS: biome.Linux, Arch: biome.Intel64}: "linux_amd64",
+		{OS: biome.Linux, Arch: biome.ARM64}:   "linux_arm64",
+		{OS: biome.MacOS, Arch: biome.Intel64}: "darwin_amd64",
+	}, desc)
+	if err != nil {
+		return "", err
+	}
+	return templateToString(template, struct {
+		Version  string
+		Platform string
+	}{version, platform})
+}
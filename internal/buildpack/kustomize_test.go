@@ -0,0 +1,59 @@
+// Copyright 2020 YourBase Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package buildpack
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/yourbase/yb/internal/biome"
+	"zombiezen.com/go/log/testlog"
+)
+
+func TestKustomize(t *testing.T) {
+	const version = "4.1.2"
+	ctx := testlog.WithTB(context.Background(), t)
+	kustomizeBiome, _ := testInstall(ctx, t, "kustomize:"+version)
+	versionOutput := new(strings.Builder)
+	err := kustomizeBiome.Run(ctx, &biome.Invocation{
+		Argv:   []string{"kustomize", "version"},
+		Stdout: versionOutput,
+		Stderr: versionOutput,
+	})
+	t.Logf("kustomize version output:\n%s", versionOutput)
+	if err != nil {
+		t.Errorf("kustomize version: %v", err)
+	}
+	if got := versionOutput.String(); !strings.Contains(got, version) {
+		t.Errorf("kustomize version output does not include %q", version)
+	}
+}
+
+func TestKustomizeDownloadURL(t *testing.T) {
+	const version = "4.1.2"
+	testDownloadURLs(t, http.MethodGet, func(desc *biome.Descriptor) (string, error) {
+		return kustomizeDownloadURL(version, desc)
+	}, []downloadURLTest{
+		{os: biome.Linux, arch: biome.Intel64, want: "https://github.com/kubernetes-sigs/kustomize/releases/download/kustomize%2Fv4.1.2/kustomize_v4.1.2_linux_amd64.tar.gz"},
+		{os: biome.Linux, arch: biome.Intel32, want: ""},
+		{os: biome.Linux, arch: biome.ARM64, want: "https://github.com/kubernetes-sigs/kustomize/releases/download/kustomize%2Fv4.1.2/kustomize_v4.1.2_linux_arm64.tar.gz"},
+		{os: biome.MacOS, arch: biome.Intel64, want: "https://github.com/kubernetes-sigs/kustomize/releases/download/kustomize%2Fv4.1.2/kustomize_v4.1.2_darwin_amd64.tar.gz"},
+		{os: biome.Windows, arch: biome.Intel64, want: ""},
+	})
+}
@@ -28,6 +28,7 @@ func installMaven(ctx context.Context, sys Sys, spec yb.BuildpackSpec) (biome.En
 	// If directory already exists, then use it.
 	if _, err := biome.EvalSymlinks(ctx, sys.Biome, mavenDir); err == nil {
 		log.Infof(ctx, "Maven v%s located in %s", spec.Version(), mavenDir)
+		recordInstall(ctx, sys, spec, mavenDir)
 		return env, nil
 	}
 
@@ -47,5 +48,6 @@ func installMaven(ctx context.Context, sys Sys, spec yb.BuildpackSpec) (biome.En
 	if err := extract(ctx, sys, mavenDir, downloadURL, stripTopDirectory); err != nil {
 		return biome.Environment{}, err
 	}
+	recordInstall(ctx, sys, spec, mavenDir)
 	return env, nil
 }
@@ -0,0 +1,68 @@
+// Copyright 2020 YourBase Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package buildpack
+
+import (
+	"context"
+
+	"github.com/yourbase/yb"
+	"github.com/yourbase/yb/internal/biome"
+	"zombiezen.com/go/log"
+)
+
+func installNinja(ctx context.Context, sys Sys, spec yb.BuildpackSpec) (biome.Environment, error) {
+	ninjaDir := sys.Biome.JoinPath(sys.Biome.Dirs().Tools, "ninja", "ninja-"+spec.Version())
+	env := biome.Environment{
+		PrependPath: []string{ninjaDir},
+	}
+
+	// If directory already exists, then use it.
+	if _, err := biome.EvalSymlinks(ctx, sys.Biome, ninjaDir); err == nil {
+		log.Infof(ctx, "Ninja v%s located in %s", spec.Version(), ninjaDir)
+		recordInstall(ctx, sys, spec, ninjaDir)
+		return env, nil
+	}
+
+	log.Infof(ctx, "Installing Ninja v%s in %s", spec.Version(), ninjaDir)
+	downloadURL, err := ninjaDownloadURL(spec.Version(), sys.Biome.Describe())
+	if err != nil {
+		return biome.Environment{}, err
+	}
+	if err := extract(ctx, sys, ninjaDir, downloadURL, tarbomb); err != nil {
+		return biome.Environment{}, err
+	}
+	recordInstall(ctx, sys, spec, ninjaDir)
+	return env, nil
+}
+
+func ninjaDownloadURL(version string, desc *biome.Descriptor) (string, error) {
+	const template = "https://github.com/ninja-build/ninja/releases/download/v{{.Version}}/ninja-{{.Platform}}.zip"
+	platform, err := platformName("Ninja", map[biome.Descriptor]string{
+		{OS: biome.Linux, Arch: biome.Intel64}: "linux",
+		{OS: biome.Linux, Arch: biome.ARM64}:   "linux-aarch64",
+		// The macOS build is a universal binary.
+		{OS: biome.MacOS, Arch: biome.Intel64}: "mac",
+		{OS: biome.MacOS, Arch: biome.ARM64}:   "mac",
+	}, desc)
+	if err != nil {
+		return "", err
+	}
+	return templateToString(template, struct {
+		Version  string
+		Platform string
+	}{version, platform})
+}
@@ -0,0 +1,60 @@
+// Copyright 2020 YourBase Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package buildpack
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/yourbase/yb/internal/biome"
+	"zombiezen.com/go/log/testlog"
+)
+
+func TestNinja(t *testing.T) {
+	const version = "1.12.1"
+	ctx := testlog.WithTB(context.Background(), t)
+	ninjaBiome, _ := testInstall(ctx, t, "ninja:"+version)
+	versionOutput := new(strings.Builder)
+	err := ninjaBiome.Run(ctx, &biome.Invocation{
+		Argv:   []string{"ninja", "--version"},
+		Stdout: versionOutput,
+		Stderr: versionOutput,
+	})
+	t.Logf("ninja --version output:\n%s", versionOutput)
+	if err != nil {
+		t.Errorf("ninja --version: %v", err)
+	}
+	if got := versionOutput.String(); !strings.Contains(got, version) {
+		t.Errorf("ninja --version output does not include %q", version)
+	}
+}
+
+func TestNinjaDownloadURL(t *testing.T) {
+	const version = "1.12.1"
+	testDownloadURLs(t, http.MethodGet, func(desc *biome.Descriptor) (string, error) {
+		return ninjaDownloadURL(version, desc)
+	}, []downloadURLTest{
+		{os: biome.Linux, arch: biome.Intel64, want: "https://github.com/ninja-build/ninja/releases/download/v1.12.1/ninja-linux.zip"},
+		{os: biome.Linux, arch: biome.Intel32, want: ""},
+		{os: biome.Linux, arch: biome.ARM64, want: "https://github.com/ninja-build/ninja/releases/download/v1.12.1/ninja-linux-aarch64.zip"},
+		{os: biome.MacOS, arch: biome.Intel64, want: "https://github.com/ninja-build/ninja/releases/download/v1.12.1/ninja-mac.zip"},
+		{os: biome.MacOS, arch: biome.ARM64, want: "https://github.com/ninja-build/ninja/releases/download/v1.12.1/ninja-mac.zip"},
+		{os: biome.Windows, arch: biome.Intel64, want: ""},
+	})
+}
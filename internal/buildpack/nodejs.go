@@ -2,7 +2,6 @@ package buildpack
 
 import (
 	"context"
-	"fmt"
 
 	"github.com/yourbase/yb"
 	"github.com/yourbase/yb/internal/biome"
@@ -26,38 +25,34 @@ func installNode(ctx context.Context, sys Sys, spec yb.BuildpackSpec) (biome.Env
 	// If directory already exists, then use it.
 	if _, err := biome.EvalSymlinks(ctx, sys.Biome, nodeDir); err == nil {
 		log.Infof(ctx, "Node v%s located in %s", spec.Version(), nodeDir)
+		recordInstall(ctx, sys, spec, nodeDir)
 		return env, nil
 	}
 
 	log.Infof(ctx, "Installing Node v%s in %s", spec.Version(), nodeDir)
-	const template = "https://nodejs.org/dist/v{{.Version}}/node-v{{.Version}}-{{.OS}}-{{.Arch}}.tar.gz"
-	desc := sys.Biome.Describe()
-	data := struct {
-		Version string
-		OS      string
-		Arch    string
-	}{
-		spec.Version(),
-		map[string]string{
-			biome.Linux: "linux",
-			biome.MacOS: "darwin",
-		}[desc.OS],
-		map[string]string{
-			biome.Intel64: "x64",
-		}[desc.Arch],
-	}
-	if data.OS == "" {
-		return biome.Environment{}, fmt.Errorf("unsupported os %s", desc.OS)
-	}
-	if data.Arch == "" {
-		return biome.Environment{}, fmt.Errorf("unsupported architecture %s", desc.Arch)
-	}
-	downloadURL, err := templateToString(template, data)
+	downloadURL, err := nodeDownloadURL(spec.Version(), sys.Biome.Describe())
 	if err != nil {
 		return biome.Environment{}, err
 	}
 	if err := extract(ctx, sys, nodeDir, downloadURL, stripTopDirectory); err != nil {
 		return biome.Environment{}, err
 	}
+	recordInstall(ctx, sys, spec, nodeDir)
 	return env, nil
 }
+
+func nodeDownloadURL(version string, desc *biome.Descriptor) (string, error) {
+	const template = "https://nodejs.org/dist/v{{.Version}}/node-v{{.Version}}-{{.Platform}}.tar.gz"
+	platform, err := platformName("Node.js", map[biome.Descriptor]string{
+		{OS: biome.Linux, Arch: biome.Intel64}: "linux-x64",
+		{OS: biome.Linux, Arch: biome.ARM64}:   "linux-arm64",
+		{OS: biome.MacOS, Arch: biome.Intel64}: "darwin-x64",
+	}, desc)
+	if err != nil {
+		return "", err
+	}
+	return templateToString(template, struct {
+		Version  string
+		Platform string
+	}{version, platform})
+}
@@ -18,6 +18,7 @@ package buildpack
 
 import (
 	"context"
+	"net/http"
 	"strings"
 	"testing"
 
@@ -43,3 +44,16 @@ func TestNode(t *testing.T) {
 		t.Errorf("node --version output does not include %q", version)
 	}
 }
+
+func TestNodeDownloadURL(t *testing.T) {
+	const version = "12.19.0"
+	testDownloadURLs(t, http.MethodHead, func(desc *biome.Descriptor) (string, error) {
+		return nodeDownloadURL(version, desc)
+	}, []downloadURLTest{
+		{os: biome.Linux, arch: biome.Intel64, want: "https://nodejs.org/dist/v12.19.0/node-v12.19.0-linux-x64.tar.gz"},
+		{os: biome.Linux, arch: biome.ARM64, want: "https://nodejs.org/dist/v12.19.0/node-v12.19.0-linux-arm64.tar.gz"},
+		{os: biome.Linux, arch: biome.Intel32},
+		{os: biome.MacOS, arch: biome.Intel64, want: "https://nodejs.org/dist/v12.19.0/node-v12.19.0-darwin-x64.tar.gz"},
+		{os: biome.Windows, arch: biome.Intel64},
+	})
+}
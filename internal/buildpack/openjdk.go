@@ -33,6 +33,7 @@ func installJava(ctx context.Context, sys Sys, spec yb.BuildpackSpec) (_ biome.E
 	// If directory already exists, then use it.
 	if _, err := biome.EvalSymlinks(ctx, sys.Biome, home); err == nil {
 		log.Infof(ctx, "OpenJDK v%s located in %s", spec.Version(), installDir)
+		recordInstall(ctx, sys, spec, installDir)
 		return env, nil
 	}
 
@@ -44,6 +45,7 @@ func installJava(ctx context.Context, sys Sys, spec yb.BuildpackSpec) (_ biome.E
 	if err := extract(ctx, sys, installDir, downloadURL, stripTopDirectory); err != nil {
 		return biome.Environment{}, err
 	}
+	recordInstall(ctx, sys, spec, installDir)
 	return env, nil
 }
 
@@ -104,41 +106,38 @@ func javaDownloadURL(version string, desc *biome.Descriptor) (string, error) {
 	var urlPattern string
 	if majorVersion < 9 {
 		// TODO add openjdk8 OpenJ9 support
-		urlPattern = "https://github.com/AdoptOpenJDK/openjdk{{.MajorVersion}}-binaries/releases/download/jdk{{.MajorVersion}}u{{.MinorVersion}}-b{{.SubVersion}}/OpenJDK{{.MajorVersion}}U-jdk_{{.Arch}}_{{.OS}}_hotspot_{{.MajorVersion}}u{{.MinorVersion}}b{{.SubVersion}}.tar.gz"
+		urlPattern = "https://github.com/AdoptOpenJDK/openjdk{{.MajorVersion}}-binaries/releases/download/jdk{{.MajorVersion}}u{{.MinorVersion}}-b{{.SubVersion}}/OpenJDK{{.MajorVersion}}U-jdk_{{.Platform}}_hotspot_{{.MajorVersion}}u{{.MinorVersion}}b{{.SubVersion}}.tar.gz"
+	} else if majorVersion >= 17 {
+		// Releases since 17 are published by Adoptium as Eclipse Temurin.
+		urlPattern = "https://github.com/adoptium/temurin{{ .MajorVersion }}-binaries/releases/download/jdk-{{ .MajorVersion }}.{{ .MinorVersion }}.{{ .PatchVersion }}%2B{{ .SubVersion }}/OpenJDK{{ .MajorVersion }}U-jdk_{{.Platform}}_hotspot_{{ .MajorVersion }}.{{ .MinorVersion }}.{{ .PatchVersion }}_{{ .SubVersion }}.tar.gz"
 	} else {
 		if majorVersion < 14 {
 			// OpenJDK 9 has a whole other scheme
 			if majorVersion == 9 && subVersion == "181" {
-				urlPattern = "https://github.com/AdoptOpenJDK/openjdk{{ .MajorVersion }}-binaries/releases/download/jdk-{{ .MajorVersion }}%2B{{ .SubVersion }}/OpenJDK{{ .MajorVersion }}U-jdk_{{.Arch}}_{{.OS}}_hotspot_{{ .MajorVersion }}_{{ .SubVersion }}.tar.gz"
+				urlPattern = "https://github.com/AdoptOpenJDK/openjdk{{ .MajorVersion }}-binaries/releases/download/jdk-{{ .MajorVersion }}%2B{{ .SubVersion }}/OpenJDK{{ .MajorVersion }}U-jdk_{{.Platform}}_hotspot_{{ .MajorVersion }}_{{ .SubVersion }}.tar.gz"
 			} else {
-				urlPattern = "https://github.com/AdoptOpenJDK/openjdk{{ .MajorVersion }}-binaries/releases/download/jdk-{{ .MajorVersion }}.{{ .MinorVersion }}.{{ .PatchVersion }}%2B{{ .SubVersion }}/OpenJDK{{ .MajorVersion }}U-jdk_{{.Arch}}_{{.OS}}_hotspot_{{ .MajorVersion }}.{{ .MinorVersion }}.{{ .PatchVersion }}_{{ .SubVersion }}.tar.gz"
+				urlPattern = "https://github.com/AdoptOpenJDK/openjdk{{ .MajorVersion }}-binaries/releases/download/jdk-{{ .MajorVersion }}.{{ .MinorVersion }}.{{ .PatchVersion }}%2B{{ .SubVersion }}/OpenJDK{{ .MajorVersion }}U-jdk_{{.Platform}}_hotspot_{{ .MajorVersion }}.{{ .MinorVersion }}.{{ .PatchVersion }}_{{ .SubVersion }}.tar.gz"
 			}
 		} else {
 			// 14: https://github.com/AdoptOpenJDK/openjdk14-binaries/releases/download/jdk-14%2B36/OpenJDK14U-jdk_aarch64_linux_hotspot_14_36.tar.gz
-			urlPattern = "https://github.com/AdoptOpenJDK/openjdk{{ .MajorVersion }}-binaries/releases/download/jdk-{{ .MajorVersion }}%2B{{ .SubVersion }}/OpenJDK{{ .MajorVersion }}U-jdk_{{.Arch}}_{{.OS}}_hotspot_{{ .MajorVersion }}_{{ .SubVersion }}.tar.gz"
+			urlPattern = "https://github.com/AdoptOpenJDK/openjdk{{ .MajorVersion }}-binaries/releases/download/jdk-{{ .MajorVersion }}%2B{{ .SubVersion }}/OpenJDK{{ .MajorVersion }}U-jdk_{{.Platform}}_hotspot_{{ .MajorVersion }}_{{ .SubVersion }}.tar.gz"
 		}
 	}
 
 	var data struct {
-		OS           string
-		Arch         string
+		Platform     string
 		MajorVersion int64
 		MinorVersion int64
 		PatchVersion int64
 		SubVersion   string // not always an int, sometimes a float
 	}
-	data.OS = map[string]string{
-		biome.Linux: "linux",
-		biome.MacOS: "mac",
-	}[desc.OS]
-	if data.OS == "" {
-		return "", fmt.Errorf("unsupported os %s", desc.OS)
-	}
-	data.Arch = map[string]string{
-		biome.Intel64: "x64",
-	}[desc.Arch]
-	if data.Arch == "" {
-		return "", fmt.Errorf("unsupported architecture %s", desc.Arch)
+	data.Platform, err = platformName("AdoptOpenJDK", map[biome.Descriptor]string{
+		{OS: biome.Linux, Arch: biome.Intel64}: "x64_linux",
+		{OS: biome.Linux, Arch: biome.ARM64}:   "aarch64_linux",
+		{OS: biome.MacOS, Arch: biome.Intel64}: "x64_mac",
+	}, desc)
+	if err != nil {
+		return "", err
 	}
 	data.MajorVersion = majorVersion
 	data.MinorVersion = minorVersion
@@ -118,6 +118,10 @@ func TestJavaDownloadURL(t *testing.T) {
 			version: "14+36",
 			want:    "https://github.com/AdoptOpenJDK/openjdk14-binaries/releases/download/jdk-14%2B36/OpenJDK14U-jdk_x64_linux_hotspot_14_36.tar.gz",
 		},
+		{
+			version: "17.0.9+9",
+			want:    "https://github.com/adoptium/temurin17-binaries/releases/download/jdk-17.0.9%2B9/OpenJDK17U-jdk_x64_linux_hotspot_17.0.9_9.tar.gz",
+		},
 	}
 	for _, test := range tests {
 		desc := &biome.Descriptor{
@@ -139,3 +143,15 @@ func TestJavaDownloadURL(t *testing.T) {
 		}
 	})
 }
+
+func TestJavaDownloadURLPlatforms(t *testing.T) {
+	const version = "14+36"
+	testDownloadURLs(t, http.MethodGet, func(desc *biome.Descriptor) (string, error) {
+		return javaDownloadURL(version, desc)
+	}, []downloadURLTest{
+		{os: biome.Linux, arch: biome.Intel64, want: "https://github.com/AdoptOpenJDK/openjdk14-binaries/releases/download/jdk-14%2B36/OpenJDK14U-jdk_x64_linux_hotspot_14_36.tar.gz"},
+		{os: biome.Linux, arch: biome.ARM64, want: "https://github.com/AdoptOpenJDK/openjdk14-binaries/releases/download/jdk-14%2B36/OpenJDK14U-jdk_aarch64_linux_hotspot_14_36.tar.gz"},
+		{os: biome.MacOS, arch: biome.Intel64, want: "https://github.com/AdoptOpenJDK/openjdk14-binaries/releases/download/jdk-14%2B36/OpenJDK14U-jdk_x64_mac_hotspot_14_36.tar.gz"},
+		{os: biome.Windows, arch: biome.Intel64},
+	})
+}
@@ -0,0 +1,112 @@
+// Copyright 2020 YourBase Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package buildpack
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sync"
+)
+
+// packOutput returns a context and Sys for installing the named buildpack
+// alongside other buildpacks. Output written to the returned Sys's Stdout and
+// Stderr is labeled with the buildpack name and written to the original
+// writers a line at a time while holding mu. The caller must call flush after
+// the installation finishes to write any incomplete final line.
+func packOutput(ctx context.Context, sys Sys, mu *sync.Mutex, name string) (_ context.Context, _ Sys, flush func() error) {
+	if sys.LogPrefix != nil {
+		ctx = sys.LogPrefix(ctx, name)
+	}
+	var writers []*packLineWriter
+	wrap := func(w io.Writer) io.Writer {
+		if w == nil {
+			return nil
+		}
+		lw := &packLineWriter{mu: mu, w: w, prefix: "[" + name + "] "}
+		writers = append(writers, lw)
+		return lw
+	}
+	sameWriter := sys.Stdout == sys.Stderr
+	sys.Stdout = wrap(sys.Stdout)
+	if sameWriter {
+		// Keep interleaved stdout and stderr lines in order.
+		sys.Stderr = sys.Stdout
+	} else {
+		sys.Stderr = wrap(sys.Stderr)
+	}
+	flush = func() error {
+		var firstErr error
+		for _, lw := range writers {
+			if err := lw.flush(); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+		return firstErr
+	}
+	return ctx, sys, flush
+}
+
+// packLineWriter prepends a prefix to every line written to it and writes
+// complete lines to an underlying writer while holding mu, so that lines from
+// different writers sharing mu are not interleaved.
+type packLineWriter struct {
+	mu     *sync.Mutex
+	w      io.Writer
+	prefix string
+	buf    []byte
+}
+
+func (lw *packLineWriter) Write(p []byte) (int, error) {
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+	lw.buf = append(lw.buf, p...)
+	rest := lw.buf
+	var out []byte
+	for {
+		i := bytes.IndexByte(rest, '\n')
+		if i == -1 {
+			break
+		}
+		out = append(out, lw.prefix...)
+		out = append(out, rest[:i+1]...)
+		rest = rest[i+1:]
+	}
+	lw.buf = append(lw.buf[:0], rest...)
+	if len(out) > 0 {
+		if _, err := lw.w.Write(out); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// flush writes any buffered incomplete line followed by a newline.
+func (lw *packLineWriter) flush() error {
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+	if len(lw.buf) == 0 {
+		return nil
+	}
+	out := make([]byte, 0, len(lw.prefix)+len(lw.buf)+1)
+	out = append(out, lw.prefix...)
+	out = append(out, lw.buf...)
+	out = append(out, '\n')
+	lw.buf = lw.buf[:0]
+	_, err := lw.w.Write(out)
+	return err
+}
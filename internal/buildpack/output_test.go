@@ -0,0 +1,62 @@
+// Copyright 2020 YourBase Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package buildpack
+
+import (
+	"context"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestPackOutput(t *testing.T) {
+	out := new(strings.Builder)
+	mu := new(sync.Mutex)
+	_, javaSys, javaFlush := packOutput(context.Background(), Sys{Stdout: out, Stderr: out}, mu, "java")
+	_, nodeSys, nodeFlush := packOutput(context.Background(), Sys{Stdout: out, Stderr: out}, mu, "node")
+	writes := []struct {
+		w    io.Writer
+		data string
+	}{
+		{javaSys.Stdout, "Downloading"},
+		{nodeSys.Stderr, "foo\nba"},
+		{javaSys.Stderr, "...\nDone\n"},
+		{nodeSys.Stdout, "r\nbaz"},
+	}
+	for _, w := range writes {
+		if _, err := io.WriteString(w.w, w.data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := javaFlush(); err != nil {
+		t.Error("java flush:", err)
+	}
+	if err := nodeFlush(); err != nil {
+		t.Error("node flush:", err)
+	}
+	want := "[node] foo\n" +
+		"[java] Downloading...\n" +
+		"[java] Done\n" +
+		"[node] bar\n" +
+		"[node] baz\n"
+	if diff := cmp.Diff(want, out.String()); diff != "" {
+		t.Errorf("output (-want +got):\n%s", diff)
+	}
+}
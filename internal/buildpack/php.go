@@ -0,0 +1,103 @@
+// Copyright 2020 YourBase Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package buildpack
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/yourbase/yb"
+	"github.com/yourbase/yb/internal/biome"
+	"zombiezen.com/go/log"
+)
+
+// installPHP compiles PHP from source, since php.net only publishes source
+// releases. The biome needs a C toolchain along with the OpenSSL, zlib,
+// libxml2, and SQLite development headers.
+func installPHP(ctx context.Context, sys Sys, spec yb.BuildpackSpec) (biome.Environment, error) {
+	phpDir := sys.Biome.JoinPath(sys.Biome.Dirs().Tools, "php", "php-"+spec.Version())
+	env := biome.Environment{
+		PrependPath: []string{sys.Biome.JoinPath(phpDir, "bin")},
+	}
+
+	// If directory already exists, then use it.
+	if _, err := biome.EvalSymlinks(ctx, sys.Biome, phpDir); err == nil {
+		log.Infof(ctx, "PHP v%s located in %s", spec.Version(), phpDir)
+		recordInstall(ctx, sys, spec, phpDir)
+		return env, nil
+	}
+
+	log.Infof(ctx, "Installing PHP v%s in %s", spec.Version(), phpDir)
+	downloadURL, checksum, err := phpDownloadURL(ctx, sys, spec.Version())
+	if err != nil {
+		return biome.Environment{}, err
+	}
+	ctx, err = verifyDownload(ctx, sys, downloadURL, checksum)
+	if err != nil {
+		return biome.Environment{}, err
+	}
+	// Composer needs OpenSSL to download packages over HTTPS and zlib to
+	// unpack them.
+	err = buildFromSource(ctx, sys, "PHP", phpDir, downloadURL, "--with-openssl", "--with-zlib")
+	if err != nil {
+		return biome.Environment{}, err
+	}
+	recordInstall(ctx, sys, spec, phpDir)
+	return env, nil
+}
+
+// phpDownloadURL looks up the URL and SHA-256 digest of a PHP source release.
+func phpDownloadURL(ctx context.Context, sys Sys, version string) (downloadURL, checksum string, _ error) {
+	var release phpRelease
+	releaseURL := "https://www.php.net/releases/index.php?json&version=" + url.QueryEscape(version)
+	if err := fetchJSON(ctx, sys, releaseURL, &release); err != nil {
+		return "", "", fmt.Errorf("find php %s: %w", version, err)
+	}
+	if release.Version != version {
+		// php.net returns the latest release of a major version or an error
+		// object for other queries.
+		return "", "", fmt.Errorf("find php %s: no such release", version)
+	}
+	filename, checksum := pickPHPSource(&release)
+	if filename == "" {
+		return "", "", fmt.Errorf("find php %s: no .tar.gz source published", version)
+	}
+	return "https://www.php.net/distributions/" + filename, checksum, nil
+}
+
+// phpRelease is the subset of php.net's release JSON that the php buildpack
+// uses.
+type phpRelease struct {
+	Version string `json:"version"`
+	Source  []struct {
+		Filename string `json:"filename"`
+		SHA256   string `json:"sha256"`
+	} `json:"source"`
+}
+
+// pickPHPSource returns the filename and SHA-256 digest of the release's
+// .tar.gz source archive.
+func pickPHPSource(release *phpRelease) (filename, checksum string) {
+	for _, src := range release.Source {
+		if strings.HasSuffix(src.Filename, ".tar.gz") {
+			return src.Filename, src.SHA256
+		}
+	}
+	return "", ""
+}
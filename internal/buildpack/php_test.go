@@ -0,0 +1,67 @@
+// Copyright 2020 YourBase Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package buildpack
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/yourbase/yb/internal/biome"
+	"zombiezen.com/go/log/testlog"
+)
+
+func TestPHP(t *testing.T) {
+	const version = "8.0.3"
+	ctx := testlog.WithTB(context.Background(), t)
+	phpBiome, _ := testInstall(ctx, t, "php:"+version)
+	versionOutput := new(strings.Builder)
+	err := phpBiome.Run(ctx, &biome.Invocation{
+		Argv:   []string{"php", "--version"},
+		Stdout: versionOutput,
+		Stderr: versionOutput,
+	})
+	t.Logf("php --version output:\n%s", versionOutput)
+	if err != nil {
+		t.Errorf("php --version: %v", err)
+	}
+	if got := versionOutput.String(); !strings.Contains(got, version) {
+		t.Errorf("php --version output does not include %q", version)
+	}
+}
+
+func TestPickPHPSource(t *testing.T) {
+	const releaseJSON = `{
+		"announcement": true,
+		"date": "04 Mar 2021",
+		"source": [
+			{"filename": "php-8.0.3.tar.bz2", "name": "PHP 8.0.3 (tar.bz2)", "sha256": "aaaa"},
+			{"filename": "php-8.0.3.tar.gz", "name": "PHP 8.0.3 (tar.gz)", "sha256": "bbbb"},
+			{"filename": "php-8.0.3.tar.xz", "name": "PHP 8.0.3 (tar.xz)", "sha256": "cccc"}
+		],
+		"version": "8.0.3"
+	}`
+	release := new(phpRelease)
+	if err := json.Unmarshal([]byte(releaseJSON), release); err != nil {
+		t.Fatal(err)
+	}
+	filename, checksum := pickPHPSource(release)
+	if filename != "php-8.0.3.tar.gz" || checksum != "bbbb" {
+		t.Errorf("pickPHPSource(...) = %q, %q; want %q, %q", filename, checksum, "php-8.0.3.tar.gz", "bbbb")
+	}
+}
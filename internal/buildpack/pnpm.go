@@ -0,0 +1,104 @@
+// Copyright 2020 YourBase Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package buildpack
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/yourbase/commons/xcontext"
+	"github.com/yourbase/yb"
+	"github.com/yourbase/yb/internal/biome"
+	"zombiezen.com/go/log"
+)
+
+// installPnpm installs pnpm's standalone executable, which bundles its own
+// Node.js runtime.
+func installPnpm(ctx context.Context, sys Sys, spec yb.BuildpackSpec) (_ biome.Environment, err error) {
+	home := sys.Biome.Dirs().Home
+	pnpmDir := sys.Biome.JoinPath(sys.Biome.Dirs().Tools, "pnpm", "pnpm-"+spec.Version())
+	pnpmHome := sys.Biome.JoinPath(home, ".local", "share", "pnpm")
+	env := biome.Environment{
+		Vars: map[string]string{
+			// Where `pnpm add --global` puts executables.
+			"PNPM_HOME": pnpmHome,
+			// Share one content-addressable store among the build's projects.
+			"npm_config_store_dir": sys.Biome.JoinPath(home, ".cache", "pnpm", "store"),
+		},
+		PrependPath: []string{pnpmDir, pnpmHome},
+	}
+
+	// If directory already exists, then use it.
+	if _, err := biome.EvalSymlinks(ctx, sys.Biome, pnpmDir); err == nil {
+		log.Infof(ctx, "pnpm v%s located in %s", spec.Version(), pnpmDir)
+		recordInstall(ctx, sys, spec, pnpmDir)
+		return env, nil
+	}
+
+	log.Infof(ctx, "Installing pnpm v%s in %s", spec.Version(), pnpmDir)
+	downloadURL, err := pnpmDownloadURL(spec.Version(), sys.Biome.Describe())
+	if err != nil {
+		return biome.Environment{}, err
+	}
+	if err := installBinary(ctx, sys, pnpmDir, downloadURL); err != nil {
+		return biome.Environment{}, err
+	}
+	defer func() {
+		// Remove the directory on failure so that later builds retry.
+		if err != nil {
+			rmCtx, cancel := xcontext.KeepAlive(ctx, 10*time.Second)
+			defer cancel()
+			rmErr := sys.Biome.Run(rmCtx, &biome.Invocation{
+				Argv:   []string{"rm", "-rf", pnpmDir},
+				Stdout: sys.Stdout,
+				Stderr: sys.Stderr,
+			})
+			if rmErr != nil {
+				log.Warnf(ctx, "Cleaning up failed pnpm install: %v", rmErr)
+			}
+		}
+	}()
+	err = sys.Biome.Run(ctx, &biome.Invocation{
+		Argv:   []string{"ln", "-s", downloadURL[strings.LastIndex(downloadURL, "/")+1:], sys.Biome.JoinPath(pnpmDir, "pnpm")},
+		Stdout: sys.Stdout,
+		Stderr: sys.Stderr,
+	})
+	if err != nil {
+		return biome.Environment{}, fmt.Errorf("install pnpm: %w", err)
+	}
+	recordInstall(ctx, sys, spec, pnpmDir)
+	return env, nil
+}
+
+func pnpmDownloadURL(version string, desc *biome.Descriptor) (string, error) {
+	const template = "https://github.com/pnpm/pnpm/releases/download/v{{.Version}}/pnpm-{{.Platform}}"
+	platform, err := platformName("pnpm", map[biome.Descriptor]string{
+		{OS: biome.Linux, Arch: biome.Intel64}: "linux-x64",
+		{OS: biome.Linux, Arch: biome.ARM64}:   "linux-arm64",
+		{OS: biome.MacOS, Arch: biome.Intel64}: "macos-x64",
+		{OS: biome.MacOS, Arch: biome.ARM64}:   "macos-arm64",
+	}, desc)
+	if err != nil {
+		return "", err
+	}
+	return templateToString(template, struct {
+		Version  string
+		Platform string
+	}{version, platform})
+}
@@ -0,0 +1,59 @@
+// Copyright 2020 YourBase Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package buildpack
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/yourbase/yb/internal/biome"
+	"zombiezen.com/go/log/testlog"
+)
+
+func TestPnpm(t *testing.T) {
+	const version = "8.6.0"
+	ctx := testlog.WithTB(context.Background(), t)
+	pnpmBiome, _ := testInstall(ctx, t, "pnpm:"+version)
+	versionOutput := new(strings.Builder)
+	err := pnpmBiome.Run(ctx, &biome.Invocation{
+		Argv:   []string{"pnpm", "--version"},
+		Stdout: versionOutput,
+		Stderr: versionOutput,
+	})
+	t.Logf("pnpm --version output:\n%s", versionOutput)
+	if err != nil {
+		t.Errorf("pnpm --version: %v", err)
+	}
+	if got := versionOutput.String(); !strings.Contains(got, version) {
+		t.Errorf("pnpm --version output does not include %q", version)
+	}
+}
+
+func TestPnpmDownloadURL(t *testing.T) {
+	const version = "8.6.0"
+	testDownloadURLs(t, http.MethodGet, func(desc *biome.Descriptor) (string, error) {
+		return pnpmDownloadURL(version, desc)
+	}, []downloadURLTest{
+		{os: biome.Linux, arch: biome.Intel64, want: "https://github.com/pnpm/pnpm/releases/download/v8.6.0/pnpm-linux-x64"},
+		{os: biome.Linux, arch: biome.Intel32, want: ""},
+		{os: biome.Linux, arch: biome.ARM64, want: "https://github.com/pnpm/pnpm/releases/download/v8.6.0/pnpm-linux-arm64"},
+		{os: biome.MacOS, arch: biome.Intel64, want: "https://github.com/pnpm/pnpm/releases/download/v8.6.0/pnpm-macos-x64"},
+		{os: biome.MacOS, arch: biome.ARM64, want: "https://github.com/pnpm/pnpm/releases/download/v8.6.0/pnpm-macos-arm64"},
+	})
+}
@@ -2,7 +2,6 @@ package buildpack
 
 import (
 	"context"
-	"fmt"
 
 	"github.com/yourbase/yb"
 	"github.com/yourbase/yb/internal/biome"
@@ -20,37 +19,37 @@ func installProtoc(ctx context.Context, sys Sys, spec yb.BuildpackSpec) (biome.E
 	// If directory already exists, then use it.
 	if _, err := biome.EvalSymlinks(ctx, sys.Biome, protocDir); err == nil {
 		log.Infof(ctx, "protoc v%s located in %s", spec.Version(), protocDir)
+		recordInstall(ctx, sys, spec, protocDir)
 		return env, nil
 	}
 
 	log.Infof(ctx, "Installing protoc v%s in %s", spec.Version(), protocDir)
-	const template = "https://github.com/google/protobuf/releases/download/v{{.Version}}/protoc-{{.Version}}-{{.Variant}}.zip"
-	data := struct {
-		Version string
-		Variant string
-	}{
-		Version: spec.Version(),
-	}
-	switch desc := sys.Biome.Describe(); {
-	case desc.OS == biome.Linux && desc.Arch == biome.Intel64:
-		data.Variant = "linux-x86_64"
-	case desc.OS == biome.Linux && desc.Arch == biome.Intel32:
-		data.Variant = "linux-x86_32"
-	case desc.OS == biome.MacOS && desc.Arch == biome.Intel64:
-		data.Variant = "osx-x86_64"
-	case desc.OS == biome.Windows && desc.Arch == biome.Intel64:
-		data.Variant = "win64"
-	case desc.OS == biome.Windows && desc.Arch == biome.Intel32:
-		data.Variant = "win32"
-	default:
-		return biome.Environment{}, fmt.Errorf("unsupported os/arch %s/%s", desc.OS, desc.Arch)
-	}
-	downloadURL, err := templateToString(template, data)
+	downloadURL, err := protocDownloadURL(spec.Version(), sys.Biome.Describe())
 	if err != nil {
 		return biome.Environment{}, err
 	}
 	if err := extract(ctx, sys, protocDir, downloadURL, tarbomb); err != nil {
 		return biome.Environment{}, err
 	}
+	recordInstall(ctx, sys, spec, protocDir)
 	return env, nil
 }
+
+func protocDownloadURL(version string, desc *biome.Descriptor) (string, error) {
+	const template = "https://github.com/google/protobuf/releases/download/v{{.Version}}/protoc-{{.Version}}-{{.Variant}}.zip"
+	variant, err := platformName("protoc", map[biome.Descriptor]string{
+		{OS: biome.Linux, Arch: biome.Intel64}:   "linux-x86_64",
+		{OS: biome.Linux, Arch: biome.Intel32}:   "linux-x86_32",
+		{OS: biome.Linux, Arch: biome.ARM64}:     "linux-aarch_64",
+		{OS: biome.MacOS, Arch: biome.Intel64}:   "osx-x86_64",
+		{OS: biome.Windows, Arch: biome.Intel64}: "win64",
+		{OS: biome.Windows, Arch: biome.Intel32}: "win32",
+	}, desc)
+	if err != nil {
+		return "", err
+	}
+	return templateToString(template, struct {
+		Version string
+		Variant string
+	}{version, variant})
+}
@@ -18,6 +18,7 @@ package buildpack
 
 import (
 	"context"
+	"net/http"
 	"strings"
 	"testing"
 
@@ -43,3 +44,17 @@ func TestProtoc(t *testing.T) {
 		t.Errorf("protoc --version output does not include %q", version)
 	}
 }
+
+func TestProtocDownloadURL(t *testing.T) {
+	const version = "3.14.0"
+	testDownloadURLs(t, http.MethodGet, func(desc *biome.Descriptor) (string, error) {
+		return protocDownloadURL(version, desc)
+	}, []downloadURLTest{
+		{os: biome.Linux, arch: biome.Intel64, want: "https://github.com/google/protobuf/releases/download/v3.14.0/protoc-3.14.0-linux-x86_64.zip"},
+		{os: biome.Linux, arch: biome.Intel32, want: "https://github.com/google/protobuf/releases/download/v3.14.0/protoc-3.14.0-linux-x86_32.zip"},
+		{os: biome.Linux, arch: biome.ARM64, want: "https://github.com/google/protobuf/releases/download/v3.14.0/protoc-3.14.0-linux-aarch_64.zip"},
+		{os: biome.MacOS, arch: biome.Intel64, want: "https://github.com/google/protobuf/releases/download/v3.14.0/protoc-3.14.0-osx-x86_64.zip"},
+		{os: biome.Windows, arch: biome.Intel64, want: "https://github.com/google/protobuf/releases/download/v3.14.0/protoc-3.14.0-win64.zip"},
+		{os: biome.Windows, arch: biome.Intel32, want: "https://github.com/google/protobuf/releases/download/v3.14.0/protoc-3.14.0-win32.zip"},
+	})
+}
@@ -0,0 +1,81 @@
+// Copyright 2020 YourBase Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package buildpack
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/yourbase/yb"
+	"github.com/yourbase/yb/internal/biome"
+	"zombiezen.com/go/log"
+)
+
+func installPowerShell(ctx context.Context, sys Sys, spec yb.BuildpackSpec) (biome.Environment, error) {
+	pwshDir := sys.Biome.JoinPath(sys.Biome.Dirs().Tools, "pwsh", "powershell-"+spec.Version())
+	env := biome.Environment{
+		Vars: map[string]string{
+			"POWERSHELL_TELEMETRY_OPTOUT": "1",
+			"POWERSHELL_UPDATECHECK":      "Off",
+		},
+		PrependPath: []string{pwshDir},
+	}
+
+	// If directory already exists, then use it.
+	if _, err := biome.EvalSymlinks(ctx, sys.Biome, pwshDir); err == nil {
+		log.Infof(ctx, "PowerShell v%s located in %s", spec.Version(), pwshDir)
+		recordInstall(ctx, sys, spec, pwshDir)
+		return env, nil
+	}
+
+	log.Infof(ctx, "Installing PowerShell v%s in %s", spec.Version(), pwshDir)
+	downloadURL, err := pwshDownloadURL(spec.Version(), sys.Biome.Describe())
+	if err != nil {
+		return biome.Environment{}, err
+	}
+	if err := extract(ctx, sys, pwshDir, downloadURL, tarbomb); err != nil {
+		return biome.Environment{}, err
+	}
+	// The archives don't mark pwsh as executable.
+	err = sys.Biome.Run(ctx, &biome.Invocation{
+		Argv:   []string{"chmod", "+x", sys.Biome.JoinPath(pwshDir, "pwsh")},
+		Stdout: sys.Stdout,
+		Stderr: sys.Stderr,
+	})
+	if err != nil {
+		return biome.Environment{}, fmt.Errorf("install PowerShell: %w", err)
+	}
+	recordInstall(ctx, sys, spec, pwshDir)
+	return env, nil
+}
+
+func pwshDownloadURL(version string, desc *biome.Descriptor) (string, error) {
+	const template = "https://github.com/PowerShell/PowerShell/releases/download/v{{.Version}}/powershell-{{.Version}}-{{.Platform}}.tar.gz"
+	platform, err := platformName("PowerShell", map[biome.Descriptor]string{
+		{OS: biome.Linux, Arch: biome.Intel64}: "linux-x64",
+		{OS: biome.Linux, Arch: biome.ARM64}:   "linux-arm64",
+		{OS: biome.MacOS, Arch: biome.Intel64}: "osx-x64",
+		{OS: biome.MacOS, Arch: biome.ARM64}:   "osx-arm64",
+	}, desc)
+	if err != nil {
+		return "", err
+	}
+	return templateToString(template, struct {
+		Version  string
+		Platform string
+	}{version, platform})
+}
@@ -0,0 +1,60 @@
+// Copyright 2020 YourBase Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package buildpack
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/yourbase/yb/internal/biome"
+	"zombiezen.com/go/log/testlog"
+)
+
+func TestPowerShell(t *testing.T) {
+	const version = "7.2.0"
+	ctx := testlog.WithTB(context.Background(), t)
+	pwshBiome, _ := testInstall(ctx, t, "pwsh:"+version)
+	versionOutput := new(strings.Builder)
+	err := pwshBiome.Run(ctx, &biome.Invocation{
+		Argv:   []string{"pwsh", "-Version"},
+		Stdout: versionOutput,
+		Stderr: versionOutput,
+	})
+	t.Logf("pwsh -Version output:\n%s", versionOutput)
+	if err != nil {
+		t.Errorf("pwsh -Version: %v", err)
+	}
+	if got := versionOutput.String(); !strings.Contains(got, version) {
+		t.Errorf("pwsh -Version output does not include %q", version)
+	}
+}
+
+func TestPwshDownloadURL(t *testing.T) {
+	const version = "7.2.0"
+	testDownloadURLs(t, http.MethodGet, func(desc *biome.Descriptor) (string, error) {
+		return pwshDownloadURL(version, desc)
+	}, []downloadURLTest{
+		{os: biome.Linux, arch: biome.Intel64, want: "https://github.com/PowerShell/PowerShell/releases/download/v7.2.0/powershell-7.2.0-linux-x64.tar.gz"},
+		{os: biome.Linux, arch: biome.Intel32, want: ""},
+		{os: biome.Linux, arch: biome.ARM64, want: "https://github.com/PowerShell/PowerShell/releases/download/v7.2.0/powershell-7.2.0-linux-arm64.tar.gz"},
+		{os: biome.MacOS, arch: biome.Intel64, want: "https://github.com/PowerShell/PowerShell/releases/download/v7.2.0/powershell-7.2.0-osx-x64.tar.gz"},
+		{os: biome.MacOS, arch: biome.ARM64, want: "https://github.com/PowerShell/PowerShell/releases/download/v7.2.0/powershell-7.2.0-osx-arm64.tar.gz"},
+		{os: biome.Windows, arch: biome.Intel64, want: ""},
+	})
+}
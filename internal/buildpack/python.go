@@ -9,20 +9,21 @@ import (
 )
 
 func installPython(ctx context.Context, sys Sys, spec yb.BuildpackSpec) (biome.Environment, error) {
+	// Environments are created with conda, which the anaconda3 buildpack
+	// provides in sys.Env.
 	envDir := sys.Biome.JoinPath(sys.Biome.Dirs().Tools, "conda-python", spec.Version())
-	env, err := installAnaconda(ctx, sys, 3, "4.8.3")
-	if err != nil {
-		return biome.Environment{}, err
-	}
 	envBinDir := sys.Biome.JoinPath(envDir, "bin")
+	env := biome.Environment{
+		PrependPath: []string{envBinDir},
+	}
 	// If environment already exists, return early.
 	if _, err := biome.EvalSymlinks(ctx, sys.Biome, envDir); err == nil {
-		env.PrependPath = append([]string{envBinDir}, env.PrependPath...)
+		recordInstall(ctx, sys, spec, envDir)
 		return env, nil
 	}
-	err = sys.Biome.Run(ctx, &biome.Invocation{
+	err := sys.Biome.Run(ctx, &biome.Invocation{
 		Argv:   []string{"conda", "install", "-c", "anaconda", "setuptools"},
-		Env:    env,
+		Env:    sys.Env,
 		Stdout: sys.Stdout,
 		Stderr: sys.Stderr,
 	})
@@ -31,13 +32,13 @@ func installPython(ctx context.Context, sys Sys, spec yb.BuildpackSpec) (biome.E
 	}
 	err = sys.Biome.Run(ctx, &biome.Invocation{
 		Argv:   []string{"conda", "create", "--prefix", envDir, "python=" + spec.Version()},
-		Env:    env,
+		Env:    sys.Env,
 		Stdout: sys.Stdout,
 		Stderr: sys.Stderr,
 	})
 	if err != nil {
 		return biome.Environment{}, fmt.Errorf("create environment: %w", err)
 	}
-	env.PrependPath = append([]string{envBinDir}, env.PrependPath...)
+	recordInstall(ctx, sys, spec, envDir)
 	return env, nil
 }
@@ -0,0 +1,344 @@
+// Copyright 2020 YourBase Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package buildpack
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/yourbase/yb"
+	"github.com/yourbase/yb/internal/biome"
+	"zombiezen.com/go/log"
+)
+
+// A requirement is a set of buildpacks, one of which must be installed before
+// the buildpack that has the requirement.
+type requirement struct {
+	// names is the set of buildpacks that satisfy the requirement.
+	names []string
+	// fallback is added to the installation if none of names are present.
+	fallback yb.BuildpackSpec
+	// optional is true if the requirement only constrains installation order.
+	// If optional is false and fallback is empty, a missing buildpack is an
+	// error.
+	optional bool
+}
+
+// Default versions of buildpacks added to satisfy requirements.
+const (
+	defaultAnaconda3 yb.BuildpackSpec = "anaconda3:4.8.3"
+	defaultJava      yb.BuildpackSpec = "java:8.265+01"
+	defaultNode      yb.BuildpackSpec = "node:12.19.0"
+
+	// defaultCorepackNode is the default Node.js for Yarn 2 and later, which
+	// need the corepack that comes with Node.js 16.9 and later.
+	defaultCorepackNode yb.BuildpackSpec = "node:18.19.0"
+
+	// defaultCmdlineToolsJava is the default Java for Android cmdline-tools
+	// builds, which need Java 17. The legacy sdk-tools only run on Java 8.
+	defaultCmdlineToolsJava yb.BuildpackSpec = "java:17.0.9+9"
+)
+
+// requirements is the set of requirements of the built-in buildpacks, keyed
+// by buildpack name.
+var requirements = map[string][]requirement{
+	"android":  {{names: []string{"java"}, fallback: defaultJava}},
+	"ant":      {{names: []string{"java"}, fallback: defaultJava}},
+	"composer": {{names: []string{"php"}, optional: true}},
+	"elixir":   {{names: []string{"erlang"}, optional: true}},
+	"flutter":  {{names: []string{"dart", "android"}, optional: true}},
+	"gradle":   {{names: []string{"java"}, fallback: defaultJava}},
+	"kotlin":   {{names: []string{"java"}, fallback: defaultJava}},
+	"maven":    {{names: []string{"java"}, fallback: defaultJava}},
+	"pnpm":     {{names: []string{"node"}, optional: true}},
+	"python":   {{names: []string{"anaconda3", "anaconda2"}, fallback: defaultAnaconda3}},
+	"sbt":      {{names: []string{"java"}, fallback: defaultJava}},
+	"yarn":     {{names: []string{"node"}, fallback: defaultNode}},
+}
+
+// requirementsFor returns the requirements of the given buildpack.
+func requirementsFor(spec yb.BuildpackSpec, defs map[string]*yb.BuildpackDefinition) []requirement {
+	name := spec.Name()
+	def := defs[name]
+	if def == nil {
+		if name == "yarn" && isYarnBerry(spec.Version()) {
+			return []requirement{{names: []string{"node"}, fallback: defaultCorepackNode}}
+		}
+		// Aliases are resolved after planning, so look through them here.
+		if name == "android" && isAndroidCmdlineTools(aliasedVersion(spec)) {
+			return []requirement{{names: []string{"java"}, fallback: defaultCmdlineToolsJava}}
+		}
+		return requirements[name]
+	}
+	var reqs []requirement
+	for _, r := range def.Requirements() {
+		reqs = append(reqs, requirement{
+			names:    []string{r.Name},
+			fallback: r.Default,
+		})
+	}
+	return reqs
+}
+
+// A plannedPack is a buildpack to install.
+type plannedPack struct {
+	spec yb.BuildpackSpec
+	// requires is the list of names of buildpacks that must be installed
+	// before this one.
+	requires []string
+}
+
+// planInstall returns the order to install the given buildpacks in, adding
+// any buildpacks needed to satisfy requirements. A buildpack is always
+// installed after the buildpacks it requires. Otherwise, buildpacks are
+// installed in the order given.
+func planInstall(specs []yb.BuildpackSpec, defs map[string]*yb.BuildpackDefinition) ([]*plannedPack, error) {
+	var packs []*plannedPack
+	index := make(map[string]int)
+	add := func(spec yb.BuildpackSpec) error {
+		if _, dup := index[spec.Name()]; dup {
+			return fmt.Errorf("buildpack %s listed more than once", spec.Name())
+		}
+		index[spec.Name()] = len(packs)
+		packs = append(packs, &plannedPack{spec: spec})
+		return nil
+	}
+	for _, spec := range specs {
+		if err := add(spec); err != nil {
+			return nil, err
+		}
+	}
+	// packs grows as fallbacks are added, which are in turn checked for
+	// their own requirements.
+	for i := 0; i < len(packs); i++ {
+		p := packs[i]
+		for _, req := range requirementsFor(p.spec, defs) {
+			found := ""
+			for _, name := range req.names {
+				if _, ok := index[name]; ok {
+					found = name
+					break
+				}
+			}
+			switch {
+			case found != "":
+				p.requires = append(p.requires, found)
+			case req.fallback != "":
+				if err := add(req.fallback); err != nil {
+					return nil, err
+				}
+				p.requires = append(p.requires, req.fallback.Name())
+			case !req.optional:
+				return nil, fmt.Errorf("buildpack %s requires %s, which is not listed", p.spec.Name(), strings.Join(req.names, " or "))
+			}
+		}
+	}
+
+	// Topological sort, preferring the earliest listed buildpack that is ready.
+	ordered := make([]*plannedPack, 0, len(packs))
+	done := make(map[string]bool, len(packs))
+	for len(ordered) < len(packs) {
+		progress := false
+		for _, p := range packs {
+			if done[p.spec.Name()] || !allDone(done, p.requires) {
+				continue
+			}
+			ordered = append(ordered, p)
+			done[p.spec.Name()] = true
+			progress = true
+			break
+		}
+		if !progress {
+			var cycle []string
+			for _, p := range packs {
+				if !done[p.spec.Name()] {
+					cycle = append(cycle, p.spec.Name())
+				}
+			}
+			sort.Strings(cycle)
+			return nil, fmt.Errorf("buildpacks %s require each other", strings.Join(cycle, ", "))
+		}
+	}
+	return ordered, nil
+}
+
+func allDone(done map[string]bool, names []string) bool {
+	for _, name := range names {
+		if !done[name] {
+			return false
+		}
+	}
+	return true
+}
+
+// InstallAll installs the given buildpacks and any buildpacks they require
+// into the biome, returning the merged environment of all of them.
+// Buildpacks are installed after the buildpacks they require and each
+// installer receives the merged environment of its requirements in sys.Env.
+// A required buildpack that is not listed is added with a default version,
+// or InstallAll returns an error if the requirement has no default.
+//
+// If sys.Parallelism is greater than one, buildpacks that do not depend on
+// each other are installed concurrently and their output is labeled with the
+// buildpack name.
+func InstallAll(ctx context.Context, sys Sys, specs []yb.BuildpackSpec) (biome.Environment, error) {
+	plan, err := planInstall(specs, sys.Definitions)
+	if err != nil {
+		return biome.Environment{}, err
+	}
+	for _, p := range plan {
+		if !containsSpec(specs, p.spec) {
+			log.Infof(ctx, "Adding %s required by other buildpacks", p.spec)
+		}
+	}
+	var envs []biome.Environment
+	if sys.Parallelism > 1 && len(plan) > 1 {
+		envs, err = installConcurrently(ctx, sys, plan)
+	} else {
+		envs, err = installSerially(ctx, sys, plan)
+	}
+	if err != nil {
+		return biome.Environment{}, err
+	}
+	var merged biome.Environment
+	for _, env := range envs {
+		merged = merged.Merge(env)
+	}
+	return merged, nil
+}
+
+// installSerially installs the planned buildpacks one at a time and returns
+// the environment of each.
+func installSerially(ctx context.Context, sys Sys, plan []*plannedPack) ([]biome.Environment, error) {
+	envs := make([]biome.Environment, len(plan))
+	// fullEnvs holds the environment of each installed buildpack merged with
+	// the environments of its requirements.
+	fullEnvs := make(map[string]biome.Environment, len(plan))
+	for i, p := range plan {
+		var reqEnv biome.Environment
+		for _, name := range p.requires {
+			reqEnv = reqEnv.Merge(fullEnvs[name])
+		}
+		packSys := sys
+		packSys.Env = reqEnv
+		env, err := Install(ctx, packSys, p.spec)
+		if err != nil {
+			return nil, err
+		}
+		envs[i] = env
+		fullEnvs[p.spec.Name()] = reqEnv.Merge(env)
+	}
+	return envs, nil
+}
+
+// errRequirementFailed is recorded for a buildpack that was not installed
+// because one of its requirements failed to install.
+var errRequirementFailed = errors.New("requirement failed to install")
+
+// installConcurrently installs up to sys.Parallelism of the planned
+// buildpacks at once and returns the environment of each. Each buildpack
+// starts once all of its requirements are installed. If any buildpack fails,
+// the remaining installations are canceled and the first error is returned.
+func installConcurrently(ctx context.Context, sys Sys, plan []*plannedPack) ([]biome.Environment, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		done    chan struct{}
+		env     biome.Environment
+		fullEnv biome.Environment
+		err     error
+	}
+	results := make(map[string]*result, len(plan))
+	for _, p := range plan {
+		results[p.spec.Name()] = &result{done: make(chan struct{})}
+	}
+	sem := make(chan struct{}, sys.Parallelism)
+	outputMu := new(sync.Mutex)
+	var errMu sync.Mutex
+	var firstErr error
+	for _, p := range plan {
+		go func(p *plannedPack) {
+			r := results[p.spec.Name()]
+			defer close(r.done)
+			var reqEnv biome.Environment
+			for _, name := range p.requires {
+				req := results[name]
+				<-req.done
+				if req.err != nil {
+					r.err = errRequirementFailed
+					return
+				}
+				reqEnv = reqEnv.Merge(req.fullEnv)
+			}
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				r.err = ctx.Err()
+				return
+			}
+
+			packCtx, packSys, flush := packOutput(ctx, sys, outputMu, p.spec.Name())
+			packSys.Env = reqEnv
+			r.env, r.err = Install(packCtx, packSys, p.spec)
+			if err := flush(); err != nil && r.err == nil {
+				r.err = fmt.Errorf("install buildpack %s: %w", p.spec, err)
+			}
+			if r.err != nil {
+				errMu.Lock()
+				if firstErr == nil {
+					firstErr = r.err
+					cancel()
+				}
+				errMu.Unlock()
+				return
+			}
+			r.fullEnv = reqEnv.Merge(r.env)
+		}(p)
+	}
+
+	envs := make([]biome.Environment, 0, len(plan))
+	for _, p := range plan {
+		r := results[p.spec.Name()]
+		<-r.done
+		envs = append(envs, r.env)
+	}
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	for _, p := range plan {
+		// Only reachable if ctx was canceled by the caller.
+		if err := results[p.spec.Name()].err; err != nil {
+			return nil, err
+		}
+	}
+	return envs, nil
+}
+
+func containsSpec(specs []yb.BuildpackSpec, spec yb.BuildpackSpec) bool {
+	for _, s := range specs {
+		if s == spec {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,267 @@
+// Copyright 2020 YourBase Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package buildpack
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/yourbase/commons/http/headers"
+	"github.com/yourbase/yb"
+	"github.com/yourbase/yb/internal/biome"
+	"github.com/yourbase/yb/internal/ybdata"
+	"zombiezen.com/go/log/testlog"
+)
+
+func TestPlanInstall(t *testing.T) {
+	tests := []struct {
+		name    string
+		specs   []yb.BuildpackSpec
+		defs    map[string]*yb.BuildpackDefinition
+		want    []yb.BuildpackSpec
+		wantErr bool
+	}{
+		{
+			name:  "Empty",
+			specs: nil,
+			want:  []yb.BuildpackSpec{},
+		},
+		{
+			name:  "Independent",
+			specs: []yb.BuildpackSpec{"go:1.15.2", "rust:1.47.0"},
+			want:  []yb.BuildpackSpec{"go:1.15.2", "rust:1.47.0"},
+		},
+		{
+			name:  "RequirementListedLater",
+			specs: []yb.BuildpackSpec{"yarn:1.22.10", "go:1.15.2", "node:14.17.0"},
+			want:  []yb.BuildpackSpec{"go:1.15.2", "node:14.17.0", "yarn:1.22.10"},
+		},
+		{
+			name:  "YarnBerryDefault",
+			specs: []yb.BuildpackSpec{"yarn:3.6.1"},
+			want:  []yb.BuildpackSpec{defaultCorepackNode, "yarn:3.6.1"},
+		},
+		{
+			name:  "YarnClassicDefault",
+			specs: []yb.BuildpackSpec{"yarn:1.22.10"},
+			want:  []yb.BuildpackSpec{defaultNode, "yarn:1.22.10"},
+		},
+		{
+			name:  "AndroidCmdlineToolsDefault",
+			specs: []yb.BuildpackSpec{"android:11076708"},
+			want:  []yb.BuildpackSpec{defaultCmdlineToolsJava, "android:11076708"},
+		},
+		{
+			name:  "AndroidLatestDefault",
+			specs: []yb.BuildpackSpec{"android:latest"},
+			want:  []yb.BuildpackSpec{defaultCmdlineToolsJava, "android:latest"},
+		},
+		{
+			name:  "AndroidSDKToolsDefault",
+			specs: []yb.BuildpackSpec{"android:4333796"},
+			want:  []yb.BuildpackSpec{defaultJava, "android:4333796"},
+		},
+		{
+			name:  "DefaultAdded",
+			specs: []yb.BuildpackSpec{"maven:3.6.3"},
+			want:  []yb.BuildpackSpec{defaultJava, "maven:3.6.3"},
+		},
+		{
+			name:  "DefaultSharedByRequirers",
+			specs: []yb.BuildpackSpec{"maven:3.6.3", "ant:1.10.9"},
+			want:  []yb.BuildpackSpec{defaultJava, "maven:3.6.3", "ant:1.10.9"},
+		},
+		{
+			name:  "PythonDefault",
+			specs: []yb.BuildpackSpec{"python:3.8.5"},
+			want:  []yb.BuildpackSpec{defaultAnaconda3, "python:3.8.5"},
+		},
+		{
+			name:  "PythonAnaconda2",
+			specs: []yb.BuildpackSpec{"python:2.7.18", "anaconda2:4.8.3"},
+			want:  []yb.BuildpackSpec{"anaconda2:4.8.3", "python:2.7.18"},
+		},
+		{
+			name:  "OptionalMissing",
+			specs: []yb.BuildpackSpec{"flutter:1.22.2"},
+			want:  []yb.BuildpackSpec{"flutter:1.22.2"},
+		},
+		{
+			name:  "OptionalPresent",
+			specs: []yb.BuildpackSpec{"flutter:1.22.2", "dart:2.10.2"},
+			want:  []yb.BuildpackSpec{"dart:2.10.2", "flutter:1.22.2"},
+		},
+		{
+			name:  "ElixirAfterErlang",
+			specs: []yb.BuildpackSpec{"elixir:1.11.4", "erlang:23.3.1"},
+			want:  []yb.BuildpackSpec{"erlang:23.3.1", "elixir:1.11.4"},
+		},
+		{
+			name:    "Duplicate",
+			specs:   []yb.BuildpackSpec{"node:12.19.0", "node:14.17.0"},
+			wantErr: true,
+		},
+		{
+			name:  "CycleThroughDefault",
+			specs: []yb.BuildpackSpec{"yarn:1.22.10"},
+			defs: map[string]*yb.BuildpackDefinition{
+				"node": {Requires: []string{"yarn"}},
+			},
+			wantErr: true,
+		},
+		{
+			name:  "DefinitionRequirementListed",
+			specs: []yb.BuildpackSpec{"tool:1.0", "go:1.15.2"},
+			defs: map[string]*yb.BuildpackDefinition{
+				"tool": {Requires: []string{"go"}},
+			},
+			want: []yb.BuildpackSpec{"go:1.15.2", "tool:1.0"},
+		},
+		{
+			name:  "DefinitionRequirementMissing",
+			specs: []yb.BuildpackSpec{"tool:1.0"},
+			defs: map[string]*yb.BuildpackDefinition{
+				"tool": {Requires: []string{"go"}},
+			},
+			wantErr: true,
+		},
+		{
+			name:  "DefinitionDefault",
+			specs: []yb.BuildpackSpec{"tool:1.0"},
+			defs: map[string]*yb.BuildpackDefinition{
+				"tool": {Requires: []string{"go:1.15.2"}},
+			},
+			want: []yb.BuildpackSpec{"go:1.15.2", "tool:1.0"},
+		},
+		{
+			name:  "DefinitionOverridesBuiltin",
+			specs: []yb.BuildpackSpec{"yarn:1.22.10"},
+			defs: map[string]*yb.BuildpackDefinition{
+				"yarn": {},
+			},
+			want: []yb.BuildpackSpec{"yarn:1.22.10"},
+		},
+		{
+			name:  "Cycle",
+			specs: []yb.BuildpackSpec{"a:1.0", "b:1.0"},
+			defs: map[string]*yb.BuildpackDefinition{
+				"a": {Requires: []string{"b"}},
+				"b": {Requires: []string{"a"}},
+			},
+			wantErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			plan, err := planInstall(test.specs, test.defs)
+			if err != nil {
+				if !test.wantErr {
+					t.Fatal("planInstall:", err)
+				}
+				t.Log(err)
+				return
+			}
+			if test.wantErr {
+				t.Fatal("planInstall did not return an error")
+			}
+			got := make([]yb.BuildpackSpec, 0, len(plan))
+			for _, p := range plan {
+				got = append(got, p.spec)
+			}
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("planInstall(%q, defs) (-want +got):\n%s", test.specs, diff)
+			}
+		})
+	}
+}
+
+func TestInstallAllConcurrent(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/"), ".tar.gz")
+		if name == r.URL.Path || name == "broken-1.0" {
+			http.NotFound(w, r)
+			return
+		}
+		content := makeGzipTar(name + "/bin/hello.txt")
+		w.Header().Set(headers.ContentLength, strconv.Itoa(len(content)))
+		w.Write(content)
+	}))
+	t.Cleanup(srv.Close)
+	defs := make(map[string]*yb.BuildpackDefinition)
+	for _, name := range []string{"a", "b", "c", "d", "broken"} {
+		defs[name] = &yb.BuildpackDefinition{
+			URL:         srv.URL + "/" + name + "-{{.Version}}.tar.gz",
+			StripTopDir: true,
+			Path:        []string{"bin"},
+		}
+	}
+	defs["b"].Requires = []string{"a"}
+	defs["d"].Requires = []string{"b"}
+
+	install := func(t *testing.T, parallelism int, specs ...yb.BuildpackSpec) ([]string, error) {
+		ctx := testlog.WithTB(context.Background(), t)
+		bio := biome.Local{
+			PackageDir: t.TempDir(),
+			HomeDir:    t.TempDir(),
+		}
+		output := new(strings.Builder)
+		sys := Sys{
+			Biome:       bio,
+			Stdout:      output,
+			Stderr:      output,
+			Downloader:  ybdata.NewDownloader(t.TempDir()),
+			Definitions: defs,
+			Parallelism: parallelism,
+		}
+		sys.Downloader.Client = srv.Client()
+		env, err := InstallAll(ctx, sys, specs)
+		t.Logf("InstallAll(ctx, sys, %q) output:\n%s", specs, output)
+		if err != nil {
+			return nil, err
+		}
+		// Make paths comparable across biomes.
+		var path []string
+		for _, dir := range env.PrependPath {
+			path = append(path, strings.TrimPrefix(dir, bio.Dirs().Tools))
+		}
+		return path, nil
+	}
+
+	specs := []yb.BuildpackSpec{"d:1.0", "c:1.0", "b:1.0", "a:1.0"}
+	want, err := install(t, 0, specs...)
+	if err != nil {
+		t.Fatal("Serial:", err)
+	}
+	got, err := install(t, 4, specs...)
+	if err != nil {
+		t.Fatal("Concurrent:", err)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("PATH (-serial +concurrent):\n%s", diff)
+	}
+
+	if _, err := install(t, 4, "a:1.0", "broken:1.0", "b:1.0"); err == nil {
+		t.Error("InstallAll with a broken buildpack did not return an error")
+	} else {
+		t.Log(err)
+	}
+}
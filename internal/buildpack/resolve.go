@@ -0,0 +1,320 @@
+// Copyright 2020 YourBase Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package buildpack
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/blang/semver"
+	"github.com/yourbase/yb"
+	"github.com/yourbase/yb/internal/ybdata"
+	"zombiezen.com/go/log"
+)
+
+// availableVersion is a version of a tool published upstream.
+type availableVersion struct {
+	// name is the version as it appears in download URLs.
+	name string
+	// lts is true if the version is a long-term support release.
+	lts bool
+}
+
+// versionIndexes is the set of buildpacks that can resolve version ranges and
+// aliases, keyed by buildpack name. Each function lists the versions published
+// upstream. python is absent because it installs whatever conda has, which
+// doesn't match python.org's releases, and conda resolves partial versions
+// itself.
+var versionIndexes = map[string]func(context.Context, Sys) ([]availableVersion, error){
+	"cpython": pythonVersions,
+	"dotnet":  dotnetVersions,
+	"go":      goVersions,
+	"node":    nodeVersions,
+}
+
+// versionAliases maps aliases to concrete versions for buildpacks without a
+// version index, keyed by buildpack name.
+var versionAliases = map[string]map[string]string{
+	"android": {"latest": latestAndroidVersion},
+}
+
+// aliasedVersion returns the version that the specifier's version is an alias
+// for in versionAliases, or the specifier's version if it is not an alias.
+func aliasedVersion(spec yb.BuildpackSpec) string {
+	if v := versionAliases[spec.Name()][spec.Version()]; v != "" {
+		return v
+	}
+	return spec.Version()
+}
+
+// Resolve returns the buildpack specifier with its version resolved to a
+// concrete version. Specifiers may use aliases ("latest", or "lts" for Node),
+// wildcards ("1.16.x"), caret or tilde ranges ("^14", "~3.9.1"), comparisons
+// (">=1.15"), or partial versions ("3.9"). A partial version that exactly
+// names a published version is used as-is. Specifiers with a concrete version
+// are returned unchanged without consulting the upstream index. Buildpacks
+// declared in sys.Definitions replace the built-in ones, so their versions are
+// never resolved against a built-in index.
+func Resolve(ctx context.Context, sys Sys, spec yb.BuildpackSpec) (yb.BuildpackSpec, error) {
+	version := spec.Version()
+	if !isVersionQuery(version) {
+		return spec, nil
+	}
+	var listVersions func(context.Context, Sys) ([]availableVersion, error)
+	if sys.Definitions[spec.Name()] == nil {
+		if v := aliasedVersion(spec); v != version {
+			return yb.BuildpackSpec(spec.Name() + ":" + v), nil
+		}
+		listVersions = versionIndexes[spec.Name()]
+	}
+	if listVersions == nil {
+		if isVersionRange(version) {
+			return "", fmt.Errorf("resolve %s: %s buildpack does not support version ranges", spec, spec.Name())
+		}
+		// Partial versions are passed through for buildpacks without an index.
+		return spec, nil
+	}
+	available, err := listVersions(ctx, sys)
+	if err != nil {
+		return "", fmt.Errorf("resolve %s: %w", spec, err)
+	}
+	resolved, err := pickVersion(version, available)
+	if err != nil {
+		return "", fmt.Errorf("resolve %s: %w", spec, err)
+	}
+	return yb.BuildpackSpec(spec.Name() + ":" + resolved), nil
+}
+
+// isVersionQuery reports whether a version needs to be resolved: either it is
+// a range or alias, or it has fewer than three numeric components.
+func isVersionQuery(version string) bool {
+	if isVersionRange(version) {
+		return true
+	}
+	parts := strings.Split(version, ".")
+	if len(parts) >= 3 {
+		return false
+	}
+	for _, part := range parts {
+		if _, err := strconv.Atoi(part); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// isVersionRange reports whether a version uses an alias or range syntax.
+func isVersionRange(version string) bool {
+	switch version {
+	case "latest", "lts":
+		return true
+	}
+	if strings.ContainsAny(version, "^~<>=*") {
+		return true
+	}
+	for _, part := range strings.Split(version, ".") {
+		if part == "x" || part == "X" {
+			return true
+		}
+	}
+	return false
+}
+
+// pickVersion returns the newest stable version that satisfies the query.
+func pickVersion(query string, available []availableVersion) (string, error) {
+	for _, v := range available {
+		if v.name == query {
+			return v.name, nil
+		}
+	}
+	match, err := parseVersionQuery(query)
+	if err != nil {
+		return "", err
+	}
+	var best string
+	var bestVersion semver.Version
+	for _, v := range available {
+		sv, err := semver.ParseTolerant(v.name)
+		if err != nil || len(sv.Pre) > 0 {
+			// Skip versions we don't understand and prereleases.
+			continue
+		}
+		if query == "lts" && !v.lts {
+			continue
+		}
+		if match(sv) && (best == "" || sv.GT(bestVersion)) {
+			best, bestVersion = v.name, sv
+		}
+	}
+	if best == "" {
+		return "", fmt.Errorf("no published version matches %q", query)
+	}
+	return best, nil
+}
+
+// parseVersionQuery parses a version query into a predicate.
+func parseVersionQuery(query string) (func(semver.Version) bool, error) {
+	switch query {
+	case "latest", "lts", "*", "x", "X":
+		return func(semver.Version) bool { return true }, nil
+	}
+	for _, op := range []string{">=", "<=", ">", "<", "="} {
+		if !strings.HasPrefix(query, op) {
+			continue
+		}
+		v, err := semver.ParseTolerant(strings.TrimPrefix(query, op))
+		if err != nil {
+			return nil, fmt.Errorf("parse version query %q: %w", query, err)
+		}
+		r, err := semver.ParseRange(op + v.String())
+		if err != nil {
+			return nil, fmt.Errorf("parse version query %q: %w", query, err)
+		}
+		return r, nil
+	}
+
+	prefix := ""
+	if strings.HasPrefix(query, "^") || strings.HasPrefix(query, "~") {
+		prefix, query = query[:1], query[1:]
+	}
+	var nums []uint64
+	for _, part := range strings.Split(query, ".") {
+		if part == "x" || part == "X" || part == "*" {
+			break
+		}
+		n, err := strconv.ParseUint(part, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("parse version query %q: invalid component %q", prefix+query, part)
+		}
+		nums = append(nums, n)
+	}
+	if len(nums) == 0 || len(nums) > 3 {
+		return nil, fmt.Errorf("parse version query %q: invalid version", prefix+query)
+	}
+	lower := semver.Version{Major: nums[0]}
+	if len(nums) > 1 {
+		lower.Minor = nums[1]
+	}
+	if len(nums) > 2 {
+		lower.Patch = nums[2]
+	}
+
+	// Compute exclusive upper bound.
+	var upper semver.Version
+	switch {
+	case prefix == "^" && (lower.Major > 0 || len(nums) == 1):
+		upper = semver.Version{Major: lower.Major + 1}
+	case prefix == "^" && (lower.Minor > 0 || len(nums) == 2):
+		upper = semver.Version{Minor: lower.Minor + 1}
+	case prefix == "^":
+		upper = semver.Version{Patch: lower.Patch + 1}
+	case prefix == "~" && len(nums) == 1:
+		upper = semver.Version{Major: lower.Major + 1}
+	case prefix == "~":
+		upper = semver.Version{Major: lower.Major, Minor: lower.Minor + 1}
+	case len(nums) == 1:
+		upper = semver.Version{Major: lower.Major + 1}
+	case len(nums) == 2:
+		upper = semver.Version{Major: lower.Major, Minor: lower.Minor + 1}
+	default:
+		// Fully specified version that isn't published.
+		return func(v semver.Version) bool { return v.EQ(lower) }, nil
+	}
+	return func(v semver.Version) bool {
+		return v.GTE(lower) && v.LT(upper)
+	}, nil
+}
+
+// fetchJSON downloads a JSON document through the download cache and decodes
+// it into v.
+func fetchJSON(ctx context.Context, sys Sys, url string, v interface{}) error {
+	// Indexes change as versions are published, so they are never locked.
+	f, err := sys.Downloader.Download(ybdata.Unpinned(ctx), url)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := json.NewDecoder(f).Decode(v); err != nil {
+		return fmt.Errorf("read %s: %w", url, err)
+	}
+	return nil
+}
+
+func goVersions(ctx context.Context, sys Sys) ([]availableVersion, error) {
+	const indexURL = "https://golang.org/dl/?mode=json&include=all"
+	var releases []struct {
+		Version string `json:"version"`
+		Stable  bool   `json:"stable"`
+	}
+	if err := fetchJSON(ctx, sys, indexURL, &releases); err != nil {
+		return nil, fmt.Errorf("list go versions: %w", err)
+	}
+	versions := make([]availableVersion, 0, len(releases))
+	for _, r := range releases {
+		if !r.Stable {
+			continue
+		}
+		versions = append(versions, availableVersion{name: strings.TrimPrefix(r.Version, "go")})
+	}
+	log.Debugf(ctx, "Found %d Go versions", len(versions))
+	return versions, nil
+}
+
+func nodeVersions(ctx context.Context, sys Sys) ([]availableVersion, error) {
+	const indexURL = "https://nodejs.org/dist/index.json"
+	var releases []struct {
+		Version string `json:"version"`
+		// LTS is either false or the LTS codename.
+		LTS interface{} `json:"lts"`
+	}
+	if err := fetchJSON(ctx, sys, indexURL, &releases); err != nil {
+		return nil, fmt.Errorf("list node versions: %w", err)
+	}
+	versions := make([]availableVersion, 0, len(releases))
+	for _, r := range releases {
+		codename, _ := r.LTS.(string)
+		versions = append(versions, availableVersion{
+			name: strings.TrimPrefix(r.Version, "v"),
+			lts:  codename != "",
+		})
+	}
+	log.Debugf(ctx, "Found %d Node versions", len(versions))
+	return versions, nil
+}
+
+func pythonVersions(ctx context.Context, sys Sys) ([]availableVersion, error) {
+	const indexURL = "https://www.python.org/api/v2/downloads/release/?is_published=true"
+	var releases []struct {
+		Name       string `json:"name"`
+		PreRelease bool   `json:"pre_release"`
+	}
+	if err := fetchJSON(ctx, sys, indexURL, &releases); err != nil {
+		return nil, fmt.Errorf("list python versions: %w", err)
+	}
+	versions := make([]availableVersion, 0, len(releases))
+	for _, r := range releases {
+		if r.PreRelease || !strings.HasPrefix(r.Name, "Python ") {
+			continue
+		}
+		versions = append(versions, availableVersion{name: strings.TrimPrefix(r.Name, "Python ")})
+	}
+	log.Debugf(ctx, "Found %d Python versions", len(versions))
+	return versions, nil
+}
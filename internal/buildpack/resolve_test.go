@@ -0,0 +1,149 @@
+// Copyright 2020 YourBase Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package buildpack
+
+import (
+	"context"
+	"testing"
+
+	"github.com/yourbase/yb"
+	"zombiezen.com/go/log/testlog"
+)
+
+func TestPickVersion(t *testing.T) {
+	available := []availableVersion{
+		{name: "16.0.0"},
+		{name: "15.14.0"},
+		{name: "14.17.0", lts: true},
+		{name: "14.16.1", lts: true},
+		{name: "14.0.0"},
+		{name: "1.16"},
+		{name: "1.16.3"},
+		{name: "1.16.10"},
+		{name: "1.17rc1"},
+		{name: "0.2.5"},
+		{name: "0.2.1"},
+		{name: "0.0.3"},
+	}
+	tests := []struct {
+		query   string
+		want    string
+		wantErr bool
+	}{
+		{query: "latest", want: "16.0.0"},
+		{query: "lts", want: "14.17.0"},
+		{query: "*", want: "16.0.0"},
+		{query: "14", want: "14.17.0"},
+		{query: "14.x", want: "14.17.0"},
+		{query: "^14", want: "14.17.0"},
+		{query: "^14.16.1", want: "14.17.0"},
+		{query: "~14.16.0", want: "14.16.1"},
+		{query: "~15", want: "15.14.0"},
+		{query: "1.16", want: "1.16"},
+		{query: "1.16.x", want: "1.16.10"},
+		{query: "1.16.3", want: "1.16.3"},
+		{query: ">=15", want: "16.0.0"},
+		{query: "<15", want: "14.17.0"},
+		{query: "^0.2", want: "0.2.5"},
+		{query: "^0.0.3", want: "0.0.3"},
+		{query: "1.17", wantErr: true},
+		{query: "17", wantErr: true},
+		{query: "^foo", wantErr: true},
+		{query: "1.16.4", wantErr: true},
+	}
+	for _, test := range tests {
+		got, err := pickVersion(test.query, available)
+		if err != nil {
+			if !test.wantErr {
+				t.Errorf("pickVersion(%q, ...) = _, %v; want %q, <nil>", test.query, err, test.want)
+			}
+			continue
+		}
+		if test.wantErr {
+			t.Errorf("pickVersion(%q, ...) = %q, <nil>; want error", test.query, got)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("pickVersion(%q, ...) = %q, <nil>; want %q, <nil>", test.query, got, test.want)
+		}
+	}
+}
+
+func TestIsVersionQuery(t *testing.T) {
+	tests := []struct {
+		version string
+		want    bool
+	}{
+		{"1.16.3", false},
+		{"8.265+01", false},
+		{"r21d", false},
+		{"15+36", false},
+		{"1.16", true},
+		{"14", true},
+		{"1.16.x", true},
+		{"^14", true},
+		{"~3.9.1", true},
+		{">=1.15", true},
+		{"lts", true},
+		{"latest", true},
+	}
+	for _, test := range tests {
+		if got := isVersionQuery(test.version); got != test.want {
+			t.Errorf("isVersionQuery(%q) = %t; want %t", test.version, got, test.want)
+		}
+	}
+}
+
+func TestResolveAlias(t *testing.T) {
+	ctx := testlog.WithTB(context.Background(), t)
+	// android has no version index, so this must not touch the network.
+	got, err := Resolve(ctx, Sys{}, "android:latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := yb.BuildpackSpec("android:" + latestAndroidVersion); got != want {
+		t.Errorf("Resolve(ctx, sys, %q) = %q; want %q", "android:latest", got, want)
+	}
+}
+
+func TestResolveWithoutIndex(t *testing.T) {
+	ctx := testlog.WithTB(context.Background(), t)
+	// None of these may touch the network: sys has no Downloader.
+	sys := Sys{
+		Definitions: map[string]*yb.BuildpackDefinition{
+			"go":      {},
+			"android": {},
+		},
+	}
+	tests := []struct {
+		spec    yb.BuildpackSpec
+		want    yb.BuildpackSpec
+		wantErr bool
+	}{
+		{spec: "python:3.8", want: "python:3.8"},
+		{spec: "python:3.8.x", wantErr: true},
+		{spec: "go:1.16", want: "go:1.16"},
+		{spec: "go:1.16.x", wantErr: true},
+		{spec: "android:latest", wantErr: true},
+	}
+	for _, test := range tests {
+		got, err := Resolve(ctx, sys, test.spec)
+		if got != test.want || (err != nil) != test.wantErr {
+			t.Errorf("Resolve(ctx, sys, %q) = %q, %v; want %q, error = %t", test.spec, got, err, test.want, test.wantErr)
+		}
+	}
+}
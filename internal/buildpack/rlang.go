@@ -30,6 +30,7 @@ func installR(ctx context.Context, sys Sys, spec yb.BuildpackSpec) (_ biome.Envi
 	// If directory already exists, then use it.
 	if _, err := biome.EvalSymlinks(ctx, sys.Biome, rlangDir); err == nil {
 		log.Infof(ctx, "R v%s located in %s", version, rlangDir)
+		recordInstall(ctx, sys, spec, rlangDir)
 		return env, nil
 	}
 
@@ -88,5 +89,6 @@ func installR(ctx context.Context, sys Sys, spec yb.BuildpackSpec) (_ biome.Envi
 			return biome.Environment{}, fmt.Errorf("compiling R: %s: %w", argv[0], err)
 		}
 	}
+	recordInstall(ctx, sys, spec, rlangDir)
 	return env, nil
 }
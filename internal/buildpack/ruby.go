@@ -30,41 +30,27 @@ func installRuby(ctx context.Context, sys Sys, spec yb.BuildpackSpec) (biome.Env
 	// If directory already exists, then use it.
 	if _, err := biome.EvalSymlinks(ctx, sys.Biome, rubyDir); err == nil {
 		log.Infof(ctx, "Ruby v%s located in %s", spec.Version(), rubyDir)
+		recordInstall(ctx, sys, spec, rubyDir)
 		return env, nil
 	}
 
 	// Try to download a YourBase pre-built binary.
 	desc := sys.Biome.Describe()
-	const template = "https://yourbase-build-tools.s3-us-west-2.amazonaws.com/ruby/ruby-{{ .Version }}-{{ .OS }}-{{ .Arch }}-{{ .DistroCodename }}.tar.bz2"
-	data := struct {
-		Version        string
-		OS             string
-		DistroCodename string
-		Arch           string
-	}{
-		Version: spec.Version(),
-		OS: map[string]string{
-			biome.Linux: "Linux",
-		}[desc.OS],
-		Arch: map[string]string{
-			biome.Intel64: "x86_64",
-		}[desc.Arch],
-	}
-	if data.OS == "" || data.Arch == "" {
-		log.Debugf(ctx, "Pre-built binaries unsupported for %s/%s", desc.OS, desc.Arch)
+	if _, err := rubyDownloadURL(spec.Version(), "", desc); err != nil {
+		log.Debugf(ctx, "Pre-built binaries unsupported: %v", err)
 	} else {
 		log.Infof(ctx, "Searching for YourBase-built Ruby binary...")
-		var err error
-		data.DistroCodename, err = readLSBCodename(ctx, sys)
+		codename, err := readLSBCodename(ctx, sys)
 		if err != nil {
 			log.Warnf(ctx, "Skipping search for pre-built binary: %v", err)
 		} else {
-			downloadURL, err := templateToString(template, data)
+			downloadURL, err := rubyDownloadURL(spec.Version(), codename, desc)
 			if err != nil {
 				return biome.Environment{}, fmt.Errorf("download pre-built binary: %w", err)
 			}
 			err = extract(ctx, sys, rubyDir, downloadURL, stripTopDirectory)
 			if err == nil {
+				recordInstall(ctx, sys, spec, rubyDir)
 				return env, nil
 			}
 			if !ybdata.IsNotFound(err) {
@@ -105,12 +91,18 @@ func installRuby(ctx context.Context, sys Sys, spec yb.BuildpackSpec) (biome.Env
 	if err != nil {
 		return biome.Environment{}, fmt.Errorf("rbenv: %w", err)
 	}
+	recordInstall(ctx, sys, spec, rubyDir)
 	return env, nil
 }
 
 func readLSBCodename(ctx context.Context, sys Sys) (string, error) {
+	return readOSRelease(ctx, sys, "VERSION_CODENAME")
+}
+
+// readOSRelease returns the value of a variable in the biome's
+// /etc/os-release file.
+func readOSRelease(ctx context.Context, sys Sys, varname string) (string, error) {
 	const filename = "/etc/os-release"
-	const varname = "VERSION_CODENAME"
 	info := new(strings.Builder)
 	err := sys.Biome.Run(ctx, &biome.Invocation{
 		Argv:   []string{"cat", filename},
@@ -121,11 +113,28 @@ func readLSBCodename(ctx context.Context, sys Sys) (string, error) {
 		return "", fmt.Errorf("read lsb release: %w", err)
 	}
 	lines := strings.Split(info.String(), "\n")
-	const varPrefix = varname + "="
+	varPrefix := varname + "="
 	for _, line := range lines {
 		if strings.HasPrefix(line, varPrefix) {
-			return line[len(varPrefix):], nil
+			return strings.Trim(line[len(varPrefix):], `"`), nil
 		}
 	}
 	return "", fmt.Errorf("read lsb release: could not find %s in %s", varname, filename)
 }
+
+// rubyDownloadURL returns the URL of a YourBase pre-built Ruby binary for the
+// given Linux distribution release. The build is not guaranteed to exist.
+func rubyDownloadURL(version string, distroCodename string, desc *biome.Descriptor) (string, error) {
+	const template = "https://yourbase-build-tools.s3-us-west-2.amazonaws.com/ruby/ruby-{{ .Version }}-{{ .Platform }}-{{ .DistroCodename }}.tar.bz2"
+	platform, err := platformName("YourBase", map[biome.Descriptor]string{
+		{OS: biome.Linux, Arch: biome.Intel64}: "Linux-x86_64",
+	}, desc)
+	if err != nil {
+		return "", err
+	}
+	return templateToString(template, struct {
+		Version        string
+		Platform       string
+		DistroCodename string
+	}{version, platform, distroCodename})
+}
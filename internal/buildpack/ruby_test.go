@@ -43,3 +43,34 @@ func TestRuby(t *testing.T) {
 		t.Errorf("ruby --version output does not include %q", version)
 	}
 }
+
+func TestRubyDownloadURL(t *testing.T) {
+	tests := []struct {
+		desc      biome.Descriptor
+		want      string
+		wantError bool
+	}{
+		{
+			desc: biome.Descriptor{OS: biome.Linux, Arch: biome.Intel64},
+			want: "https://yourbase-build-tools.s3-us-west-2.amazonaws.com/ruby/ruby-2.7.2-Linux-x86_64-focal.tar.bz2",
+		},
+		{
+			desc:      biome.Descriptor{OS: biome.Linux, Arch: biome.ARM64},
+			wantError: true,
+		},
+		{
+			desc:      biome.Descriptor{OS: biome.MacOS, Arch: biome.Intel64},
+			wantError: true,
+		},
+	}
+	for _, test := range tests {
+		got, err := rubyDownloadURL("2.7.2", "focal", &test.desc)
+		if got != test.want || (err != nil) != test.wantError {
+			errString := "<nil>"
+			if test.wantError {
+				errString = "<error>"
+			}
+			t.Errorf("rubyDownloadURL(\"2.7.2\", \"focal\", %+v) = %q, %v; want %q, %s", test.desc, got, err, test.want, errString)
+		}
+	}
+}
@@ -2,7 +2,6 @@ package buildpack
 
 import (
 	"context"
-	"fmt"
 
 	"github.com/yourbase/yb"
 	"github.com/yourbase/yb/internal/biome"
@@ -24,34 +23,12 @@ func installRust(ctx context.Context, sys Sys, spec yb.BuildpackSpec) (biome.Env
 	// If directory already exists, then use it.
 	if _, err := biome.EvalSymlinks(ctx, sys.Biome, rustDir); err == nil {
 		log.Infof(ctx, "Rust v%s located in %s", spec.Version(), rustDir)
+		recordInstall(ctx, sys, spec, rustDir)
 		return env, nil
 	}
 
 	log.Infof(ctx, "Installing Rust v%s in %s", spec.Version(), rustDir)
-	const template = "https://static.rust-lang.org/dist/rust-{{.Version}}-{{.Arch}}-{{.OS}}.tar.gz"
-	desc := sys.Biome.Describe()
-	data := struct {
-		Version string
-		OS      string
-		Arch    string
-	}{
-		Version: spec.Version(),
-		OS: map[string]string{
-			biome.Linux: "unknown-linux-gnu",
-			biome.MacOS: "apple-darwin",
-		}[desc.OS],
-		Arch: map[string]string{
-			biome.Intel64: "x86_64",
-			biome.Intel32: "i686",
-		}[desc.Arch],
-	}
-	if data.OS == "" {
-		return biome.Environment{}, fmt.Errorf("unsupported os %s", desc.OS)
-	}
-	if data.Arch == "" {
-		return biome.Environment{}, fmt.Errorf("unsupported architecture %s", desc.Arch)
-	}
-	downloadURL, err := templateToString(template, data)
+	downloadURL, err := rustDownloadURL(spec.Version(), sys.Biome.Describe())
 	if err != nil {
 		return biome.Environment{}, err
 	}
@@ -70,5 +47,23 @@ func installRust(ctx context.Context, sys Sys, spec yb.BuildpackSpec) (biome.Env
 	if err != nil {
 		return biome.Environment{}, err
 	}
+	recordInstall(ctx, sys, spec, rustDir)
 	return env, nil
 }
+
+func rustDownloadURL(version string, desc *biome.Descriptor) (string, error) {
+	const template = "https://static.rust-lang.org/dist/rust-{{.Version}}-{{.Target}}.tar.gz"
+	target, err := platformName("Rust", map[biome.Descriptor]string{
+		{OS: biome.Linux, Arch: biome.Intel64}: "x86_64-unknown-linux-gnu",
+		{OS: biome.Linux, Arch: biome.Intel32}: "i686-unknown-linux-gnu",
+		{OS: biome.Linux, Arch: biome.ARM64}:   "aarch64-unknown-linux-gnu",
+		{OS: biome.MacOS, Arch: biome.Intel64}: "x86_64-apple-darwin",
+	}, desc)
+	if err != nil {
+		return "", err
+	}
+	return templateToString(template, struct {
+		Version string
+		Target  string
+	}{version, target})
+}
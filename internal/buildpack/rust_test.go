@@ -18,6 +18,7 @@ package buildpack
 
 import (
 	"context"
+	"net/http"
 	"strings"
 	"testing"
 
@@ -55,3 +56,16 @@ func TestRust(t *testing.T) {
 		t.Errorf("cargo --version: %v", err)
 	}
 }
+
+func TestRustDownloadURL(t *testing.T) {
+	const version = "1.43.1"
+	testDownloadURLs(t, http.MethodHead, func(desc *biome.Descriptor) (string, error) {
+		return rustDownloadURL(version, desc)
+	}, []downloadURLTest{
+		{os: biome.Linux, arch: biome.Intel64, want: "https://static.rust-lang.org/dist/rust-1.43.1-x86_64-unknown-linux-gnu.tar.gz"},
+		{os: biome.Linux, arch: biome.Intel32, want: "https://static.rust-lang.org/dist/rust-1.43.1-i686-unknown-linux-gnu.tar.gz"},
+		{os: biome.Linux, arch: biome.ARM64, want: "https://static.rust-lang.org/dist/rust-1.43.1-aarch64-unknown-linux-gnu.tar.gz"},
+		{os: biome.MacOS, arch: biome.Intel64, want: "https://static.rust-lang.org/dist/rust-1.43.1-x86_64-apple-darwin.tar.gz"},
+		{os: biome.Windows, arch: biome.Intel64},
+	})
+}
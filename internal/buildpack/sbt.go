@@ -0,0 +1,75 @@
+// Copyright 2020 YourBase Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package buildpack
+
+import (
+	"context"
+	"strings"
+
+	"github.com/yourbase/yb"
+	"github.com/yourbase/yb/internal/biome"
+	"zombiezen.com/go/log"
+)
+
+func installSbt(ctx context.Context, sys Sys, spec yb.BuildpackSpec) (biome.Environment, error) {
+	home := sys.Biome.Dirs().Home
+	sbtDir := sys.Biome.JoinPath(sys.Biome.Dirs().Tools, "sbt", "sbt-"+spec.Version())
+	coursierCache := sys.Biome.JoinPath(home, ".cache", "coursier")
+	// sbt derives its caches from the user.home JVM property, which Java reads
+	// from /etc/passwd, so point each of them at the build's home directory.
+	sbtOpts := []string{
+		"-Dsbt.global.base=" + sys.Biome.JoinPath(home, ".sbt"),
+		"-Dsbt.boot.directory=" + sys.Biome.JoinPath(home, ".sbt", "boot"),
+		"-Dsbt.ivy.home=" + sys.Biome.JoinPath(home, ".ivy2"),
+		"-Divy.home=" + sys.Biome.JoinPath(home, ".ivy2"),
+		"-Dsbt.coursier.home=" + coursierCache,
+	}
+	env := biome.Environment{
+		Vars: map[string]string{
+			"SBT_OPTS":       strings.Join(sbtOpts, " "),
+			"COURSIER_CACHE": coursierCache,
+		},
+		PrependPath: []string{
+			sys.Biome.JoinPath(sbtDir, "bin"),
+		},
+	}
+
+	// If directory already exists, then use it.
+	if _, err := biome.EvalSymlinks(ctx, sys.Biome, sbtDir); err == nil {
+		log.Infof(ctx, "sbt v%s located in %s", spec.Version(), sbtDir)
+		recordInstall(ctx, sys, spec, sbtDir)
+		return env, nil
+	}
+
+	log.Infof(ctx, "Installing sbt v%s in %s", spec.Version(), sbtDir)
+	downloadURL, err := sbtDownloadURL(spec.Version())
+	if err != nil {
+		return biome.Environment{}, err
+	}
+	if err := extract(ctx, sys, sbtDir, downloadURL, stripTopDirectory); err != nil {
+		return biome.Environment{}, err
+	}
+	recordInstall(ctx, sys, spec, sbtDir)
+	return env, nil
+}
+
+func sbtDownloadURL(version string) (string, error) {
+	const template = "https://github.com/sbt/sbt/releases/download/v{{.Version}}/sbt-{{.Version}}.zip"
+	return templateToString(template, struct {
+		Version string
+	}{version})
+}
@@ -0,0 +1,62 @@
+// Copyright 2020 YourBase Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package buildpack
+
+import (
+	"context"
+	"strings"
+
+	"github.com/yourbase/yb/internal/biome"
+	"zombiezen.com/go/log"
+)
+
+// warnMissingLibraries logs a warning if the given executable needs shared
+// libraries that the biome doesn't have. Browsers in particular depend on
+// many system libraries that minimal base images lack. The check only runs
+// on Linux, and failures to run ldd are ignored.
+func warnMissingLibraries(ctx context.Context, sys Sys, name, exe string) {
+	if sys.Biome.Describe().OS != biome.Linux {
+		return
+	}
+	output := new(strings.Builder)
+	err := sys.Biome.Run(ctx, &biome.Invocation{
+		Argv:   []string{"ldd", exe},
+		Stdout: output,
+		Stderr: output,
+	})
+	if err != nil {
+		log.Debugf(ctx, "Skipping shared library check for %s: ldd: %v", name, err)
+		return
+	}
+	if missing := parseLddMissing(output.String()); len(missing) > 0 {
+		log.Warnf(ctx, "%s needs shared libraries that are missing from the build environment: %s",
+			name, strings.Join(missing, ", "))
+	}
+}
+
+// parseLddMissing returns the libraries that ldd reports as "not found".
+func parseLddMissing(output string) []string {
+	var missing []string
+	for _, line := range strings.Split(output, "\n") {
+		i := strings.Index(line, "=>")
+		if i == -1 || strings.TrimSpace(line[i+len("=>"):]) != "not found" {
+			continue
+		}
+		missing = append(missing, strings.TrimSpace(line[:i]))
+	}
+	return missing
+}
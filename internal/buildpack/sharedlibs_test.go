@@ -0,0 +1,40 @@
+// Copyright 2020 YourBase Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package buildpack
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseLddMissing(t *testing.T) {
+	const output = "\tlinux-vdso.so.1 (0x00007ffc8a5f2000)\n" +
+		"\tlibdl.so.2 => /lib/x86_64-linux-gnu/libdl.so.2 (0x00007f0c7c1c3000)\n" +
+		"\tlibnss3.so => not found\n" +
+		"\tlibatk-1.0.so.0 => not found\n" +
+		"\tlibc.so.6 => /lib/x86_64-linux-gnu/libc.so.6 (0x00007f0c7bfd1000)\n" +
+		"\t/lib64/ld-linux-x86-64.so.2 (0x00007f0c8276e000)\n"
+	got := parseLddMissing(output)
+	want := []string{"libnss3.so", "libatk-1.0.so.0"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("parseLddMissing(...) (-want +got):\n%s", diff)
+	}
+	if got := parseLddMissing("\tlibc.so.6 => /lib/x86_64-linux-gnu/libc.so.6 (0x00007f0c7bfd1000)\n"); len(got) > 0 {
+		t.Errorf("parseLddMissing(...) with no missing libraries = %q; want []", got)
+	}
+}
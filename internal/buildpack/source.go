@@ -0,0 +1,76 @@
+// Copyright 2020 YourBase Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package buildpack
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/yourbase/commons/xcontext"
+	"github.com/yourbase/yb/internal/biome"
+	"zombiezen.com/go/log"
+)
+
+// buildFromSource extracts an autotools source archive into installDir/src,
+// then configures, compiles, and installs it into installDir. name is the
+// tool's name in log messages. On failure, installDir is removed to prevent
+// subsequent invocations from using a corrupt installation.
+func buildFromSource(ctx context.Context, sys Sys, name, installDir, downloadURL string, configureArgs ...string) (err error) {
+	srcDir := sys.Biome.JoinPath(installDir, "src")
+	log.Infof(ctx, "Downloading %s source to %s...", name, srcDir)
+	if err := extract(ctx, sys, srcDir, downloadURL, stripTopDirectory); err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			rmCtx, cancel := xcontext.KeepAlive(ctx, 10*time.Second)
+			defer cancel()
+			rmErr := sys.Biome.Run(rmCtx, &biome.Invocation{
+				Argv:   []string{"rm", "-rf", installDir},
+				Stdout: sys.Stdout,
+				Stderr: sys.Stderr,
+			})
+			if rmErr != nil {
+				log.Warnf(ctx, "Cleaning up failed %s install: %v", name, rmErr)
+			}
+		}
+	}()
+
+	log.Infof(ctx, "Compiling %s in %s...", name, srcDir)
+	configure := append([]string{
+		sys.Biome.JoinPath(srcDir, "configure"),
+		"--prefix=" + installDir,
+	}, configureArgs...)
+	commands := [][]string{
+		configure,
+		{"make", "--jobs=2"},
+		{"make", "install"},
+	}
+	for _, argv := range commands {
+		err := sys.Biome.Run(ctx, &biome.Invocation{
+			Argv:   argv,
+			Dir:    srcDir,
+			Stdout: sys.Stdout,
+			Stderr: sys.Stderr,
+		})
+		if err != nil {
+			return fmt.Errorf("compiling %s: %s: %w", name, argv[0], err)
+		}
+	}
+	return nil
+}
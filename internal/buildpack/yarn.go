@@ -3,13 +3,20 @@ package buildpack
 import (
 	"context"
 	"fmt"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/yourbase/commons/xcontext"
 	"github.com/yourbase/yb"
 	"github.com/yourbase/yb/internal/biome"
 	"zombiezen.com/go/log"
 )
 
 func installYarn(ctx context.Context, sys Sys, spec yb.BuildpackSpec) (biome.Environment, error) {
+	if isYarnBerry(spec.Version()) {
+		return installYarnBerry(ctx, sys, spec)
+	}
 	yarnDir := sys.Biome.JoinPath(sys.Biome.Dirs().Tools, "yarn", "yarn-v"+spec.Version())
 	env := biome.Environment{
 		PrependPath: []string{
@@ -20,6 +27,7 @@ func installYarn(ctx context.Context, sys Sys, spec yb.BuildpackSpec) (biome.Env
 	// If directory already exists, then use it.
 	if _, err := biome.EvalSymlinks(ctx, sys.Biome, yarnDir); err == nil {
 		log.Infof(ctx, "Yarn v%s located in %s", spec.Version(), yarnDir)
+		recordInstall(ctx, sys, spec, yarnDir)
 		return env, nil
 	}
 
@@ -28,5 +36,133 @@ func installYarn(ctx context.Context, sys Sys, spec yb.BuildpackSpec) (biome.Env
 	if err := extract(ctx, sys, yarnDir, downloadURL, stripTopDirectory); err != nil {
 		return biome.Environment{}, err
 	}
+	recordInstall(ctx, sys, spec, yarnDir)
+	return env, nil
+}
+
+// isYarnBerry reports whether the given Yarn version is Yarn 2 or later.
+func isYarnBerry(version string) bool {
+	major, err := strconv.Atoi(strings.SplitN(version, ".", 2)[0])
+	return err == nil && major >= 2
+}
+
+// npmRegistry is the public npm registry, which corepack fetches package
+// managers from by default.
+const npmRegistry = "https://registry.npmjs.org/"
+
+// installYarnBerry installs Yarn 2 and later, which are distributed through
+// Node.js's corepack instead of as tarballs. corepack comes with Node.js 16.9
+// and later.
+//
+// corepack downloads Yarn itself rather than through sys.Downloader, so the
+// only mirror rule it follows is one for the public npm registry, which is
+// passed along as COREPACK_NPM_REGISTRY.
+func installYarnBerry(ctx context.Context, sys Sys, spec yb.BuildpackSpec) (_ biome.Environment, err error) {
+	// The shims that corepack installs run the Node.js that created them, so
+	// each Node.js release gets its own directory.
+	nodeVersion, err := corepackNodeVersion(ctx, sys)
+	if err != nil {
+		return biome.Environment{}, fmt.Errorf("install yarn: %w", err)
+	}
+	yarnDir := sys.Biome.JoinPath(sys.Biome.Dirs().Tools, "yarn", "yarn-v"+spec.Version()+"-node-v"+nodeVersion)
+	// corepack records the activated version in its home, so keep it with the
+	// shims rather than in the build's home directory.
+	corepackEnv := biome.Environment{
+		Vars: map[string]string{
+			"COREPACK_HOME":                   sys.Biome.JoinPath(yarnDir, "corepack"),
+			"COREPACK_ENABLE_DOWNLOAD_PROMPT": "0",
+			// Don't refuse to run in projects that use another package manager.
+			"COREPACK_ENABLE_STRICT": "0",
+		},
+	}
+	if registry := corepackRegistry(sys); registry != "" {
+		corepackEnv.Vars["COREPACK_NPM_REGISTRY"] = registry
+	}
+	env := corepackEnv.Merge(biome.Environment{
+		Vars: map[string]string{
+			// Share one package cache among the build's projects.
+			"YARN_GLOBAL_FOLDER":       sys.Biome.JoinPath(sys.Biome.Dirs().Home, ".cache", "yarn", "berry"),
+			"YARN_ENABLE_GLOBAL_CACHE": "true",
+		},
+		PrependPath: []string{yarnDir},
+	})
+
+	// If directory already exists, then use it.
+	if _, err := biome.EvalSymlinks(ctx, sys.Biome, yarnDir); err == nil {
+		log.Infof(ctx, "Yarn v%s located in %s", spec.Version(), yarnDir)
+		recordInstall(ctx, sys, spec, yarnDir)
+		return env, nil
+	}
+
+	log.Infof(ctx, "Installing Yarn v%s in %s with corepack", spec.Version(), yarnDir)
+	if err := biome.MkdirAll(ctx, sys.Biome, yarnDir); err != nil {
+		return biome.Environment{}, fmt.Errorf("install yarn: %w", err)
+	}
+	defer func() {
+		// Remove the directory on failure so that later builds retry.
+		if err != nil {
+			rmCtx, cancel := xcontext.KeepAlive(ctx, 10*time.Second)
+			defer cancel()
+			rmErr := sys.Biome.Run(rmCtx, &biome.Invocation{
+				Argv:   []string{"rm", "-rf", yarnDir},
+				Stdout: sys.Stdout,
+				Stderr: sys.Stderr,
+			})
+			if rmErr != nil {
+				log.Warnf(ctx, "Cleaning up failed Yarn install: %v", rmErr)
+			}
+		}
+	}()
+	commands := [][]string{
+		{"corepack", "enable", "--install-directory", yarnDir, "yarn"},
+		{"corepack", "prepare", "yarn@" + spec.Version(), "--activate"},
+	}
+	for _, argv := range commands {
+		err := sys.Biome.Run(ctx, &biome.Invocation{
+			Argv:   argv,
+			Env:    sys.Env.Merge(corepackEnv),
+			Stdout: sys.Stdout,
+			Stderr: sys.Stderr,
+		})
+		if err != nil {
+			return biome.Environment{}, fmt.Errorf("install yarn: %s (corepack requires Node.js 16.9 or later): %w", strings.Join(argv, " "), err)
+		}
+	}
+	recordInstall(ctx, sys, spec, yarnDir)
 	return env, nil
 }
+
+// corepackNodeVersion returns the version of the Node.js in sys.Env, without
+// the leading "v".
+func corepackNodeVersion(ctx context.Context, sys Sys) (string, error) {
+	out := new(strings.Builder)
+	err := sys.Biome.Run(ctx, &biome.Invocation{
+		Argv:   []string{"node", "--version"},
+		Env:    sys.Env,
+		Stdout: out,
+		Stderr: sys.Stderr,
+	})
+	if err != nil {
+		return "", fmt.Errorf("find node version: %w", err)
+	}
+	version := strings.TrimPrefix(strings.TrimSpace(out.String()), "v")
+	if version == "" || strings.ContainsAny(version, "/\\ ") {
+		return "", fmt.Errorf("find node version: unexpected output %q", out.String())
+	}
+	return version, nil
+}
+
+// corepackRegistry returns the npm registry URL that corepack should use, as
+// given by the first of sys.Downloader's mirror rules that applies to the
+// public npm registry, or the empty string if no rule applies.
+func corepackRegistry(sys Sys) string {
+	if sys.Downloader == nil {
+		return ""
+	}
+	for _, m := range sys.Downloader.Mirrors {
+		if u, ok := m.Rewrite(npmRegistry); ok {
+			return strings.TrimSuffix(u, "/")
+		}
+	}
+	return ""
+}
@@ -21,15 +21,16 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/yourbase/yb"
 	"github.com/yourbase/yb/internal/biome"
+	"github.com/yourbase/yb/internal/ybdata"
 	"zombiezen.com/go/log/testlog"
 )
 
 func TestYarn(t *testing.T) {
 	const version = "1.22.10"
 	ctx := testlog.WithTB(context.Background(), t)
-	// TODO(light): Yarn depends on Node but won't install it by itself.
-	yarnBiome, _ := testInstall(ctx, t, "node:12.19.0", "yarn:"+version)
+	yarnBiome, _ := testInstall(ctx, t, "yarn:"+version)
 	versionOutput := new(strings.Builder)
 	err := yarnBiome.Run(ctx, &biome.Invocation{
 		Argv:   []string{"yarn", "--version"},
@@ -44,3 +45,54 @@ func TestYarn(t *testing.T) {
 		t.Errorf("yarn --version output does not include %q", version)
 	}
 }
+
+// TestYarnBerry installs Yarn 2+ through corepack, using the default Node.js
+// release that ships with corepack.
+func TestYarnBerry(t *testing.T) {
+	const version = "3.6.1"
+	ctx := testlog.WithTB(context.Background(), t)
+	yarnBiome, _ := testInstall(ctx, t, "yarn:"+version)
+	versionOutput := new(strings.Builder)
+	err := yarnBiome.Run(ctx, &biome.Invocation{
+		Argv:   []string{"yarn", "--version"},
+		Stdout: versionOutput,
+		Stderr: versionOutput,
+	})
+	t.Logf("yarn --version output:\n%s", versionOutput)
+	if err != nil {
+		t.Errorf("yarn --version: %v", err)
+	}
+	if got := versionOutput.String(); !strings.Contains(got, version) {
+		t.Errorf("yarn --version output does not include %q", version)
+	}
+}
+
+func TestCorepackRegistry(t *testing.T) {
+	tests := []struct {
+		mirrors []string
+		want    string
+	}{
+		{mirrors: nil, want: ""},
+		{mirrors: []string{"https://nodejs.org/dist/ -> https://mirror.example.com/node/"}, want: ""},
+		{
+			mirrors: []string{
+				"https://nodejs.org/dist/ -> https://mirror.example.com/node/",
+				"https://registry.npmjs.org/ -> https://mirror.example.com/npm/",
+			},
+			want: "https://mirror.example.com/npm",
+		},
+	}
+	for _, test := range tests {
+		d := ybdata.NewDownloader(t.TempDir())
+		for _, s := range test.mirrors {
+			m, err := yb.ParseMirror(s)
+			if err != nil {
+				t.Fatal(err)
+			}
+			d.Mirrors = append(d.Mirrors, m)
+		}
+		if got := corepackRegistry(Sys{Downloader: d}); got != test.want {
+			t.Errorf("corepackRegistry(%q) = %q; want %q", test.mirrors, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/yourbase/yb"
+	"go4.org/xdgdir"
+)
+
+const buildpacksName = "buildpacks.yml"
+
+// LoadBuildpacks reads the buildpacks declared in the user's buildpacks.yml
+// files. Files earlier in the XDG configuration search path take precedence.
+func LoadBuildpacks() (map[string]*yb.BuildpackDefinition, error) {
+	defs := make(map[string]*yb.BuildpackDefinition)
+	paths := xdgdir.Config.SearchPaths()
+	for i := len(paths) - 1; i >= 0; i-- {
+		path := filepath.Join(paths[i], dirName, buildpacksName)
+		data, err := ioutil.ReadFile(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("load buildpacks: %w", err)
+		}
+		fileDefs, err := yb.ParseBuildpackDefinitions(data)
+		if err != nil {
+			return nil, fmt.Errorf("load buildpacks: %s: %w", path, err)
+		}
+		for name, def := range fileDefs {
+			defs[name] = def
+		}
+	}
+	return defs, nil
+}
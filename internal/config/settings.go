@@ -6,6 +6,8 @@ import (
 	"os"
 	"strconv"
 	"strings"
+
+	"github.com/yourbase/yb"
 )
 
 func ShouldUploadBuildLogs(cfg Getter) bool {
@@ -168,3 +170,92 @@ func UserToken(cfg Getter) (string, error) {
 	}
 	return token, nil
 }
+
+// CacheMaxSize returns the maximum total size in bytes of yb's download cache
+// and build homes, or zero if the cache size is unbounded.
+func CacheMaxSize(cfg Getter) (int64, error) {
+	s := os.Getenv("YB_CACHE_MAX_SIZE")
+	if s == "" {
+		s = Get(cfg, "defaults", "cache-max-size")
+	}
+	if s == "" {
+		return 0, nil
+	}
+	n, err := ParseSize(s)
+	if err != nil {
+		return 0, fmt.Errorf("get cache max size: %w", err)
+	}
+	return n, nil
+}
+
+// ParseSize parses a size in bytes with an optional unit suffix like "500M",
+// "20GiB", or "1.5G". Units are powers of 1024.
+func ParseSize(s string) (int64, error) {
+	num := strings.TrimSpace(s)
+	unit := strings.TrimLeft(num, "0123456789.")
+	num = num[:len(num)-len(unit)]
+	unit = strings.TrimSuffix(strings.TrimSuffix(strings.ToUpper(strings.TrimSpace(unit)), "B"), "I")
+	shifts := map[string]uint{"": 0, "K": 10, "M": 20, "G": 30, "T": 40}
+	shift, ok := shifts[unit]
+	if num == "" || !ok {
+		return 0, fmt.Errorf("parse size %q: invalid syntax", s)
+	}
+	f, err := strconv.ParseFloat(num, 64)
+	if err != nil {
+		return 0, fmt.Errorf("parse size %q: invalid syntax", s)
+	}
+	return int64(f * float64(int64(1)<<shift)), nil
+}
+
+// Mirrors returns the download mirror rules from the YB_MIRRORS environment
+// variable or the mirrors setting: a comma-separated list of rules of the form
+// "FROM -> TO". If the mirror-fallback setting is true, downloads fall back to
+// the original URL when a mirror fails.
+func Mirrors(cfg Getter) ([]*yb.Mirror, error) {
+	s := os.Getenv("YB_MIRRORS")
+	if s == "" {
+		s = Get(cfg, "defaults", "mirrors")
+	}
+	var fallback bool
+	if v := Get(cfg, "defaults", "mirror-fallback"); v != "" {
+		var err error
+		fallback, err = strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("get mirrors: mirror-fallback: %w", err)
+		}
+	}
+	var mirrors []*yb.Mirror
+	for _, rule := range strings.Split(s, ",") {
+		if strings.TrimSpace(rule) == "" {
+			continue
+		}
+		m, err := yb.ParseMirror(rule)
+		if err != nil {
+			return nil, fmt.Errorf("get mirrors: %w", err)
+		}
+		m.Fallback = fallback
+		mirrors = append(mirrors, m)
+	}
+	return mirrors, nil
+}
+
+// RemoteCache returns the base URL of the shared download cache from the
+// YB_REMOTE_CACHE environment variable or the remote-cache setting, or the
+// empty string if none is configured.
+func RemoteCache(cfg Getter) (string, error) {
+	s := os.Getenv("YB_REMOTE_CACHE")
+	if s == "" {
+		s = Get(cfg, "defaults", "remote-cache")
+	}
+	if s == "" {
+		return "", nil
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		return "", fmt.Errorf("get remote cache: %w", err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("get remote cache: %s is not an http or https URL", s)
+	}
+	return s, nil
+}
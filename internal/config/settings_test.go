@@ -0,0 +1,113 @@
+// Copyright 2020 YourBase Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package config
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/yourbase/commons/ini"
+	"github.com/yourbase/yb"
+)
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		s       string
+		want    int64
+		wantErr bool
+	}{
+		{s: "0", want: 0},
+		{s: "1234", want: 1234},
+		{s: "1234B", want: 1234},
+		{s: "1K", want: 1 << 10},
+		{s: "500M", want: 500 << 20},
+		{s: "500MiB", want: 500 << 20},
+		{s: "20G", want: 20 << 30},
+		{s: "20 GB", want: 20 << 30},
+		{s: "1.5g", want: 3 << 29},
+		{s: "2T", want: 2 << 40},
+		{s: "", wantErr: true},
+		{s: "G", wantErr: true},
+		{s: "-1G", wantErr: true},
+		{s: "10X", wantErr: true},
+		{s: "1.2.3M", wantErr: true},
+	}
+	for _, test := range tests {
+		got, err := ParseSize(test.s)
+		if err != nil {
+			if !test.wantErr {
+				t.Errorf("ParseSize(%q): %v", test.s, err)
+			}
+			continue
+		}
+		if test.wantErr {
+			t.Errorf("ParseSize(%q) = %d, <nil>; want error", test.s, got)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("ParseSize(%q) = %d; want %d", test.s, got, test.want)
+		}
+	}
+}
+
+func TestMirrors(t *testing.T) {
+	cfg, err := ini.Parse(strings.NewReader(
+		"[defaults]\n"+
+			"mirrors = https://nodejs.org/dist/ -> https://artifactory.example.com/nodejs/, "+
+			"https://dl.google.com/ -> https://artifactory.example.com/google/\n"+
+			"mirror-fallback = true\n",
+	), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := Mirrors(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*yb.Mirror{
+		{From: "https://nodejs.org/dist/", To: "https://artifactory.example.com/nodejs/", Fallback: true},
+		{From: "https://dl.google.com/", To: "https://artifactory.example.com/google/", Fallback: true},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Mirrors(cfg) (-want +got):\n%s", diff)
+	}
+}
+
+func TestRemoteCache(t *testing.T) {
+	tests := []struct {
+		setting string
+		want    string
+		wantErr bool
+	}{
+		{setting: "", want: ""},
+		{setting: "http://cache.example.com:8080", want: "http://cache.example.com:8080"},
+		{setting: "https://cache.example.com/yb/", want: "https://cache.example.com/yb/"},
+		{setting: "cache.example.com", wantErr: true},
+		{setting: "file:///var/cache/yb", wantErr: true},
+	}
+	for _, test := range tests {
+		cfg, err := ini.Parse(strings.NewReader("[defaults]\nremote-cache = "+test.setting+"\n"), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := RemoteCache(cfg)
+		if got != test.want || (err != nil) != test.wantErr {
+			t.Errorf("RemoteCache(%q) = %q, %v; want %q, error = %t", test.setting, got, err, test.want, test.wantErr)
+		}
+	}
+}
@@ -0,0 +1,311 @@
+// Copyright 2020 YourBase Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package ybdata
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// metaSuffix is appended to the path of a download cache file or a build home
+// to form the path of its metadata file.
+const metaSuffix = ".json"
+
+// downloadMeta is the metadata stored next to each file in the download cache.
+type downloadMeta struct {
+	URL       string    `json:"url"`
+	Size      int64     `json:"size"`
+	SHA256    string    `json:"sha256"`
+	FetchedAt time.Time `json:"fetched_at"`
+	LastUsed  time.Time `json:"last_used"`
+
+	// ETag and LastModified are the validators from the response, used to
+	// resume a partial fetch.
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+}
+
+// buildHomeMeta is the metadata stored next to each build home.
+type buildHomeMeta struct {
+	PackageDir string    `json:"package_dir"`
+	Target     string    `json:"target"`
+	OS         string    `json:"os"`
+	Arch       string    `json:"arch"`
+	LastUsed   time.Time `json:"last_used"`
+}
+
+// Kinds of cache entries.
+const (
+	DownloadEntry  = "download"
+	BuildHomeEntry = "home"
+)
+
+// A CacheEntry is a downloaded file or a build home.
+type CacheEntry struct {
+	// Kind is either DownloadEntry or BuildHomeEntry.
+	Kind string
+	// Name describes the entry: the URL of a download or the package
+	// directory and target of a build home.
+	Name string
+	// Path is the path of the downloaded file or build home directory.
+	Path string
+	// Size is the total size of the entry's files in bytes.
+	Size int64
+	// LastUsed is the last time the entry was used by a build.
+	LastUsed time.Time
+}
+
+// CacheEntries returns the entries in the download cache, including files
+// uploaded to a cache server sharing it, and the build homes, from least to
+// most recently used.
+func (dirs *Dirs) CacheEntries() ([]*CacheEntry, error) {
+	entries, err := downloadEntries(dirs.Downloads())
+	if err != nil {
+		return nil, fmt.Errorf("list cache: %w", err)
+	}
+	uploads, err := downloadEntries(filepath.Join(dirs.Downloads(), cacheServerUploadsDir))
+	if err != nil {
+		return nil, fmt.Errorf("list cache: %w", err)
+	}
+	entries = append(entries, uploads...)
+	homes, err := dirs.AllBuildHomes()
+	if err != nil {
+		return nil, fmt.Errorf("list cache: %w", err)
+	}
+	for _, home := range homes {
+		ent, err := buildHomeEntry(home)
+		if err != nil {
+			return nil, fmt.Errorf("list cache: %w", err)
+		}
+		entries = append(entries, ent)
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].LastUsed.Before(entries[j].LastUsed)
+	})
+	return entries, nil
+}
+
+func downloadEntries(dir string) ([]*CacheEntry, error) {
+	infos, err := ioutil.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var entries []*CacheEntry
+	for _, info := range infos {
+		name := info.Name()
+		if !info.Mode().IsRegular() || strings.HasPrefix(name, ".") || strings.HasSuffix(name, metaSuffix) {
+			continue
+		}
+		ent := &CacheEntry{
+			Kind:     DownloadEntry,
+			Name:     name,
+			Path:     filepath.Join(dir, name),
+			Size:     info.Size(),
+			LastUsed: info.ModTime(),
+		}
+		meta := new(downloadMeta)
+		if err := readJSONFile(ent.Path+metaSuffix, meta); err == nil {
+			ent.Name = meta.URL
+			if strings.HasSuffix(name, partialSuffix) {
+				ent.Name += " (partial)"
+			}
+			ent.LastUsed = meta.LastUsed
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		entries = append(entries, ent)
+	}
+	return entries, nil
+}
+
+func buildHomeEntry(home string) (*CacheEntry, error) {
+	size, err := DiskUsage(home)
+	if err != nil {
+		return nil, err
+	}
+	ent := &CacheEntry{
+		Kind: BuildHomeEntry,
+		Name: home,
+		Path: home,
+		Size: size,
+	}
+	meta := new(buildHomeMeta)
+	if err := readJSONFile(home+metaSuffix, meta); err == nil {
+		ent.Name = fmt.Sprintf("%s (%s, %s/%s)", meta.PackageDir, meta.Target, meta.OS, meta.Arch)
+		ent.LastUsed = meta.LastUsed
+	} else if errors.Is(err, os.ErrNotExist) {
+		// Build home created before metadata was recorded.
+		info, err := os.Stat(home)
+		if err != nil {
+			return nil, err
+		}
+		ent.LastUsed = info.ModTime()
+	} else {
+		return nil, err
+	}
+	return ent, nil
+}
+
+// Remove deletes the entry's files and metadata.
+func (ent *CacheEntry) Remove() error {
+	if err := removeAll(ent.Path); err != nil {
+		return fmt.Errorf("remove %s: %w", ent.Name, err)
+	}
+	if err := os.Remove(ent.Path + metaSuffix); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("remove %s: %w", ent.Name, err)
+	}
+	if ent.Kind == BuildHomeEntry {
+		// Remove the now-empty OS, target, and package directories. Errors are
+		// ignored because the directories may contain other build homes.
+		dir := filepath.Dir(ent.Path)
+		for i := 0; i < 3; i++ {
+			if os.Remove(dir) != nil {
+				break
+			}
+			dir = filepath.Dir(dir)
+		}
+	}
+	return nil
+}
+
+// TrimOptions is the set of optional parameters to TrimCache.
+type TrimOptions struct {
+	// MinAge is how long ago an entry must have been last used for TrimCache
+	// to remove it. Entries used more recently may belong to a build that is
+	// still running.
+	MinAge time.Duration
+	// Keep is a list of entry paths that TrimCache will not remove, like the
+	// build homes of the build that just finished.
+	Keep []string
+}
+
+// TrimCache removes the least recently used entries until the total size of
+// the remaining entries is at most maxSize bytes. entries must be sorted from
+// least to most recently used, as returned by CacheEntries. Entries excluded by
+// opts are never removed, even if that leaves the cache larger than maxSize.
+// opts may be nil. TrimCache returns the entries it removed.
+//
+// Callers should hold the lock returned by TryLockCacheForTrim.
+func TrimCache(entries []*CacheEntry, maxSize int64, opts *TrimOptions) ([]*CacheEntry, error) {
+	if opts == nil {
+		opts = new(TrimOptions)
+	}
+	keep := make(map[string]struct{}, len(opts.Keep))
+	for _, path := range opts.Keep {
+		keep[filepath.Clean(path)] = struct{}{}
+	}
+	cutoff := time.Now().Add(-opts.MinAge)
+	var total int64
+	for _, ent := range entries {
+		total += ent.Size
+	}
+	var removed []*CacheEntry
+	for _, ent := range entries {
+		if total <= maxSize {
+			break
+		}
+		if _, kept := keep[filepath.Clean(ent.Path)]; kept || (opts.MinAge > 0 && ent.LastUsed.After(cutoff)) {
+			continue
+		}
+		if err := ent.Remove(); err != nil {
+			return removed, fmt.Errorf("trim cache: %w", err)
+		}
+		total -= ent.Size
+		removed = append(removed, ent)
+	}
+	return removed, nil
+}
+
+// DiskUsage returns the total size in bytes of the regular files in the tree
+// rooted at root. Symbolic links are not followed.
+func DiskUsage(root string) (int64, error) {
+	var total int64
+	err := filepath.Walk(root, func(_ string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Mode().IsRegular() {
+			total += info.Size()
+		}
+		return nil
+	})
+	return total, err
+}
+
+// removeAll is like os.RemoveAll, but also removes read-only directories like
+// the ones in a Go module cache.
+func removeAll(path string) error {
+	err := os.RemoveAll(path)
+	if err == nil || !errors.Is(err, os.ErrPermission) {
+		return err
+	}
+	filepath.Walk(path, func(p string, info os.FileInfo, err error) error {
+		if err == nil && info.IsDir() && info.Mode().Perm()&0o200 == 0 {
+			os.Chmod(p, info.Mode().Perm()|0o700)
+		}
+		return nil
+	})
+	return os.RemoveAll(path)
+}
+
+func readJSONFile(path string, v interface{}) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("read %s: %w", path, err)
+	}
+	return nil
+}
+
+// writeJSONFile atomically replaces the file at path with the JSON encoding of
+// v.
+func writeJSONFile(path string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("write %s: %w", path, err)
+	}
+	f, err := ioutil.TempFile(filepath.Dir(path), ".tmp*")
+	if err != nil {
+		return fmt.Errorf("write %s: %w", path, err)
+	}
+	_, writeErr := f.Write(data)
+	closeErr := f.Close()
+	if writeErr != nil || closeErr != nil {
+		os.Remove(f.Name())
+		if writeErr != nil {
+			return fmt.Errorf("write %s: %w", path, writeErr)
+		}
+		return fmt.Errorf("write %s: %w", path, closeErr)
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		os.Remove(f.Name())
+		return fmt.Errorf("write %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,243 @@
+// Copyright 2020 YourBase Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package ybdata
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yourbase/commons/http/headers"
+	"github.com/yourbase/yb/internal/biome"
+	"zombiezen.com/go/log/testlog"
+)
+
+func TestDownloadMetadata(t *testing.T) {
+	const content = "Hello, World!\n"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(headers.ContentLength, fmt.Sprint(len(content)))
+		io.WriteString(w, content)
+	}))
+	t.Cleanup(srv.Close)
+	ctx := testlog.WithTB(context.Background(), t)
+	dir := t.TempDir()
+	d := NewDownloader(dir)
+	d.Client = srv.Client()
+	u1 := srv.URL + "/a/b.txt"
+	u2 := srv.URL + "/a_b.txt"
+	for _, u := range []string{u1, u2} {
+		f, err := d.Download(ctx, u)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+	}
+	if cacheFilenameForURL(u1) == cacheFilenameForURL(u2) {
+		t.Errorf("%s and %s have the same cache filename", u1, u2)
+	}
+	meta := new(downloadMeta)
+	if err := readJSONFile(filepath.Join(dir, cacheFilenameForURL(u1))+metaSuffix, meta); err != nil {
+		t.Fatal(err)
+	}
+	const wantDigest = "c98c24b677eff44860afea6f493bbaec5bb1c4cbb209c6fc2bbb47f66ff2ad31"
+	if meta.URL != u1 || meta.Size != int64(len(content)) || meta.SHA256 != wantDigest {
+		t.Errorf("metadata = %+v; want URL %s, size %d, digest %s", meta, u1, len(content), wantDigest)
+	}
+	if meta.FetchedAt.IsZero() || meta.LastUsed.Before(meta.FetchedAt) {
+		t.Errorf("metadata times = fetched %v, last used %v", meta.FetchedAt, meta.LastUsed)
+	}
+
+	// Reusing the file updates its last use.
+	fetchedAt := meta.FetchedAt
+	time.Sleep(10 * time.Millisecond)
+	f, err := d.Download(ctx, u1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	if err := readJSONFile(filepath.Join(dir, cacheFilenameForURL(u1))+metaSuffix, meta); err != nil {
+		t.Fatal(err)
+	}
+	if !meta.FetchedAt.Equal(fetchedAt) || !meta.LastUsed.After(fetchedAt) {
+		t.Errorf("after reuse, metadata times = fetched %v, last used %v; want fetched %v and later use", meta.FetchedAt, meta.LastUsed, fetchedAt)
+	}
+}
+
+func TestTrimCache(t *testing.T) {
+	root := t.TempDir()
+	dirs := NewDirs(root)
+	base := time.Date(2020, time.December, 1, 12, 0, 0, 0, time.UTC)
+
+	// Downloads: old (100 bytes), legacy file without metadata (50 bytes).
+	if err := os.MkdirAll(dirs.Downloads(), 0o777); err != nil {
+		t.Fatal(err)
+	}
+	oldDownload := filepath.Join(dirs.Downloads(), cacheFilenameForURL("https://example.com/old.tar.gz"))
+	writeTestFile(t, oldDownload, 100)
+	err := writeJSONFile(oldDownload+metaSuffix, &downloadMeta{
+		URL:      "https://example.com/old.tar.gz",
+		Size:     100,
+		LastUsed: base,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacyDownload := filepath.Join(dirs.Downloads(), "httpsexample.comlegacy.zip")
+	writeTestFile(t, legacyDownload, 50)
+	if err := os.Chtimes(legacyDownload, base.Add(1*time.Hour), base.Add(1*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	// Build home used most recently (200 bytes).
+	desc := &biome.Descriptor{OS: "linux", Arch: "amd64"}
+	home, err := dirs.BuildHome("/src/project", "default", desc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, filepath.Join(home, "go", "pkg", "mod", "cache.bin"), 200)
+	// Module caches are read-only.
+	if err := os.Chmod(filepath.Join(home, "go", "pkg", "mod"), 0o555); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := dirs.CacheEntries()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, ent := range entries {
+		got = append(got, fmt.Sprintf("%s %s %d", ent.Kind, ent.Name, ent.Size))
+	}
+	want := []string{
+		"download https://example.com/old.tar.gz 100",
+		"download httpsexample.comlegacy.zip 50",
+		"home /src/project (default, linux/amd64) 200",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("CacheEntries() =\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	removed, err := TrimCache(entries, 200, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(removed) != 2 || removed[0] != entries[0] || removed[1] != entries[1] {
+		t.Errorf("TrimCache(entries, 200) removed %d entries; want the two downloads", len(removed))
+	}
+	for _, path := range []string{oldDownload, oldDownload + metaSuffix, legacyDownload} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("after TrimCache, stat %s: %v; want not exist", path, err)
+		}
+	}
+	if _, err := os.Stat(home); err != nil {
+		t.Errorf("after TrimCache, build home: %v", err)
+	}
+
+	removed, err = TrimCache(entries[2:], 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(removed) != 1 {
+		t.Errorf("TrimCache(entries[2:], 0) removed %d entries; want 1", len(removed))
+	}
+	if _, err := os.Stat(dirs.BuildHomeRoot("/src/project")); !os.IsNotExist(err) {
+		t.Errorf("after removing build home, stat package directory: %v; want not exist", err)
+	}
+}
+
+func TestTrimCacheOptions(t *testing.T) {
+	root := t.TempDir()
+	now := time.Now()
+	var entries []*CacheEntry
+	for i, lastUsed := range []time.Time{now.Add(-2 * time.Hour), now.Add(-1 * time.Hour), now.Add(-1 * time.Minute)} {
+		path := filepath.Join(root, fmt.Sprintf("file%d", i))
+		writeTestFile(t, path, 100)
+		entries = append(entries, &CacheEntry{
+			Kind:     DownloadEntry,
+			Name:     path,
+			Path:     path,
+			Size:     100,
+			LastUsed: lastUsed,
+		})
+	}
+	removed, err := TrimCache(entries, 0, &TrimOptions{
+		MinAge: 5 * time.Minute,
+		Keep:   []string{entries[0].Path},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(removed) != 1 || removed[0] != entries[1] {
+		t.Errorf("TrimCache removed %d entries; want only %s", len(removed), entries[1].Path)
+	}
+	for _, ent := range []*CacheEntry{entries[0], entries[2]} {
+		if _, err := os.Stat(ent.Path); err != nil {
+			t.Errorf("after TrimCache: %v", err)
+		}
+	}
+}
+
+func TestCacheLock(t *testing.T) {
+	dirs := NewDirs(t.TempDir())
+	shared1, err := dirs.LockCache()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer shared1.Unlock()
+	shared2, err := dirs.LockCache()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer shared2.Unlock()
+	if l, err := dirs.TryLockCacheForTrim(); !errors.Is(err, ErrCacheInUse) {
+		l.Unlock()
+		t.Fatalf("TryLockCacheForTrim() with shared locks held = _, %v; want %v", err, ErrCacheInUse)
+	}
+
+	shared1.Unlock()
+	shared2.Unlock()
+	excl, err := dirs.TryLockCacheForTrim()
+	if err != nil {
+		t.Fatal("TryLockCacheForTrim() after unlocking:", err)
+	}
+	if l, err := dirs.TryLockCacheForTrim(); !errors.Is(err, ErrCacheInUse) {
+		l.Unlock()
+		t.Errorf("TryLockCacheForTrim() while trimming = _, %v; want %v", err, ErrCacheInUse)
+	}
+	if err := excl.Unlock(); err != nil {
+		t.Error(err)
+	}
+}
+
+func writeTestFile(tb testing.TB, path string, size int) {
+	tb.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o777); err != nil {
+		tb.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, make([]byte, size), 0o666); err != nil {
+		tb.Fatal(err)
+	}
+}
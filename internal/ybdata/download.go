@@ -18,14 +18,20 @@ package ybdata
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
 	"path/filepath"
-	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/yourbase/yb"
 	"github.com/yourbase/yb/internal/ybtrace"
 	"go.opentelemetry.io/otel/api/trace"
 	"go.opentelemetry.io/otel/codes"
@@ -39,20 +45,222 @@ type Downloader struct {
 	// This can only be changed before the first call to Download.
 	Client *http.Client
 
+	// Checksums maps URLs to the hex-encoded SHA-256 digests of their
+	// expected content. Download returns an error if the content of a URL
+	// in Checksums does not match.
+	// This can only be changed before the first call to Download.
+	Checksums map[string]string
+
+	// OnDownload, if not nil, is called with the hex-encoded SHA-256 digest
+	// of the content of every file returned by Download, whether it was
+	// fetched or reused from the cache. It is called from the goroutine
+	// calling Download, so it may be called concurrently.
+	// This can only be changed before the first call to Download.
+	OnDownload func(url string, sha256 string)
+
+	// MaxConcurrent is the maximum number of files that will be fetched from
+	// the network at once. Zero means no limit.
+	// This can only be changed before the first call to Download.
+	MaxConcurrent int
+
+	// Mirrors is an ordered list of rules that redirect downloads. Download
+	// fetches a URL from the first mirror that matches it. If that mirror
+	// can't be reached or does not have the file and the rule permits falling
+	// back, the next matching mirror is tried, and then the URL itself. The
+	// cache and Checksums are keyed by the original URL.
+	// This can only be changed before the first call to Download.
+	Mirrors []*yb.Mirror
+
+	// RemoteCache is the base URL of a shared download cache, like one served
+	// by NewCacheServer. If set, files missing from the local cache are fetched
+	// from the remote cache before their origin, and files fetched from their
+	// origin are uploaded to the remote cache. The remote cache is addressed by
+	// content, so it is only consulted for URLs with a known digest: one in
+	// Checksums or one passed to WithChecksum. Failures to use the remote cache
+	// are ignored.
+	// This can only be changed before the first call to Download.
+	RemoteCache string
+
+	// RemoteCacheClient is the HTTP client to use for the remote cache. If it
+	// is nil, Client is used. NewDownloader sets it to a client that gives up
+	// quickly if the remote cache can't be reached.
+	// This can only be changed before the first call to Download.
+	RemoteCacheClient *http.Client
+
+	// MaxAttempts is the maximum number of times a fetch is attempted before
+	// Download gives up. Connection failures, interrupted transfers, and 5xx
+	// responses are retried. Values less than 1 are treated as 1.
+	// This can only be changed before the first call to Download.
+	MaxAttempts int
+
+	// RetryDelay is the time to wait before the first retry. The delay doubles
+	// after each failed attempt, up to a minute.
+	// This can only be changed before the first call to Download.
+	RetryDelay time.Duration
+
+	// Progress, if not nil, receives a status line for the fetches in progress
+	// that is redrawn in place, so it should be a terminal. If Progress is nil,
+	// the progress of long fetches is logged periodically instead.
+	// This can only be changed before the first call to Download.
+	Progress io.Writer
+
 	dir string
+
+	initOnce sync.Once
+	sem      chan struct{}
+
+	mu       sync.Mutex
+	urlLocks map[string]*sync.Mutex
+
+	progressMu sync.Mutex
+	transfers  []*transfer
+	drawStop   chan struct{}
+	drawDone   chan struct{}
 }
 
+// Default values for the fields in a Downloader returned by NewDownloader.
+const (
+	DefaultMaxConcurrentDownloads = 4
+	DefaultMaxDownloadAttempts    = 5
+	DefaultDownloadRetryDelay     = 1 * time.Second
+)
+
+// maxRetryDelay is the longest Download will wait between attempts.
+const maxRetryDelay = 1 * time.Minute
+
+// partialSuffix is appended to the path of a download cache file to form the
+// path of the file that an in-progress fetch is written to.
+const partialSuffix = ".partial"
+
 // NewDownloader returns a Downloader that maintains a cache in the
 // given directory. The Downloader will create the directory if it
-// does not exist.
+// does not exist. It is safe to call Download concurrently.
 func NewDownloader(dir string) *Downloader {
 	return &Downloader{
-		Client: http.DefaultClient,
-		dir:    dir,
+		Client:            http.DefaultClient,
+		RemoteCacheClient: newRemoteCacheClient(),
+		MaxConcurrent:     DefaultMaxConcurrentDownloads,
+		MaxAttempts:       DefaultMaxDownloadAttempts,
+		RetryDelay:        DefaultDownloadRetryDelay,
+		dir:               dir,
 	}
 }
 
-func (d *Downloader) download(ctx context.Context, dst io.Writer, url string) (err error) {
+func (d *Downloader) init() {
+	d.initOnce.Do(func() {
+		if d.MaxConcurrent > 0 {
+			d.sem = make(chan struct{}, d.MaxConcurrent)
+		}
+	})
+}
+
+// lockURL prevents other calls to Download from using the cache file for url
+// until the returned function is called.
+func (d *Downloader) lockURL(url string) (unlock func()) {
+	d.mu.Lock()
+	if d.urlLocks == nil {
+		d.urlLocks = make(map[string]*sync.Mutex)
+	}
+	mu := d.urlLocks[url]
+	if mu == nil {
+		mu = new(sync.Mutex)
+		d.urlLocks[url] = mu
+	}
+	d.mu.Unlock()
+	mu.Lock()
+	return mu.Unlock
+}
+
+// acquire waits until fewer than d.MaxConcurrent fetches are in progress.
+// The caller must call the returned function when its fetch is finished.
+func (d *Downloader) acquire(ctx context.Context) (release func(), err error) {
+	d.init()
+	if d.sem == nil {
+		return func() {}, nil
+	}
+	select {
+	case d.sem <- struct{}{}:
+		return func() { <-d.sem }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// fetch downloads url from src, which is either url or a mirror of it, to
+// cacheFilename. The content is written to a partial file first so that a fetch
+// interrupted by a network failure, or by yb exiting, can be resumed with a
+// range request. Transient failures are retried with exponential backoff.
+func (d *Downloader) fetch(ctx context.Context, cacheFilename string, url string, src string) (err error) {
+	release, err := d.acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	partialFilename := cacheFilename + partialSuffix
+	f, err := os.OpenFile(partialFilename, os.O_RDWR|os.O_CREATE, 0o666)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if f != nil {
+			f.Close()
+		}
+		if err != nil && !keepPartial(partialFilename, err) {
+			os.Remove(partialFilename)
+			os.Remove(partialFilename + metaSuffix)
+		}
+	}()
+	meta := new(downloadMeta)
+	if err := readJSONFile(partialFilename+metaSuffix, meta); err != nil || meta.URL != url {
+		// Without the validators from the earlier response, the partial content
+		// can't be trusted.
+		if err := f.Truncate(0); err != nil {
+			return err
+		}
+		meta = &downloadMeta{URL: url}
+	}
+	meta.LastUsed = time.Now().UTC()
+
+	maxAttempts := d.MaxAttempts
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+	for attempt := 1; ; attempt++ {
+		err = d.fetchAttempt(ctx, f, partialFilename, meta, src)
+		if err == nil {
+			break
+		}
+		if attempt >= maxAttempts || !isRetryable(err) || ctx.Err() != nil {
+			return err
+		}
+		delay := d.retryDelay(attempt, err)
+		log.Warnf(ctx, "Download %s: %v (retrying in %v)", src, err, delay)
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+
+	closeErr := f.Close()
+	f = nil
+	if closeErr != nil {
+		return closeErr
+	}
+	if err := os.Rename(partialFilename, cacheFilename); err != nil {
+		return err
+	}
+	os.Remove(partialFilename + metaSuffix)
+	return nil
+}
+
+// fetchAttempt makes a single request for url and appends the response to f.
+// If f is not empty, then fetchAttempt asks the server for only the rest of the
+// file.
+func (d *Downloader) fetchAttempt(ctx context.Context, f *os.File, partialFilename string, meta *downloadMeta, url string) (err error) {
 	ctx, span := ybtrace.Start(ctx, "Download "+url,
 		trace.WithSpanKind(trace.SpanKindClient),
 		trace.WithAttributes(
@@ -67,37 +275,186 @@ func (d *Downloader) download(ctx context.Context, dst io.Writer, url string) (e
 		span.End()
 	}()
 
+	offset, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+	validator := meta.ETag
+	if validator == "" || strings.HasPrefix(validator, "W/") {
+		// Weak entity tags can't be used for range requests.
+		validator = meta.LastModified
+	}
+	if offset > 0 && validator == "" {
+		if err := truncateFile(f); err != nil {
+			return err
+		}
+		offset = 0
+	}
+
 	// Make HTTP request.
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
-		return fmt.Errorf("download %s: %w", url, err)
+		return err
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		req.Header.Set("If-Range", validator)
+		log.Infof(ctx, "Resuming download of %s at %s", url, formatBytes(offset))
+	} else {
+		log.Infof(ctx, "Downloading %s", url)
 	}
-	log.Infof(ctx, "Downloading %s", url)
 	resp, err := d.Client.Do(req)
 	if err != nil {
-		return fmt.Errorf("download %s: %w", url, err)
+		return unreachableError{err}
 	}
 	defer resp.Body.Close()
 	span.SetAttribute("http.status_code", resp.StatusCode)
 	span.SetAttribute("http.response_content_length", resp.ContentLength)
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("download %s: %w", url, httpError{
+	switch {
+	case resp.StatusCode == http.StatusPartialContent && offset > 0:
+		if start, ok := contentRangeStart(resp.Header.Get("Content-Range")); !ok || start != offset {
+			truncateFile(f)
+			return retryableError{fmt.Errorf("server sent range %q, want bytes %d-", resp.Header.Get("Content-Range"), offset)}
+		}
+	case resp.StatusCode == http.StatusOK:
+		// Either a new fetch or the file changed on the server since the partial
+		// content was written.
+		if offset > 0 {
+			log.Infof(ctx, "Restarting download of %s", url)
+			if err := truncateFile(f); err != nil {
+				return err
+			}
+			offset = 0
+		}
+		meta.ETag = resp.Header.Get("ETag")
+		meta.LastModified = resp.Header.Get("Last-Modified")
+		meta.FetchedAt = time.Now().UTC()
+		if err := writeJSONFile(partialFilename+metaSuffix, meta); err != nil {
+			return err
+		}
+	case resp.StatusCode == http.StatusRequestedRangeNotSatisfiable && offset > 0:
+		truncateFile(f)
+		return retryableError{fmt.Errorf("resume at byte %d: %w", offset, httpError{
 			status:     resp.Status,
 			statusCode: resp.StatusCode,
-		})
+		})}
+	default:
+		return httpError{
+			status:     resp.Status,
+			statusCode: resp.StatusCode,
+			retryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+		}
 	}
 
 	// Copy to file.
-	if _, err := io.Copy(dst, resp.Body); err != nil {
-		return fmt.Errorf("download %s: %w", url, err)
+	total := int64(-1)
+	if resp.ContentLength >= 0 {
+		total = offset + resp.ContentLength
 	}
-	return nil
+	t := d.startTransfer(ctx, url, offset, total)
+	defer d.finishTransfer(t)
+	_, err = io.Copy(io.MultiWriter(f, t), bodyReader{resp.Body})
+	return err
+}
+
+// sources returns the URLs to try fetching url from, in order.
+func (d *Downloader) sources(url string) []string {
+	var sources []string
+	for _, m := range d.Mirrors {
+		u, ok := m.Rewrite(url)
+		if !ok {
+			continue
+		}
+		sources = append(sources, u)
+		if !m.Fallback {
+			return sources
+		}
+	}
+	return append(sources, url)
+}
+
+// shouldFallBack reports whether a download that failed with err should be
+// attempted from the next source.
+func shouldFallBack(ctx context.Context, err error) bool {
+	return ctx.Err() == nil && (errors.As(err, new(httpError)) || isRetryable(err))
+}
+
+// retryDelay returns how long to wait after the given failed attempt.
+func (d *Downloader) retryDelay(attempt int, err error) time.Duration {
+	var httpErr httpError
+	if errors.As(err, &httpErr) && httpErr.retryAfter > 0 {
+		if httpErr.retryAfter > maxRetryDelay {
+			return maxRetryDelay
+		}
+		return httpErr.retryAfter
+	}
+	delay := d.RetryDelay
+	for i := 1; i < attempt && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxRetryDelay {
+		delay = maxRetryDelay
+	}
+	return delay
+}
+
+// keepPartial reports whether the partial file left by a fetch that failed
+// with err is worth resuming later.
+func keepPartial(partialFilename string, err error) bool {
+	if !isRetryable(err) && !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	info, statErr := os.Stat(partialFilename)
+	return statErr == nil && info.Size() > 0
+}
+
+func truncateFile(f *os.File) error {
+	if err := f.Truncate(0); err != nil {
+		return err
+	}
+	_, err := f.Seek(0, io.SeekStart)
+	return err
+}
+
+// contentRangeStart returns the first byte position of a Content-Range header
+// value like "bytes 100-199/200".
+func contentRangeStart(s string) (int64, bool) {
+	s = strings.TrimPrefix(s, "bytes ")
+	i := strings.IndexByte(s, '-')
+	if i < 0 {
+		return 0, false
+	}
+	start, err := strconv.ParseInt(s[:i], 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return start, true
+}
+
+// parseRetryAfter parses the delay in a Retry-After header value. It returns
+// zero if the header is empty or invalid.
+func parseRetryAfter(s string) time.Duration {
+	if s == "" {
+		return 0
+	}
+	if n, err := strconv.Atoi(s); err == nil && n >= 0 {
+		return time.Duration(n) * time.Second
+	}
+	if t, err := http.ParseTime(s); err == nil {
+		return time.Until(t)
+	}
+	return 0
 }
 
 // Download downloads a URL to the local filesystem and returns a handle to
 // the file. If the URL could not be found on the server, then IsNotFound(err)
-// will return true.
+// will return true. At most d.MaxConcurrent files are fetched at once.
 func (d *Downloader) Download(ctx context.Context, url string) (_ *os.File, err error) {
+	// Concurrent downloads of the same URL would clobber each other's cache
+	// file. The later call will reuse the file from the earlier one.
+	unlock := d.lockURL(url)
+	defer unlock()
+
 	cacheFilename := filepath.Join(d.dir, cacheFilenameForURL(url))
 	if err := os.MkdirAll(filepath.Dir(cacheFilename), 0777); err != nil {
 		return nil, fmt.Errorf("download %s: %w", url, err)
@@ -116,31 +473,174 @@ func (d *Downloader) Download(ctx context.Context, url string) (_ *os.File, err
 			if err := os.Remove(cacheFilename); err != nil {
 				log.Warnf(ctx, "Failed to clean up failed download: %v", err)
 			}
+			if err := os.Remove(cacheFilename + metaSuffix); err != nil && !errors.Is(err, os.ErrNotExist) {
+				log.Warnf(ctx, "Failed to clean up failed download: %v", err)
+			}
 		}
 	}()
 
-	cacheErr := d.validateDownloadCache(ctx, f, url)
+	sources := d.sources(url)
+	cacheErr := d.validateDownloadCache(ctx, f, sources[0])
+	for len(sources) > 1 && shouldFallBack(ctx, cacheErr) {
+		log.Warnf(ctx, "Falling back to %s: %v", sources[1], cacheErr)
+		sources = sources[1:]
+		cacheErr = d.validateDownloadCache(ctx, f, sources[0])
+	}
+	if cacheErr == nil {
+		cacheErr = d.checkDigest(ctx, f, url)
+	}
 	if cacheErr == nil {
 		log.Infof(ctx, "Reusing cached version of %s", url)
+		d.touch(ctx, cacheFilename, f, url)
 		return f, nil
 	}
 	if IsNotFound(cacheErr) {
 		return nil, fmt.Errorf("download %s: %w", url, cacheErr)
 	}
+	if errors.As(cacheErr, new(unreachableError)) && ctx.Err() == nil {
+		// Use a previously downloaded copy so that builds work offline.
+		if info, err := f.Stat(); err == nil && info.Size() > 0 {
+			if err := d.checkDigest(ctx, f, url); err != nil {
+				return nil, err
+			}
+			log.Warnf(ctx, "Using cached version of %s: %v", url, cacheErr)
+			d.touch(ctx, cacheFilename, f, url)
+			return f, nil
+		}
+	}
 	log.Debugf(ctx, "Cache error: %v", cacheErr)
 	log.Infof(ctx, "Not using cache for %s", url)
-	if err := f.Truncate(0); err != nil {
+	// Documents that change over time are never shared through the remote
+	// cache. Other files are only fetched from it if their digest is known,
+	// since its entries are written by any client.
+	useRemote := d.RemoteCache != "" && ctx.Value(unpinnedKey{}) == nil
+	fromRemote := false
+	if want := d.expectedDigest(ctx, url); useRemote && want != "" {
+		if err := d.fetchRemoteCache(ctx, cacheFilename, url, want); err != nil {
+			log.Debugf(ctx, "Remote cache: fetch %s: %v", url, err)
+		} else {
+			fromRemote = true
+		}
+	}
+	if !fromRemote {
+		err = d.fetch(ctx, cacheFilename, url, sources[0])
+		for err != nil && len(sources) > 1 && shouldFallBack(ctx, err) {
+			log.Warnf(ctx, "Falling back to %s: %v", sources[1], err)
+			sources = sources[1:]
+			err = d.fetch(ctx, cacheFilename, url, sources[0])
+		}
+		if err != nil {
+			return nil, fmt.Errorf("download %s: %w", url, err)
+		}
+	}
+	// The fetch replaced the cache file, so open the new one.
+	f.Close()
+	f, err = os.OpenFile(cacheFilename, os.O_RDWR, 0)
+	if err != nil {
 		return nil, fmt.Errorf("download %s: %w", url, err)
 	}
-	if err := d.download(ctx, f, url); err != nil {
+	if err := d.checkDigest(ctx, f, url); err != nil {
+		return nil, err
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
 		return nil, fmt.Errorf("download %s: %w", url, err)
 	}
 	if _, err := f.Seek(0, io.SeekStart); err != nil {
 		return nil, fmt.Errorf("download %s: %w", url, err)
 	}
+	sum := hex.EncodeToString(h.Sum(nil))
+	info, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("download %s: %w", url, err)
+	}
+	now := time.Now().UTC()
+	err = writeJSONFile(cacheFilename+metaSuffix, &downloadMeta{
+		URL:       url,
+		Size:      info.Size(),
+		SHA256:    sum,
+		FetchedAt: now,
+		LastUsed:  now,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("download %s: %w", url, err)
+	}
+	if useRemote && !fromRemote {
+		if err := d.storeRemoteCache(ctx, f, url, sum); err != nil {
+			log.Debugf(ctx, "Remote cache: store %s: %v", url, err)
+		}
+	}
 	return f, nil
 }
 
+// touch records that the cached file for url was used. f is left positioned at
+// its beginning. Failures are logged, since the metadata is only used for
+// cache eviction.
+func (d *Downloader) touch(ctx context.Context, cacheFilename string, f *os.File, url string) {
+	metaFilename := cacheFilename + metaSuffix
+	meta := new(downloadMeta)
+	if err := readJSONFile(metaFilename, meta); err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			log.Debugf(ctx, "Replacing download metadata: %v", err)
+		}
+		// Reconstruct missing or corrupt metadata from the file.
+		h := sha256.New()
+		if _, err := io.Copy(h, f); err != nil {
+			log.Warnf(ctx, "Record use of %s: %v", url, err)
+			return
+		}
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			log.Warnf(ctx, "Record use of %s: %v", url, err)
+			return
+		}
+		info, err := f.Stat()
+		if err != nil {
+			log.Warnf(ctx, "Record use of %s: %v", url, err)
+			return
+		}
+		meta = &downloadMeta{
+			URL:       url,
+			Size:      info.Size(),
+			SHA256:    hex.EncodeToString(h.Sum(nil)),
+			FetchedAt: info.ModTime().UTC(),
+		}
+	}
+	meta.LastUsed = time.Now().UTC()
+	if err := writeJSONFile(metaFilename, meta); err != nil {
+		log.Warnf(ctx, "Record use of %s: %v", url, err)
+	}
+}
+
+// checkDigest verifies the content of f against d.Checksums or the digest
+// passed to WithChecksum and reports its digest to d.OnDownload. f is left positioned at its beginning.
+func (d *Downloader) checkDigest(ctx context.Context, f *os.File, url string) error {
+	if ctx.Value(unpinnedKey{}) != nil {
+		return nil
+	}
+	want := d.expectedDigest(ctx, url)
+	if want == "" && d.OnDownload == nil {
+		return nil
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("download %s: %w", url, err)
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return fmt.Errorf("download %s: %w", url, err)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("download %s: %w", url, err)
+	}
+	got := hex.EncodeToString(h.Sum(nil))
+	if want != "" && !strings.EqualFold(got, want) {
+		return fmt.Errorf("download %s: sha256 %s does not match expected %s", url, got, want)
+	}
+	if d.OnDownload != nil {
+		d.OnDownload(url, got)
+	}
+	return nil
+}
+
 func (d *Downloader) validateDownloadCache(ctx context.Context, statter interface{ Stat() (os.FileInfo, error) }, url string) (err error) {
 	ctx, span := ybtrace.Start(ctx, "Validate cache for "+url,
 		trace.WithSpanKind(trace.SpanKindClient),
@@ -166,7 +666,7 @@ func (d *Downloader) validateDownloadCache(ctx context.Context, statter interfac
 	}
 	resp, err := d.Client.Do(req)
 	if err != nil {
-		return fmt.Errorf("validate %s download cache: %w", url, err)
+		return fmt.Errorf("validate %s download cache: %w", url, unreachableError{err})
 	}
 	resp.Body.Close()
 	span.SetAttribute("http.status_code", resp.StatusCode)
@@ -183,11 +683,44 @@ func (d *Downloader) validateDownloadCache(ctx context.Context, statter interfac
 	return nil
 }
 
-var cacheFilenameUnsafeChars = regexp.MustCompile(`[^a-zA-Z0-9.]+`)
+// expectedDigest returns the hex-encoded SHA-256 digest that the content of
+// url is known to have, or the empty string if it is not known.
+func (d *Downloader) expectedDigest(ctx context.Context, url string) string {
+	if want := d.Checksums[url]; want != "" {
+		return want
+	}
+	want, _ := ctx.Value(checksumKey{url}).(string)
+	return want
+}
+
+type checksumKey struct {
+	url string
+}
+
+// WithChecksum returns a new context that tells a Downloader the hex-encoded
+// SHA-256 digest of the content of url, such as one published by the file's
+// origin. Downloads of url with the context return an error if the content
+// does not match, like URLs in Downloader.Checksums, and may come from the
+// remote cache. Digests in Downloader.Checksums take precedence.
+func WithChecksum(ctx context.Context, url string, sha256 string) context.Context {
+	return context.WithValue(ctx, checksumKey{url}, sha256)
+}
+
+type unpinnedKey struct{}
+
+// Unpinned returns a new context that exempts downloads from a Downloader's
+// Checksums and OnDownload. This is intended for documents that are expected
+// to change over time, like indexes of published versions.
+func Unpinned(ctx context.Context) context.Context {
+	return context.WithValue(ctx, unpinnedKey{}, true)
+}
 
+// cacheFilenameForURL returns the name of the file in the download cache for
+// url: the hex-encoded SHA-256 hash of the URL. Hashing avoids collisions
+// between similar URLs and keeps names within filesystem limits.
 func cacheFilenameForURL(url string) string {
-	// TODO(light): Use a hash-based scheme.
-	return cacheFilenameUnsafeChars.ReplaceAllString(url, "")
+	h := sha256.Sum256([]byte(url))
+	return hex.EncodeToString(h[:])
 }
 
 // IsNotFound reports whether e indicates an HTTP 404 Not Found or
@@ -204,8 +737,67 @@ func IsNotFound(e error) bool {
 type httpError struct {
 	status     string
 	statusCode int
+	// retryAfter is the delay requested by the server, if any.
+	retryAfter time.Duration
 }
 
 func (e httpError) Error() string {
 	return "http " + e.status
 }
+
+// unreachableError wraps an error from an HTTP client that prevented
+// a request from getting a response.
+type unreachableError struct {
+	err error
+}
+
+func (e unreachableError) Error() string {
+	return e.err.Error()
+}
+
+func (e unreachableError) Unwrap() error {
+	return e.err
+}
+
+// retryableError wraps an error that a later attempt may not encounter.
+type retryableError struct {
+	err error
+}
+
+func (e retryableError) Error() string {
+	return e.err.Error()
+}
+
+func (e retryableError) Unwrap() error {
+	return e.err
+}
+
+// isRetryable reports whether a fetch that failed with e should be attempted
+// again: the server couldn't be reached, the transfer was interrupted, or the
+// server reported a temporary problem.
+func isRetryable(e error) bool {
+	if errors.As(e, new(unreachableError)) || errors.As(e, new(retryableError)) {
+		return true
+	}
+	var httpErr httpError
+	if !errors.As(e, &httpErr) {
+		return false
+	}
+	return httpErr.statusCode >= 500 ||
+		httpErr.statusCode == http.StatusTooManyRequests ||
+		httpErr.statusCode == http.StatusRequestTimeout
+}
+
+// bodyReader marks errors from reading an HTTP response body as retryable, to
+// distinguish them from errors writing to disk.
+type bodyReader struct {
+	r io.Reader
+}
+
+func (br bodyReader) Read(p []byte) (int, error) {
+	n, err := br.r.Read(p)
+	if err != nil && err != io.EOF {
+		err = retryableError{err}
+	}
+	return n, err
+}
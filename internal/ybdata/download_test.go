@@ -25,9 +25,15 @@ import (
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
 	"github.com/yourbase/commons/http/headers"
+	"github.com/yourbase/yb"
 	"zombiezen.com/go/log/testlog"
 )
 
@@ -72,6 +78,7 @@ func TestDownload(t *testing.T) {
 			dir := t.TempDir()
 			d := NewDownloader(dir)
 			d.Client = srv.Client()
+			d.RetryDelay = time.Millisecond
 
 			f, err := d.Download(context.Background(), srv.URL)
 			if err != nil {
@@ -108,6 +115,498 @@ func TestDownload(t *testing.T) {
 	}
 }
 
+func TestDownloadOffline(t *testing.T) {
+	const content = "Hello, World!\n"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(headers.ContentLength, fmt.Sprint(len(content)))
+		io.WriteString(w, content)
+	}))
+	ctx := testlog.WithTB(context.Background(), t)
+	d := NewDownloader(t.TempDir())
+	d.Client = srv.Client()
+	f, err := d.Download(ctx, srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	// Once the server goes away, the cached copy should be used.
+	srv.Close()
+	f, err = d.Download(ctx, srv.URL)
+	if err != nil {
+		t.Fatal("Download after server closed:", err)
+	}
+	defer f.Close()
+	data, err := ioutil.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != content {
+		t.Errorf("content = %q; want %q", data, content)
+	}
+}
+
+func TestDownloadChecksums(t *testing.T) {
+	const content = "Hello, World!\n"
+	const contentSHA256 = "c98c24b677eff44860afea6f493bbaec5bb1c4cbb209c6fc2bbb47f66ff2ad31"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(headers.ContentLength, fmt.Sprint(len(content)))
+		io.WriteString(w, content)
+	}))
+	t.Cleanup(srv.Close)
+	ctx := testlog.WithTB(context.Background(), t)
+
+	t.Run("Match", func(t *testing.T) {
+		d := NewDownloader(t.TempDir())
+		d.Client = srv.Client()
+		d.Checksums = map[string]string{srv.URL: contentSHA256}
+		var reported []string
+		d.OnDownload = func(url, sum string) {
+			reported = append(reported, url+" "+sum)
+		}
+		for i := 0; i < 2; i++ {
+			f, err := d.Download(ctx, srv.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, err := ioutil.ReadAll(f)
+			f.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != content {
+				t.Errorf("content = %q; want %q", data, content)
+			}
+		}
+		want := srv.URL + " " + contentSHA256
+		if len(reported) != 2 || reported[0] != want || reported[1] != want {
+			t.Errorf("OnDownload calls = %q; want [%q %q]", reported, want, want)
+		}
+	})
+	t.Run("Mismatch", func(t *testing.T) {
+		dir := t.TempDir()
+		d := NewDownloader(dir)
+		d.Client = srv.Client()
+		d.Checksums = map[string]string{srv.URL: strings.Repeat("0", 64)}
+		f, err := d.Download(ctx, srv.URL)
+		if err == nil {
+			f.Close()
+			t.Fatal("Download did not return an error")
+		}
+		t.Logf("Download: %v", err)
+		files, err := ioutil.ReadDir(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(files) > 0 {
+			t.Errorf("download left %s on disk", files)
+		}
+	})
+	t.Run("WithChecksum", func(t *testing.T) {
+		d := NewDownloader(t.TempDir())
+		d.Client = srv.Client()
+		f, err := d.Download(WithChecksum(ctx, srv.URL, contentSHA256), srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+		// A different digest applies even to the cached file.
+		f, err = d.Download(WithChecksum(ctx, srv.URL, strings.Repeat("0", 64)), srv.URL)
+		if err == nil {
+			f.Close()
+			t.Error("Download with mismatched WithChecksum did not return an error")
+		}
+		// Digests for other URLs are ignored.
+		f, err = d.Download(WithChecksum(ctx, srv.URL+"/other", strings.Repeat("0", 64)), srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+	})
+}
+
+func TestDownloadConcurrent(t *testing.T) {
+	const content = "Hello, World!\n"
+	const maxConcurrent = 2
+	var mu sync.Mutex
+	inFlight, maxInFlight, gets := 0, 0, 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(headers.ContentLength, fmt.Sprint(len(content)))
+		if r.Method == http.MethodHead {
+			return
+		}
+		mu.Lock()
+		inFlight++
+		gets++
+		if inFlight > maxInFlight {
+			maxInFlight = inFlight
+		}
+		mu.Unlock()
+		time.Sleep(20 * time.Millisecond)
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+		io.WriteString(w, content)
+	}))
+	t.Cleanup(srv.Close)
+	ctx := testlog.WithTB(context.Background(), t)
+	d := NewDownloader(t.TempDir())
+	d.Client = srv.Client()
+	d.MaxConcurrent = maxConcurrent
+
+	// Download each path twice to check that concurrent downloads of the same
+	// URL share a single fetch.
+	paths := []string{"/a", "/b", "/c", "/d", "/e", "/a", "/b", "/c", "/d", "/e"}
+	errs := make([]error, len(paths))
+	var wg sync.WaitGroup
+	for i, path := range paths {
+		wg.Add(1)
+		go func(i int, url string) {
+			defer wg.Done()
+			f, err := d.Download(ctx, url)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			defer f.Close()
+			data, err := ioutil.ReadAll(f)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			if string(data) != content {
+				errs[i] = fmt.Errorf("%s content = %q; want %q", url, data, content)
+			}
+		}(i, srv.URL+path)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+	if maxInFlight > maxConcurrent {
+		t.Errorf("%d downloads in progress at once; want <= %d", maxInFlight, maxConcurrent)
+	}
+	if gets != 5 {
+		t.Errorf("server received %d GET requests; want 5", gets)
+	}
+}
+
+func TestDownloadRetry(t *testing.T) {
+	const content = "Hello, World!\n"
+	var mu sync.Mutex
+	gets := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(headers.ContentLength, fmt.Sprint(len(content)))
+		if r.Method == http.MethodHead {
+			return
+		}
+		mu.Lock()
+		gets++
+		n := gets
+		mu.Unlock()
+		switch n {
+		case 1:
+			w.Header().Del(headers.ContentLength)
+			http.Error(w, "try again", http.StatusServiceUnavailable)
+		case 2:
+			// Drop the connection before sending the whole body.
+			io.WriteString(w, content[:5])
+			w.(http.Flusher).Flush()
+			panic(http.ErrAbortHandler)
+		default:
+			io.WriteString(w, content)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	ctx := testlog.WithTB(context.Background(), t)
+	d := NewDownloader(t.TempDir())
+	d.Client = srv.Client()
+	d.RetryDelay = time.Millisecond
+	f, err := d.Download(ctx, srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	data, err := ioutil.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != content {
+		t.Errorf("content = %q; want %q", data, content)
+	}
+	if gets != 3 {
+		t.Errorf("server received %d GET requests; want 3", gets)
+	}
+
+	t.Run("GiveUp", func(t *testing.T) {
+		gets := 0
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodGet {
+				gets++
+			}
+			http.Error(w, "bork", http.StatusBadGateway)
+		}))
+		t.Cleanup(srv.Close)
+		d := NewDownloader(t.TempDir())
+		d.Client = srv.Client()
+		d.MaxAttempts = 3
+		d.RetryDelay = time.Millisecond
+		f, err := d.Download(ctx, srv.URL)
+		if err == nil {
+			f.Close()
+			t.Fatal("Download did not return an error")
+		}
+		t.Logf("Download: %v", err)
+		if gets != 3 {
+			t.Errorf("server received %d GET requests; want 3", gets)
+		}
+	})
+}
+
+func TestDownloadResume(t *testing.T) {
+	content := strings.Repeat("0123456789", 1000)
+	modTime := time.Date(2020, time.December, 1, 12, 0, 0, 0, time.UTC)
+	var mu sync.Mutex
+	var ranges []string
+	drop := true
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(headers.ContentLength, fmt.Sprint(len(content)))
+		if r.Method == http.MethodHead {
+			return
+		}
+		mu.Lock()
+		ranges = append(ranges, r.Header.Get("Range"))
+		dropThis := drop
+		drop = false
+		mu.Unlock()
+		w.Header().Set("ETag", `"v1"`)
+		if dropThis {
+			io.WriteString(w, content[:4000])
+			w.(http.Flusher).Flush()
+			panic(http.ErrAbortHandler)
+		}
+		http.ServeContent(w, r, "", modTime, strings.NewReader(content))
+	}))
+	t.Cleanup(srv.Close)
+	ctx := testlog.WithTB(context.Background(), t)
+	dir := t.TempDir()
+	d := NewDownloader(dir)
+	d.Client = srv.Client()
+	d.RetryDelay = time.Millisecond
+	f, err := d.Download(ctx, srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	data, err := ioutil.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != content {
+		t.Errorf("content is %d bytes; want %d bytes", len(data), len(content))
+	}
+	if len(ranges) != 2 || ranges[0] != "" || ranges[1] != "bytes=4000-" {
+		t.Errorf("Range headers = %q; want [\"\" \"bytes=4000-\"]", ranges)
+	}
+	partial := filepath.Join(dir, cacheFilenameForURL(srv.URL)+partialSuffix)
+	for _, path := range []string{partial, partial + metaSuffix} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("after download, stat %s: %v; want not exist", path, err)
+		}
+	}
+
+	t.Run("Changed", func(t *testing.T) {
+		// A partial file from an earlier version of the resource must not be
+		// resumed.
+		dir := t.TempDir()
+		cacheFilename := filepath.Join(dir, cacheFilenameForURL(srv.URL))
+		writeTestFile(t, cacheFilename+partialSuffix, 4000)
+		err := writeJSONFile(cacheFilename+partialSuffix+metaSuffix, &downloadMeta{
+			URL:  srv.URL,
+			ETag: `"v0"`,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		d := NewDownloader(dir)
+		d.Client = srv.Client()
+		f, err := d.Download(ctx, srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		data, err := ioutil.ReadAll(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != content {
+			t.Errorf("content does not match resource")
+		}
+	})
+}
+
+func TestDownloadMirrors(t *testing.T) {
+	const content = "Hello, World!\n"
+	serve := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(headers.ContentLength, fmt.Sprint(len(content)))
+		if r.Method == http.MethodGet {
+			io.WriteString(w, content)
+		}
+	}
+	var mu sync.Mutex
+	var originRequests, mirrorRequests []string
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		originRequests = append(originRequests, r.Method+" "+r.URL.Path)
+		mu.Unlock()
+		serve(w, r)
+	}))
+	t.Cleanup(origin.Close)
+	mirror := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		mirrorRequests = append(mirrorRequests, r.Method+" "+r.URL.Path)
+		mu.Unlock()
+		if !strings.HasPrefix(r.URL.Path, "/mirror/dist/") {
+			http.NotFound(w, r)
+			return
+		}
+		serve(w, r)
+	}))
+	t.Cleanup(mirror.Close)
+	ctx := testlog.WithTB(context.Background(), t)
+
+	tests := []struct {
+		name         string
+		path         string
+		fallback     bool
+		wantOrigin   []string
+		wantMirror   []string
+		wantNotFound bool
+	}{
+		{
+			name:       "Mirrored",
+			path:       "/dist/foo.tar.gz",
+			wantMirror: []string{"HEAD /mirror/dist/foo.tar.gz", "GET /mirror/dist/foo.tar.gz"},
+		},
+		{
+			name:       "NotMatched",
+			path:       "/other/foo.tar.gz",
+			wantOrigin: []string{"HEAD /other/foo.tar.gz", "GET /other/foo.tar.gz"},
+		},
+		{
+			name:         "MissingWithoutFallback",
+			path:         "/missing/foo.tar.gz",
+			wantMirror:   []string{"HEAD /mirror/missing/foo.tar.gz"},
+			wantNotFound: true,
+		},
+		{
+			name:       "MissingWithFallback",
+			path:       "/missing/foo.tar.gz",
+			fallback:   true,
+			wantMirror: []string{"HEAD /mirror/missing/foo.tar.gz"},
+			wantOrigin: []string{"HEAD /missing/foo.tar.gz", "GET /missing/foo.tar.gz"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			originRequests, mirrorRequests = nil, nil
+			d := NewDownloader(t.TempDir())
+			d.Client = origin.Client()
+			d.Mirrors = []*yb.Mirror{
+				{From: origin.URL + "/dist/", To: mirror.URL + "/mirror/dist/", Fallback: test.fallback},
+				{From: origin.URL + "/missing/", To: mirror.URL + "/mirror/missing/", Fallback: test.fallback},
+			}
+			var reported []string
+			d.OnDownload = func(url, sum string) {
+				reported = append(reported, url)
+			}
+			u := origin.URL + test.path
+			f, err := d.Download(ctx, u)
+			if err != nil {
+				t.Log("Download:", err)
+				if !test.wantNotFound {
+					t.Fail()
+				}
+				if !IsNotFound(err) {
+					t.Errorf("IsNotFound(err) = false; want true")
+				}
+			} else {
+				f.Close()
+				if test.wantNotFound {
+					t.Error("Download did not return an error")
+				}
+				if len(reported) != 1 || reported[0] != u {
+					t.Errorf("OnDownload URLs = %q; want [%q]", reported, u)
+				}
+			}
+			if diff := cmp.Diff(test.wantOrigin, originRequests, cmpopts.EquateEmpty()); diff != "" {
+				t.Errorf("origin requests (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(test.wantMirror, mirrorRequests, cmpopts.EquateEmpty()); diff != "" {
+				t.Errorf("mirror requests (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	d := NewDownloader(t.TempDir())
+	d.RetryDelay = 1 * time.Second
+	tests := []struct {
+		attempt int
+		err     error
+		want    time.Duration
+	}{
+		{attempt: 1, err: unreachableError{io.EOF}, want: 1 * time.Second},
+		{attempt: 2, err: unreachableError{io.EOF}, want: 2 * time.Second},
+		{attempt: 4, err: unreachableError{io.EOF}, want: 8 * time.Second},
+		{attempt: 20, err: unreachableError{io.EOF}, want: maxRetryDelay},
+		{attempt: 1, err: httpError{statusCode: 503, retryAfter: 5 * time.Second}, want: 5 * time.Second},
+		{attempt: 1, err: httpError{statusCode: 503, retryAfter: time.Hour}, want: maxRetryDelay},
+	}
+	for _, test := range tests {
+		if got := d.retryDelay(test.attempt, test.err); got != test.want {
+			t.Errorf("retryDelay(%d, %v) = %v; want %v", test.attempt, test.err, got, test.want)
+		}
+	}
+}
+
+func TestTransferStatus(t *testing.T) {
+	start := time.Date(2020, time.December, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		t    *transfer
+		now  time.Time
+		want string
+	}{
+		{
+			name: "Known",
+			t:    &transfer{start: start, total: 10 << 20, n: 2 << 20},
+			now:  start.Add(2 * time.Second),
+			want: "2.0 MiB / 10.0 MiB (20%), 1.0 MiB/s, 8s left",
+		},
+		{
+			name: "Resumed",
+			t:    &transfer{start: start, offset: 4 << 20, total: 10 << 20, n: 6 << 20},
+			now:  start.Add(1 * time.Second),
+			want: "6.0 MiB / 10.0 MiB (60%), 2.0 MiB/s, 2s left",
+		},
+		{
+			name: "UnknownSize",
+			t:    &transfer{start: start, total: -1, n: 1536},
+			now:  start.Add(1 * time.Second),
+			want: "1.5 KiB, 1.5 KiB/s",
+		},
+	}
+	for _, test := range tests {
+		if got := test.t.status(test.now); got != test.want {
+			t.Errorf("%s: status = %q; want %q", test.name, got, test.want)
+		}
+	}
+}
+
 func TestValidateDownloadCache(t *testing.T) {
 	tests := []struct {
 		name         string
@@ -0,0 +1,83 @@
+// Copyright 2020 YourBase Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package ybdata
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// cacheLockName is the name of the file in the cache directory that yb
+// processes lock while they use or trim the cache.
+const cacheLockName = ".lock"
+
+// ErrCacheInUse is returned by TryLockCacheForTrim when another process is
+// using the cache.
+var ErrCacheInUse = errors.New("cache in use by another yb process")
+
+// A CacheLock is an advisory lock on the cache directory.
+type CacheLock struct {
+	f *os.File
+}
+
+// LockCache takes a shared lock on the cache, waiting for any process that is
+// trimming it to finish. Commands that use downloads or build homes hold the
+// lock while they run so that other processes don't trim the cache from under
+// them.
+func (dirs *Dirs) LockCache() (*CacheLock, error) {
+	return dirs.lockCache(false, true)
+}
+
+// TryLockCacheForTrim takes an exclusive lock on the cache without waiting.
+// It returns ErrCacheInUse if another process holds a lock on the cache.
+func (dirs *Dirs) TryLockCacheForTrim() (*CacheLock, error) {
+	return dirs.lockCache(true, false)
+}
+
+func (dirs *Dirs) lockCache(exclusive, wait bool) (*CacheLock, error) {
+	if err := os.MkdirAll(dirs.cache, 0o777); err != nil {
+		return nil, fmt.Errorf("lock cache: %w", err)
+	}
+	f, err := os.OpenFile(filepath.Join(dirs.cache, cacheLockName), os.O_RDWR|os.O_CREATE, 0o666)
+	if err != nil {
+		return nil, fmt.Errorf("lock cache: %w", err)
+	}
+	if err := lockFile(f, exclusive, wait); err != nil {
+		f.Close()
+		if errors.Is(err, ErrCacheInUse) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("lock cache: %w", err)
+	}
+	return &CacheLock{f: f}, nil
+}
+
+// Unlock releases the lock. Calling Unlock more than once is a no-op, so it is
+// safe to defer Unlock after releasing the lock early.
+func (l *CacheLock) Unlock() error {
+	if l == nil || l.f == nil {
+		return nil
+	}
+	err := l.f.Close()
+	l.f = nil
+	if err != nil {
+		return fmt.Errorf("unlock cache: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,206 @@
+// Copyright 2020 YourBase Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package ybdata
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"zombiezen.com/go/log"
+)
+
+const (
+	// progressDrawInterval is how often the progress line is redrawn when
+	// Downloader.Progress is set.
+	progressDrawInterval = 250 * time.Millisecond
+	// progressLogInterval is how often progress is logged when
+	// Downloader.Progress is not set.
+	progressLogInterval = 10 * time.Second
+)
+
+// A transfer tracks the progress of a single fetch.
+type transfer struct {
+	url   string
+	start time.Time
+	// offset is the number of bytes already on disk when the fetch started.
+	offset int64
+	// total is the expected size of the file in bytes or -1 if unknown.
+	total int64
+	// n is the number of bytes on disk. It is accessed atomically.
+	n int64
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// startTransfer begins reporting progress for a fetch. The caller must call
+// finishTransfer when the fetch ends.
+func (d *Downloader) startTransfer(ctx context.Context, url string, offset, total int64) *transfer {
+	t := &transfer{
+		url:    url,
+		start:  time.Now(),
+		offset: offset,
+		total:  total,
+		n:      offset,
+	}
+	if d.Progress == nil {
+		t.stop = make(chan struct{})
+		t.done = make(chan struct{})
+		go func() {
+			defer close(t.done)
+			ticker := time.NewTicker(progressLogInterval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ticker.C:
+					log.Infof(ctx, "Downloading %s: %s", t.url, t.status(time.Now()))
+				case <-t.stop:
+					return
+				}
+			}
+		}()
+		return t
+	}
+
+	d.progressMu.Lock()
+	defer d.progressMu.Unlock()
+	d.transfers = append(d.transfers, t)
+	if d.drawStop == nil {
+		stop := make(chan struct{})
+		done := make(chan struct{})
+		d.drawStop, d.drawDone = stop, done
+		go d.drawProgress(stop, done)
+	}
+	return t
+}
+
+// finishTransfer stops reporting progress for t.
+func (d *Downloader) finishTransfer(t *transfer) {
+	if t.stop != nil {
+		close(t.stop)
+		<-t.done
+		return
+	}
+
+	d.progressMu.Lock()
+	for i := range d.transfers {
+		if d.transfers[i] == t {
+			d.transfers = append(d.transfers[:i], d.transfers[i+1:]...)
+			break
+		}
+	}
+	var stop, done chan struct{}
+	if len(d.transfers) == 0 {
+		stop, done = d.drawStop, d.drawDone
+		d.drawStop, d.drawDone = nil, nil
+	}
+	d.progressMu.Unlock()
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+}
+
+// drawProgress periodically redraws a status line for the transfers in
+// progress to d.Progress until stop is closed. It erases the line before
+// closing done.
+func (d *Downloader) drawProgress(stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(progressDrawInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			d.progressMu.Lock()
+			var line string
+			if len(d.transfers) > 0 {
+				t := d.transfers[0]
+				line = fmt.Sprintf("Downloading %s: %s", displayName(t.url), t.status(now))
+				if len(d.transfers) > 1 {
+					line += fmt.Sprintf(" (+%d more)", len(d.transfers)-1)
+				}
+			}
+			d.progressMu.Unlock()
+			// Return the cursor to the start of the line so that other output
+			// overwrites the status line.
+			io.WriteString(d.Progress, "\r\x1b[K"+line+"\r")
+		case <-stop:
+			io.WriteString(d.Progress, "\r\x1b[K")
+			return
+		}
+	}
+}
+
+// Write records that len(p) bytes were written to disk.
+func (t *transfer) Write(p []byte) (int, error) {
+	atomic.AddInt64(&t.n, int64(len(p)))
+	return len(p), nil
+}
+
+// status formats the transfer's size, rate, and estimated time remaining.
+func (t *transfer) status(now time.Time) string {
+	n := atomic.LoadInt64(&t.n)
+	sb := new(strings.Builder)
+	sb.WriteString(formatBytes(n))
+	if t.total >= 0 {
+		fmt.Fprintf(sb, " / %s", formatBytes(t.total))
+		if t.total > 0 {
+			fmt.Fprintf(sb, " (%d%%)", n*100/t.total)
+		}
+	}
+	elapsed := now.Sub(t.start)
+	if elapsed <= 0 {
+		return sb.String()
+	}
+	rate := float64(n-t.offset) / elapsed.Seconds()
+	fmt.Fprintf(sb, ", %s/s", formatBytes(int64(rate)))
+	if t.total >= 0 && rate > 0 {
+		remaining := time.Duration(float64(t.total-n) / rate * float64(time.Second))
+		fmt.Fprintf(sb, ", %v left", remaining.Round(time.Second))
+	}
+	return sb.String()
+}
+
+// displayName returns the last element of a URL's path, or the URL itself if
+// it does not have a path.
+func displayName(rawurl string) string {
+	u, err := url.Parse(rawurl)
+	if err != nil || strings.Trim(u.Path, "/") == "" {
+		return rawurl
+	}
+	return path.Base(u.Path)
+}
+
+// formatBytes formats a number of bytes for display.
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,412 @@
+// Copyright 2020 YourBase Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package ybdata
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"zombiezen.com/go/log"
+)
+
+// remoteCacheURLHeader is the request header a Downloader uses to tell a
+// remote cache the original URL of a file it stores.
+const remoteCacheURLHeader = "Yb-Url"
+
+// remoteCacheURL returns the URL of the entry with the given hex-encoded
+// SHA-256 digest in the remote cache.
+func (d *Downloader) remoteCacheURL(sum string) string {
+	return strings.TrimSuffix(d.RemoteCache, "/") + "/" + strings.ToLower(sum)
+}
+
+// remoteCacheClient returns the HTTP client to use for the remote cache.
+func (d *Downloader) remoteCacheClient() *http.Client {
+	if d.RemoteCacheClient != nil {
+		return d.RemoteCacheClient
+	}
+	return d.Client
+}
+
+// newRemoteCacheClient returns an HTTP client that gives up quickly on a
+// remote cache that can't be reached, so that Download can fall back to the
+// origin. Transfers themselves are not limited, since files can be large.
+func newRemoteCacheClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout:   remoteCacheTimeout,
+		KeepAlive: 30 * time.Second,
+	}
+	return &http.Client{
+		Transport: &http.Transport{
+			Proxy:                 http.ProxyFromEnvironment,
+			DialContext:           dialer.DialContext,
+			TLSHandshakeTimeout:   remoteCacheTimeout,
+			ResponseHeaderTimeout: remoteCacheTimeout,
+			IdleConnTimeout:       90 * time.Second,
+			MaxIdleConns:          100,
+			ForceAttemptHTTP2:     true,
+		},
+	}
+}
+
+// remoteCacheTimeout is how long a Downloader waits to connect to the remote
+// cache and for the start of its response.
+const remoteCacheTimeout = 10 * time.Second
+
+// fetchRemoteCache downloads the file with the given hex-encoded SHA-256
+// digest from the remote cache to cacheFilename. url is only used for
+// messages. The content is checked against want, so an entry modified on the
+// server is never used.
+func (d *Downloader) fetchRemoteCache(ctx context.Context, cacheFilename string, url string, want string) (err error) {
+	release, err := d.acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, d.remoteCacheURL(want), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := d.remoteCacheClient().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return httpError{
+			status:     resp.Status,
+			statusCode: resp.StatusCode,
+		}
+	}
+	log.Infof(ctx, "Downloading %s from remote cache", url)
+	f, err := ioutil.TempFile(filepath.Dir(cacheFilename), ".remote*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}()
+	h := sha256.New()
+	t := d.startTransfer(ctx, url, 0, resp.ContentLength)
+	n, err := io.Copy(io.MultiWriter(f, h, t), resp.Body)
+	d.finishTransfer(t)
+	if err != nil {
+		return err
+	}
+	if resp.ContentLength >= 0 && n != resp.ContentLength {
+		return fmt.Errorf("received %d bytes; want %d", n, resp.ContentLength)
+	}
+	if got := hex.EncodeToString(h.Sum(nil)); !strings.EqualFold(got, want) {
+		return fmt.Errorf("sha256 %s does not match expected %s", got, want)
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), cacheFilename)
+}
+
+// storeRemoteCache uploads the content of f, downloaded from url, to the
+// remote cache under its hex-encoded SHA-256 digest. f is left positioned at
+// its beginning.
+func (d *Downloader) storeRemoteCache(ctx context.Context, f *os.File, url string, sum string) error {
+	defer f.Seek(0, io.SeekStart)
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, d.remoteCacheURL(sum), ioutil.NopCloser(f))
+	if err != nil {
+		return err
+	}
+	req.ContentLength = info.Size()
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set(remoteCacheURLHeader, url)
+	resp, err := d.remoteCacheClient().Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return httpError{
+			status:     resp.Status,
+			statusCode: resp.StatusCode,
+		}
+	}
+	return nil
+}
+
+// NewCacheServer returns an HTTP handler that serves a remote download cache
+// from the given directory. Files are addressed by the hex-encoded SHA-256
+// digest of their content: a GET or HEAD request for /DIGEST returns the file
+// with that digest, and a PUT request for /DIGEST stores a file, which is
+// rejected if its content does not match. The directory may be a Downloader's
+// cache directory, in which case the files it downloaded are served too.
+// Uploaded files are stored in the directory's "sha256" subdirectory so that
+// they never replace the Downloader's files, which are named by their URL.
+func NewCacheServer(dir string) http.Handler {
+	return &cacheServer{
+		dir:   dir,
+		index: make(map[string]string),
+		seen:  make(map[string]time.Time),
+	}
+}
+
+// cacheServerUploadsDir is the subdirectory of a cache server's directory
+// that holds uploaded files.
+const cacheServerUploadsDir = "sha256"
+
+type cacheServer struct {
+	dir string
+
+	mu sync.Mutex
+	// index maps digests to the paths of complete files downloaded by a
+	// Downloader sharing dir.
+	index map[string]string
+	// seen maps the names of the metadata files in dir to their modification
+	// times when they were last read into index.
+	seen map[string]time.Time
+}
+
+func (srv *cacheServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	key := strings.TrimPrefix(r.URL.Path, "/")
+	if !isCacheKey(key) {
+		http.NotFound(w, r)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		srv.get(w, r, key)
+	case http.MethodPut:
+		srv.put(w, r, key)
+	default:
+		w.Header().Set("Allow", "GET, HEAD, PUT")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (srv *cacheServer) get(w http.ResponseWriter, r *http.Request, key string) {
+	ctx := r.Context()
+	path, meta, err := srv.find(key)
+	if err != nil {
+		log.Warnf(ctx, "Serve %s: %v", r.URL.Path, err)
+	}
+	if meta == nil {
+		http.NotFound(w, r)
+		return
+	}
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		log.Errorf(ctx, "Serve %s: %v", r.URL.Path, err)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		log.Errorf(ctx, "Serve %s: %v", r.URL.Path, err)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	if info.Size() != meta.Size {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method == http.MethodGet {
+		meta.LastUsed = time.Now().UTC()
+		if err := writeJSONFile(path+metaSuffix, meta); err != nil {
+			log.Warnf(ctx, "Serve %s: %v", r.URL.Path, err)
+		}
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	http.ServeContent(w, r, "", info.ModTime(), f)
+}
+
+func (srv *cacheServer) put(w http.ResponseWriter, r *http.Request, key string) {
+	ctx := r.Context()
+	dir := filepath.Join(srv.dir, cacheServerUploadsDir)
+	path := filepath.Join(dir, key)
+	if _, meta, _ := srv.find(key); meta != nil {
+		// Another client already stored the file.
+		io.Copy(ioutil.Discard, r.Body)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if err := os.MkdirAll(dir, 0o777); err != nil {
+		log.Errorf(ctx, "Store %s: %v", r.URL.Path, err)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	f, err := ioutil.TempFile(dir, ".upload*")
+	if err != nil {
+		log.Errorf(ctx, "Store %s: %v", r.URL.Path, err)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(f, h), r.Body)
+	closeErr := f.Close()
+	if err == nil && r.ContentLength >= 0 && n != r.ContentLength {
+		err = fmt.Errorf("received %d bytes; want %d", n, r.ContentLength)
+	}
+	sum := hex.EncodeToString(h.Sum(nil))
+	if err == nil && sum != key {
+		err = fmt.Errorf("content has sha256 %s", sum)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		log.Warnf(ctx, "Store %s: %v", r.URL.Path, err)
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	if closeErr != nil {
+		os.Remove(f.Name())
+		log.Errorf(ctx, "Store %s: %v", r.URL.Path, closeErr)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		os.Remove(f.Name())
+		log.Errorf(ctx, "Store %s: %v", r.URL.Path, err)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	now := time.Now().UTC()
+	meta := &downloadMeta{
+		Size:      n,
+		SHA256:    sum,
+		URL:       r.Header.Get(remoteCacheURLHeader),
+		FetchedAt: now,
+		LastUsed:  now,
+	}
+	if err := writeJSONFile(path+metaSuffix, meta); err != nil {
+		os.Remove(path)
+		log.Errorf(ctx, "Store %s: %v", r.URL.Path, err)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	log.Infof(ctx, "Stored %s (%s)", r.URL.Path, formatBytes(n))
+	w.WriteHeader(http.StatusCreated)
+}
+
+// find returns the path and metadata of a complete file in the server's
+// directory whose content has the given digest, or a nil meta if there is
+// none. Uploaded files are named by their digest. Files downloaded by a
+// Downloader sharing the directory are named by their URL, so they are looked
+// up in the index.
+func (srv *cacheServer) find(key string) (path string, _ *downloadMeta, err error) {
+	path = filepath.Join(srv.dir, cacheServerUploadsDir, key)
+	// Only complete files have metadata. A Downloader sharing the directory
+	// may be writing to a file without it.
+	meta := new(downloadMeta)
+	if err := readJSONFile(path+metaSuffix, meta); err == nil && meta.SHA256 == key {
+		return path, meta, nil
+	} else if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return "", nil, err
+	}
+
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if err := srv.updateIndex(); err != nil {
+		return "", nil, err
+	}
+	path, ok := srv.index[key]
+	if !ok {
+		return "", nil, nil
+	}
+	meta = new(downloadMeta)
+	if err := readJSONFile(path+metaSuffix, meta); err != nil || meta.SHA256 != key {
+		// The file was removed or downloaded again with different content.
+		delete(srv.index, key)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return "", nil, err
+		}
+		return "", nil, nil
+	}
+	return path, meta, nil
+}
+
+// updateIndex adds the files described by metadata files in the server's
+// directory that were created or changed since the last call to the index.
+// srv.mu must be held.
+func (srv *cacheServer) updateIndex() error {
+	infos, err := ioutil.ReadDir(srv.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	present := make(map[string]struct{}, len(infos))
+	for _, info := range infos {
+		name := info.Name()
+		if !info.Mode().IsRegular() || !strings.HasSuffix(name, metaSuffix) || strings.HasPrefix(name, ".") {
+			continue
+		}
+		present[name] = struct{}{}
+		if t, ok := srv.seen[name]; ok && t.Equal(info.ModTime()) {
+			continue
+		}
+		srv.seen[name] = info.ModTime()
+		meta := new(downloadMeta)
+		if err := readJSONFile(filepath.Join(srv.dir, name), meta); err != nil || !isCacheKey(meta.SHA256) {
+			continue
+		}
+		srv.index[meta.SHA256] = filepath.Join(srv.dir, strings.TrimSuffix(name, metaSuffix))
+	}
+	for name := range srv.seen {
+		if _, ok := present[name]; !ok {
+			delete(srv.seen, name)
+		}
+	}
+	return nil
+}
+
+// isCacheKey reports whether s has the form of a lowercase hex-encoded SHA-256
+// digest.
+func isCacheKey(s string) bool {
+	if len(s) != 64 {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if !('0' <= s[i] && s[i] <= '9' || 'a' <= s[i] && s[i] <= 'f') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,262 @@
+// Copyright 2020 YourBase Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package ybdata
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/yourbase/commons/http/headers"
+	"zombiezen.com/go/log/testlog"
+)
+
+func TestCacheServer(t *testing.T) {
+	dir := t.TempDir()
+	srv := httptest.NewServer(NewCacheServer(dir))
+	t.Cleanup(srv.Close)
+	client := srv.Client()
+	const content = "Hello, World!\n"
+	const origURL = "https://example.com/hello.txt"
+	key := fmt.Sprintf("%x", sha256.Sum256([]byte(content)))
+
+	do := func(method, path string, body string) (int, string) {
+		t.Helper()
+		req, err := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set(remoteCacheURLHeader, origURL)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		got, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp.StatusCode, string(got)
+	}
+
+	if code, _ := do(http.MethodGet, "/"+key, ""); code != http.StatusNotFound {
+		t.Errorf("GET before PUT = %d; want %d", code, http.StatusNotFound)
+	}
+	if code, _ := do(http.MethodPut, "/"+key, "something else"); code != http.StatusBadRequest {
+		t.Errorf("PUT with mismatched content = %d; want %d", code, http.StatusBadRequest)
+	}
+	if code, _ := do(http.MethodPut, "/"+key, content); code != http.StatusCreated {
+		t.Errorf("PUT = %d; want %d", code, http.StatusCreated)
+	}
+	if code, _ := do(http.MethodPut, "/"+key, content); code != http.StatusNoContent {
+		t.Errorf("second PUT = %d; want %d", code, http.StatusNoContent)
+	}
+	if code, got := do(http.MethodGet, "/"+key, ""); code != http.StatusOK || got != content {
+		t.Errorf("GET = %d %q; want %d %q", code, got, http.StatusOK, content)
+	}
+	for _, path := range []string{"/", "/../" + key, "/" + strings.ToUpper(key), "/" + key + metaSuffix} {
+		if code, _ := do(http.MethodPut, path, content); code != http.StatusNotFound {
+			t.Errorf("PUT %s = %d; want %d", path, code, http.StatusNotFound)
+		}
+	}
+	if code, _ := do(http.MethodDelete, "/"+key, ""); code != http.StatusMethodNotAllowed {
+		t.Errorf("DELETE = %d; want %d", code, http.StatusMethodNotAllowed)
+	}
+
+	t.Run("DownloaderFile", func(t *testing.T) {
+		// A file in a Downloader's cache is named by its URL.
+		const content = "Downloaded\n"
+		sum := fmt.Sprintf("%x", sha256.Sum256([]byte(content)))
+		path := filepath.Join(dir, cacheFilenameForURL("https://example.com/downloaded.txt"))
+		if err := ioutil.WriteFile(path, []byte(content), 0o666); err != nil {
+			t.Fatal(err)
+		}
+		err := writeJSONFile(path+metaSuffix, &downloadMeta{
+			URL:    "https://example.com/downloaded.txt",
+			Size:   int64(len(content)),
+			SHA256: sum,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if code, got := do(http.MethodGet, "/"+sum, ""); code != http.StatusOK || got != content {
+			t.Errorf("GET = %d %q; want %d %q", code, got, http.StatusOK, content)
+		}
+	})
+
+	t.Run("UploadNamedLikeDownload", func(t *testing.T) {
+		// The Downloader names files by the SHA-256 of their URL, so a client
+		// can upload the URL itself under that name.
+		const url = "https://example.com/victim.txt"
+		const content = "Downloaded\n"
+		path := filepath.Join(dir, cacheFilenameForURL(url))
+		if err := ioutil.WriteFile(path, []byte(content), 0o666); err != nil {
+			t.Fatal(err)
+		}
+		meta := &downloadMeta{
+			URL:    url,
+			Size:   int64(len(content)),
+			SHA256: fmt.Sprintf("%x", sha256.Sum256([]byte(content))),
+		}
+		if err := writeJSONFile(path+metaSuffix, meta); err != nil {
+			t.Fatal(err)
+		}
+		if code, _ := do(http.MethodPut, "/"+cacheFilenameForURL(url), url); code != http.StatusCreated {
+			t.Errorf("PUT = %d; want %d", code, http.StatusCreated)
+		}
+		if got, err := ioutil.ReadFile(path); err != nil {
+			t.Error(err)
+		} else if string(got) != content {
+			t.Errorf("download cache file = %q after PUT; want %q", got, content)
+		}
+		gotMeta := new(downloadMeta)
+		if err := readJSONFile(path+metaSuffix, gotMeta); err != nil {
+			t.Error(err)
+		} else if gotMeta.URL != url || gotMeta.SHA256 != meta.SHA256 {
+			t.Errorf("download cache metadata = %+v after PUT; want %+v", gotMeta, meta)
+		}
+	})
+}
+
+func TestDownloadRemoteCache(t *testing.T) {
+	const content = "Hello, World!\n"
+	const contentSHA256 = "c98c24b677eff44860afea6f493bbaec5bb1c4cbb209c6fc2bbb47f66ff2ad31"
+	var mu sync.Mutex
+	var originRequests []string
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		originRequests = append(originRequests, r.Method+" "+r.URL.Path)
+		mu.Unlock()
+		w.Header().Set(headers.ContentLength, fmt.Sprint(len(content)))
+		if r.Method == http.MethodGet {
+			io.WriteString(w, content)
+		}
+	}))
+	t.Cleanup(origin.Close)
+	remote := httptest.NewServer(NewCacheServer(t.TempDir()))
+	t.Cleanup(remote.Close)
+	ctx := testlog.WithTB(context.Background(), t)
+	newDownloader := func(remoteCache string) *Downloader {
+		d := NewDownloader(t.TempDir())
+		d.Client = origin.Client()
+		d.RemoteCache = remoteCache
+		return d
+	}
+	download := func(ctx context.Context, d *Downloader, path string) {
+		t.Helper()
+		f, err := d.Download(ctx, origin.URL+path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		got, err := ioutil.ReadAll(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != content {
+			t.Errorf("content = %q; want %q", got, content)
+		}
+	}
+	originGets := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		n := 0
+		for _, req := range originRequests {
+			if strings.HasPrefix(req, http.MethodGet+" ") {
+				n++
+			}
+		}
+		originRequests = nil
+		return n
+	}
+
+	t.Run("Shared", func(t *testing.T) {
+		download(ctx, newDownloader(remote.URL), "/shared.txt")
+		if n := originGets(); n != 1 {
+			t.Errorf("first download made %d GET requests to origin; want 1", n)
+		}
+		d := newDownloader(remote.URL)
+		d.Checksums = map[string]string{origin.URL + "/shared.txt": contentSHA256}
+		download(ctx, d, "/shared.txt")
+		if n := originGets(); n != 0 {
+			t.Errorf("second download made %d GET requests to origin; want 0", n)
+		}
+	})
+
+	t.Run("WithChecksum", func(t *testing.T) {
+		download(ctx, newDownloader(remote.URL), "/upstream.txt")
+		if n := originGets(); n != 1 {
+			t.Errorf("first download made %d GET requests to origin; want 1", n)
+		}
+		download(WithChecksum(ctx, origin.URL+"/upstream.txt", contentSHA256), newDownloader(remote.URL), "/upstream.txt")
+		if n := originGets(); n != 0 {
+			t.Errorf("second download made %d GET requests to origin; want 0", n)
+		}
+	})
+
+	t.Run("UnknownChecksum", func(t *testing.T) {
+		// Without a known digest, the remote cache can't vouch for the file.
+		download(ctx, newDownloader(remote.URL), "/unchecked.txt")
+		download(ctx, newDownloader(remote.URL), "/unchecked.txt")
+		if n := originGets(); n != 2 {
+			t.Errorf("downloads made %d GET requests to origin; want 2", n)
+		}
+	})
+
+	t.Run("Unpinned", func(t *testing.T) {
+		download(Unpinned(ctx), newDownloader(remote.URL), "/index.json")
+		download(Unpinned(ctx), newDownloader(remote.URL), "/index.json")
+		if n := originGets(); n != 2 {
+			t.Errorf("downloads made %d GET requests to origin; want 2", n)
+		}
+	})
+
+	t.Run("Unreachable", func(t *testing.T) {
+		down := httptest.NewServer(http.NotFoundHandler())
+		down.Close()
+		download(ctx, newDownloader(down.URL), "/fallback.txt")
+		if n := originGets(); n != 1 {
+			t.Errorf("download made %d GET requests to origin; want 1", n)
+		}
+	})
+
+	t.Run("BadChecksum", func(t *testing.T) {
+		const path = "/checked.txt"
+		bad := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodGet {
+				io.WriteString(w, "corrupted\n")
+			}
+		}))
+		t.Cleanup(bad.Close)
+		d := newDownloader(bad.URL)
+		d.Checksums = map[string]string{
+			origin.URL + path: contentSHA256,
+		}
+		download(ctx, d, path)
+		if n := originGets(); n != 1 {
+			t.Errorf("download made %d GET requests to origin; want 1", n)
+		}
+	})
+}
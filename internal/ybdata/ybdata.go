@@ -24,6 +24,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/yourbase/yb/internal/biome"
 	"go4.org/xdgdir"
@@ -71,12 +72,24 @@ func (dirs *Dirs) Downloads() string {
 	return filepath.Join(dirs.cache, "downloads")
 }
 
-// BuildHome finds or creates a directory to store cached data for a target.
+// BuildHome finds or creates a directory to store cached data for a target
+// and records that the directory was used.
 func (dirs *Dirs) BuildHome(packageDir, target string, desc *biome.Descriptor) (string, error) {
 	path := dirs.FindBuildHome(packageDir, target, desc)
 	if err := os.MkdirAll(path, 0777); err != nil {
 		return "", fmt.Errorf("create build home: %w", err)
 	}
+	// Record the use for cache eviction.
+	err := writeJSONFile(path+metaSuffix, &buildHomeMeta{
+		PackageDir: packageDir,
+		Target:     target,
+		OS:         desc.OS,
+		Arch:       desc.Arch,
+		LastUsed:   time.Now().UTC(),
+	})
+	if err != nil {
+		return "", fmt.Errorf("create build home: %w", err)
+	}
 	return path, nil
 }
 
@@ -94,3 +107,37 @@ func (dirs *Dirs) BuildHomeRoot(packageDir string) string {
 	workspaceHash := hex.EncodeToString(h[:hex.DecodedLen(12)])
 	return filepath.Join(dirs.workspaces, workspaceHash)
 }
+
+// BuildHomes returns the build home directories for all targets in a package.
+func (dirs *Dirs) BuildHomes(packageDir string) ([]string, error) {
+	return findBuildHomes(dirs.BuildHomeRoot(packageDir))
+}
+
+// AllBuildHomes returns the build home directories for all targets in all
+// packages.
+func (dirs *Dirs) AllBuildHomes() ([]string, error) {
+	return findBuildHomes(filepath.Join(dirs.workspaces, "*"))
+}
+
+// findBuildHomes returns the directories matching root/TARGET/OS/ARCH.
+// root may be a glob pattern.
+func findBuildHomes(root string) ([]string, error) {
+	matches, err := filepath.Glob(filepath.Join(root, "*", "*", "*"))
+	if err != nil {
+		return nil, fmt.Errorf("find build homes: %w", err)
+	}
+	homes := matches[:0]
+	for _, m := range matches {
+		if info, err := os.Stat(m); err == nil && info.IsDir() {
+			homes = append(homes, m)
+		}
+	}
+	return homes, nil
+}
+
+// BuildHomeTools returns the directory inside a build home where buildpacks
+// are installed. It is the host path of biome.Dirs.Tools for a biome that uses
+// the build home as its home directory.
+func BuildHomeTools(home string) string {
+	return filepath.Join(home, ".cache", "yb", "tools")
+}
@@ -0,0 +1,231 @@
+// Copyright 2020 YourBase Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package yb
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/yourbase/narwhal"
+	"gopkg.in/yaml.v2"
+)
+
+// LockFilename is the name of the file next to the package configuration that
+// records the resolved dependencies of each target.
+const LockFilename = ".yourbase.lock"
+
+// lockVersion is the version of the lock file format written by Encode.
+const lockVersion = 1
+
+// Lock is a parsed lock file (.yourbase.lock). It pins the buildpack
+// versions, downloaded files, and container images used by a package's
+// targets so that builds are reproducible.
+type Lock struct {
+	// Targets is the set of locked targets, keyed by target name.
+	Targets map[string]*LockedTarget
+}
+
+// LockedTarget records the resolved dependencies of a single target.
+type LockedTarget struct {
+	// Buildpacks maps buildpack names to the specifier in the configuration
+	// and the version it resolved to.
+	Buildpacks map[string]*LockedBuildpack `yaml:"buildpacks,omitempty"`
+	// Downloads maps URLs fetched while setting up the target to the
+	// hex-encoded SHA-256 digest of their content.
+	Downloads map[string]string `yaml:"downloads,omitempty"`
+	// Container is the target's build container image, if the target builds
+	// inside a container.
+	Container *LockedImage `yaml:"container,omitempty"`
+	// Resources maps the names of the target's dependency containers to their
+	// images.
+	Resources map[string]*LockedImage `yaml:"resources,omitempty"`
+}
+
+// LockedBuildpack records the resolution of a buildpack specifier.
+type LockedBuildpack struct {
+	// Spec is the specifier as written in the configuration.
+	Spec BuildpackSpec `yaml:"spec"`
+	// Resolved is the specifier with a concrete version.
+	Resolved BuildpackSpec `yaml:"resolved"`
+}
+
+// LockedImage records the digest of a Docker image.
+type LockedImage struct {
+	// Image is the image reference as written in the configuration.
+	Image string `yaml:"image"`
+	// Digest is a reference to the image by content, like
+	// "postgres@sha256:...". It is empty if the image has no registry digest,
+	// as is the case for images that were built locally.
+	Digest string `yaml:"digest,omitempty"`
+}
+
+type lockFile struct {
+	Version int                      `yaml:"version"`
+	Targets map[string]*LockedTarget `yaml:"targets"`
+}
+
+// LoadLock reads a lock file. If the file does not exist, the returned error
+// will satisfy errors.Is(err, os.ErrNotExist).
+func LoadLock(path string) (*Lock, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("load lock: %w", err)
+	}
+	lock, err := parseLock(data)
+	if err != nil {
+		return nil, fmt.Errorf("load lock %s: %w", path, err)
+	}
+	return lock, nil
+}
+
+func parseLock(data []byte) (*Lock, error) {
+	var f lockFile
+	if err := yaml.UnmarshalStrict(data, &f); err != nil {
+		return nil, err
+	}
+	if f.Version != lockVersion {
+		return nil, fmt.Errorf("unsupported version %d (this version of yb supports %d)", f.Version, lockVersion)
+	}
+	lock := &Lock{Targets: f.Targets}
+	if lock.Targets == nil {
+		lock.Targets = make(map[string]*LockedTarget)
+	}
+	for name, lt := range lock.Targets {
+		if lt == nil {
+			lock.Targets[name] = new(LockedTarget)
+		}
+	}
+	return lock, nil
+}
+
+// Encode returns the YAML serialization of the lock file. Keys are sorted so
+// that the output is stable.
+func (lock *Lock) Encode() ([]byte, error) {
+	data, err := yaml.Marshal(&lockFile{
+		Version: lockVersion,
+		Targets: lock.Targets,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("encode lock: %w", err)
+	}
+	const header = "# This file is generated by yb lock. Do not edit.\n"
+	return append([]byte(header), data...), nil
+}
+
+// Checksums returns the locked SHA-256 digests of all downloads, keyed by URL.
+func (lock *Lock) Checksums() map[string]string {
+	sums := make(map[string]string)
+	for _, lt := range lock.Targets {
+		for url, sum := range lt.Downloads {
+			sums[url] = sum
+		}
+	}
+	return sums
+}
+
+// Check returns an error describing how the target's configuration has drifted
+// from what was locked, or nil if the lock is up-to-date.
+func (lt *LockedTarget) Check(target *Target) error {
+	var problems []string
+	for name, spec := range target.Buildpacks {
+		switch locked := lt.Buildpacks[name]; {
+		case locked == nil:
+			problems = append(problems, fmt.Sprintf("buildpack %s is not locked", spec))
+		case locked.Spec != spec:
+			problems = append(problems, fmt.Sprintf("buildpack %s was locked as %s", spec, locked.Spec))
+		}
+	}
+	for name := range lt.Buildpacks {
+		if _, ok := target.Buildpacks[name]; !ok {
+			problems = append(problems, fmt.Sprintf("buildpack %s was removed", name))
+		}
+	}
+	switch {
+	case lt.Container != nil && lt.Container.Image != target.Container.Image:
+		problems = append(problems, fmt.Sprintf("container image %s was locked as %s", target.Container.Image, lt.Container.Image))
+	case lt.Container == nil && target.UseContainer:
+		problems = append(problems, fmt.Sprintf("container image %s is not locked", target.Container.Image))
+	}
+	for name, rd := range target.Resources {
+		switch locked := lt.Resources[name]; {
+		case locked == nil:
+			problems = append(problems, fmt.Sprintf("resource %s is not locked", name))
+		case locked.Image != rd.Image:
+			problems = append(problems, fmt.Sprintf("resource %s image %s was locked as %s", name, rd.Image, locked.Image))
+		}
+	}
+	for name := range lt.Resources {
+		if _, ok := target.Resources[name]; !ok {
+			problems = append(problems, fmt.Sprintf("resource %s was removed", name))
+		}
+	}
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return fmt.Errorf("target %s does not match %s: %s", target.Name, LockFilename, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// Pin returns a copy of the target that uses the locked buildpack versions and
+// image digests. Pin does not modify target. Callers should call Check first to
+// ensure that the lock applies to the target.
+//
+// Pinned images keep the tag from the configuration, like
+// "postgres:12@sha256:...". Docker uses the digest, but narwhal ignores it when
+// it looks for and pulls an image, so callers must pull the digest themselves
+// before handing the target to narwhal.
+func (lt *LockedTarget) Pin(target *Target) *Target {
+	pinned := new(Target)
+	*pinned = *target
+	pinned.Buildpacks = make(map[string]BuildpackSpec, len(target.Buildpacks))
+	for name, spec := range target.Buildpacks {
+		if locked := lt.Buildpacks[name]; locked != nil && locked.Spec == spec {
+			spec = locked.Resolved
+		}
+		pinned.Buildpacks[name] = spec
+	}
+	if lt.Container != nil && lt.Container.Digest != "" && lt.Container.Image == target.Container.Image {
+		pinned.Container = new(narwhal.ContainerDefinition)
+		*pinned.Container = *target.Container
+		pinned.Container.Image = pinImage(target.Container.Image, lt.Container.Digest)
+	}
+	if len(target.Resources) > 0 {
+		pinned.Resources = make(map[string]*ResourceDefinition, len(target.Resources))
+		for name, rd := range target.Resources {
+			if locked := lt.Resources[name]; locked != nil && locked.Digest != "" && locked.Image == rd.Image {
+				rd2 := new(ResourceDefinition)
+				*rd2 = *rd
+				rd2.Image = pinImage(rd.Image, locked.Digest)
+				rd = rd2
+			}
+			pinned.Resources[name] = rd
+		}
+	}
+	return pinned
+}
+
+// pinImage returns the image reference with the digest from a locked reference
+// like "postgres@sha256:..." appended.
+func pinImage(image, digestRef string) string {
+	i := strings.LastIndexByte(digestRef, '@')
+	if i == -1 {
+		return digestRef
+	}
+	return image + digestRef[i:]
+}
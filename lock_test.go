@@ -0,0 +1,186 @@
+// Copyright 2020 YourBase Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package yb
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/yourbase/narwhal"
+)
+
+func TestLockRoundTrip(t *testing.T) {
+	lock := &Lock{
+		Targets: map[string]*LockedTarget{
+			"default": {
+				Buildpacks: map[string]*LockedBuildpack{
+					"go": {Spec: "go:1.16.x", Resolved: "go:1.16.3"},
+				},
+				Downloads: map[string]string{
+					"https://dl.google.com/go/go1.16.3.linux-amd64.tar.gz": "951a3c7c6ce4e56ad883f97d9db74d3d6d80d5fec77455c6ada6c1f7ac4776d2",
+				},
+				Container: &LockedImage{
+					Image:  "yourbase/yb_ubuntu:18.04",
+					Digest: "yourbase/yb_ubuntu@sha256:0123",
+				},
+				Resources: map[string]*LockedImage{
+					"db": {Image: "postgres:12", Digest: "postgres@sha256:4567"},
+				},
+			},
+			"empty": {},
+		},
+	}
+	data, err := lock.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := parseLock(data)
+	if err != nil {
+		t.Fatalf("parseLock(...) = _, %v; encoded:\n%s", err, data)
+	}
+	if diff := cmp.Diff(lock, got); diff != "" {
+		t.Errorf("round trip (-want +got):\n%s\nencoded:\n%s", diff, data)
+	}
+}
+
+func TestParseLockVersion(t *testing.T) {
+	_, err := parseLock([]byte("version: 99\ntargets: {}\n"))
+	if err == nil {
+		t.Error("parseLock did not return an error for unknown version")
+	}
+}
+
+func TestLockedTargetCheck(t *testing.T) {
+	locked := &LockedTarget{
+		Buildpacks: map[string]*LockedBuildpack{
+			"go":     {Spec: "go:1.16.x", Resolved: "go:1.16.3"},
+			"python": {Spec: "python:3.9", Resolved: "python:3.9.4"},
+		},
+		Resources: map[string]*LockedImage{
+			"db": {Image: "postgres:12", Digest: "postgres@sha256:4567"},
+		},
+	}
+	tests := []struct {
+		name   string
+		target *Target
+		want   []string
+	}{
+		{
+			name: "UpToDate",
+			target: &Target{
+				Name:      "default",
+				Container: &narwhal.ContainerDefinition{Image: "yourbase/yb_ubuntu:18.04"},
+				Buildpacks: map[string]BuildpackSpec{
+					"go":     "go:1.16.x",
+					"python": "python:3.9",
+				},
+				Resources: map[string]*ResourceDefinition{
+					"db": {ContainerDefinition: narwhal.ContainerDefinition{Image: "postgres:12"}},
+				},
+			},
+		},
+		{
+			name: "Drift",
+			target: &Target{
+				Name:         "default",
+				Container:    &narwhal.ContainerDefinition{Image: "yourbase/yb_ubuntu:18.04"},
+				UseContainer: true,
+				Buildpacks: map[string]BuildpackSpec{
+					"go":   "go:1.15.x",
+					"node": "node:lts",
+				},
+				Resources: map[string]*ResourceDefinition{
+					"db":    {ContainerDefinition: narwhal.ContainerDefinition{Image: "postgres:13"}},
+					"cache": {ContainerDefinition: narwhal.ContainerDefinition{Image: "redis"}},
+				},
+			},
+			want: []string{
+				"buildpack go:1.15.x was locked as go:1.16.x",
+				"buildpack node:lts is not locked",
+				"buildpack python was removed",
+				"container image yourbase/yb_ubuntu:18.04 is not locked",
+				"resource cache is not locked",
+				"resource db image postgres:13 was locked as postgres:12",
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := locked.Check(test.target)
+			if len(test.want) == 0 {
+				if err != nil {
+					t.Errorf("Check(...) = %v; want <nil>", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("Check(...) = <nil>; want error")
+			}
+			for _, msg := range test.want {
+				if !strings.Contains(err.Error(), msg) {
+					t.Errorf("Check(...) = %v; want to contain %q", err, msg)
+				}
+			}
+		})
+	}
+}
+
+func TestLockedTargetPin(t *testing.T) {
+	locked := &LockedTarget{
+		Buildpacks: map[string]*LockedBuildpack{
+			"go": {Spec: "go:1.16.x", Resolved: "go:1.16.3"},
+		},
+		Container: &LockedImage{
+			Image:  "yourbase/yb_ubuntu:18.04",
+			Digest: "yourbase/yb_ubuntu@sha256:0123",
+		},
+		Resources: map[string]*LockedImage{
+			"db":    {Image: "postgres:12", Digest: "postgres@sha256:4567"},
+			"local": {Image: "myimage"},
+		},
+	}
+	target := &Target{
+		Name:      "default",
+		Container: &narwhal.ContainerDefinition{Image: "yourbase/yb_ubuntu:18.04"},
+		Buildpacks: map[string]BuildpackSpec{
+			"go": "go:1.16.x",
+		},
+		Resources: map[string]*ResourceDefinition{
+			"db":    {ContainerDefinition: narwhal.ContainerDefinition{Image: "postgres:12"}},
+			"local": {ContainerDefinition: narwhal.ContainerDefinition{Image: "myimage"}},
+		},
+	}
+	got := locked.Pin(target)
+	if want := BuildpackSpec("go:1.16.3"); got.Buildpacks["go"] != want {
+		t.Errorf("Buildpacks[go] = %q; want %q", got.Buildpacks["go"], want)
+	}
+	if want := "yourbase/yb_ubuntu:18.04@sha256:0123"; got.Container.Image != want {
+		t.Errorf("Container.Image = %q; want %q", got.Container.Image, want)
+	}
+	if want := "postgres:12@sha256:4567"; got.Resources["db"].Image != want {
+		t.Errorf("Resources[db].Image = %q; want %q", got.Resources["db"].Image, want)
+	}
+	if want := "myimage"; got.Resources["local"].Image != want {
+		t.Errorf("Resources[local].Image = %q; want %q", got.Resources["local"].Image, want)
+	}
+
+	// The original target must not be modified.
+	if target.Buildpacks["go"] != "go:1.16.x" || target.Container.Image != "yourbase/yb_ubuntu:18.04" || target.Resources["db"].Image != "postgres:12" {
+		t.Error("Pin modified its argument")
+	}
+}
@@ -0,0 +1,228 @@
+// Copyright 2020 YourBase Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package yb
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	yaml3 "gopkg.in/yaml.v3"
+)
+
+// MigrateConfig rewrites the legacy forms in a .yourbase.yml document into
+// their canonical equivalents:
+//
+//   - A top-level build section becomes a build_targets entry named "default".
+//   - Environment variables given as a list of KEY=VALUE strings become a
+//     mapping.
+//
+// Comments and key order are preserved. MigrateConfig returns a description of
+// each change it made. If there is nothing to change, MigrateConfig returns
+// data unmodified and no changes.
+func MigrateConfig(data []byte) (_ []byte, changes []string, err error) {
+	doc := new(yaml3.Node)
+	if err := yaml3.Unmarshal(data, doc); err != nil {
+		return nil, nil, fmt.Errorf("migrate config: %w", err)
+	}
+	if doc.Kind != yaml3.DocumentNode || len(doc.Content) == 0 {
+		return data, nil, nil
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml3.MappingNode {
+		return nil, nil, errors.New("migrate config: top level is not a mapping")
+	}
+	m := new(migrator)
+	m.migrateBuild(root)
+	for _, tgt := range sequenceItems(mappingValue(root, "build_targets")) {
+		m.migrateEnv(mappingValue(tgt, "environment"))
+		m.migrateContainer(mappingValue(tgt, "container"))
+		m.migrateContainerMap(mappingValue(mappingValue(tgt, "dependencies"), "containers"))
+	}
+	if exec := mappingValue(root, "exec"); exec != nil {
+		m.migrateContainer(mappingValue(exec, "container"))
+		m.migrateContainerMap(mappingValue(mappingValue(exec, "dependencies"), "containers"))
+		envs := mappingValue(exec, "environment")
+		for i := 0; envs != nil && envs.Kind == yaml3.MappingNode && i+1 < len(envs.Content); i += 2 {
+			m.migrateEnv(resolveAlias(envs.Content[i+1]))
+		}
+	}
+	if len(m.changes) == 0 {
+		return data, nil, nil
+	}
+
+	buf := new(bytes.Buffer)
+	enc := yaml3.NewEncoder(buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(doc); err != nil {
+		return nil, nil, fmt.Errorf("migrate config: %w", err)
+	}
+	if err := enc.Close(); err != nil {
+		return nil, nil, fmt.Errorf("migrate config: %w", err)
+	}
+	return buf.Bytes(), m.changes, nil
+}
+
+type migrator struct {
+	changes []string
+}
+
+func (m *migrator) changef(node *yaml3.Node, format string, args ...interface{}) {
+	m.changes = append(m.changes, fmt.Sprintf("line %d: ", node.Line)+fmt.Sprintf(format, args...))
+}
+
+// migrateBuild moves the top-level build section into build_targets.
+func (m *migrator) migrateBuild(root *yaml3.Node) {
+	buildIndex := mappingIndex(root, "build")
+	if buildIndex == -1 {
+		return
+	}
+	buildKey, build := root.Content[buildIndex], resolveAlias(root.Content[buildIndex+1])
+	if build.Tag == "!!null" {
+		root.Content = append(root.Content[:buildIndex], root.Content[buildIndex+2:]...)
+		m.changef(buildKey, "removed empty build section")
+		return
+	}
+	if build.Kind != yaml3.MappingNode {
+		// Not something the parser accepts. Leave it for checkconfig to report.
+		return
+	}
+	if nameIndex := mappingIndex(build, "name"); nameIndex == -1 {
+		build.Content = append([]*yaml3.Node{
+			{Kind: yaml3.ScalarNode, Tag: "!!str", Value: "name"},
+			{Kind: yaml3.ScalarNode, Tag: "!!str", Value: DefaultTarget},
+		}, build.Content...)
+	} else {
+		// The parser always names the build section's target "default".
+		build.Content[nameIndex+1].Value = DefaultTarget
+	}
+
+	targetsIndex := mappingIndex(root, "build_targets")
+	switch {
+	case targetsIndex == -1:
+		buildKey.Value = "build_targets"
+		root.Content[buildIndex+1] = &yaml3.Node{
+			Kind:    yaml3.SequenceNode,
+			Tag:     "!!seq",
+			Content: []*yaml3.Node{build},
+		}
+	case root.Content[targetsIndex+1].Kind == yaml3.SequenceNode:
+		targets := root.Content[targetsIndex+1]
+		if buildKey.HeadComment != "" {
+			build.HeadComment = buildKey.HeadComment
+		}
+		targets.Content = append(targets.Content, build)
+		root.Content = append(root.Content[:buildIndex], root.Content[buildIndex+2:]...)
+	case root.Content[targetsIndex+1].Tag == "!!null":
+		buildKey.Value = "build_targets"
+		root.Content[buildIndex+1] = &yaml3.Node{
+			Kind:    yaml3.SequenceNode,
+			Tag:     "!!seq",
+			Content: []*yaml3.Node{build},
+		}
+		root.Content = append(root.Content[:targetsIndex], root.Content[targetsIndex+2:]...)
+	default:
+		return
+	}
+	m.changef(buildKey, "moved build section to build_targets as target %q", DefaultTarget)
+}
+
+func (m *migrator) migrateContainerMap(containers *yaml3.Node) {
+	for i := 0; containers != nil && containers.Kind == yaml3.MappingNode && i+1 < len(containers.Content); i += 2 {
+		m.migrateContainer(resolveAlias(containers.Content[i+1]))
+	}
+}
+
+func (m *migrator) migrateContainer(def *yaml3.Node) {
+	m.migrateEnv(mappingValue(def, "environment"))
+}
+
+// migrateEnv converts a list of KEY=VALUE strings into a mapping in place.
+func (m *migrator) migrateEnv(env *yaml3.Node) {
+	if env == nil || env.Kind != yaml3.SequenceNode {
+		return
+	}
+	var content []*yaml3.Node
+	for _, item := range env.Content {
+		item = resolveAlias(item)
+		if item.Kind != yaml3.ScalarNode {
+			return
+		}
+		k, v, err := parseVar(item.Value)
+		if err != nil {
+			// Not something the parser accepts. Leave it for checkconfig to report.
+			return
+		}
+		// Later entries override earlier ones. Keep the variable at its first
+		// position so the mapping reads in the same order as the list.
+		if i := indexOfKey(content, k); i != -1 {
+			content[i].HeadComment = joinComments(content[i].HeadComment, item.HeadComment, "\n")
+			content[i].FootComment = joinComments(content[i].FootComment, item.FootComment, "\n")
+			content[i+1].Value = v
+			content[i+1].LineComment = joinComments(content[i+1].LineComment, item.LineComment, " ")
+			continue
+		}
+		content = append(content,
+			&yaml3.Node{
+				Kind:        yaml3.ScalarNode,
+				Tag:         "!!str",
+				Value:       k,
+				HeadComment: item.HeadComment,
+				FootComment: item.FootComment,
+			},
+			&yaml3.Node{
+				Kind:        yaml3.ScalarNode,
+				Tag:         "!!str",
+				Value:       v,
+				LineComment: item.LineComment,
+			},
+		)
+	}
+	env.Kind = yaml3.MappingNode
+	env.Tag = "!!map"
+	env.Content = content
+	m.changef(env, "converted environment list to a mapping")
+}
+
+// indexOfKey returns the index of the key node for the given key in a list of
+// alternating key and value nodes or -1 if there is no such key.
+func indexOfKey(content []*yaml3.Node, key string) int {
+	for i := 0; i+1 < len(content); i += 2 {
+		if content[i].Value == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// joinComments concatenates two comments with sep, omitting empty ones.
+func joinComments(c1, c2, sep string) string {
+	switch {
+	case c1 == "":
+		return c2
+	case c2 == "":
+		return c1
+	default:
+		return c1 + sep + c2
+	}
+}
+
+// mappingIndex returns the index of the key node for the given key in a
+// mapping node's content or -1 if the mapping does not contain the key.
+func mappingIndex(node *yaml3.Node, key string) int {
+	return indexOfKey(node.Content, key)
+}
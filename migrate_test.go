@@ -0,0 +1,88 @@
+// Copyright 2020 YourBase Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package yb
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestMigrateConfig(t *testing.T) {
+	packageDir, err := filepath.Abs(filepath.Join("testdata", "Migrate"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Source files are under testdata/Migrate. Each NAME.yml is migrated and
+	// compared against NAME.want.yml.
+	tests := []struct {
+		name        string
+		wantChanges int
+	}{
+		{name: "Canonical", wantChanges: 0},
+		{name: "Build", wantChanges: 2},
+		{name: "BuildTargets", wantChanges: 1},
+		{name: "Containers", wantChanges: 3},
+		{name: "DuplicateEnv", wantChanges: 1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			input, err := ioutil.ReadFile(filepath.Join(packageDir, test.name+".yml"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, err := ioutil.ReadFile(filepath.Join(packageDir, test.name+".want.yml"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, changes, err := MigrateConfig(input)
+			if err != nil {
+				t.Fatal("MigrateConfig:", err)
+			}
+			for _, c := range changes {
+				t.Log(c)
+			}
+			if len(changes) != test.wantChanges {
+				t.Errorf("len(changes) = %d; want %d", len(changes), test.wantChanges)
+			}
+			if diff := cmp.Diff(string(want), string(got)); diff != "" {
+				t.Errorf("output (-want +got):\n%s", diff)
+			}
+
+			// The migrated configuration must mean the same thing.
+			oldPackage, err := parse(packageDir, input, nil)
+			if err != nil {
+				t.Fatal("parse original:", err)
+			}
+			newPackage, err := parse(packageDir, got, nil)
+			if err != nil {
+				t.Fatal("parse migrated:", err)
+			}
+			diff := cmp.Diff(oldPackage, newPackage,
+				cmpopts.IgnoreFields(Target{}, "Package", "Deps"),
+				cmpopts.SortSlices(func(s1, s2 string) bool { return s1 < s2 }),
+				cmpopts.EquateEmpty(),
+			)
+			if diff != "" {
+				t.Errorf("parsed package (-original +migrated):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+// Copyright 2020 YourBase Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package yb
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// A Mirror is a rule that redirects downloads from one URL prefix to another,
+// like from https://nodejs.org/dist/ to an internal artifact repository.
+type Mirror struct {
+	// From is the URL prefix to match.
+	From string `yaml:"from"`
+	// To replaces From in matching URLs.
+	To string `yaml:"to"`
+	// Fallback indicates that the original URL should be tried if the mirror
+	// can't be reached or does not have the file.
+	Fallback bool `yaml:"fallback"`
+}
+
+// mirrorArrow separates the prefixes in the string form of a Mirror.
+const mirrorArrow = "->"
+
+// ParseMirror parses a mirror rule of the form "FROM -> TO".
+func ParseMirror(s string) (*Mirror, error) {
+	i := strings.Index(s, mirrorArrow)
+	if i < 0 {
+		return nil, fmt.Errorf("parse mirror %q: missing %q", s, mirrorArrow)
+	}
+	m := &Mirror{
+		From: strings.TrimSpace(s[:i]),
+		To:   strings.TrimSpace(s[i+len(mirrorArrow):]),
+	}
+	if err := m.Validate(); err != nil {
+		return nil, fmt.Errorf("parse mirror %q: %w", s, err)
+	}
+	return m, nil
+}
+
+// String returns the mirror in the form "FROM -> TO".
+func (m *Mirror) String() string {
+	return m.From + " " + mirrorArrow + " " + m.To
+}
+
+// Validate checks that both prefixes are absolute HTTP or HTTPS URLs.
+func (m *Mirror) Validate() error {
+	if m.From == "" {
+		return errors.New("from is required")
+	}
+	if m.To == "" {
+		return errors.New("to is required")
+	}
+	for _, prefix := range []string{m.From, m.To} {
+		u, err := url.Parse(prefix)
+		if err != nil {
+			return err
+		}
+		if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("%s is not an http or https URL", prefix)
+		}
+	}
+	return nil
+}
+
+// Rewrite returns the URL with the mirror's From prefix replaced by its To
+// prefix. ok is false if the URL does not start with From.
+func (m *Mirror) Rewrite(u string) (_ string, ok bool) {
+	if !strings.HasPrefix(u, m.From) {
+		return u, false
+	}
+	return m.To + strings.TrimPrefix(u, m.From), true
+}
+
+func validateMirrors(mirrors []*Mirror) error {
+	for i, m := range mirrors {
+		if m == nil {
+			return fmt.Errorf("mirror %d: from is required", i+1)
+		}
+		if err := m.Validate(); err != nil {
+			return fmt.Errorf("mirror %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
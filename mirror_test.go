@@ -0,0 +1,104 @@
+// Copyright 2020 YourBase Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package yb
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseMirror(t *testing.T) {
+	tests := []struct {
+		s       string
+		want    *Mirror
+		wantErr bool
+	}{
+		{
+			s: "https://nodejs.org/dist/ -> https://artifactory.example.com/nodejs/",
+			want: &Mirror{
+				From: "https://nodejs.org/dist/",
+				To:   "https://artifactory.example.com/nodejs/",
+			},
+		},
+		{
+			s: "https://dl.google.com/->http://mirror.internal/google/",
+			want: &Mirror{
+				From: "https://dl.google.com/",
+				To:   "http://mirror.internal/google/",
+			},
+		},
+		{s: "https://nodejs.org/dist/ https://artifactory.example.com/nodejs/", wantErr: true},
+		{s: "https://nodejs.org/dist/ ->", wantErr: true},
+		{s: "-> https://artifactory.example.com/nodejs/", wantErr: true},
+		{s: "nodejs.org/dist/ -> https://artifactory.example.com/nodejs/", wantErr: true},
+		{s: "https://nodejs.org/dist/ -> file:///srv/nodejs/", wantErr: true},
+	}
+	for _, test := range tests {
+		got, err := ParseMirror(test.s)
+		if err != nil {
+			if !test.wantErr {
+				t.Errorf("ParseMirror(%q): %v", test.s, err)
+			}
+			continue
+		}
+		if test.wantErr {
+			t.Errorf("ParseMirror(%q) = %v, <nil>; want error", test.s, got)
+			continue
+		}
+		if diff := cmp.Diff(test.want, got); diff != "" {
+			t.Errorf("ParseMirror(%q) (-want +got):\n%s", test.s, diff)
+		}
+	}
+}
+
+func TestMirrorRewrite(t *testing.T) {
+	m := &Mirror{
+		From: "https://nodejs.org/dist/",
+		To:   "https://artifactory.example.com/nodejs/",
+	}
+	tests := []struct {
+		url    string
+		want   string
+		wantOK bool
+	}{
+		{
+			url:    "https://nodejs.org/dist/v14.17.0/node-v14.17.0-linux-x64.tar.gz",
+			want:   "https://artifactory.example.com/nodejs/v14.17.0/node-v14.17.0-linux-x64.tar.gz",
+			wantOK: true,
+		},
+		{
+			url:    "https://nodejs.org/dist/",
+			want:   "https://artifactory.example.com/nodejs/",
+			wantOK: true,
+		},
+		{
+			url:  "https://nodejs.org/download/release/index.json",
+			want: "https://nodejs.org/download/release/index.json",
+		},
+		{
+			url:  "http://nodejs.org/dist/index.json",
+			want: "http://nodejs.org/dist/index.json",
+		},
+	}
+	for _, test := range tests {
+		got, ok := m.Rewrite(test.url)
+		if got != test.want || ok != test.wantOK {
+			t.Errorf("Rewrite(%q) = %q, %t; want %q, %t", test.url, got, ok, test.want, test.wantOK)
+		}
+	}
+}
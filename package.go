@@ -52,10 +52,24 @@ type Package struct {
 	// ExecEnvironments is the set of targets representing the exec phase
 	// in the configuration, keyed by environment name.
 	ExecEnvironments map[string]*Target
+	// Buildpacks is the set of buildpacks declared in the configuration,
+	// keyed by buildpack name.
+	Buildpacks map[string]*BuildpackDefinition
+	// Mirrors is the list of download mirror rules declared in the
+	// configuration, in the order they should be tried.
+	Mirrors []*Mirror
+}
+
+// LoadOptions is the set of optional parameters to LoadPackage.
+type LoadOptions struct {
+	// Buildpacks is the set of buildpack names that tool_versions entries may
+	// refer to. Buildpacks defined in the configuration are always included.
+	// Entries for other tools are skipped. If nil, every entry is used.
+	Buildpacks []string
 }
 
 // LoadPackage loads the package for the given .yourbase.yml file.
-func LoadPackage(configPath string) (*Package, error) {
+func LoadPackage(configPath string, opts *LoadOptions) (*Package, error) {
 	configPath, err := filepath.Abs(configPath)
 	if err != nil {
 		return nil, fmt.Errorf("load package %s: %w", configPath, err)
@@ -67,7 +81,7 @@ func LoadPackage(configPath string) (*Package, error) {
 	if err != nil {
 		return nil, fmt.Errorf("load package %s: %w", configPath, err)
 	}
-	pkg, err := parse(filepath.Dir(configPath), configYAML)
+	pkg, err := parse(filepath.Dir(configPath), configYAML, opts)
 	if err != nil {
 		return nil, fmt.Errorf("load package %s: %w", configPath, err)
 	}
@@ -100,6 +114,10 @@ type Target struct {
 	Env        map[string]EnvTemplate
 	Buildpacks map[string]BuildpackSpec
 	Resources  map[string]*ResourceDefinition
+
+	// AndroidComponents is the list of Android SDK packages to install with
+	// the android buildpack, from the top-level and target dependencies.
+	AndroidComponents []string
 }
 
 type ResourceDefinition struct {
@@ -92,7 +92,7 @@ func TestBuildOrder(t *testing.T) {
 			if err := os.WriteFile(configPath, configData, 0o666); err != nil {
 				t.Fatal(err)
 			}
-			pkg, err := LoadPackage(configPath)
+			pkg, err := LoadPackage(configPath, nil)
 			if err != nil {
 				t.Fatal(err)
 			}
@@ -28,6 +28,9 @@ import (
 	"gopkg.in/yaml.v2"
 )
 
+// buildManifest is the top-level structure of a .yourbase.yml file.
+// Fields with a schema struct tag use the named definition from JSONSchema
+// for their values (or for their elements, in the case of lists).
 type buildManifest struct {
 	Dependencies dependencySet  `yaml:"dependencies"`
 	Sandbox      bool           `yaml:"sandbox"`
@@ -36,24 +39,50 @@ type buildManifest struct {
 	Exec         *execPhase     `yaml:"exec"`
 	Package      *packagePhase  `yaml:"package"`
 	CI           *ciInfo        `yaml:"ci"`
+
+	Buildpacks map[string]*BuildpackDefinition `yaml:"buildpacks" schemaKeys:"buildpackName"`
+
+	// ToolVersions is the path of an asdf .tool-versions file relative to
+	// the package directory. Every buildpack listed in the file is added to
+	// the top-level build dependencies.
+	ToolVersions string `yaml:"tool_versions"`
+
+	// Mirrors is an ordered list of rules that redirect downloads.
+	Mirrors []*Mirror `yaml:"mirrors"`
 }
 
 // parse parses YAML data into a *Package. dir must be an absolute path.
-func parse(dir string, b []byte) (*Package, error) {
+// opts may be nil.
+func parse(dir string, b []byte, opts *LoadOptions) (*Package, error) {
 	manifest := new(buildManifest)
 	if err := yaml.UnmarshalStrict(b, manifest); err != nil {
 		return nil, err
 	}
+	if err := validateBuildpackDefinitions(manifest.Buildpacks); err != nil {
+		return nil, fmt.Errorf("buildpacks: %w", err)
+	}
+	if err := validateMirrors(manifest.Mirrors); err != nil {
+		return nil, fmt.Errorf("mirrors: %w", err)
+	}
 	pkg := &Package{
-		Name: filepath.Base(dir),
-		Path: dir,
+		Name:       filepath.Base(dir),
+		Path:       dir,
+		Buildpacks: manifest.Buildpacks,
+		Mirrors:    manifest.Mirrors,
+	}
+	if filepath.IsAbs(manifest.ToolVersions) {
+		return nil, fmt.Errorf("tool_versions: %s is absolute; must be relative to the package directory", manifest.ToolVersions)
+	}
+	versions := &versionFiles{
+		dir:          dir,
+		toolVersions: manifest.ToolVersions,
 	}
 	var err error
-	pkg.Targets, err = parseTargets(pkg, manifest)
+	pkg.Targets, err = parseTargets(pkg, manifest, versions, opts)
 	if err != nil {
 		return nil, err
 	}
-	pkg.ExecEnvironments, err = parseExecPhase(pkg, manifest)
+	pkg.ExecEnvironments, err = parseExecPhase(pkg, manifest, versions)
 	if err != nil {
 		return nil, err
 	}
@@ -73,13 +102,43 @@ type buildTarget struct {
 }
 
 type buildDependencies struct {
-	Build      []string                        `yaml:"build"`
-	Containers map[string]*containerDefinition `yaml:"containers"`
+	Build             []string                        `yaml:"build" schema:"buildpackSpec"`
+	Containers        map[string]*containerDefinition `yaml:"containers"`
+	AndroidComponents []string                        `yaml:"android_components"`
 }
 
-func parseTargets(pkg *Package, manifest *buildManifest) (map[string]*Target, error) {
+func parseTargets(pkg *Package, manifest *buildManifest, versions *versionFiles, opts *LoadOptions) (map[string]*Target, error) {
 	globalBuildDeps := make(map[string]BuildpackSpec)
-	if err := parseBuildpacks(globalBuildDeps, manifest.Dependencies.Build); err != nil {
+	if manifest.ToolVersions != "" {
+		entries, err := versions.toolVersionsEntries()
+		if err != nil {
+			return nil, fmt.Errorf("tool_versions: %w", err)
+		}
+		var known map[string]bool
+		if opts != nil && opts.Buildpacks != nil {
+			known = make(map[string]bool, len(opts.Buildpacks)+len(manifest.Buildpacks))
+			for _, name := range opts.Buildpacks {
+				known[name] = true
+			}
+			for name := range manifest.Buildpacks {
+				known[name] = true
+			}
+		}
+		for _, src := range entries {
+			// asdf files commonly list tools that yb doesn't install (like
+			// direnv) or that should come from the system. Neither should
+			// prevent the package from loading.
+			if known != nil && !known[src.name] || isUnreleasedToolVersion(src.version) {
+				continue
+			}
+			src, err := src.normalize()
+			if err != nil {
+				return nil, fmt.Errorf("tool_versions: %w", err)
+			}
+			globalBuildDeps[src.name] = src.spec()
+		}
+	}
+	if err := parseBuildpacks(globalBuildDeps, manifest.Dependencies.Build, versions); err != nil {
 		return nil, fmt.Errorf("top-level build dependencies: %w", err)
 	}
 
@@ -96,11 +155,12 @@ func parseTargets(pkg *Package, manifest *buildManifest) (map[string]*Target, er
 		if targetMap[tgt.Name] != nil {
 			return nil, fmt.Errorf("multiple targets with name %q", tgt.Name)
 		}
-		parsed, err := parseTarget(pkg.Path, globalBuildDeps, tgt)
+		parsed, err := parseTarget(pkg.Path, globalBuildDeps, versions, tgt)
 		if err != nil {
 			return nil, err
 		}
 		parsed.Package = pkg
+		parsed.AndroidComponents = appendUnique(manifest.Dependencies.AndroidComponents, tgt.Dependencies.AndroidComponents)
 		targetMap[parsed.Name] = parsed
 	}
 
@@ -124,7 +184,7 @@ func parseTargets(pkg *Package, manifest *buildManifest) (map[string]*Target, er
 
 // parseTarget parses a target's data attributes (i.e. anything that doesn't
 // refer to other targets).
-func parseTarget(packageDir string, globalDeps map[string]BuildpackSpec, tgt *buildTarget) (*Target, error) {
+func parseTarget(packageDir string, globalDeps map[string]BuildpackSpec, versions *versionFiles, tgt *buildTarget) (*Target, error) {
 	if tgt.Name == "" {
 		return nil, errors.New("found target without name")
 	}
@@ -150,7 +210,7 @@ func parseTarget(packageDir string, globalDeps map[string]BuildpackSpec, tgt *bu
 	for tool, spec := range globalDeps {
 		parsed.Buildpacks[tool] = spec
 	}
-	if err := parseBuildpacks(parsed.Buildpacks, tgt.Dependencies.Build); err != nil {
+	if err := parseBuildpacks(parsed.Buildpacks, tgt.Dependencies.Build, versions); err != nil {
 		return nil, fmt.Errorf("target %s: dependencies: build: %w", tgt.Name, err)
 	}
 	if tgt.Environment != nil {
@@ -159,17 +219,43 @@ func parseTarget(packageDir string, globalDeps map[string]BuildpackSpec, tgt *bu
 	return parsed, nil
 }
 
-func parseBuildpacks(dst map[string]BuildpackSpec, list []string) error {
+// parseBuildpacks parses a list of buildpack specifiers into dst, replacing
+// AutoVersion with the version read from the package's version files.
+func parseBuildpacks(dst map[string]BuildpackSpec, list []string, versions *versionFiles) error {
 	for _, s := range list {
 		spec, err := ParseBuildpackSpec(s)
 		if err != nil {
 			return err
 		}
+		if spec.Version() == AutoVersion {
+			src, err := versions.lookup(spec.Name())
+			if err != nil {
+				return err
+			}
+			spec = src.spec()
+		}
 		dst[spec.Name()] = spec
 	}
 	return nil
 }
 
+// appendUnique returns the concatenation of a and b with duplicates removed,
+// keeping the first occurrence of each element.
+func appendUnique(a, b []string) []string {
+	var result []string
+	seen := make(map[string]struct{})
+	for _, list := range [][]string{a, b} {
+		for _, s := range list {
+			if _, dup := seen[s]; dup {
+				continue
+			}
+			seen[s] = struct{}{}
+			result = append(result, s)
+		}
+	}
+	return result
+}
+
 type ciInfo struct {
 	CIBuilds []*ciBuild `yaml:"builds"`
 }
@@ -186,8 +272,9 @@ type packagePhase struct {
 }
 
 type dependencySet struct {
-	Build   []string `yaml:"build"`
-	Runtime []string `yaml:"runtime"`
+	Build             []string `yaml:"build" schema:"buildpackSpec"`
+	Runtime           []string `yaml:"runtime" schema:"buildpackSpec"`
+	AndroidComponents []string `yaml:"android_components"`
 }
 
 type execPhase struct {
@@ -202,19 +289,19 @@ type execPhase struct {
 }
 
 type execDependencies struct {
-	Runtime    []string                        `yaml:"runtime"`
+	Runtime    []string                        `yaml:"runtime" schema:"buildpackSpec"`
 	Containers map[string]*containerDefinition `yaml:"containers"`
 }
 
-func parseExecPhase(pkg *Package, manifest *buildManifest) (map[string]*Target, error) {
+func parseExecPhase(pkg *Package, manifest *buildManifest, versions *versionFiles) (map[string]*Target, error) {
 	if manifest.Exec == nil {
 		return nil, nil
 	}
 	buildpacks := make(map[string]BuildpackSpec)
-	if err := parseBuildpacks(buildpacks, manifest.Dependencies.Runtime); err != nil {
+	if err := parseBuildpacks(buildpacks, manifest.Dependencies.Runtime, versions); err != nil {
 		return nil, fmt.Errorf("top-level runtime dependencies: %w", err)
 	}
-	if err := parseBuildpacks(buildpacks, manifest.Exec.Dependencies.Runtime); err != nil {
+	if err := parseBuildpacks(buildpacks, manifest.Exec.Dependencies.Runtime, versions); err != nil {
 		return nil, fmt.Errorf("exec runtime dependencies: %w", err)
 	}
 	container, err := manifest.Exec.Container.toResource(pkg.Path)
@@ -234,6 +321,8 @@ func parseExecPhase(pkg *Package, manifest *buildManifest) (map[string]*Target,
 		Env:          make(map[string]EnvTemplate),
 		Buildpacks:   buildpacks,
 		Resources:    resources,
+
+		AndroidComponents: appendUnique(manifest.Dependencies.AndroidComponents, nil),
 	}
 	if manifest.Exec.Environment != nil {
 		defaultTarget.Env = manifest.Exec.Environment[defaultTarget.Name]
@@ -266,8 +355,8 @@ const DefaultContainerImage = "yourbase/yb_ubuntu:18.04"
 
 type containerDefinition struct {
 	Image         string        `yaml:"image"`
-	Mounts        []string      `yaml:"mounts"`
-	Ports         []string      `yaml:"ports"`
+	Mounts        []string      `yaml:"mounts" schema:"mount"`
+	Ports         []string      `yaml:"ports" schema:"port"`
 	Environment   envObject     `yaml:"environment"`
 	Command       string        `yaml:"command"`
 	WorkDir       string        `yaml:"workdir"`
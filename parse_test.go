@@ -130,6 +130,27 @@ func TestLoadPackage(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "AndroidComponents",
+			want: &Package{
+				Targets: map[string]*Target{
+					"default": {
+						Name: "default",
+						Container: &narwhal.ContainerDefinition{
+							Image: DefaultContainerImage,
+						},
+						Buildpacks: map[string]BuildpackSpec{
+							"android": "android:latest",
+						},
+						AndroidComponents: []string{
+							"platform-tools",
+							"platforms;android-30",
+							"build-tools;30.0.3",
+						},
+					},
+				},
+			},
+		},
 		{
 			name: "Environment",
 			want: &Package{
@@ -301,6 +322,56 @@ func TestLoadPackage(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "Buildpacks",
+			want: &Package{
+				Targets: map[string]*Target{
+					"default": {
+						Name: "default",
+						Container: &narwhal.ContainerDefinition{
+							Image: DefaultContainerImage,
+						},
+						Buildpacks: map[string]BuildpackSpec{
+							"terraform": "terraform:1.0.0",
+						},
+					},
+				},
+				Buildpacks: map[string]*BuildpackDefinition{
+					"terraform": {
+						URL:      "https://releases.hashicorp.com/terraform/{{.Version}}/terraform_{{.Version}}_{{.OS}}_{{.Arch}}.zip",
+						Checksum: "https://releases.hashicorp.com/terraform/{{.Version}}/terraform_{{.Version}}_SHA256SUMS",
+						Env: map[string]string{
+							"TF_PLUGIN_CACHE_DIR": "{{.InstallDir}}/plugins",
+						},
+						Path: []string{"."},
+					},
+				},
+			},
+		},
+		{
+			name:      "BuildpacksInvalid",
+			wantError: true,
+		},
+		{
+			name: "Mirrors",
+			want: &Package{
+				Mirrors: []*Mirror{
+					{
+						From:     "https://nodejs.org/dist/",
+						To:       "https://artifactory.example.com/nodejs/",
+						Fallback: true,
+					},
+					{
+						From: "https://dl.google.com/",
+						To:   "https://artifactory.example.com/google/",
+					},
+				},
+			},
+		},
+		{
+			name:      "MirrorsInvalid",
+			wantError: true,
+		},
 		{
 			name:      "Cycle",
 			wantError: true,
@@ -313,7 +384,7 @@ func TestLoadPackage(t *testing.T) {
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
 			configPath := filepath.Join(packageDir, filepath.FromSlash(test.name+".yml"))
-			got, err := LoadPackage(configPath)
+			got, err := LoadPackage(configPath, nil)
 			if err != nil {
 				t.Log("LoadPackage:", err)
 				if !test.wantError {
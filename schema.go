@@ -0,0 +1,197 @@
+// Copyright 2020 YourBase Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package yb
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// SchemaOptions is the set of optional parameters to JSONSchema.
+type SchemaOptions struct {
+	// Buildpacks is the set of built-in buildpack names that editors should
+	// suggest. The schema permits any buildpack name, since packages may
+	// declare their own buildpacks.
+	Buildpacks []string
+}
+
+// JSONSchema returns a JSON Schema (draft-07) document describing the
+// .yourbase.yml format. The schema is generated from the types used by the
+// parser, so it always reflects the fields that LoadPackage accepts.
+func JSONSchema(opts *SchemaOptions) ([]byte, error) {
+	var names []string
+	if opts != nil {
+		names = opts.Buildpacks
+	}
+	g := &schemaGenerator{defs: baseSchemaDefinitions(names)}
+	root := g.objectSchema(reflect.TypeOf(buildManifest{}))
+	root.Schema = "http://json-schema.org/draft-07/schema#"
+	root.Title = PackageConfigFilename
+	root.Definitions = g.defs
+	data, err := json.MarshalIndent(root, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("generate schema: %w", err)
+	}
+	return append(data, '\n'), nil
+}
+
+// jsonSchema is a JSON Schema node.
+type jsonSchema struct {
+	Schema               string                 `json:"$schema,omitempty"`
+	Title                string                 `json:"title,omitempty"`
+	Description          string                 `json:"description,omitempty"`
+	Ref                  string                 `json:"$ref,omitempty"`
+	Type                 interface{}            `json:"type,omitempty"`
+	Enum                 []string               `json:"enum,omitempty"`
+	Pattern              string                 `json:"pattern,omitempty"`
+	Properties           map[string]*jsonSchema `json:"properties,omitempty"`
+	AdditionalProperties interface{}            `json:"additionalProperties,omitempty"`
+	PropertyNames        *jsonSchema            `json:"propertyNames,omitempty"`
+	Items                *jsonSchema            `json:"items,omitempty"`
+	OneOf                []*jsonSchema          `json:"oneOf,omitempty"`
+	AnyOf                []*jsonSchema          `json:"anyOf,omitempty"`
+	Definitions          map[string]*jsonSchema `json:"definitions,omitempty"`
+}
+
+func schemaRef(name string) *jsonSchema {
+	return &jsonSchema{Ref: "#/definitions/" + name}
+}
+
+// Definitions referenced by schema and schemaKeys struct tags.
+const (
+	schemaBuildpackName = "buildpackName"
+	schemaBuildpackSpec = "buildpackSpec"
+	schemaMount         = "mount"
+	schemaPort          = "port"
+	schemaEnvironment   = "environment"
+)
+
+// Patterns used in the schema. They must be valid in both Go and ECMA 262
+// regular expression syntax.
+const (
+	buildpackNameSchemaPattern = `^[^:\s]+$`
+	buildpackSpecSchemaPattern = `^[^:\s]+:` + buildpackVersionExpr + `$`
+	mountSchemaPattern         = `^[^:]+:/[^:]*$`
+	portSchemaPattern          = `^([^:]+:)?([0-9]+:)?[0-9]+(/(tcp|udp|sctp))?$`
+)
+
+func baseSchemaDefinitions(buildpackNames []string) map[string]*jsonSchema {
+	defs := map[string]*jsonSchema{
+		schemaBuildpackName: {
+			Description: "The name of a buildpack.",
+			Type:        "string",
+			Pattern:     buildpackNameSchemaPattern,
+		},
+		schemaBuildpackSpec: {
+			Description: "A buildpack name and version separated by a colon, like go:1.16.3. The version auto reads the version from a file in the package directory, like .tool-versions or .nvmrc.",
+			Type:        "string",
+			Pattern:     buildpackSpecSchemaPattern,
+		},
+		schemaMount: {
+			Description: "A bind mount in the form SOURCE:TARGET. SOURCE is relative to the package directory and TARGET is an absolute path in the container.",
+			Type:        "string",
+			Pattern:     mountSchemaPattern,
+		},
+		schemaPort: {
+			Description: "A container port, optionally preceded by a host address and port.",
+			Type:        []string{"string", "integer"},
+			Pattern:     portSchemaPattern,
+		},
+		schemaEnvironment: {
+			Description: `Environment variables. Values may refer to container addresses with {{ .Containers.IP "name" }}.`,
+			OneOf: []*jsonSchema{
+				{
+					Type:                 "object",
+					AdditionalProperties: &jsonSchema{Type: []string{"string", "number", "boolean"}},
+				},
+				{
+					Type:  "array",
+					Items: &jsonSchema{Type: "string", Pattern: "^[^=]+="},
+				},
+			},
+		},
+	}
+	if len(buildpackNames) > 0 {
+		// The enum only gives editors names to suggest: packages may declare
+		// buildpacks with other names.
+		defs[schemaBuildpackName].AnyOf = []*jsonSchema{
+			{Enum: append([]string(nil), buildpackNames...)},
+			{Pattern: buildpackNameSchemaPattern},
+		}
+	}
+	return defs
+}
+
+type schemaGenerator struct {
+	defs map[string]*jsonSchema
+}
+
+// typeSchema returns the schema for a value of type t. If definition is not
+// empty, it names the definition to use for the value (or its elements).
+func (g *schemaGenerator) typeSchema(t reflect.Type, definition string) *jsonSchema {
+	if definition != "" {
+		if t.Kind() == reflect.Slice {
+			return &jsonSchema{Type: "array", Items: schemaRef(definition)}
+		}
+		return schemaRef(definition)
+	}
+	if t == envObjectType {
+		return schemaRef(schemaEnvironment)
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		return g.typeSchema(t.Elem(), "")
+	case reflect.Struct:
+		name := t.Name()
+		if g.defs[name] == nil {
+			// Reserve the name before recursing in case the type refers to itself.
+			g.defs[name] = new(jsonSchema)
+			*g.defs[name] = *g.objectSchema(t)
+		}
+		return schemaRef(name)
+	case reflect.Slice:
+		return &jsonSchema{Type: "array", Items: g.typeSchema(t.Elem(), "")}
+	case reflect.Map:
+		return &jsonSchema{Type: "object", AdditionalProperties: g.typeSchema(t.Elem(), "")}
+	case reflect.String:
+		return &jsonSchema{Type: "string"}
+	case reflect.Int:
+		return &jsonSchema{Type: "integer"}
+	case reflect.Bool:
+		return &jsonSchema{Type: "boolean"}
+	default:
+		panic("no schema for " + t.String())
+	}
+}
+
+// objectSchema returns the schema for a struct type.
+func (g *schemaGenerator) objectSchema(t reflect.Type) *jsonSchema {
+	s := &jsonSchema{
+		Type:                 "object",
+		Properties:           make(map[string]*jsonSchema),
+		AdditionalProperties: false,
+	}
+	for name, f := range yamlFields(t) {
+		prop := g.typeSchema(f.Type, f.Tag.Get("schema"))
+		if keys := f.Tag.Get("schemaKeys"); keys != "" {
+			prop.PropertyNames = schemaRef(keys)
+		}
+		s.Properties[name] = prop
+	}
+	return s
+}
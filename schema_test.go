@@ -0,0 +1,175 @@
+// Copyright 2020 YourBase Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package yb
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// TestJSONSchemaInSync verifies that every field the parser accepts is present
+// in the schema and vice versa.
+func TestJSONSchemaInSync(t *testing.T) {
+	data, err := JSONSchema(&SchemaOptions{Buildpacks: []string{"go", "node"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := new(jsonSchema)
+	if err := json.Unmarshal(data, root); err != nil {
+		t.Fatal(err)
+	}
+
+	visited := make(map[reflect.Type]bool)
+	var check func(path string, s *jsonSchema, typ reflect.Type)
+	check = func(path string, s *jsonSchema, typ reflect.Type) {
+		if visited[typ] {
+			return
+		}
+		visited[typ] = true
+		if s.Ref != "" {
+			name := strings.TrimPrefix(s.Ref, "#/definitions/")
+			s = root.Definitions[name]
+			if s == nil {
+				t.Errorf("%s: reference to missing definition %q", path, name)
+				return
+			}
+		}
+		if s.AdditionalProperties != false {
+			t.Errorf("%s: additionalProperties = %v; want false", path, s.AdditionalProperties)
+		}
+		fields := yamlFields(typ)
+		var want, got []string
+		for name := range fields {
+			want = append(want, name)
+		}
+		for name := range s.Properties {
+			got = append(got, name)
+		}
+		sort.Strings(want)
+		sort.Strings(got)
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("%s: properties (-parser +schema):\n%s", path, diff)
+		}
+		for name, f := range fields {
+			prop := s.Properties[name]
+			if prop == nil {
+				continue
+			}
+			ft := f.Type
+			for ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			switch {
+			case f.Tag.Get("schema") != "":
+				def := f.Tag.Get("schema")
+				ref := prop.Ref
+				if prop.Items != nil {
+					ref = prop.Items.Ref
+				}
+				if ref != "#/definitions/"+def || root.Definitions[def] == nil {
+					t.Errorf("%s.%s: does not use definition %q", path, name, def)
+				}
+			case ft.Kind() == reflect.Struct:
+				check(path+"."+name, prop, ft)
+			case ft.Kind() == reflect.Slice && ft.Elem().Kind() == reflect.Ptr && ft.Elem().Elem().Kind() == reflect.Struct:
+				if prop.Items == nil {
+					t.Errorf("%s.%s: no items schema", path, name)
+					continue
+				}
+				check(path+"."+name+"[]", prop.Items, ft.Elem().Elem())
+			case ft.Kind() == reflect.Map && ft.Elem().Kind() == reflect.Ptr && ft.Elem().Elem().Kind() == reflect.Struct:
+				sub, ok := prop.AdditionalProperties.(map[string]interface{})
+				if !ok {
+					t.Errorf("%s.%s: additionalProperties = %v; want schema", path, name, prop.AdditionalProperties)
+					continue
+				}
+				ref, _ := sub["$ref"].(string)
+				check(path+"."+name+".*", &jsonSchema{Ref: ref}, ft.Elem().Elem())
+			}
+		}
+	}
+	check("", root, reflect.TypeOf(buildManifest{}))
+	if got, want := root.Properties["buildpacks"].PropertyNames, schemaRef(schemaBuildpackName); !cmp.Equal(got, want) {
+		t.Errorf("buildpacks.propertyNames = %+v; want %+v", got, want)
+	}
+	if def := root.Definitions[schemaBuildpackName]; def == nil {
+		t.Errorf("no %s definition", schemaBuildpackName)
+	} else {
+		var enum []string
+		for _, s := range def.AnyOf {
+			enum = append(enum, s.Enum...)
+		}
+		if want := []string{"go", "node"}; !cmp.Equal(enum, want) {
+			t.Errorf("%s enum = %q; want %q", schemaBuildpackName, enum, want)
+		}
+	}
+}
+
+// TestJSONSchemaPatterns verifies that the schema's patterns agree with
+// ValidatePackage. The schema can't know which buildpacks a package declares,
+// so it permits any buildpack name.
+func TestJSONSchemaPatterns(t *testing.T) {
+	tests := []struct {
+		definition string
+		pattern    string
+		values     []string
+		// config is a .yourbase.yml with %s where the value goes.
+		config string
+	}{
+		{
+			definition: schemaBuildpackSpec,
+			pattern:    buildpackSpecSchemaPattern,
+			values:     []string{"go:1.16.3", "node:12.19.0", "go:1.16.x", "node:^14", "node:lts", "go:>=1.15", "go", "go:", "gopher:1.0", "node:12 beta", "node:^"},
+			config:     "dependencies:\n  build:\n    - %q\n",
+		},
+		{
+			definition: schemaMount,
+			pattern:    mountSchemaPattern,
+			values:     []string{"data:/data", "/abs:/data", "data", "data:rel", "a:/b:/c", ":/data"},
+			config:     "build_targets:\n  - name: default\n    container:\n      mounts:\n        - %q\n",
+		},
+		{
+			definition: schemaPort,
+			pattern:    portSchemaPattern,
+			values:     []string{"5432", "8080:80", "127.0.0.1:8080:80", "53/udp", "http", "53/foo"},
+			config:     "build_targets:\n  - name: default\n    container:\n      ports:\n        - %q\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.definition, func(t *testing.T) {
+			re, err := regexp.Compile(test.pattern)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, value := range test.values {
+				config := fmt.Sprintf(test.config, value)
+				diags := validate(".yourbase.yml", []byte(config), nil)
+				valid := len(diags) == 0
+				if got := re.MatchString(value); got != valid {
+					t.Errorf("pattern matches %q = %t; ValidatePackage reports %v", value, got, diags)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,363 @@
+// Copyright 2020 YourBase Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package yb
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// AutoVersion is a buildpack version that is replaced during parsing with the
+// version pinned by a file in the package directory, like .tool-versions or
+// .nvmrc.
+const AutoVersion = "auto"
+
+// defaultToolVersionsFile is the asdf version file consulted for AutoVersion
+// when the configuration does not name one with tool_versions.
+const defaultToolVersionsFile = ".tool-versions"
+
+// asdfPluginNames maps asdf plugin names to buildpack names where they differ.
+var asdfPluginNames = map[string]string{
+	"golang": "go",
+	"nodejs": "node",
+}
+
+// versionFilenames lists the single-tool version files for each buildpack in
+// order of precedence. go.mod is handled separately.
+var versionFilenames = map[string][]string{
+	"cpython": {".python-version"},
+	"java":    {".java-version"},
+	"node":    {".nvmrc", ".node-version"},
+	"python":  {".python-version"},
+	"ruby":    {".ruby-version"},
+}
+
+// packageManagerFile is the file whose packageManager field pins the version
+// of the pnpm and yarn buildpacks.
+const packageManagerFile = "package.json"
+
+// A versionSource is a buildpack version read from a file.
+type versionSource struct {
+	name    string
+	version string
+	// file is the path of the file relative to the package directory.
+	file string
+	// line is the 1-based line number the version appeared on.
+	line int
+}
+
+func (src versionSource) spec() BuildpackSpec {
+	return BuildpackSpec(src.name + ":" + src.version)
+}
+
+// String returns the version's location as "file:line".
+func (src versionSource) String() string {
+	return fmt.Sprintf("%s:%d", filepath.ToSlash(src.file), src.line)
+}
+
+// versionFiles reads buildpack versions from the files in a package directory.
+type versionFiles struct {
+	// dir is the absolute path to the package directory.
+	dir string
+	// toolVersions is the path of the asdf version file given by the
+	// tool_versions key relative to dir, or empty if the key is not set.
+	toolVersions string
+}
+
+// toolVersionsEntries returns the versions listed in the asdf version file.
+// If the configuration did not name a file and .tool-versions does not exist,
+// toolVersionsEntries returns no entries.
+func (vf *versionFiles) toolVersionsEntries() ([]versionSource, error) {
+	file := vf.toolVersions
+	if file == "" {
+		file = defaultToolVersionsFile
+	}
+	data, err := ioutil.ReadFile(filepath.Join(vf.dir, filepath.FromSlash(file)))
+	if errors.Is(err, os.ErrNotExist) && vf.toolVersions == "" {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return parseToolVersions(file, data), nil
+}
+
+// lookup finds the version of the named buildpack. The asdf version file takes
+// precedence over single-tool files like .nvmrc.
+func (vf *versionFiles) lookup(name string) (versionSource, error) {
+	entries, err := vf.toolVersionsEntries()
+	if err != nil {
+		return versionSource{}, fmt.Errorf("%s:%s: %w", name, AutoVersion, err)
+	}
+	for _, src := range entries {
+		if src.name == name {
+			src, err := src.normalize()
+			if err != nil {
+				return versionSource{}, fmt.Errorf("%s:%s: %w", name, AutoVersion, err)
+			}
+			return src, nil
+		}
+	}
+	searched := []string{defaultToolVersionsFile}
+	if vf.toolVersions != "" {
+		searched[0] = vf.toolVersions
+	}
+	for _, file := range versionFilenames[name] {
+		searched = append(searched, file)
+		data, err := ioutil.ReadFile(filepath.Join(vf.dir, file))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return versionSource{}, fmt.Errorf("%s:%s: %w", name, AutoVersion, err)
+		}
+		src, err := parseVersionFile(name, file, data)
+		if err != nil {
+			return versionSource{}, fmt.Errorf("%s:%s: %w", name, AutoVersion, err)
+		}
+		return src, nil
+	}
+	if name == "pnpm" || name == "yarn" {
+		searched = append(searched, packageManagerFile)
+		data, err := ioutil.ReadFile(filepath.Join(vf.dir, packageManagerFile))
+		if err == nil {
+			src, ok, err := parsePackageManager(name, packageManagerFile, data)
+			if err != nil {
+				return versionSource{}, fmt.Errorf("%s:%s: %w", name, AutoVersion, err)
+			}
+			if ok {
+				return src, nil
+			}
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return versionSource{}, fmt.Errorf("%s:%s: %w", name, AutoVersion, err)
+		}
+	}
+	if name == "go" {
+		searched = append(searched, "go.mod")
+		data, err := ioutil.ReadFile(filepath.Join(vf.dir, "go.mod"))
+		if err == nil {
+			src, err := parseGoModVersion("go.mod", data)
+			if err != nil {
+				return versionSource{}, fmt.Errorf("%s:%s: %w", name, AutoVersion, err)
+			}
+			return src, nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return versionSource{}, fmt.Errorf("%s:%s: %w", name, AutoVersion, err)
+		}
+	}
+	return versionSource{}, fmt.Errorf("%s:%s: no version found in %s", name, AutoVersion, strings.Join(searched, ", "))
+}
+
+// parseToolVersions parses an asdf .tool-versions file. Only the first version
+// listed for a tool is used. Versions are returned as written: entries are
+// normalized with normalize when they are used, so that an entry for one tool
+// can't prevent reading the version of another.
+func parseToolVersions(file string, data []byte) []versionSource {
+	var entries []versionSource
+	seen := make(map[string]bool)
+	lineno := 0
+	for s := bufio.NewScanner(bytes.NewReader(data)); s.Scan(); {
+		lineno++
+		line := s.Text()
+		if i := strings.IndexByte(line, '#'); i != -1 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		name := fields[0]
+		if n := asdfPluginNames[name]; n != "" {
+			name = n
+		}
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		version := ""
+		if len(fields) >= 2 {
+			version = fields[1]
+		}
+		entries = append(entries, versionSource{
+			name:    name,
+			version: version,
+			file:    file,
+			line:    lineno,
+		})
+	}
+	return entries
+}
+
+// normalize returns src with its version converted by normalizeToolVersion.
+func (src versionSource) normalize() (versionSource, error) {
+	if src.version == "" {
+		return versionSource{}, fmt.Errorf("%v: %s has no version", src, src.name)
+	}
+	version, err := normalizeToolVersion(src.name, src.version)
+	if err != nil {
+		return versionSource{}, fmt.Errorf("%v: %w", src, err)
+	}
+	src.version = version
+	return src, nil
+}
+
+// parseVersionFile parses a single-tool version file like .nvmrc, which
+// contains the version on its first non-blank line.
+func parseVersionFile(name, file string, data []byte) (versionSource, error) {
+	lineno := 0
+	for s := bufio.NewScanner(bytes.NewReader(data)); s.Scan(); {
+		lineno++
+		line := s.Text()
+		if i := strings.IndexByte(line, '#'); i != -1 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		version, err := normalizeToolVersion(name, fields[0])
+		if err != nil {
+			return versionSource{}, fmt.Errorf("%s:%d: %w", file, lineno, err)
+		}
+		return versionSource{
+			name:    name,
+			version: version,
+			file:    file,
+			line:    lineno,
+		}, nil
+	}
+	return versionSource{}, fmt.Errorf("%s: empty", file)
+}
+
+// parseGoModVersion returns the version in a go.mod file's go directive.
+// A directive without a patch version, like "go 1.16", selects the latest
+// patch release.
+func parseGoModVersion(file string, data []byte) (versionSource, error) {
+	lineno := 0
+	for s := bufio.NewScanner(bytes.NewReader(data)); s.Scan(); {
+		lineno++
+		line := s.Text()
+		if i := strings.Index(line, "//"); i != -1 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 || fields[0] != "go" {
+			continue
+		}
+		version := fields[1]
+		if strings.Count(version, ".") == 1 {
+			version += ".x"
+		}
+		if !buildpackVersionPattern.MatchString(version) {
+			return versionSource{}, fmt.Errorf("%s:%d: malformed go version %q", file, lineno, fields[1])
+		}
+		return versionSource{
+			name:    "go",
+			version: version,
+			file:    file,
+			line:    lineno,
+		}, nil
+	}
+	return versionSource{}, fmt.Errorf("%s: no go directive", file)
+}
+
+// parsePackageManager returns the version of the named buildpack in a
+// package.json file's packageManager field, like "yarn@3.6.1" or
+// "pnpm@8.6.0+sha256.abc123". ok is false if the field names a different
+// package manager or is absent.
+func parsePackageManager(name, file string, data []byte) (_ versionSource, ok bool, _ error) {
+	var pkg struct {
+		PackageManager string `json:"packageManager"`
+	}
+	if err := json.Unmarshal(data, &pkg); err != nil {
+		return versionSource{}, false, fmt.Errorf("%s: %w", file, err)
+	}
+	i := strings.IndexByte(pkg.PackageManager, '@')
+	if i == -1 || pkg.PackageManager[:i] != name {
+		return versionSource{}, false, nil
+	}
+	version := pkg.PackageManager[i+1:]
+	// Drop the hash that corepack uses to verify the download.
+	if j := strings.IndexByte(version, '+'); j != -1 {
+		version = version[:j]
+	}
+	version, err := normalizeToolVersion(name, version)
+	if err != nil {
+		return versionSource{}, false, fmt.Errorf("%s: packageManager: %w", file, err)
+	}
+	lineno := 1
+	if j := bytes.Index(data, []byte(`"packageManager"`)); j != -1 {
+		lineno += bytes.Count(data[:j], []byte("\n"))
+	}
+	return versionSource{
+		name:    name,
+		version: version,
+		file:    file,
+		line:    lineno,
+	}, true, nil
+}
+
+// javaVendorPrefix matches the distribution names that asdf-java and jenv put
+// before Java versions, like "adoptopenjdk-" or "openjdk64-".
+var javaVendorPrefix = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9]*(-[A-Za-z][A-Za-z0-9]*)*-`)
+
+// normalizeToolVersion converts a version as written by a version manager into
+// the form that the named buildpack accepts.
+func normalizeToolVersion(name, version string) (string, error) {
+	orig := version
+	switch {
+	case isUnreleasedToolVersion(version):
+		return "", fmt.Errorf("%s %s: only released versions are supported", name, version)
+	case len(version) > 1 && version[0] == 'v' && '0' <= version[1] && version[1] <= '9':
+		version = version[1:]
+	}
+	switch name {
+	case "node":
+		switch {
+		case version == "lts/*":
+			version = "lts"
+		case strings.HasPrefix(version, "lts/"):
+			return "", fmt.Errorf("node %s: release codenames are not supported; use a version or lts/*", orig)
+		case version == "node" || version == "stable":
+			version = "latest"
+		}
+	case "ruby":
+		version = strings.TrimPrefix(version, "ruby-")
+	case "java":
+		version = javaVendorPrefix.ReplaceAllString(version, "")
+	}
+	if !buildpackVersionPattern.MatchString(version) {
+		return "", fmt.Errorf("%s: malformed version %q", name, orig)
+	}
+	return version, nil
+}
+
+// isUnreleasedToolVersion reports whether version tells a version manager to
+// use a tool that is not a released version, like the system installation or
+// a build from source.
+func isUnreleasedToolVersion(version string) bool {
+	return version == "system" || strings.HasPrefix(version, "ref:") || strings.HasPrefix(version, "path:")
+}
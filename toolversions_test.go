@@ -0,0 +1,376 @@
+// Copyright 2020 YourBase Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package yb
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestNormalizeToolVersion(t *testing.T) {
+	tests := []struct {
+		name    string
+		version string
+		want    string
+		wantErr bool
+	}{
+		{name: "node", version: "14.17.0", want: "14.17.0"},
+		{name: "node", version: "v14.17.0", want: "14.17.0"},
+		{name: "node", version: "lts/*", want: "lts"},
+		{name: "node", version: "node", want: "latest"},
+		{name: "node", version: "lts/fermium", wantErr: true},
+		{name: "ruby", version: "ruby-2.7.2", want: "2.7.2"},
+		{name: "java", version: "adoptopenjdk-11.0.8+10", want: "11.0.8+10"},
+		{name: "java", version: "openjdk-11", want: "11"},
+		{name: "java", version: "8.265+01", want: "8.265+01"},
+		{name: "python", version: "3.9.1", want: "3.9.1"},
+		{name: "python", version: "system", wantErr: true},
+		{name: "go", version: "ref:master", wantErr: true},
+		{name: "go", version: "1.16/", wantErr: true},
+	}
+	for _, test := range tests {
+		got, err := normalizeToolVersion(test.name, test.version)
+		if err != nil {
+			if !test.wantErr {
+				t.Errorf("normalizeToolVersion(%q, %q): %v", test.name, test.version, err)
+			}
+			continue
+		}
+		if test.wantErr {
+			t.Errorf("normalizeToolVersion(%q, %q) = %q, <nil>; want error", test.name, test.version, got)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("normalizeToolVersion(%q, %q) = %q; want %q", test.name, test.version, got, test.want)
+		}
+	}
+}
+
+func TestParseToolVersions(t *testing.T) {
+	const data = "# Versions for asdf\n" +
+		"nodejs 14.17.0\n" +
+		"\n" +
+		"golang 1.16.3 1.15.11 # fallback\n" +
+		"python system\n" +
+		"direnv\n" +
+		"nodejs 12.19.0\n"
+	got := parseToolVersions(".tool-versions", []byte(data))
+	want := []versionSource{
+		{name: "node", version: "14.17.0", file: ".tool-versions", line: 2},
+		{name: "go", version: "1.16.3", file: ".tool-versions", line: 4},
+		{name: "python", version: "system", file: ".tool-versions", line: 5},
+		{name: "direnv", version: "", file: ".tool-versions", line: 6},
+	}
+	if diff := cmp.Diff(want, got, cmp.AllowUnexported(versionSource{})); diff != "" {
+		t.Errorf("parseToolVersions(...) (-want +got):\n%s", diff)
+	}
+}
+
+func TestVersionFilesLookup(t *testing.T) {
+	tests := []struct {
+		name         string
+		files        map[string]string
+		toolVersions string
+		lookup       string
+		want         string
+		wantSource   string
+		wantErr      bool
+	}{
+		{
+			name:       "Nvmrc",
+			files:      map[string]string{".nvmrc": "v14.17.0\n"},
+			lookup:     "node",
+			want:       "node:14.17.0",
+			wantSource: ".nvmrc:1",
+		},
+		{
+			name:       "NodeVersion",
+			files:      map[string]string{".node-version": "\n14.17.0\n"},
+			lookup:     "node",
+			want:       "node:14.17.0",
+			wantSource: ".node-version:2",
+		},
+		{
+			name: "ToolVersionsTakesPrecedence",
+			files: map[string]string{
+				".tool-versions": "nodejs 12.19.0\n",
+				".nvmrc":         "14.17.0\n",
+			},
+			lookup:     "node",
+			want:       "node:12.19.0",
+			wantSource: ".tool-versions:1",
+		},
+		{
+			name: "IgnoresOtherToolVersions",
+			files: map[string]string{
+				".tool-versions": "python system\n" +
+					"direnv\n" +
+					"nodejs 12.19.0\n",
+			},
+			lookup:     "node",
+			want:       "node:12.19.0",
+			wantSource: ".tool-versions:3",
+		},
+		{
+			name:    "UnreleasedToolVersion",
+			files:   map[string]string{".tool-versions": "python system\n"},
+			lookup:  "python",
+			wantErr: true,
+		},
+		{
+			name:    "ToolVersionsMissingVersion",
+			files:   map[string]string{".tool-versions": "nodejs\n"},
+			lookup:  "node",
+			wantErr: true,
+		},
+		{
+			name: "FallsBackFromToolVersions",
+			files: map[string]string{
+				".tool-versions": "python 3.9.1\n",
+				".ruby-version":  "ruby-2.7.2\n",
+			},
+			lookup:     "ruby",
+			want:       "ruby:2.7.2",
+			wantSource: ".ruby-version:1",
+		},
+		{
+			name: "NamedToolVersions",
+			files: map[string]string{
+				".tool-versions":        "nodejs 12.19.0\n",
+				"config/.tool-versions": "nodejs 14.17.0\n",
+			},
+			toolVersions: "config/.tool-versions",
+			lookup:       "node",
+			want:         "node:14.17.0",
+			wantSource:   "config/.tool-versions:1",
+		},
+		{
+			name:         "MissingNamedToolVersions",
+			toolVersions: ".tool-versions",
+			lookup:       "node",
+			wantErr:      true,
+		},
+		{
+			name:       "JavaVersion",
+			files:      map[string]string{".java-version": "11.0.8+10\n"},
+			lookup:     "java",
+			want:       "java:11.0.8+10",
+			wantSource: ".java-version:1",
+		},
+		{
+			name:       "PythonVersion",
+			files:      map[string]string{".python-version": "3.8.6\n3.9.1\n"},
+			lookup:     "python",
+			want:       "python:3.8.6",
+			wantSource: ".python-version:1",
+		},
+		{
+			name:       "CPythonVersion",
+			files:      map[string]string{".python-version": "3.9.1\n"},
+			lookup:     "cpython",
+			want:       "cpython:3.9.1",
+			wantSource: ".python-version:1",
+		},
+		{
+			name:       "GoMod",
+			files:      map[string]string{"go.mod": "module example.com/foo\n\ngo 1.16\n"},
+			lookup:     "go",
+			want:       "go:1.16.x",
+			wantSource: "go.mod:3",
+		},
+		{
+			name:       "GoModPatch",
+			files:      map[string]string{"go.mod": "module example.com/foo\n\ngo 1.21.3\n"},
+			lookup:     "go",
+			want:       "go:1.21.3",
+			wantSource: "go.mod:3",
+		},
+		{
+			name:    "GoModNoDirective",
+			files:   map[string]string{"go.mod": "module example.com/foo\n"},
+			lookup:  "go",
+			wantErr: true,
+		},
+		{
+			name:       "PackageManagerYarn",
+			files:      map[string]string{"package.json": "{\n  \"name\": \"foo\",\n  \"packageManager\": \"yarn@3.6.1\"\n}\n"},
+			lookup:     "yarn",
+			want:       "yarn:3.6.1",
+			wantSource: "package.json:3",
+		},
+		{
+			name:       "PackageManagerPnpmHash",
+			files:      map[string]string{"package.json": `{"packageManager": "pnpm@8.6.0+sha256.0123abcd"}`},
+			lookup:     "pnpm",
+			want:       "pnpm:8.6.0",
+			wantSource: "package.json:1",
+		},
+		{
+			name:    "PackageManagerOther",
+			files:   map[string]string{"package.json": `{"packageManager": "pnpm@8.6.0"}`},
+			lookup:  "yarn",
+			wantErr: true,
+		},
+		{
+			name: "ToolVersionsBeforePackageManager",
+			files: map[string]string{
+				".tool-versions": "yarn 1.22.10\n",
+				"package.json":   `{"packageManager": "yarn@3.6.1"}`,
+			},
+			lookup:     "yarn",
+			want:       "yarn:1.22.10",
+			wantSource: ".tool-versions:1",
+		},
+		{
+			name:    "NotFound",
+			files:   map[string]string{".nvmrc": "14.17.0\n"},
+			lookup:  "ruby",
+			wantErr: true,
+		},
+		{
+			name:    "Empty",
+			files:   map[string]string{".nvmrc": "\n"},
+			lookup:  "node",
+			wantErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, test.files)
+			vf := &versionFiles{dir: dir, toolVersions: test.toolVersions}
+			got, err := vf.lookup(test.lookup)
+			if err != nil {
+				t.Log(err)
+				if !test.wantErr {
+					t.Fail()
+				}
+				return
+			}
+			if test.wantErr {
+				t.Fatalf("lookup(%q) = %v, <nil>; want error", test.lookup, got.spec())
+			}
+			if got.spec() != BuildpackSpec(test.want) || got.String() != test.wantSource {
+				t.Errorf("lookup(%q) = %s from %v; want %s from %s", test.lookup, got.spec(), got, test.want, test.wantSource)
+			}
+		})
+	}
+}
+
+func TestLoadPackageToolVersions(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		PackageConfigFilename: "tool_versions: .tool-versions\n" +
+			"dependencies:\n" +
+			"  build:\n" +
+			"    - python:3.8.6\n" +
+			"build_targets:\n" +
+			"  - name: default\n" +
+			"    dependencies:\n" +
+			"      build:\n" +
+			"        - ruby:auto\n" +
+			"exec:\n" +
+			"  dependencies:\n" +
+			"    runtime:\n" +
+			"      - node:auto\n",
+		".tool-versions": "nodejs 14.17.0\n" +
+			"python 3.9.1\n" +
+			"golang system\n" +
+			"direnv 2.28.0\n" +
+			"shellcheck\n",
+		".ruby-version": "2.7.2\n",
+	})
+	pkg, err := LoadPackage(filepath.Join(dir, PackageConfigFilename), &LoadOptions{
+		Buildpacks: []string{"go", "node", "python", "ruby"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]BuildpackSpec{
+		"node":   "node:14.17.0",
+		"python": "python:3.8.6",
+		"ruby":   "ruby:2.7.2",
+	}
+	if diff := cmp.Diff(want, pkg.Targets[DefaultTarget].Buildpacks); diff != "" {
+		t.Errorf("default target buildpacks (-want +got):\n%s", diff)
+	}
+	wantExec := map[string]BuildpackSpec{
+		"node": "node:14.17.0",
+	}
+	if diff := cmp.Diff(wantExec, pkg.ExecEnvironments[DefaultExecEnvironment].Buildpacks); diff != "" {
+		t.Errorf("exec buildpacks (-want +got):\n%s", diff)
+	}
+}
+
+func TestValidateToolVersions(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		PackageConfigFilename: "tool_versions: .tool-versions\n" +
+			"build_targets:\n" +
+			"  - name: default\n" +
+			"    dependencies:\n" +
+			"      build:\n" +
+			"        - node:auto\n" +
+			"        - go:auto\n",
+		".tool-versions": "nodejs 14.17.0\n" +
+			"shellcheck 0.7.1\n" +
+			"python system\n",
+		".nvmrc": "12.19.0\n",
+	})
+	path := filepath.Join(dir, PackageConfigFilename)
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	diags := validate(path, data, &ValidateOptions{
+		Buildpacks: []string{"go", "node", "python"},
+	})
+	var got []string
+	for _, d := range diags {
+		t.Log(d)
+		got = append(got, strings.TrimPrefix(d.String(), path+":"))
+	}
+	want := []string{
+		"1:16: info: buildpack node version 14.17.0 read from .tool-versions:1",
+		"1:16: warning: tool_versions: .tool-versions:2: shellcheck is not a buildpack; skipping",
+		"1:16: warning: tool_versions: .tool-versions:3: python system is not a released version; skipping",
+		"6:11: info: buildpack node version 14.17.0 read from .tool-versions:1",
+		"7:11: error: go:auto: no version found in .tool-versions, go.mod",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("diagnostics (-want +got):\n%s", diff)
+	}
+}
+
+// writeFiles writes the given files, keyed by slash-separated path, to dir.
+func writeFiles(tb testing.TB, dir string, files map[string]string) {
+	tb.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o777); err != nil {
+			tb.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0o666); err != nil {
+			tb.Fatal(err)
+		}
+	}
+}
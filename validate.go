@@ -0,0 +1,831 @@
+// Copyright 2020 YourBase Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package yb
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	tmplparse "text/template/parse"
+
+	"github.com/google/shlex"
+	yaml3 "gopkg.in/yaml.v3"
+)
+
+// Severity indicates how serious a Diagnostic is.
+type Severity int
+
+// Diagnostic severities.
+const (
+	// SeverityError indicates a problem that prevents the package from building.
+	SeverityError Severity = iota
+	// SeverityWarning indicates a construct that works, but should be changed.
+	SeverityWarning
+	// SeverityInfo describes how the configuration is interpreted, like
+	// where a buildpack version was read from.
+	SeverityInfo
+)
+
+// String returns "error", "warning", or "info".
+func (sev Severity) String() string {
+	switch sev {
+	case SeverityError:
+		return "error"
+	case SeverityWarning:
+		return "warning"
+	case SeverityInfo:
+		return "info"
+	default:
+		return fmt.Sprintf("Severity(%d)", int(sev))
+	}
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (sev Severity) MarshalText() ([]byte, error) {
+	return []byte(sev.String()), nil
+}
+
+// A Diagnostic is a problem found in a package configuration file or a note
+// about how it is interpreted.
+type Diagnostic struct {
+	Severity Severity `json:"severity"`
+	Filename string   `json:"file"`
+	// Line and Column are the 1-based position of the problem in the file.
+	// They are zero if the problem applies to the file as a whole.
+	Line    int    `json:"line,omitempty"`
+	Column  int    `json:"column,omitempty"`
+	Message string `json:"message"`
+}
+
+// String formats the diagnostic as "file:line:col: severity: message".
+func (d *Diagnostic) String() string {
+	pos := d.Filename
+	if d.Line > 0 {
+		pos += ":" + strconv.Itoa(d.Line)
+		if d.Column > 0 {
+			pos += ":" + strconv.Itoa(d.Column)
+		}
+	}
+	return fmt.Sprintf("%s: %v: %s", pos, d.Severity, d.Message)
+}
+
+// ValidateOptions is the set of optional parameters to ValidatePackage.
+type ValidateOptions struct {
+	// Buildpacks is the set of buildpack names that may be used in the
+	// configuration. If nil, buildpack names are not checked.
+	Buildpacks []string
+}
+
+// ValidatePackage checks the package configuration file at configPath for
+// problems that would prevent it from building and for deprecated constructs.
+// The returned diagnostics are sorted by position. ValidatePackage only returns
+// an error if the file could not be read.
+func ValidatePackage(configPath string, opts *ValidateOptions) ([]*Diagnostic, error) {
+	data, err := ioutil.ReadFile(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("validate %s: %w", configPath, err)
+	}
+	return validate(configPath, data, opts), nil
+}
+
+func validate(filename string, data []byte, opts *ValidateOptions) []*Diagnostic {
+	dir, err := filepath.Abs(filepath.Dir(filename))
+	if err != nil {
+		dir = filepath.Dir(filename)
+	}
+	v := &validator{
+		filename: filename,
+		versions: &versionFiles{dir: dir},
+	}
+	if opts != nil && opts.Buildpacks != nil {
+		v.buildpacks = make(map[string]bool, len(opts.Buildpacks))
+		for _, name := range opts.Buildpacks {
+			v.buildpacks[name] = true
+		}
+	}
+	doc := new(yaml3.Node)
+	if err := yaml3.Unmarshal(data, doc); err != nil {
+		v.syntaxError(err)
+		return v.diags
+	}
+	if doc.Kind == yaml3.DocumentNode && len(doc.Content) > 0 {
+		root := doc.Content[0]
+		v.checkShape(root, reflect.TypeOf(buildManifest{}))
+		if !v.hasErrors() {
+			v.checkManifest(root)
+		}
+	}
+	if !v.hasErrors() {
+		// Catch anything that the checks above don't know about, like cycles.
+		if err := checkParse(filename, data, opts); err != nil {
+			v.diags = append(v.diags, &Diagnostic{
+				Severity: SeverityError,
+				Filename: filename,
+				Message:  err.Error(),
+			})
+		}
+	}
+	sort.SliceStable(v.diags, func(i, j int) bool {
+		di, dj := v.diags[i], v.diags[j]
+		if di.Line != dj.Line {
+			return di.Line < dj.Line
+		}
+		return di.Column < dj.Column
+	})
+	return v.diags
+}
+
+// checkParse runs the regular parser over the file.
+func checkParse(filename string, data []byte, opts *ValidateOptions) error {
+	dir, err := filepath.Abs(filepath.Dir(filename))
+	if err != nil {
+		return err
+	}
+	var loadOpts *LoadOptions
+	if opts != nil {
+		loadOpts = &LoadOptions{Buildpacks: opts.Buildpacks}
+	}
+	pkg, err := parse(dir, data, loadOpts)
+	if err != nil {
+		return err
+	}
+	targets := make([]*Target, 0, len(pkg.Targets))
+	for _, target := range pkg.Targets {
+		targets = append(targets, target)
+	}
+	_, err = buildOrder(targets)
+	return err
+}
+
+type validator struct {
+	filename   string
+	buildpacks map[string]bool
+	versions   *versionFiles
+	diags      []*Diagnostic
+}
+
+func (v *validator) errorf(node *yaml3.Node, format string, args ...interface{}) {
+	v.report(SeverityError, node, format, args...)
+}
+
+func (v *validator) warnf(node *yaml3.Node, format string, args ...interface{}) {
+	v.report(SeverityWarning, node, format, args...)
+}
+
+func (v *validator) infof(node *yaml3.Node, format string, args ...interface{}) {
+	v.report(SeverityInfo, node, format, args...)
+}
+
+func (v *validator) report(sev Severity, node *yaml3.Node, format string, args ...interface{}) {
+	d := &Diagnostic{
+		Severity: sev,
+		Filename: v.filename,
+		Message:  fmt.Sprintf(format, args...),
+	}
+	if node != nil {
+		d.Line = node.Line
+		d.Column = node.Column
+	}
+	v.diags = append(v.diags, d)
+}
+
+func (v *validator) hasErrors() bool {
+	for _, d := range v.diags {
+		if d.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+var yamlErrorLinePattern = regexp.MustCompile(`^yaml: line ([0-9]+): (.*)$`)
+
+func (v *validator) syntaxError(err error) {
+	d := &Diagnostic{
+		Severity: SeverityError,
+		Filename: v.filename,
+		Message:  err.Error(),
+	}
+	if m := yamlErrorLinePattern.FindStringSubmatch(err.Error()); m != nil {
+		d.Line, _ = strconv.Atoi(m[1])
+		d.Message = m[2]
+	}
+	v.diags = append(v.diags, d)
+}
+
+var envObjectType = reflect.TypeOf(envObject(nil))
+
+// checkShape verifies that the node can be decoded into a value of type t,
+// reporting unknown fields and mismatched types.
+func (v *validator) checkShape(node *yaml3.Node, t reflect.Type) {
+	node = resolveAlias(node)
+	if node.Tag == "!!null" {
+		return
+	}
+	if t == envObjectType {
+		v.checkEnvShape(node)
+		return
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		v.checkShape(node, t.Elem())
+	case reflect.Struct:
+		if node.Kind != yaml3.MappingNode {
+			v.errorf(node, "expected a mapping, found %s", describeNode(node))
+			return
+		}
+		fields := yamlFields(t)
+		seen := make(map[string]bool)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			k, val := node.Content[i], node.Content[i+1]
+			f, ok := fields[k.Value]
+			if !ok {
+				v.errorf(k, "unknown field %q", k.Value)
+				continue
+			}
+			if seen[k.Value] {
+				v.errorf(k, "field %q already set", k.Value)
+				continue
+			}
+			seen[k.Value] = true
+			v.checkShape(val, f.Type)
+		}
+	case reflect.Slice:
+		if node.Kind != yaml3.SequenceNode {
+			v.errorf(node, "expected a list, found %s", describeNode(node))
+			return
+		}
+		for _, elem := range node.Content {
+			v.checkShape(elem, t.Elem())
+		}
+	case reflect.Map:
+		if node.Kind != yaml3.MappingNode {
+			v.errorf(node, "expected a mapping, found %s", describeNode(node))
+			return
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			v.checkShape(node.Content[i+1], t.Elem())
+		}
+	case reflect.String:
+		if node.Kind != yaml3.ScalarNode {
+			v.errorf(node, "expected a string, found %s", describeNode(node))
+		}
+	case reflect.Int:
+		if node.Kind != yaml3.ScalarNode {
+			v.errorf(node, "expected an integer, found %s", describeNode(node))
+			return
+		}
+		if _, err := strconv.Atoi(node.Value); err != nil {
+			v.errorf(node, "expected an integer, found %q", node.Value)
+		}
+	case reflect.Bool:
+		if node.Kind != yaml3.ScalarNode {
+			v.errorf(node, "expected true or false, found %s", describeNode(node))
+			return
+		}
+		if _, ok := parseYAMLBool(node.Value); !ok {
+			v.errorf(node, "expected true or false, found %q", node.Value)
+		}
+	}
+}
+
+func (v *validator) checkEnvShape(node *yaml3.Node) {
+	switch node.Kind {
+	case yaml3.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if val := resolveAlias(node.Content[i+1]); val.Kind != yaml3.ScalarNode {
+				v.errorf(val, "environment variable %s: expected a string, found %s", node.Content[i].Value, describeNode(val))
+			}
+		}
+	case yaml3.SequenceNode:
+		v.warnf(node, "environment as a list of KEY=VALUE strings is deprecated; use a mapping instead (yb migrate can rewrite this)")
+		for _, elem := range node.Content {
+			elem = resolveAlias(elem)
+			if elem.Kind != yaml3.ScalarNode {
+				v.errorf(elem, "expected a KEY=VALUE string, found %s", describeNode(elem))
+				continue
+			}
+			if _, _, err := parseVar(elem.Value); err != nil {
+				v.errorf(elem, "%v: must be in the form KEY=VALUE", err)
+			}
+		}
+	default:
+		v.errorf(node, "expected a mapping, found %s", describeNode(node))
+	}
+}
+
+// checkManifest runs semantic checks on a document that is known to have the
+// correct shape.
+func (v *validator) checkManifest(root *yaml3.Node) {
+	v.checkBuildpackDefinitions(mappingValue(root, "buildpacks"))
+	v.checkToolVersions(mappingValue(root, "tool_versions"))
+	deps := mappingValue(root, "dependencies")
+	v.checkBuildpackList(mappingValue(deps, "build"))
+	v.checkBuildpackList(mappingValue(deps, "runtime"))
+
+	targets := sequenceItems(mappingValue(root, "build_targets"))
+	if key := mappingKey(root, "build"); key != nil {
+		v.warnf(key, "build is deprecated; use build_targets with a target named %q instead (yb migrate can rewrite this)", DefaultTarget)
+	}
+	targetNames := make(map[string]bool)
+	for _, tgt := range targets {
+		nameNode := mappingValue(tgt, "name")
+		if nameNode == nil || nameNode.Value == "" {
+			v.errorf(tgt, "target has no name")
+			continue
+		}
+		if targetNames[nameNode.Value] {
+			v.errorf(nameNode, "multiple targets with name %q", nameNode.Value)
+			continue
+		}
+		targetNames[nameNode.Value] = true
+	}
+	if build := mappingValue(root, "build"); build != nil && build.Kind == yaml3.MappingNode {
+		if targetNames[DefaultTarget] {
+			v.errorf(build, "build conflicts with build target %q", DefaultTarget)
+		}
+		targetNames[DefaultTarget] = true
+		targets = append(targets, build)
+	}
+
+	for _, tgt := range targets {
+		containers := v.checkContainerMap(mappingValue(mappingValue(tgt, "dependencies"), "containers"))
+		v.checkBuildpackList(mappingValue(mappingValue(tgt, "dependencies"), "build"))
+		v.checkContainer(mappingValue(tgt, "container"), containers)
+		v.checkCommands(mappingValue(tgt, "commands"))
+		v.checkEnv(mappingValue(tgt, "environment"), containers)
+		if root := mappingValue(tgt, "root"); root != nil && strings.HasPrefix(root.Value, "/") {
+			v.errorf(root, "root %s is absolute; must be relative to the package directory", root.Value)
+		}
+		for _, dep := range sequenceItems(mappingValue(tgt, "build_after")) {
+			if !targetNames[dep.Value] {
+				v.errorf(dep, "build_after: unknown target %q", dep.Value)
+			}
+		}
+	}
+
+	if exec := mappingValue(root, "exec"); exec != nil {
+		deps := mappingValue(exec, "dependencies")
+		v.checkBuildpackList(mappingValue(deps, "runtime"))
+		containers := v.checkContainerMap(mappingValue(deps, "containers"))
+		v.checkContainer(mappingValue(exec, "container"), containers)
+		v.checkCommands(mappingValue(exec, "commands"))
+		envs := mappingValue(exec, "environment")
+		for i := 0; envs != nil && i+1 < len(envs.Content); i += 2 {
+			v.checkEnv(envs.Content[i+1], containers)
+		}
+	}
+
+	for _, build := range sequenceItems(mappingValue(mappingValue(root, "ci"), "builds")) {
+		tgt := mappingValue(build, "build_target")
+		if tgt != nil && tgt.Value != "" && !targetNames[tgt.Value] {
+			v.errorf(tgt, "build_target: unknown target %q", tgt.Value)
+		}
+	}
+}
+
+// checkBuildpackDefinitions checks the buildpacks declared in the
+// configuration and permits their names in buildpack specifiers.
+func (v *validator) checkBuildpackDefinitions(defs *yaml3.Node) {
+	for i := 0; defs != nil && defs.Kind == yaml3.MappingNode && i+1 < len(defs.Content); i += 2 {
+		key, value := defs.Content[i], resolveAlias(defs.Content[i+1])
+		if err := validateBuildpackName(key.Value); err != nil {
+			v.errorf(key, "%v", err)
+			continue
+		}
+		if v.buildpacks != nil {
+			v.buildpacks[key.Value] = true
+		}
+		def := new(BuildpackDefinition)
+		if err := value.Decode(def); err != nil {
+			v.errorf(value, "buildpack %s: %v", key.Value, err)
+			continue
+		}
+		if err := def.Validate(); err != nil {
+			v.errorf(value, "buildpack %s: %v", key.Value, err)
+		}
+	}
+}
+
+// checkToolVersions checks the file named by tool_versions and reports the
+// version of each buildpack it lists. Entries that LoadPackage skips are
+// reported as warnings.
+func (v *validator) checkToolVersions(node *yaml3.Node) {
+	if node == nil || node.Value == "" {
+		return
+	}
+	if filepath.IsAbs(node.Value) {
+		v.errorf(node, "tool_versions: %s is absolute; must be relative to the package directory", node.Value)
+		return
+	}
+	v.versions.toolVersions = node.Value
+	entries, err := v.versions.toolVersionsEntries()
+	if err != nil {
+		v.errorf(node, "tool_versions: %v", err)
+		return
+	}
+	for _, src := range entries {
+		if v.buildpacks != nil && !v.buildpacks[src.name] {
+			v.warnf(node, "tool_versions: %v: %s is not a buildpack; skipping", src, src.name)
+			continue
+		}
+		if isUnreleasedToolVersion(src.version) {
+			v.warnf(node, "tool_versions: %v: %s %s is not a released version; skipping", src, src.name, src.version)
+			continue
+		}
+		src, err := src.normalize()
+		if err != nil {
+			v.errorf(node, "tool_versions: %v", err)
+			continue
+		}
+		v.infof(node, "buildpack %s version %s read from %v", src.name, src.version, src)
+	}
+}
+
+func (v *validator) checkBuildpackList(list *yaml3.Node) {
+	seen := make(map[string]bool)
+	for _, item := range sequenceItems(list) {
+		name, ok := v.checkBuildpackSpec(item)
+		if !ok {
+			continue
+		}
+		if seen[name] {
+			v.warnf(item, "buildpack %s listed more than once; only the last version is used", name)
+		}
+		seen[name] = true
+	}
+}
+
+// buildpackVersionExpr is a regular expression that matches the versions
+// accepted by buildpacks, including ranges like "^14" or ">=1.15" and
+// wildcards like "1.16.x". It is shared with the JSON Schema.
+const buildpackVersionExpr = `(\^|~|[<>]=?|=)?[A-Za-z0-9*][A-Za-z0-9._+*-]*`
+
+var buildpackVersionPattern = regexp.MustCompile(`^` + buildpackVersionExpr + `$`)
+
+func (v *validator) checkBuildpackSpec(node *yaml3.Node) (name string, ok bool) {
+	spec, err := ParseBuildpackSpec(node.Value)
+	if err != nil {
+		v.errorf(node, "%v", err)
+		return "", false
+	}
+	name = spec.Name()
+	if name == "" {
+		v.errorf(node, "buildpack %q has no name", node.Value)
+		return "", false
+	}
+	if v.buildpacks != nil && !v.buildpacks[name] {
+		v.errorf(node, "unknown buildpack %q", name)
+		return "", false
+	}
+	version := spec.Version()
+	if version == AutoVersion {
+		src, err := v.versions.lookup(name)
+		if err != nil {
+			v.errorf(node, "%v", err)
+			return "", false
+		}
+		v.infof(node, "buildpack %s version %s read from %v", name, src.version, src)
+		return name, true
+	}
+	if !buildpackVersionPattern.MatchString(version) {
+		v.errorf(node, "buildpack %s: malformed version %q", name, version)
+		return "", false
+	}
+	return name, true
+}
+
+// checkContainerMap checks each of the container definitions in a
+// dependencies.containers mapping and returns the set of container names.
+func (v *validator) checkContainerMap(m *yaml3.Node) map[string]bool {
+	names := make(map[string]bool)
+	for i := 0; m != nil && i+1 < len(m.Content); i += 2 {
+		names[m.Content[i].Value] = true
+	}
+	for i := 0; m != nil && i+1 < len(m.Content); i += 2 {
+		v.checkContainer(m.Content[i+1], names)
+	}
+	return names
+}
+
+func (v *validator) checkContainer(def *yaml3.Node, containers map[string]bool) {
+	if def == nil || def.Kind != yaml3.MappingNode {
+		return
+	}
+	for _, mount := range sequenceItems(mappingValue(def, "mounts")) {
+		parts := strings.Split(mount.Value, ":")
+		switch {
+		case len(parts) != 2:
+			v.errorf(mount, "mount %q must be in the form SOURCE:TARGET", mount.Value)
+		case parts[0] == "" || parts[1] == "":
+			v.errorf(mount, "mount %q: source and target must not be empty", mount.Value)
+		case !strings.HasPrefix(parts[1], "/"):
+			v.errorf(mount, "mount %q: target %s must be an absolute path", mount.Value, parts[1])
+		}
+	}
+	exposed := make(map[int]bool)
+	for _, port := range sequenceItems(mappingValue(def, "ports")) {
+		containerPort, err := parseContainerPort(port.Value)
+		if err != nil {
+			v.errorf(port, "%v", err)
+			continue
+		}
+		exposed[containerPort] = true
+	}
+	check := mappingValue(def, "port_check")
+	if port := mappingValue(check, "port"); port != nil {
+		n, _ := strconv.Atoi(port.Value)
+		if !exposed[n] {
+			v.errorf(port, "port_check: port %d is not listed in ports", n)
+		}
+	} else if timeout := mappingValue(check, "timeout"); timeout != nil {
+		v.warnf(timeout, "port_check: timeout has no effect without a port")
+	}
+	if timeout := mappingValue(check, "timeout"); timeout != nil {
+		if n, _ := strconv.Atoi(timeout.Value); n < 0 {
+			v.errorf(timeout, "port_check: timeout must not be negative")
+		}
+	}
+	v.checkEnv(mappingValue(def, "environment"), containers)
+}
+
+// parseContainerPort parses a Docker port specification like "8080",
+// "80:8080", "127.0.0.1:80:8080", or "8080/tcp" and returns the container port.
+func parseContainerPort(spec string) (int, error) {
+	s := spec
+	if i := strings.LastIndexByte(s, '/'); i != -1 {
+		if proto := s[i+1:]; proto != "tcp" && proto != "udp" && proto != "sctp" {
+			return 0, fmt.Errorf("port %q: unknown protocol %q", spec, proto)
+		}
+		s = s[:i]
+	}
+	if i := strings.LastIndexByte(s, ':'); i != -1 {
+		s = s[i+1:]
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n <= 0 || n > 65535 {
+		return 0, fmt.Errorf("port %q: %q is not a valid port number", spec, s)
+	}
+	return n, nil
+}
+
+func (v *validator) checkCommands(list *yaml3.Node) {
+	for _, cmd := range sequenceItems(list) {
+		if dir, ok := parseChdirCommand(cmd.Value); ok {
+			switch {
+			case dir == "":
+				v.errorf(cmd, "cd: empty directory")
+			case strings.HasPrefix(dir, "/"):
+				v.errorf(cmd, "cd %s: absolute paths not supported", dir)
+			}
+			continue
+		}
+		argv, err := shlex.Split(cmd.Value)
+		if err != nil {
+			v.errorf(cmd, "command %q: %v", cmd.Value, err)
+			continue
+		}
+		if len(argv) == 0 {
+			v.errorf(cmd, "empty command")
+		}
+	}
+}
+
+// parseChdirCommand mirrors the special handling of "cd" commands in builds.
+func parseChdirCommand(cmdString string) (dir string, ok bool) {
+	const prefix = "cd "
+	if !strings.HasPrefix(cmdString, prefix) {
+		return "", false
+	}
+	return strings.TrimSpace(cmdString[len(prefix):]), true
+}
+
+func (v *validator) checkEnv(env *yaml3.Node, containers map[string]bool) {
+	if env == nil {
+		return
+	}
+	env = resolveAlias(env)
+	switch env.Kind {
+	case yaml3.MappingNode:
+		for i := 0; i+1 < len(env.Content); i += 2 {
+			val := resolveAlias(env.Content[i+1])
+			v.checkEnvVar(val, env.Content[i].Value, val.Value, containers)
+		}
+	case yaml3.SequenceNode:
+		for _, item := range env.Content {
+			item = resolveAlias(item)
+			if k, val, err := parseVar(item.Value); err == nil {
+				v.checkEnvVar(item, k, val, containers)
+			}
+		}
+	}
+}
+
+func (v *validator) checkEnvVar(node *yaml3.Node, name, value string, containers map[string]bool) {
+	if name == "" {
+		v.errorf(node, "environment variable has an empty name")
+	}
+	tmpl, err := template.New(name).Parse(value)
+	if err != nil {
+		v.errorf(node, "environment variable %s: %v", name, templateErrorMessage(err))
+		return
+	}
+	for _, ref := range templateContainerRefs(tmpl.Tree.Root) {
+		switch {
+		case ref.field != "":
+			v.errorf(node, "environment variable %s: unknown field %s; only .Containers.IP is available", name, ref.field)
+		case !containers[ref.container]:
+			v.errorf(node, "environment variable %s: unknown container %q", name, ref.container)
+		}
+	}
+}
+
+// templateErrorMessage strips the "template: NAME:LINE:" prefix from a template
+// parse error, since the position is reported separately.
+func templateErrorMessage(err error) string {
+	msg := err.Error()
+	msg = strings.TrimPrefix(msg, "template: ")
+	if i := strings.Index(msg, ": "); i != -1 {
+		msg = msg[i+2:]
+	}
+	return msg
+}
+
+type templateRef struct {
+	// container is the argument to .Containers.IP.
+	container string
+	// field is set if the template refers to an unknown field.
+	field string
+}
+
+// templateContainerRefs returns the references to .Containers.IP with a
+// literal container name, along with references to unknown fields.
+func templateContainerRefs(node tmplparse.Node) []templateRef {
+	var refs []templateRef
+	var walk func(tmplparse.Node)
+	walk = func(node tmplparse.Node) {
+		switch node := node.(type) {
+		case *tmplparse.ListNode:
+			if node == nil {
+				return
+			}
+			for _, n := range node.Nodes {
+				walk(n)
+			}
+		case *tmplparse.ActionNode:
+			walk(node.Pipe)
+		case *tmplparse.PipeNode:
+			if node == nil {
+				return
+			}
+			for _, cmd := range node.Cmds {
+				walk(cmd)
+			}
+		case *tmplparse.CommandNode:
+			if len(node.Args) == 2 {
+				field, ok1 := node.Args[0].(*tmplparse.FieldNode)
+				arg, ok2 := node.Args[1].(*tmplparse.StringNode)
+				if ok1 && ok2 && len(field.Ident) == 2 && field.Ident[0] == "Containers" && field.Ident[1] == "IP" {
+					refs = append(refs, templateRef{container: arg.Text})
+					return
+				}
+			}
+			for _, arg := range node.Args {
+				walk(arg)
+			}
+		case *tmplparse.FieldNode:
+			if len(node.Ident) == 0 || node.Ident[0] != "Containers" || (len(node.Ident) > 1 && node.Ident[1] != "IP") {
+				refs = append(refs, templateRef{field: node.String()})
+			}
+		case *tmplparse.IfNode:
+			walk(node.Pipe)
+			walk(node.List)
+			walk(node.ElseList)
+		case *tmplparse.RangeNode:
+			walk(node.Pipe)
+			walk(node.List)
+			walk(node.ElseList)
+		case *tmplparse.WithNode:
+			walk(node.Pipe)
+			walk(node.List)
+			walk(node.ElseList)
+		}
+	}
+	walk(node)
+	return refs
+}
+
+// yamlFields returns the struct fields of t keyed by their YAML name.
+func yamlFields(t reflect.Type) map[string]reflect.StructField {
+	fields := make(map[string]reflect.StructField)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := strings.Split(f.Tag.Get("yaml"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+		fields[name] = f
+	}
+	return fields
+}
+
+func resolveAlias(node *yaml3.Node) *yaml3.Node {
+	for node != nil && node.Kind == yaml3.AliasNode {
+		node = node.Alias
+	}
+	return node
+}
+
+// mappingKey returns the key node for the given key in a mapping node or nil
+// if node is not a mapping or does not contain the key.
+func mappingKey(node *yaml3.Node, key string) *yaml3.Node {
+	node = resolveAlias(node)
+	if node == nil || node.Kind != yaml3.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i]
+		}
+	}
+	return nil
+}
+
+// mappingValue returns the value node for the given key in a mapping node or
+// nil if node is not a mapping, does not contain the key, or the value is null.
+func mappingValue(node *yaml3.Node, key string) *yaml3.Node {
+	node = resolveAlias(node)
+	if node == nil || node.Kind != yaml3.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			val := resolveAlias(node.Content[i+1])
+			if val.Tag == "!!null" {
+				return nil
+			}
+			return val
+		}
+	}
+	return nil
+}
+
+// sequenceItems returns the elements of a sequence node or nil if node is not
+// a sequence.
+func sequenceItems(node *yaml3.Node) []*yaml3.Node {
+	node = resolveAlias(node)
+	if node == nil || node.Kind != yaml3.SequenceNode {
+		return nil
+	}
+	items := make([]*yaml3.Node, 0, len(node.Content))
+	for _, item := range node.Content {
+		items = append(items, resolveAlias(item))
+	}
+	return items
+}
+
+func describeNode(node *yaml3.Node) string {
+	switch node.Kind {
+	case yaml3.MappingNode:
+		return "a mapping"
+	case yaml3.SequenceNode:
+		return "a list"
+	default:
+		return fmt.Sprintf("%q", node.Value)
+	}
+}
+
+// parseYAMLBool parses the boolean forms accepted by gopkg.in/yaml.v2.
+func parseYAMLBool(s string) (value bool, ok bool) {
+	switch strings.ToLower(s) {
+	case "true", "yes", "on", "y":
+		return true, true
+	case "false", "no", "off", "n":
+		return false, true
+	default:
+		return false, false
+	}
+}
@@ -0,0 +1,177 @@
+// Copyright 2020 YourBase Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+package yb
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestValidate(t *testing.T) {
+	type pos struct {
+		Severity Severity
+		Line     int
+		Column   int
+	}
+	// Source files are under testdata/Validate.
+	tests := []struct {
+		name string
+		want []pos
+	}{
+		{name: "OK"},
+		{
+			name: "UnknownBuildpack",
+			want: []pos{
+				{SeverityError, 4, 7},
+				{SeverityError, 5, 7},
+				{SeverityError, 6, 7},
+			},
+		},
+		{
+			name: "Buildpacks",
+			want: []pos{
+				{SeverityError, 8, 5},
+				{SeverityError, 10, 5},
+				{SeverityError, 12, 5},
+				{SeverityError, 13, 3},
+				{SeverityError, 22, 11},
+			},
+		},
+		{
+			name: "UnknownField",
+			want: []pos{
+				{SeverityError, 3, 5},
+				{SeverityError, 5, 16},
+			},
+		},
+		{
+			name: "Commands",
+			want: []pos{
+				{SeverityError, 3, 11},
+				{SeverityError, 5, 9},
+				{SeverityError, 7, 9},
+			},
+		},
+		{
+			name: "Env",
+			want: []pos{
+				{SeverityError, 4, 19},
+				{SeverityError, 5, 26},
+				{SeverityError, 6, 22},
+			},
+		},
+		{
+			name: "Containers",
+			want: []pos{
+				{SeverityError, 10, 19},
+				{SeverityError, 12, 15},
+				{SeverityError, 13, 15},
+			},
+		},
+		{
+			name: "Deprecated",
+			want: []pos{
+				{SeverityWarning, 1, 1},
+				{SeverityWarning, 3, 5},
+			},
+		},
+		{
+			name: "TargetRefs",
+			want: []pos{
+				{SeverityError, 4, 9},
+				{SeverityError, 5, 11},
+				{SeverityError, 6, 5},
+				{SeverityError, 11, 21},
+			},
+		},
+		{
+			name: "Cycle",
+			want: []pos{
+				{SeverityError, 0, 0},
+			},
+		},
+		{
+			name: "Syntax",
+			want: []pos{
+				{SeverityError, 1, 0},
+			},
+		},
+	}
+	opts := &ValidateOptions{
+		Buildpacks: []string{"go", "node", "python"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join("testdata", "Validate", test.name+".yml")
+			data, err := ioutil.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			diags := validate(path, data, opts)
+			var got []pos
+			for _, d := range diags {
+				t.Log(d)
+				if d.Filename != path {
+					t.Errorf("%v: Filename = %q; want %q", d, d.Filename, path)
+				}
+				if d.Message == "" {
+					t.Errorf("%v: empty message", d)
+				}
+				got = append(got, pos{d.Severity, d.Line, d.Column})
+			}
+			if diff := cmp.Diff(test.want, got, cmpopts.EquateEmpty()); diff != "" {
+				t.Errorf("diagnostic positions (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestParseContainerPort(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    int
+		wantErr bool
+	}{
+		{spec: "5432", want: 5432},
+		{spec: "8080:80", want: 80},
+		{spec: "127.0.0.1:8080:80", want: 80},
+		{spec: "53/udp", want: 53},
+		{spec: "53/foo", wantErr: true},
+		{spec: "http", wantErr: true},
+		{spec: "70000", wantErr: true},
+	}
+	for _, test := range tests {
+		got, err := parseContainerPort(test.spec)
+		if err != nil {
+			if !test.wantErr {
+				t.Errorf("parseContainerPort(%q) = _, %v; want %d, <nil>", test.spec, err, test.want)
+			}
+			continue
+		}
+		if test.wantErr {
+			t.Errorf("parseContainerPort(%q) = %d, <nil>; want error", test.spec, got)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("parseContainerPort(%q) = %d, <nil>; want %d, <nil>", test.spec, got, test.want)
+		}
+	}
+}